@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/threeds"
+)
+
+const acsSimulatorUsage = `usage: payment-gateway acs-simulator [flags]
+
+acs-simulator serves a 3DS server and ACS for local development, to point
+three_ds.server_url at. The second-to-last digit of the card number picks the
+outcome: 9 challenges the cardholder (one-time password ` + threeds.SimulatorOTP + `), 8 fails,
+6 is unavailable, 5 is attempted, and any other authenticates.
+
+`
+
+// acsSimulatorCommand implements the "acs-simulator" subcommand and returns
+// the exit code. It serves until interrupted.
+func acsSimulatorCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("acs-simulator", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, acsSimulatorUsage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":8100", "listen address")
+	publicURL := fs.String("public-url", "http://localhost:8100", "base URL of the challenge pages as reached by browsers")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           threeds.NewSimulator(threeds.WithPublicURL(*publicURL)),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(stdout, "ACS simulator listening on %s, challenges at %s\n", *addr, *publicURL)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
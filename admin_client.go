@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultAdminAddr is the gateway the CLI subcommands talk to by default.
+const defaultAdminAddr = "http://localhost:8090"
+
+// adminClient calls the admin API of a running gateway.
+type adminClient struct {
+	baseURL string
+	token   string
+}
+
+// newAdminClient returns a client for the gateway at addr, authenticated
+// with the bearer token in ADMIN_TOKEN.
+func newAdminClient(addr string) *adminClient {
+	return &adminClient{baseURL: strings.TrimRight(addr, "/"), token: os.Getenv("ADMIN_TOKEN")}
+}
+
+// do sends a request without a body to path and decodes the JSON response
+// into out.
+func (c *adminClient) do(method, path string, out any) error {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+)
+
+const auditUsage = `usage: payment-gateway audit verify [flags]
+
+verify checks the hash chain of the audit log. With --file it reads an audit
+log file and needs no running gateway; otherwise it asks the gateway at
+--addr to check the log it holds, reading the bearer token from ADMIN_TOKEN.
+`
+
+// auditCommand implements the "audit" subcommand and returns the exit code.
+func auditCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprint(stderr, auditUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", defaultAdminAddr, "base URL of the gateway")
+	file := fs.String("file", "", "audit log file to verify instead of asking the gateway")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var result api.AuditVerification
+	if *file != "" {
+		result = verifyAuditFile(*file)
+	} else if err := newAdminClient(*addr).do(http.MethodGet, "/admin/audit/verify", &result); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if !result.Valid {
+		fmt.Fprintf(stderr, "audit log is not valid (%d events read): %s\n", result.Events, result.Error)
+		return 1
+	}
+	fmt.Fprintf(stdout, "audit log is valid: %d events\n", result.Events)
+	return 0
+}
+
+func verifyAuditFile(path string) api.AuditVerification {
+	events, err := audit.ReadFile(path)
+	if err == nil {
+		err = audit.Verify(events)
+	}
+	if err != nil {
+		return api.AuditVerification{Events: len(events), Error: err.Error()}
+	}
+	return api.AuditVerification{Valid: true, Events: len(events)}
+}
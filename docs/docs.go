@@ -15,16 +15,2883 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/audit": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List audit events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Action, e.g. payment.created",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Actor, e.g. merchant:acme",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resource, e.g. payment:\u003cid\u003e",
+                        "name": "resource",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest time (RFC 3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest time (RFC 3339)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only events after this sequence number",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum events (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/audit.Event"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/audit/verify": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Verify the audit log hash chain",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.AuditVerification"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/config/reload": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reload the configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/config.ReloadEvent"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "The new configuration was rejected",
+                        "schema": {
+                            "$ref": "#/definitions/config.ReloadEvent"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/config/reloads": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List configuration reloads",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/config.ReloadEvent"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Vault key status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.KeysStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys/rotation": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Start or resume vault key rotation",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.KeysStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "conflict",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Stop vault key rotation",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.KeysStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers": {
+            "post": {
+                "description": "Creates a customer without payment methods; save them with POST /customers/{id}/payment-methods. Only served when the vault is enabled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Create a customer",
+                "parameters": [
+                    {
+                        "description": "Name, email and currency, all optional",
+                        "name": "customer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CustomerRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant owning the customer",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Customer"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the customer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}": {
+            "get": {
+                "description": "The customer and its saved payment methods.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Get a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Customer"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the customer and its payment methods. Its payments are kept and still name it; the card tokens stay in the vault.",
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Delete a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Changes the fields sent and leaves the others unchanged; an empty string clears a field. default_payment_method_id must name a saved payment method of the customer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Update a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "customer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CustomerUpdateRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Customer"
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}/payment-methods": {
+            "post": {
+                "description": "Saves a card token from POST /tokens as a payment method of the customer. The first method saved, or one sent with default true, becomes the default. Saving a token twice answers 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Save a payment method",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Card token",
+                        "name": "payment_method",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentMethodRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentMethod"
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request, validation_failed or card_token_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "conflict",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}/payment-methods/{method_id}": {
+            "delete": {
+                "description": "Removes the payment method from the customer. Removing the default one leaves the customer without a default. The card token stays in the vault.",
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Remove a payment method",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment method ID",
+                        "name": "method_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "customer_not_found or payment_method_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}/payments": {
+            "get": {
+                "description": "The payments made with the customer's saved payment methods, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "List a customer's payments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum payments (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentList"
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/payment-batches": {
+            "post": {
+                "description": "Accepts up to batches.max_items payments as a JSON body, NDJSON (application/x-ndjson, one payment request per line), CSV (text/csv, with a header row naming the payment request fields), or a file uploaded as multipart/form-data in the \"file\" field.\nEach payment is validated and authorized like POST /payments, in the background; poll the Location for progress.",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Submit a payment batch",
+                "parameters": [
+                    {
+                        "description": "Payments, in JSON",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentBatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant, for per-merchant duplicate rules",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentBatch"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the batch"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "batch_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "unsupported_media_type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/payment-batches/{id}": {
+            "get": {
+                "description": "Progress counts and the outcome of every payment of the batch, in submission order. Stored payments can be retrieved with GET /payments/{id}.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get a payment batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "batch_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/payments": {
+            "post": {
+                "description": "Authorized and Declined payments both answer 200; declined ones carry decline_code and decline_type.\nWith \"Prefer: respond-async\", when asynchronous payments are enabled, the payment is queued and answered 202 as Pending; poll the Location or wait for the merchant callback.\nWhen 3-D Secure is enabled and the risk rules or the issuer require it, the cardholder is authenticated first. A payment whose cardholder must complete a challenge answers 200 as RequiresAction: send the cardholder's browser to three_ds.challenge_url. Failed authentications are Declined with decline_code authentication_failed.\n/api/payments is an alias answering in the version given by API-Version or Accept, v1 by default.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Process a payment",
+                "parameters": [
+                    {
+                        "description": "Card and amount, or a card token",
+                        "name": "payment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant, for per-merchant duplicate rules",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "respond-async to process the payment asynchronously",
+                        "name": "Prefer",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the queued payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request, validation_failed, card_token_not_found or card_tokens_disabled",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "duplicate_payment",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_unavailable or authentication_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "upstream_overloaded or queue_full",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/payments/{id}": {
+            "get": {
+                "description": "/api/payments/{id} is an alias answering in the version given by API-Version or Accept, v1 by default.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get a payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        }
+                    },
+                    "404": {
+                        "description": "payment_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/payments/{id}/3ds/complete": {
+            "post": {
+                "description": "Notification URL of 3-D Secure challenges, to which the ACS posts the challenge response from the cardholder's browser. The outcome is fetched from the 3DS server, and the authenticated payment is sent to the acquirer.\nThe cardholder is redirected to the payment's three_ds.return_url with the payment_id query parameter when it has one; the payment is answered otherwise. Challenges not completed in time fail the payment with authentication_expired.\nServed when 3-D Secure is enabled.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Complete a 3-D Secure challenge",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Base64url-encoded challenge response (CRes)",
+                        "name": "cres",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        }
+                    },
+                    "303": {
+                        "description": "Redirect to the return URL",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "three_ds.return_url with payment_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "payment_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "conflict or authentication_expired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_unavailable or authentication_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/subscriptions": {
+            "post": {
+                "description": "Charges a card token the same amount every billing period, with merchant-initiated payments linked to an authorized initial payment sent with stored_credential {\"initiator\": \"customer\", \"sequence\": \"initial\"}. Only served when the vault is enabled.\nCharges softly declined or failed are retried after each delay of subscriptions.retry_schedule; the subscription is suspended when they are exhausted or a charge is declined for good.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Create a subscription",
+                "parameters": [
+                    {
+                        "description": "Card token, amount and billing period",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.SubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant charging the subscription",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Subscription"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the subscription"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request, validation_failed or card_token_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/subscriptions/{id}": {
+            "get": {
+                "description": "The status and schedule of a subscription, and every charge made, oldest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Subscription"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops charging the subscription and returns it. Cancelling a cancelled subscription changes nothing.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Cancel a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Subscription"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tokens": {
+            "post": {
+                "description": "Exchanges card data for an opaque token usable as card_token in payments. Only served when the vault is enabled. The CVV is never stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Tokenize a card",
+                "parameters": [
+                    {
+                        "description": "Card to tokenize",
+                        "name": "card",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.TokenRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
         "/ping": {
             "get": {
                 "produces": [
                     "application/json"
                 ],
+                "summary": "Ping the gateway",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pong"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Fails while the server drains during shutdown, and while no acquirer is reachable according to a background probe.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/customers": {
+            "post": {
+                "description": "Creates a customer without payment methods; save them with POST /customers/{id}/payment-methods. Only served when the vault is enabled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Create a customer",
+                "parameters": [
+                    {
+                        "description": "Name, email and currency, all optional",
+                        "name": "customer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CustomerRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant owning the customer",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Customer"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the customer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/customers/{id}": {
+            "get": {
+                "description": "The customer and its saved payment methods.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Get a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Customer"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the customer and its payment methods. Its payments are kept and still name it; the card tokens stay in the vault.",
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Delete a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Changes the fields sent and leaves the others unchanged; an empty string clears a field. default_payment_method_id must name a saved payment method of the customer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Update a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "customer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CustomerUpdateRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Customer"
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/customers/{id}/payment-methods": {
+            "post": {
+                "description": "Saves a card token from POST /tokens as a payment method of the customer. The first method saved, or one sent with default true, becomes the default. Saving a token twice answers 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Save a payment method",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Card token",
+                        "name": "payment_method",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentMethodRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentMethod"
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request, validation_failed or card_token_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "conflict",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/customers/{id}/payment-methods/{method_id}": {
+            "delete": {
+                "description": "Removes the payment method from the customer. Removing the default one leaves the customer without a default. The card token stays in the vault.",
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Remove a payment method",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment method ID",
+                        "name": "method_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "customer_not_found or payment_method_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/customers/{id}/payments": {
+            "get": {
+                "description": "The payments made with the customer's saved payment methods, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "List a customer's payments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum payments (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentList"
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "customer_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/payment-batches": {
+            "post": {
+                "description": "Accepts up to batches.max_items payments as a JSON body, NDJSON (application/x-ndjson, one payment request per line), CSV (text/csv, with a header row naming the payment request fields), or a file uploaded as multipart/form-data in the \"file\" field.\nEach payment is validated and authorized like POST /payments, in the background; poll the Location for progress.",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Submit a payment batch",
+                "parameters": [
+                    {
+                        "description": "Payments, in JSON",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentBatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant, for per-merchant duplicate rules",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentBatch"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the batch"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "batch_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "unsupported_media_type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/payment-batches/{id}": {
+            "get": {
+                "description": "Progress counts and the outcome of every payment of the batch, in submission order. Stored payments can be retrieved with GET /payments/{id}.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get a payment batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentBatch"
+                        }
+                    },
+                    "404": {
+                        "description": "batch_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/payments": {
+            "post": {
+                "description": "Authorized and Declined payments both answer 200; declined ones carry decline_code and decline_type.\nWith \"Prefer: respond-async\", when asynchronous payments are enabled, the payment is queued and answered 202 as Pending; poll the Location or wait for the merchant callback.\nWhen 3-D Secure is enabled and the risk rules or the issuer require it, the cardholder is authenticated first. A payment whose cardholder must complete a challenge answers 200 as RequiresAction: send the cardholder's browser to three_ds.challenge_url. Failed authentications are Declined with decline_code authentication_failed.\n/api/payments is an alias answering in the version given by API-Version or Accept, v1 by default.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Process a payment",
+                "parameters": [
+                    {
+                        "description": "Card and amount, or a card token",
+                        "name": "payment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.PaymentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant, for per-merchant duplicate rules",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "respond-async to process the payment asynchronously",
+                        "name": "Prefer",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the queued payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request, validation_failed, card_token_not_found or card_tokens_disabled",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "duplicate_payment",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_unavailable or authentication_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "upstream_overloaded or queue_full",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/payments/{id}": {
+            "get": {
+                "description": "/api/payments/{id} is an alias answering in the version given by API-Version or Accept, v1 by default.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get a payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        }
+                    },
+                    "404": {
+                        "description": "payment_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/payments/{id}/3ds/complete": {
+            "post": {
+                "description": "Notification URL of 3-D Secure challenges, to which the ACS posts the challenge response from the cardholder's browser. The outcome is fetched from the 3DS server, and the authenticated payment is sent to the acquirer.\nThe cardholder is redirected to the payment's three_ds.return_url with the payment_id query parameter when it has one; the payment is answered otherwise. Challenges not completed in time fail the payment with authentication_expired.\nServed when 3-D Secure is enabled.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Complete a 3-D Secure challenge",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Base64url-encoded challenge response (CRes)",
+                        "name": "cres",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Payment"
+                        }
+                    },
+                    "303": {
+                        "description": "Redirect to the return URL",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "three_ds.return_url with payment_id"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "payment_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "conflict or authentication_expired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "upstream_unavailable or authentication_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions": {
+            "post": {
+                "description": "Charges a card token the same amount every billing period, with merchant-initiated payments linked to an authorized initial payment sent with stored_credential {\"initiator\": \"customer\", \"sequence\": \"initial\"}. Only served when the vault is enabled.\nCharges softly declined or failed are retried after each delay of subscriptions.retry_schedule; the subscription is suspended when they are exhausted or a charge is declined for good.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Create a subscription",
+                "parameters": [
+                    {
+                        "description": "Card token, amount and billing period",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.SubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant charging the subscription",
+                        "name": "X-Merchant-Id",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Subscription"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the subscription"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request, validation_failed or card_token_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/subscriptions/{id}": {
+            "get": {
+                "description": "The status and schedule of a subscription, and every charge made, oldest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Subscription"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops charging the subscription and returns it. Cancelling a cancelled subscription changes nothing.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Cancel a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Subscription"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/tokens": {
+            "post": {
+                "description": "Exchanges card data for an opaque token usable as card_token in payments. Only served when the vault is enabled. The CVV is never stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Tokenize a card",
+                "parameters": [
+                    {
+                        "description": "Card to tokenize",
+                        "name": "card",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.TokenRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "API version of the /api alias; ignored on versioned paths",
+                        "name": "API-Version",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/v1.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "malformed_request or validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "unsupported_version",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "410": {
+                        "description": "version_retired",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate_limited",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until a request can succeed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal_error",
                         "schema": {
-                            "$ref": "#/definitions/main.Pong"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     }
                 }
@@ -32,18 +2899,1048 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "main.Pong": {
+        "api.AuditVerification": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "integer"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.KeysStatus": {
+            "type": "object",
+            "properties": {
+                "active_key": {
+                    "type": "string"
+                },
+                "records": {
+                    "description": "Records counts stored records per master key ID.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "rotation": {
+                    "$ref": "#/definitions/keyring.Progress"
+                }
+            }
+        },
+        "api.pong": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "audit.Action": {
+            "type": "string",
+            "enum": [
+                "payment.created",
+                "token.created",
+                "config.reloaded",
+                "keys.rotation_started",
+                "keys.rotation_stopped",
+                "subscription.created",
+                "subscription.cancelled",
+                "customer.created",
+                "customer.updated",
+                "customer.deleted"
+            ],
+            "x-enum-varnames": [
+                "ActionPaymentCreated",
+                "ActionTokenCreated",
+                "ActionConfigReloaded",
+                "ActionKeyRotationStarted",
+                "ActionKeyRotationStopped",
+                "ActionSubscriptionCreated",
+                "ActionSubscriptionCancelled",
+                "ActionCustomerCreated",
+                "ActionCustomerUpdated",
+                "ActionCustomerDeleted"
+            ]
+        },
+        "audit.Event": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/audit.Action"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "before": {
+                    "description": "Before and After summarise the state around the action. Card data is\nredacted before the event is recorded.",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "hash": {
+                    "description": "Hash is the SHA-256 of the event encoded as JSON without Hash.",
+                    "type": "string"
+                },
+                "prev_hash": {
+                    "description": "PrevHash is the Hash of the previous event; it is empty for the first.",
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "resource": {
+                    "description": "Resource identifies what the action applied to, e.g. \"payment:\u003cid\u003e\".",
+                    "type": "string"
+                },
+                "seq": {
+                    "type": "integer"
+                },
+                "source_ip": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.ReloadEvent": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "description": "Changed lists the runtime settings that were applied.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "restart_required": {
+                    "description": "RestartRequired lists changed settings that only take effect after a\nrestart. They are ignored until then.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/config.ReloadStatus"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "$ref": "#/definitions/config.Trigger"
+                }
+            }
+        },
+        "config.ReloadStatus": {
+            "type": "string",
+            "enum": [
+                "applied",
+                "unchanged",
+                "rejected"
+            ],
+            "x-enum-varnames": [
+                "ReloadApplied",
+                "ReloadUnchanged",
+                "ReloadRejected"
+            ]
+        },
+        "config.Trigger": {
+            "type": "string",
+            "enum": [
+                "sighup",
+                "file",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "TriggerSignal",
+                "TriggerFile",
+                "TriggerAdmin"
+            ]
+        },
+        "health.ComponentStatus": {
+            "type": "object",
+            "properties": {
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.ComponentStatus"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "keyring.JobState": {
+            "type": "string",
+            "enum": [
+                "idle",
+                "running",
+                "stopped",
+                "completed",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "JobIdle",
+                "JobRunning",
+                "JobStopped",
+                "JobCompleted",
+                "JobFailed"
+            ]
+        },
+        "keyring.Progress": {
+            "type": "object",
+            "properties": {
+                "cursor": {
+                    "description": "Cursor is the last record ID processed. A stopped or failed run\nresumes after it.",
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "rotated": {
+                    "description": "Rotated and Failed count records since the run started, including\nruns it resumed.",
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/keyring.JobState"
+                }
+            }
+        },
+        "payments.AuthenticationStatus": {
+            "type": "string",
+            "enum": [
+                "authenticated",
+                "attempted",
+                "failed",
+                "rejected",
+                "unavailable",
+                "challenge_required"
+            ],
+            "x-enum-varnames": [
+                "AuthenticationSucceeded",
+                "AuthenticationAttempted",
+                "AuthenticationFailed",
+                "AuthenticationRejected",
+                "AuthenticationUnavailable",
+                "AuthenticationChallengeRequired"
+            ]
+        },
+        "payments.CredentialReason": {
+            "type": "string",
+            "enum": [
+                "recurring",
+                "installment",
+                "unscheduled"
+            ],
+            "x-enum-varnames": [
+                "ReasonRecurring",
+                "ReasonInstallment",
+                "ReasonUnscheduled"
+            ]
+        },
+        "payments.DeclineCode": {
+            "type": "string",
+            "enum": [
+                "insufficient_funds",
+                "do_not_honor",
+                "expired_card",
+                "suspected_fraud",
+                "invalid_cvv",
+                "invalid_card",
+                "lost_or_stolen",
+                "limit_exceeded",
+                "transaction_not_permitted",
+                "issuer_unavailable",
+                "authentication_required",
+                "authentication_failed"
+            ],
+            "x-enum-varnames": [
+                "DeclineInsufficientFunds",
+                "DeclineDoNotHonor",
+                "DeclineExpiredCard",
+                "DeclineSuspectedFraud",
+                "DeclineInvalidCVV",
+                "DeclineInvalidCard",
+                "DeclineLostOrStolen",
+                "DeclineLimitExceeded",
+                "DeclineNotPermitted",
+                "DeclineIssuerUnavailable",
+                "DeclineAuthenticationRequired",
+                "DeclineAuthenticationFailed"
+            ]
+        },
+        "payments.DeclineType": {
+            "type": "string",
+            "enum": [
+                "soft",
+                "hard"
+            ],
+            "x-enum-varnames": [
+                "DeclineSoft",
+                "DeclineHard"
+            ]
+        },
+        "payments.Initiator": {
+            "type": "string",
+            "enum": [
+                "customer",
+                "merchant"
+            ],
+            "x-enum-varnames": [
+                "InitiatorCustomer",
+                "InitiatorMerchant"
+            ]
+        },
+        "payments.Sequence": {
+            "type": "string",
+            "enum": [
+                "initial",
+                "subsequent"
+            ],
+            "x-enum-varnames": [
+                "SequenceInitial",
+                "SequenceSubsequent"
+            ]
+        },
+        "problem.Code": {
+            "type": "string",
+            "enum": [
+                "malformed_request",
+                "validation_failed",
+                "card_token_not_found",
+                "card_tokens_disabled",
+                "card_declined",
+                "duplicate_payment",
+                "payment_not_found",
+                "batch_not_found",
+                "batch_too_large",
+                "unsupported_media_type",
+                "subscription_not_found",
+                "customer_not_found",
+                "payment_method_not_found",
+                "not_found",
+                "method_not_allowed",
+                "unsupported_version",
+                "version_retired",
+                "unauthorized",
+                "conflict",
+                "rate_limited",
+                "upstream_unavailable",
+                "upstream_overloaded",
+                "queue_full",
+                "authentication_unavailable",
+                "authentication_expired",
+                "internal_error"
+            ],
+            "x-enum-varnames": [
+                "CodeMalformedRequest",
+                "CodeValidationFailed",
+                "CodeCardTokenNotFound",
+                "CodeCardTokensDisabled",
+                "CodeCardDeclined",
+                "CodeDuplicatePayment",
+                "CodePaymentNotFound",
+                "CodeBatchNotFound",
+                "CodeBatchTooLarge",
+                "CodeUnsupportedMedia",
+                "CodeSubscriptionNotFound",
+                "CodeCustomerNotFound",
+                "CodePaymentMethodNotFound",
+                "CodeNotFound",
+                "CodeMethodNotAllowed",
+                "CodeUnsupportedVersion",
+                "CodeVersionRetired",
+                "CodeUnauthorized",
+                "CodeConflict",
+                "CodeRateLimited",
+                "CodeUpstreamUnavailable",
+                "CodeUpstreamOverloaded",
+                "CodeQueueFull",
+                "CodeAuthenticationUnavailable",
+                "CodeAuthenticationExpired",
+                "CodeInternalError"
+            ]
+        },
+        "problem.FieldError": {
             "type": "object",
             "properties": {
+                "field": {
+                    "type": "string"
+                },
                 "message": {
                     "type": "string"
                 }
             }
+        },
+        "problem.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/problem.Code"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "Instance is the path of the request that failed.",
+                    "type": "string"
+                },
+                "payment_status": {
+                    "description": "PaymentStatus is set by payment endpoints so that clients can tell a\nrejected payment from a failed one without parsing the code.",
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "subscription.Interval": {
+            "type": "string",
+            "enum": [
+                "day",
+                "week",
+                "month",
+                "year"
+            ],
+            "x-enum-varnames": [
+                "IntervalDay",
+                "IntervalWeek",
+                "IntervalMonth",
+                "IntervalYear"
+            ]
+        },
+        "v1.Authentication": {
+            "type": "object",
+            "properties": {
+                "challenge_url": {
+                    "description": "ChallengeURL is where to send the cardholder's browser while Status\nis challenge_required.",
+                    "type": "string"
+                },
+                "ds_transaction_id": {
+                    "type": "string"
+                },
+                "eci": {
+                    "description": "ECI is the electronic commerce indicator given by the scheme.",
+                    "type": "string"
+                },
+                "liability_shift": {
+                    "description": "LiabilityShift reports that fraud chargebacks are borne by the\nissuer rather than the merchant.",
+                    "type": "boolean"
+                },
+                "status": {
+                    "description": "Status is authenticated, attempted, failed, rejected, unavailable or\nchallenge_required.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/payments.AuthenticationStatus"
+                        }
+                    ]
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.BatchItem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code, Detail and Errors say why a Rejected or Failed payment was not\nstored, as in the problem POST /v1/payments would have answered.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/problem.Code"
+                        }
+                    ]
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.FieldError"
+                    }
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "payment_id": {
+                    "description": "PaymentId identifies the stored payment, which GET /v1/payments/{id}\nreturns. It is empty for Rejected and Failed payments.",
+                    "type": "string"
+                },
+                "payment_status": {
+                    "description": "PaymentStatus is Pending until the payment is processed.",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.BatchProgress": {
+            "type": "object",
+            "properties": {
+                "authorized": {
+                    "type": "integer"
+                },
+                "declined": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "requires_action": {
+                    "description": "RequiresAction counts payments awaiting 3-D Secure authentication by\ntheir cardholder.",
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.Customer": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "default_payment_method_id": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "payment_methods": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.PaymentMethod"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CustomerRequest": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Currency is used by payments of the customer that name none.",
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CustomerUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "default_payment_method_id": {
+                    "description": "DefaultPaymentMethodID is the payment method paid with when a payment\nnames only the customer.",
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.Payment": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "card_fingerprint": {
+                    "description": "CardFingerprint is the same for every payment made with the same\ncard, without revealing the card number.",
+                    "type": "string"
+                },
+                "card_number_last_four": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "customer_id": {
+                    "description": "CustomerID and PaymentMethodID identify the saved payment method\npaid with.",
+                    "type": "string"
+                },
+                "decline_code": {
+                    "description": "DeclineCode is the normalized reason a Declined payment was declined.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/payments.DeclineCode"
+                        }
+                    ]
+                },
+                "decline_type": {
+                    "description": "DeclineType is soft when retrying the payment later may succeed and\nhard when it will be declined again.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/payments.DeclineType"
+                        }
+                    ]
+                },
+                "duplicate_of": {
+                    "description": "DuplicateOf is the ID of an earlier payment with the same card,\namount and currency, when the merchant's duplicate action is warn.",
+                    "type": "string"
+                },
+                "expiry_month": {
+                    "type": "integer"
+                },
+                "expiry_year": {
+                    "type": "integer"
+                },
+                "failure_code": {
+                    "description": "FailureCode is the error code of a payment that Failed while\nprocessed asynchronously, such as upstream_unavailable.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "network_transaction_id": {
+                    "description": "NetworkTransactionID identifies the payment to the card scheme. The\none of an initial payment is sent with its merchant-initiated\npayments.",
+                    "type": "string"
+                },
+                "payment_method_id": {
+                    "type": "string"
+                },
+                "payment_status": {
+                    "type": "string"
+                },
+                "stored_credential": {
+                    "description": "StoredCredential is the stored credential flags sent with the\npayment.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.StoredCredential"
+                        }
+                    ]
+                },
+                "three_ds": {
+                    "description": "ThreeDS is the 3-D Secure authentication of the cardholder, when the\npayment was authenticated.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.Authentication"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.PaymentBatch": {
+            "type": "object",
+            "properties": {
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.BatchItem"
+                    }
+                },
+                "progress": {
+                    "$ref": "#/definitions/v1.BatchProgress"
+                },
+                "status": {
+                    "description": "Status is Processing, Completed, or Interrupted when a shutdown\nstopped the batch before every payment was processed.",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.PaymentBatchRequest": {
+            "type": "object",
+            "properties": {
+                "payments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.PaymentRequest"
+                    }
+                }
+            }
+        },
+        "v1.PaymentList": {
+            "type": "object",
+            "properties": {
+                "payments": {
+                    "description": "Payments are the customer's payments, newest first.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.Payment"
+                    }
+                }
+            }
+        },
+        "v1.PaymentMethod": {
+            "type": "object",
+            "properties": {
+                "card_number_last_four": {
+                    "type": "string"
+                },
+                "card_token": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expiry_month": {
+                    "type": "integer"
+                },
+                "expiry_year": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.PaymentMethodRequest": {
+            "type": "object",
+            "properties": {
+                "card_token": {
+                    "description": "CardToken is a token from POST /v1/tokens.",
+                    "type": "string"
+                },
+                "default": {
+                    "description": "Default makes the method the customer's default one.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "v1.PaymentRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "card_number": {
+                    "type": "string"
+                },
+                "card_token": {
+                    "description": "CardToken is a token from POST /v1/tokens, used instead of\nCardNumber.",
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "customer_id": {
+                    "description": "CustomerID pays with a saved payment method of the customer, instead\nof card_number or card_token: PaymentMethodID, or the customer's\ndefault one. Currency defaults to the customer's; Cvv is still\nrequired unless the payment is merchant-initiated.",
+                    "type": "string"
+                },
+                "cvv": {
+                    "description": "Cvv may be omitted on merchant-initiated payments.",
+                    "type": "string"
+                },
+                "expiry_month": {
+                    "type": "integer"
+                },
+                "expiry_year": {
+                    "type": "integer"
+                },
+                "payment_method_id": {
+                    "type": "string"
+                },
+                "stored_credential": {
+                    "description": "StoredCredential flags payments made with, or storing, a card kept on\nfile.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.StoredCredential"
+                        }
+                    ]
+                },
+                "three_ds": {
+                    "description": "ThreeDS holds the 3-D Secure options of the payment.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/v1.ThreeDSRequest"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.StoredCredential": {
+            "type": "object",
+            "properties": {
+                "initiator": {
+                    "description": "Initiator is customer (CIT) or merchant (MIT).",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/payments.Initiator"
+                        }
+                    ]
+                },
+                "network_transaction_id": {
+                    "description": "NetworkTransactionID is the one returned with the initial payment.\nMerchant-initiated payments require it, and must be for the card,\nsent as card_number, card_token or customer_id, of an authorized\ninitial payment of the same merchant.",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Reason is recurring, installment or unscheduled. Merchant-initiated\npayments require it.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/payments.CredentialReason"
+                        }
+                    ]
+                },
+                "sequence": {
+                    "description": "Sequence is initial or subsequent.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/payments.Sequence"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.Subscription": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "cancelled_at": {
+                    "type": "string"
+                },
+                "card_token": {
+                    "type": "string"
+                },
+                "charges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.SubscriptionCharge"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "initial_payment_id": {
+                    "type": "string"
+                },
+                "interval": {
+                    "$ref": "#/definitions/subscription.Interval"
+                },
+                "interval_count": {
+                    "type": "integer"
+                },
+                "network_transaction_id": {
+                    "type": "string"
+                },
+                "next_charge_at": {
+                    "description": "NextChargeAt is absent once the subscription is Suspended or\nCancelled.",
+                    "type": "string"
+                },
+                "periods_paid": {
+                    "type": "integer"
+                },
+                "start_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is Active, PastDue while a failed charge is retried,\nSuspended once charges stopped failing, or Cancelled.",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.SubscriptionCharge": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "attempt": {
+                    "type": "integer"
+                },
+                "code": {
+                    "description": "Code and Detail say why a Rejected or Failed charge stored no\npayment.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/problem.Code"
+                        }
+                    ]
+                },
+                "decline_code": {
+                    "$ref": "#/definitions/payments.DeclineCode"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "payment_id": {
+                    "description": "PaymentId identifies the stored payment, which GET /v1/payments/{id}\nreturns. It is empty for Rejected and Failed charges.",
+                    "type": "string"
+                },
+                "payment_status": {
+                    "type": "string"
+                },
+                "period": {
+                    "description": "Period is the billing period charged, from 1, and Attempt the charge\nof the period, from 1.",
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.SubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "card_token": {
+                    "description": "CardToken is the token of the card stored by the initial payment.",
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "initial_payment_id": {
+                    "description": "InitialPaymentID is the authorized payment sent with stored_credential\n{\"initiator\": \"customer\", \"sequence\": \"initial\"}.",
+                    "type": "string"
+                },
+                "interval": {
+                    "description": "Interval is day, week, month or year.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/subscription.Interval"
+                        }
+                    ]
+                },
+                "interval_count": {
+                    "description": "IntervalCount is the number of intervals in a billing period; 1 when\nomitted.",
+                    "type": "integer"
+                },
+                "start_at": {
+                    "description": "StartAt is the first charge; one billing period from now when\nomitted, the initial payment paying for the first.",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.ThreeDSRequest": {
+            "type": "object",
+            "properties": {
+                "return_url": {
+                    "description": "ReturnURL is where the cardholder's browser is redirected once a\nchallenge ends, with the payment_id query parameter.",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.Token": {
+            "type": "object",
+            "properties": {
+                "card_number_last_four": {
+                    "type": "string"
+                },
+                "expiry_month": {
+                    "type": "integer"
+                },
+                "expiry_year": {
+                    "type": "integer"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.TokenRequest": {
+            "type": "object",
+            "properties": {
+                "card_number": {
+                    "type": "string"
+                },
+                "expiry_month": {
+                    "type": "integer"
+                },
+                "expiry_year": {
+                    "type": "integer"
+                }
+            }
         }
     },
     "securityDefinitions": {
-        "BasicAuth": {
-            "type": "basic"
+        "AdminToken": {
+            "description": "Admin API token as \"Bearer \u003cadmin.token\u003e\".",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -55,7 +3952,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "Payment Gateway Challenge Go",
-	Description:      "Interview challenge for building a Payment Gateway - Go version",
+	Description:      "Interview challenge for building a Payment Gateway - Go version\n\nPayment and token operations are versioned under `/v1`. The `/api` paths are an\nalias answering in the version named by the `API-Version` header or an\n`application/vnd.payment-gateway.v1+json` Accept type, `v1` by default.\nDeprecated versions carry `Deprecation` and `Sunset` headers.\n\nErrors are RFC 7807 problem details (application/problem+json). Branch on\nthe stable `code` member, never on `title` or `detail`:\n\n| Code | Status | Meaning |\n|------|--------|---------|\n| malformed_request | 400 | The body is not valid JSON or has the wrong shape. |\n| validation_failed | 400 | One or more fields are invalid; see `errors`. |\n| card_token_not_found | 400 | `card_token` does not match a stored card. |\n| card_tokens_disabled | 400 | `card_token` was sent but the vault is not enabled. |\n| card_declined | 402 | Reserved for decline reasons. |\n| duplicate_payment | 409 | An identical payment was made recently and the merchant rejects duplicates. |\n| payment_not_found | 404 | No payment has the requested ID. |\n| batch_not_found | 404 | No payment batch has the requested ID. |\n| batch_too_large | 413 | The batch holds more payments than `batches.max_items` allows; split it. |\n| subscription_not_found | 404 | No subscription has the requested ID. |\n| customer_not_found | 404 | No customer has the requested ID. |\n| payment_method_not_found | 404 | The customer has no payment method with the requested ID. |\n| unsupported_media_type | 415 | The request body is in a format the endpoint does not accept. |\n| not_found | 404 | No route matches the path. |\n| method_not_allowed | 405 | The route does not support the method. |\n| unsupported_version | 406 | The requested API version is not served. |\n| version_retired | 410 | The requested API version is past its sunset date. |\n| unauthorized | 401 | Missing or invalid admin bearer token. |\n| conflict | 409 | The request conflicts with the current state. |\n| rate_limited | 429 | Too many requests; see Retry-After. |\n| upstream_unavailable | 502 | The acquiring bank could not be reached. |\n| upstream_overloaded | 503 | Too many calls to the acquiring bank are in flight; see Retry-After. |\n| queue_full | 503 | The asynchronous payment queue is full; see Retry-After. |\n| authentication_unavailable | 502 | The 3DS server could not be reached; the cardholder could not be authenticated. |\n| authentication_expired | 409 | The 3-D Secure challenge was not completed in time and the payment failed. |\n| internal_error | 500 | The gateway failed to process the request. |",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
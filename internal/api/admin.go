@@ -0,0 +1,305 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+)
+
+// ReloadHistoryHandler returns an http.HandlerFunc that lists past
+// configuration reloads, oldest first.
+//
+//	@Summary	List configuration reloads
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Success	200	{array}		config.ReloadEvent
+//	@Failure	401	{object}	problem.Problem	"unauthorized"
+//	@Router		/admin/config/reloads [get]
+func (a *Api) ReloadHistoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		history := a.reloader.History()
+		if history == nil {
+			history = []config.ReloadEvent{}
+		}
+		writeJSON(w, http.StatusOK, history)
+	}
+}
+
+// ReloadConfigHandler returns an http.HandlerFunc that reloads the
+// configuration and reports the outcome. A rejected reload answers 422 and
+// leaves the running configuration untouched.
+//
+//	@Summary	Reload the configuration
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Success	200	{object}	config.ReloadEvent
+//	@Failure	401	{object}	problem.Problem	"unauthorized"
+//	@Failure	422	{object}	config.ReloadEvent	"The new configuration was rejected"
+//	@Router		/admin/config/reload [post]
+func (a *Api) ReloadConfigHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event := a.reloader.Reload(r.Context(), config.TriggerAdmin)
+		status := http.StatusOK
+		if event.Status == config.ReloadRejected {
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, event)
+	}
+}
+
+// KeysStatus describes the vault master keys and the re-encryption job.
+type KeysStatus struct {
+	ActiveKey string `json:"active_key"`
+	// Records counts stored records per master key ID.
+	Records  map[string]int   `json:"records"`
+	Rotation keyring.Progress `json:"rotation"`
+}
+
+// KeysStatusHandler returns an http.HandlerFunc that reports which master
+// keys protect vault records and how far re-encryption has progressed.
+//
+//	@Summary	Vault key status
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Success	200	{object}	KeysStatus
+//	@Failure	401	{object}	problem.Problem	"unauthorized"
+//	@Router		/admin/keys [get]
+func (a *Api) KeysStatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.keysStatus())
+	}
+}
+
+// StartKeyRotationHandler returns an http.HandlerFunc that starts, or
+// resumes, re-encrypting vault records under the active key in the
+// background. It answers 409 while a run is in progress.
+//
+//	@Summary	Start or resume vault key rotation
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Success	202	{object}	KeysStatus
+//	@Failure	401	{object}	problem.Problem	"unauthorized"
+//	@Failure	409	{object}	problem.Problem	"conflict"
+//	@Router		/admin/keys/rotation [post]
+func (a *Api) StartKeyRotationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		before := a.keyRotation.Progress()
+		if err := a.keyRotation.Start(r.Context()); err != nil {
+			problem.Write(w, r, problem.New(problem.CodeConflict, err.Error()))
+			return
+		}
+		status := a.keysStatus()
+		a.auditLog.Record(r.Context(), audit.ActionKeyRotationStarted, "keys:vault",
+			progressSummary(before), progressSummary(status.Rotation))
+		writeJSON(w, http.StatusAccepted, status)
+	}
+}
+
+// StopKeyRotationHandler returns an http.HandlerFunc that stops the
+// re-encryption job. The next start resumes where it stopped.
+//
+//	@Summary	Stop vault key rotation
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Success	200	{object}	KeysStatus
+//	@Failure	401	{object}	problem.Problem	"unauthorized"
+//	@Router		/admin/keys/rotation [delete]
+func (a *Api) StopKeyRotationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		before := a.keyRotation.Progress()
+		a.keyRotation.Stop()
+		status := a.keysStatus()
+		a.auditLog.Record(r.Context(), audit.ActionKeyRotationStopped, "keys:vault",
+			progressSummary(before), progressSummary(status.Rotation))
+		writeJSON(w, http.StatusOK, status)
+	}
+}
+
+func (a *Api) keysStatus() KeysStatus {
+	return KeysStatus{
+		ActiveKey: a.cardVault.ActiveKeyID(),
+		Records:   a.cardVault.KeyUsage(),
+		Rotation:  a.keyRotation.Progress(),
+	}
+}
+
+func progressSummary(p keyring.Progress) map[string]any {
+	return map[string]any{
+		"state":   p.State,
+		"rotated": p.Rotated,
+		"failed":  p.Failed,
+		"cursor":  p.Cursor,
+	}
+}
+
+// maxAuditEvents caps the number of events returned by AuditEventsHandler.
+const maxAuditEvents = 1000
+
+// AuditEventsHandler returns an http.HandlerFunc that lists audit events,
+// oldest first. The action, actor, resource, since, until (RFC 3339), after
+// (a sequence number) and limit query parameters narrow the result; limit
+// defaults to 100.
+//
+//	@Summary	List audit events
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Param		action		query		string	false	"Action, e.g. payment.created"
+//	@Param		actor		query		string	false	"Actor, e.g. merchant:acme"
+//	@Param		resource	query		string	false	"Resource, e.g. payment:<id>"
+//	@Param		since		query		string	false	"Earliest time (RFC 3339)"
+//	@Param		until		query		string	false	"Latest time (RFC 3339)"
+//	@Param		after		query		integer	false	"Only events after this sequence number"
+//	@Param		limit		query		integer	false	"Maximum events (1-1000)"	default(100)
+//	@Success	200			{array}		audit.Event
+//	@Failure	400			{object}	problem.Problem	"validation_failed"
+//	@Failure	401			{object}	problem.Problem	"unauthorized"
+//	@Router		/admin/audit [get]
+func (a *Api) AuditEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, errs := auditFilter(r)
+		if len(errs) > 0 {
+			problem.Write(w, r, problem.New(problem.CodeValidationFailed, "Invalid query parameters").WithErrors(errs...))
+			return
+		}
+		events := a.auditLog.Query(filter)
+		if events == nil {
+			events = []audit.Event{}
+		}
+		writeJSON(w, http.StatusOK, events)
+	}
+}
+
+func auditFilter(r *http.Request) (audit.Filter, []problem.FieldError) {
+	q := r.URL.Query()
+	filter := audit.Filter{
+		Action:   audit.Action(q.Get("action")),
+		Actor:    q.Get("actor"),
+		Resource: q.Get("resource"),
+		Limit:    100,
+	}
+
+	var errs []problem.FieldError
+	parseTime := func(name string, dst *time.Time) {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				errs = append(errs, problem.FieldError{Field: name, Message: "must be an RFC 3339 time"})
+			}
+			*dst = t
+		}
+	}
+	parseTime("since", &filter.Since)
+	parseTime("until", &filter.Until)
+	if v := q.Get("after"); v != "" {
+		after, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			errs = append(errs, problem.FieldError{Field: "after", Message: "must be a sequence number"})
+		}
+		filter.AfterSeq = after
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditEvents {
+			errs = append(errs, problem.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxAuditEvents)})
+		}
+		filter.Limit = limit
+	}
+	return filter, errs
+}
+
+// AuditVerification is the outcome of checking the audit log hash chain.
+type AuditVerification struct {
+	Valid  bool   `json:"valid"`
+	Events int    `json:"events"`
+	Error  string `json:"error,omitempty"`
+}
+
+// VerifyAuditHandler returns an http.HandlerFunc that checks the hash chain
+// of the audit log held in memory.
+//
+//	@Summary	Verify the audit log hash chain
+//	@Tags		admin
+//	@Produce	json
+//	@Security	AdminToken
+//	@Success	200	{object}	AuditVerification
+//	@Failure	401	{object}	problem.Problem	"unauthorized"
+//	@Router		/admin/audit/verify [get]
+func (a *Api) VerifyAuditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := a.auditLog.Verify()
+		result := AuditVerification{Valid: err == nil, Events: n}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// auditReload records every configuration reload, with the sections it
+// changed before and after. Secrets are masked.
+func (a *Api) auditReload(ctx context.Context, event config.ReloadEvent, before, after *config.Config) {
+	var sections []string
+	for _, key := range append(append([]string(nil), event.Changed...), event.RestartRequired...) {
+		section, _, _ := strings.Cut(strings.SplitN(key, "[", 2)[0], ".")
+		if !contains(sections, section) {
+			sections = append(sections, section)
+		}
+	}
+
+	outcome := map[string]any{
+		"trigger":          event.Trigger,
+		"status":           event.Status,
+		"changed":          event.Changed,
+		"restart_required": event.RestartRequired,
+	}
+	if event.Error != "" {
+		outcome["error"] = event.Error
+	}
+	summary := configSummary(after, sections)
+	summary["reload"] = outcome
+
+	a.auditLog.Record(ctx, audit.ActionConfigReloaded, "config", configSummary(before, sections), summary)
+}
+
+// configSummary returns the given top-level sections of the redacted cfg.
+func configSummary(cfg *config.Config, sections []string) map[string]any {
+	var all map[string]any
+	b, _ := json.Marshal(cfg.Redacted())
+	json.Unmarshal(b, &all)
+
+	out := make(map[string]any, len(sections))
+	for _, section := range sections {
+		out[section] = all[section]
+	}
+	return out
+}
+
+func contains(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
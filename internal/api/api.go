@@ -2,56 +2,480 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/batch"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bulkhead"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/customer"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/health"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/jobqueue"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/ratelimit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/subscription"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/threeds"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/vault"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"golang.org/x/sync/errgroup"
 )
 
 type Api struct {
-	router       *chi.Mux
-	paymentsRepo *payments.PaymentsRepository
-	bankClient   *bank.BankClient
+	router          *chi.Mux
+	paymentsRepo    *payments.PaymentsRepository
+	bankRouter      *bank.Router
+	paymentsHandler *payments.PaymentsHandler
+	batchHandler    *batch.Handler
+	tokensHandler   *vault.TokensHandler
+	cardVault       *vault.Vault
+	keyRotation     *keyring.Job
+	auditLog        *audit.Log
+	health          *health.Checker
+	// The acquirers are pinged every bankProbeInterval for readiness, each
+	// ping bounded by healthCheckTimeout.
+	bankProbeInterval  time.Duration
+	healthCheckTimeout time.Duration
+	reloader           *config.Reloader
+	adminToken         string
+	deprecations       map[string]config.DeprecationConfig
+	limiter            ratelimit.Limiter
+	rateLimits         atomic.Pointer[ratelimit.Policy]
+
+	// clientIPHeader, when set, carries the client IP from a trusted proxy.
+	clientIPHeader string
+
+	// tlsConfig is nil when the listener serves plain HTTP.
+	tlsConfig          *tls.Config
+	certs              *tlsconfig.CertReloader
+	certReloadInterval time.Duration
+	clientMerchants    map[string]string
+
+	// jobQueue is nil unless asynchronous payments are enabled; queueFile
+	// is also set when the queue is journaled.
+	jobQueue          payments.JobQueue
+	queueFile         *jobqueue.File
+	asyncWorkers      int
+	queueDrainTimeout time.Duration
+	// notifier is nil unless merchant callbacks are configured.
+	notifier *webhook.Notifier
+
+	// drainDelay is how long readiness reports failure before the listener
+	// closes, giving load balancers time to stop routing new requests.
+	drainDelay time.Duration
+	// shutdownTimeout bounds how long in-flight requests may take to finish.
+	shutdownTimeout time.Duration
+
+	// subscriptionHandler is nil unless the vault is enabled, as
+	// subscriptions charge card tokens. Due subscriptions are charged every
+	// subscriptionPoll.
+	subscriptionHandler *subscription.Handler
+	subscriptionPoll    time.Duration
+	// customerHandler is nil unless the vault is enabled, as payment
+	// methods are card tokens.
+	customerHandler *customer.Handler
+	// threeDS reports whether cardholders are authenticated with 3-D
+	// Secure, which serves the challenge completion endpoint.
+	threeDS bool
 }
 
-func New() *Api {
-	a := &Api{}
+// New wires every subsystem from the reloader's current configuration and
+// subscribes them to later reloads.
+func New(reloader *config.Reloader) (*Api, error) {
+	cfg := reloader.Current()
+	a := &Api{
+		reloader:        reloader,
+		adminToken:      cfg.Admin.Token.Value(),
+		deprecations:    cfg.API.Deprecations,
+		limiter:         ratelimit.NewMemory(),
+		drainDelay:      cfg.Server.DrainDelay.Std(),
+		shutdownTimeout: cfg.Server.ShutdownTimeout.Std(),
+
+		clientIPHeader:     cfg.Server.ClientIPHeader,
+		certReloadInterval: cfg.Server.TLS.ReloadInterval.Std(),
+		clientMerchants:    cfg.Server.TLS.ClientMerchants,
+	}
+
+	tlsConfig, certs, err := serverTLSFrom(cfg.Server.TLS)
+	if err != nil {
+		return nil, err
+	}
+	a.tlsConfig, a.certs = tlsConfig, certs
+
+	auditLog, err := audit.Open(cfg.Audit.File)
+	if err != nil {
+		return nil, err
+	}
+	a.auditLog = auditLog
+	a.rateLimits.Store(rateLimitsFrom(cfg))
+
+	// config.Validate only accepts config.StorageMemory for now.
 	a.paymentsRepo = payments.NewPaymentsRepository()
 
-	bankURL := os.Getenv("BANK_URL")
-	if bankURL == "" {
-		bankURL = "http://localhost:8080"
+	routes := make([]bank.Route, len(cfg.Bank.Acquirers))
+	for i, acq := range cfg.Bank.Acquirers {
+		opts, err := acquirerOptions(acq)
+		if err != nil {
+			return nil, fmt.Errorf("acquirer %s: %w", acq.Name, err)
+		}
+		if h := cfg.Bank.Hedging; h.Enabled {
+			opts = append(opts, bank.WithHedging(bank.Hedging{
+				Percentile: h.Percentile,
+				MinDelay:   h.MinDelay.Std(),
+				MaxDelay:   h.MaxDelay.Std(),
+			}))
+		}
+		routes[i] = bank.Route{
+			Client: bank.NewBankClient(acq.URL, append([]bank.Option{
+				bank.WithName(acq.Name),
+				bank.WithTimeout(cfg.Bank.Timeout.Std()),
+				bank.WithAttempts(cfg.Bank.MaxAttempts, cfg.Bank.AttemptTimeout.Std()),
+				bank.WithTransport(transportFrom(cfg.Bank.Transport)),
+				bank.WithBulkhead(bulkheadFrom(cfg.Bank.Concurrency)),
+				bank.WithDeclineCodes(declineCodesFrom(acq.DeclineCodes)),
+			}, opts...)...),
+			Weight: acq.Weight,
+		}
+	}
+	a.bankRouter = bank.NewRouter(routes...)
+
+	a.paymentsHandler = payments.NewPaymentsHandler(a.paymentsRepo, a.bankRouter)
+	a.paymentsHandler.SetRules(rulesFrom(cfg))
+	a.paymentsHandler.SetAuditLog(a.auditLog)
+
+	fingerprintKey, err := cfg.Fingerprint.LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	if fingerprintKey == nil {
+		slog.Warn("no fingerprint key configured, card fingerprints will change on restart")
+		fingerprintKey = make([]byte, keyring.KeySize)
+		if _, err := rand.Read(fingerprintKey); err != nil {
+			return nil, err
+		}
+	}
+	fingerprints := payments.NewCardFingerprinter(fingerprintKey)
+	a.paymentsHandler.SetCardFingerprinter(fingerprints)
+	a.batchHandler = batch.NewHandler(a.paymentsHandler, cfg.Batches.Concurrency, cfg.Batches.MaxItems)
+
+	a.health = health.NewChecker(cfg.Health.CheckTimeout.Std())
+	a.health.AddLivenessCheck("repository_monitor", 0, a.paymentsRepo.Ping)
+	a.health.AddReadinessCheck("storage", 0, a.paymentsRepo.Ping)
+	// An instance that cannot reach any acquirer cannot authorize payments,
+	// so it is not ready. The acquirers are checked from a background probe
+	// (see Serve), so readiness probes add no load to them.
+	a.health.AddReadinessCheck("bank", 0, a.bankRouter.Health)
+	a.bankProbeInterval = cfg.Health.BankProbeInterval.Std()
+	a.healthCheckTimeout = cfg.Health.CheckTimeout.Std()
+
+	// The vault has its own store so card data never shares storage with
+	// payment records.
+	if cfg.Vault.Enabled() {
+		ring, err := keyRingFrom(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.cardVault = vault.New(ring)
+		a.keyRotation = keyring.NewJob("vault", a.cardVault, keyring.DefaultBatchSize)
+		a.tokensHandler = vault.NewTokensHandler(a.cardVault)
+		a.tokensHandler.SetAuditLog(a.auditLog)
+		a.paymentsHandler.SetCardVault(a.cardVault)
+		a.customerHandler = customer.NewHandler(a.cardVault, a.paymentsRepo)
+		a.customerHandler.SetAuditLog(a.auditLog)
+		a.paymentsHandler.SetCustomerDirectory(a.customerHandler)
+		a.subscriptionHandler = subscription.NewHandler(a.paymentsHandler, a.paymentsRepo, a.cardVault, fingerprints,
+			cfg.Subscriptions.Concurrency, retryScheduleFrom(cfg.Subscriptions))
+		a.subscriptionHandler.SetAuditLog(a.auditLog)
+		a.subscriptionPoll = cfg.Subscriptions.PollInterval.Std()
+		a.health.AddReadinessCheck("vault", 0, a.cardVault.Ping)
+		reloader.AddValidator(a.checkVaultKeys)
+	}
+
+	if cfg.ThreeDS.Enabled() {
+		a.enableThreeDS(cfg.ThreeDS)
 	}
-	a.bankClient = bank.NewBankClient(bankURL)
+
+	if cfg.Async.Enabled {
+		if err := a.enableAsync(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	reloader.Subscribe(a.applyConfig)
+	reloader.Observe(a.auditReload)
 
 	a.setupRouter()
-	return a
+	return a, nil
+}
+
+// applyConfig applies the runtime settings of a reloaded configuration.
+func (a *Api) applyConfig(cfg *config.Config) {
+	a.paymentsHandler.SetRules(rulesFrom(cfg))
+	a.rateLimits.Store(rateLimitsFrom(cfg))
+	a.bankRouter.SetTimeout(cfg.Bank.Timeout.Std())
+
+	weights := make(map[string]int, len(cfg.Bank.Acquirers))
+	for _, acq := range cfg.Bank.Acquirers {
+		weights[acq.Name] = acq.Weight
+	}
+	if err := a.bankRouter.SetWeights(weights); err != nil {
+		slog.Error("failed to apply acquirer weights", "error", err)
+	}
+
+	if a.cardVault != nil {
+		ring, err := keyRingFrom(cfg)
+		if err == nil {
+			err = a.cardVault.SetKeyRing(ring)
+			if a.queueFile != nil {
+				a.queueFile.SetKeyRing(ring)
+			}
+		}
+		if err != nil {
+			slog.Error("failed to apply vault keys", "error", err)
+		}
+	}
 }
 
+// checkVaultKeys rejects configurations that drop a vault key still in use,
+// by the vault or by the payment queue.
+func (a *Api) checkVaultKeys(cfg *config.Config) error {
+	ring, err := keyRingFrom(cfg)
+	if err != nil {
+		return err
+	}
+	if err := a.cardVault.CheckKeyRing(ring); err != nil {
+		return err
+	}
+	if a.queueFile != nil {
+		return a.queueFile.CheckKeyRing(ring)
+	}
+	return nil
+}
+
+func keyRingFrom(cfg *config.Config) (*keyring.Ring, error) {
+	keys, active, err := cfg.Vault.LoadKeys()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.New(active, keys...)
+}
+
+func rulesFrom(cfg *config.Config) *payments.Rules {
+	merchantActions := make(map[string]payments.DuplicateAction, len(cfg.Risk.Duplicates.Merchants))
+	for merchant, action := range cfg.Risk.Duplicates.Merchants {
+		merchantActions[merchant] = payments.DuplicateAction(action)
+	}
+	return payments.NewRules(cfg.Payments.AllowedCurrencies,
+		payments.WithMaxAmounts(cfg.Payments.MaxAmount),
+		payments.WithBlockedBINs(cfg.Risk.BlockedBINs),
+		payments.WithDuplicatePolicy(cfg.Risk.Duplicates.Window.Std(),
+			payments.DuplicateAction(cfg.Risk.Duplicates.Action), merchantActions),
+		payments.WithThreeDS(cfg.Risk.ThreeDS.Currencies, cfg.Risk.ThreeDS.ExemptBelow),
+	)
+}
+
+// enableThreeDS authenticates cardholders with the configured 3DS server.
+// The ACS posts challenge responses to the /api alias, which every version
+// serves.
+func (a *Api) enableThreeDS(tc config.ThreeDSConfig) {
+	publicURL := strings.TrimSuffix(tc.PublicURL, "/")
+	a.paymentsHandler.EnableThreeDS(
+		threeds.NewClient(tc.ServerURL, threeds.WithTimeout(tc.Timeout.Std())),
+		func(paymentID string) string {
+			return publicURL + "/api/payments/" + url.PathEscape(paymentID) + "/3ds/complete"
+		},
+		tc.ChallengeTimeout.Std(),
+	)
+	a.threeDS = true
+}
+
+// retryScheduleFrom converts the configured dunning delays to the retries
+// of subscription charges.
+func retryScheduleFrom(sc config.SubscriptionsConfig) []time.Duration {
+	retries := make([]time.Duration, len(sc.RetrySchedule))
+	for i, delay := range sc.RetrySchedule {
+		retries[i] = delay.Std()
+	}
+	return retries
+}
+
+// declineCodesFrom converts an acquirer's configured decline reasons, already
+// checked by config.Validate, to payments decline codes.
+func declineCodesFrom(codes map[string]string) map[string]payments.DeclineCode {
+	out := make(map[string]payments.DeclineCode, len(codes))
+	for reason, code := range codes {
+		out[reason] = payments.DeclineCode(code)
+	}
+	return out
+}
+
+// bulkheadFrom returns a new bulkhead for one acquirer, or nil when the
+// limiter is disabled.
+func bulkheadFrom(cc config.ConcurrencyConfig) *bulkhead.Bulkhead {
+	if cc.Limit == 0 {
+		return nil
+	}
+	opts := []bulkhead.Option{bulkhead.WithQueue(cc.QueueSize, cc.QueueTimeout.Std())}
+	if cc.Mode == "adaptive" {
+		opts = append(opts, bulkhead.WithAdaptive(bulkhead.Adaptive{
+			MinLimit:      cc.MinLimit,
+			MaxLimit:      cc.MaxLimit,
+			LatencyTarget: cc.LatencyTarget.Std(),
+		}))
+	}
+	return bulkhead.New(cc.Limit, opts...)
+}
+
+func transportFrom(t config.TransportConfig) bank.TransportOptions {
+	return bank.TransportOptions{
+		MaxIdleConns:        t.MaxIdleConns,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     t.MaxConnsPerHost,
+		IdleConnTimeout:     t.IdleConnTimeout.Std(),
+		KeepAlive:           t.KeepAlive.Std(),
+		DialTimeout:         t.DialTimeout.Std(),
+		TLSHandshakeTimeout: t.TLSHandshakeTimeout.Std(),
+		HTTP2:               t.HTTP2,
+	}
+}
+
+// acquirerOptions returns the transport and signing options of an acquirer,
+// whose files config.Validate has already checked.
+func acquirerOptions(acq config.AcquirerConfig) ([]bank.Option, error) {
+	var opts []bank.Option
+	if acq.TLS.Enabled() {
+		tlsConfig, err := bank.NewTLSConfig(bank.TLSOptions{
+			CertFile:    acq.TLS.CertFile,
+			KeyFile:     acq.TLS.KeyFile,
+			CAFile:      acq.TLS.CAFile,
+			PinnedCerts: acq.TLS.PinnedCerts,
+		})
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, bank.WithTLSConfig(tlsConfig))
+	}
+
+	switch acq.Signing.Method {
+	case config.SigningHMAC:
+		key, err := acq.Signing.LoadHMACKey()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, bank.WithSigner(bank.NewHMACSigner(acq.Signing.KeyID, key)))
+	case config.SigningJWS:
+		key, err := tlsconfig.LoadPrivateKey(acq.Signing.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := bank.NewJWSSigner(acq.Signing.KeyID, key)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, bank.WithSigner(signer))
+	}
+	return opts, nil
+}
+
+// Handler returns the router serving every endpoint.
+func (a *Api) Handler() http.Handler {
+	return a.router
+}
+
+// Run listens on addr and serves until ctx is done.
 func (a *Api) Run(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return a.Serve(ctx, ln)
+}
+
+// Serve serves on ln, over TLS when it is configured, until ctx is done.
+func (a *Api) Serve(ctx context.Context, ln net.Listener) error {
 	httpServer := &http.Server{
-		Addr:        addr,
 		Handler:     a.router,
-		BaseContext: func(_ net.Listener) context.Context { return ctx },
+		TLSConfig:   a.tlsConfig,
+		BaseContext: func(_ net.Listener) context.Context { return context.WithoutCancel(ctx) },
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
+	stopWorkers, workersDone := a.processQueue(ctx)
+	subscriptionsDone := make(chan struct{})
+	if a.subscriptionHandler != nil {
+		go func() {
+			defer close(subscriptionsDone)
+			a.subscriptionHandler.Run(ctx, a.subscriptionPoll)
+		}()
+	} else {
+		close(subscriptionsDone)
+	}
+
+	g.Go(func() error {
+		a.bankRouter.Probe(ctx, a.bankProbeInterval, a.healthCheckTimeout)
+		return nil
+	})
+	if a.certs != nil {
+		g.Go(func() error {
+			a.certs.Watch(ctx, a.certReloadInterval)
+			return nil
+		})
+	}
 
 	g.Go(func() error {
 		<-ctx.Done()
-		fmt.Printf("shutting down HTTP server\n")
-		return httpServer.Shutdown(ctx)
+
+		// Fail readiness first and keep serving for drainDelay so that load
+		// balancers stop sending traffic before the listener closes.
+		a.health.SetDraining(true)
+		if a.keyRotation != nil {
+			a.keyRotation.Stop()
+		}
+		slog.Info("draining HTTP server", "delay", a.drainDelay)
+		time.Sleep(a.drainDelay)
+
+		slog.Info("shutting down HTTP server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+		defer cancel()
+		err := httpServer.Shutdown(shutdownCtx)
+		// Queued payments are audited as they are processed, so the audit
+		// log is closed last.
+		drainErr := a.drainQueue(stopWorkers, workersDone)
+		a.closeNotifier()
+		batchCtx, cancelBatches := context.WithTimeout(context.Background(), a.shutdownTimeout)
+		defer cancelBatches()
+		if err := a.batchHandler.Close(batchCtx); err != nil {
+			slog.Error("payment batches still running at shutdown", "error", err)
+		}
+		<-subscriptionsDone
+		return errors.Join(err, drainErr, a.auditLog.Close())
 	})
 
 	g.Go(func() error {
-		fmt.Printf("starting HTTP server on %s\n", addr)
-		err := httpServer.ListenAndServe()
+		var err error
+		if a.tlsConfig != nil {
+			slog.Info("starting HTTPS server", "addr", ln.Addr().String(), "mutual_tls", a.tlsConfig.ClientCAs != nil)
+			err = httpServer.ServeTLS(ln, "", "")
+		} else {
+			slog.Info("starting HTTP server", "addr", ln.Addr().String())
+			err = httpServer.Serve(ln)
+		}
 		if err != nil && err != http.ErrServerClosed {
 			return err
 		}
@@ -64,11 +488,75 @@ func (a *Api) Run(ctx context.Context, addr string) error {
 
 func (a *Api) setupRouter() {
 	a.router = chi.NewRouter()
-	a.router.Use(middleware.Logger)
+	a.router.NotFound(problem.NotFound)
+	a.router.MethodNotAllowed(problem.MethodNotAllowed)
+	a.router.Use(middleware.RequestID)
+	a.router.Use(proxiedClientIP(a.clientIPHeader))
+	a.router.Use(clientCertMerchant(a.clientMerchants))
+	a.router.Use(traceRequests)
+	a.router.Use(requestLogger)
+	a.router.Use(auditRequest)
+	a.router.Use(instrumentHTTP)
 
 	a.router.Get("/ping", a.PingHandler())
+	a.router.Get("/healthz", a.health.LivenessHandler())
+	a.router.Get("/readyz", a.health.ReadinessHandler())
+	a.router.Handle("/metrics", metrics.Handler())
 	a.router.Get("/swagger/*", a.SwaggerHandler())
 
-	a.router.Get("/api/payments/{id}", a.GetPaymentHandler())
-	a.router.Post("/api/payments", a.PostPaymentHandler())
+	for _, v := range apiVersions {
+		a.router.Route("/"+v.name, func(r chi.Router) {
+			r.Use(a.pinVersion(v))
+			a.versionedRoutes(r)
+		})
+	}
+	// The unversioned paths predate versioning and are kept as an alias.
+	a.router.Route("/api", func(r chi.Router) {
+		r.Use(a.negotiateVersion)
+		a.versionedRoutes(r)
+	})
+
+	// The admin API is only exposed when a token is configured.
+	if a.adminToken != "" {
+		a.router.Route("/admin", func(r chi.Router) {
+			r.Use(requireBearerToken(a.adminToken))
+			r.Get("/config/reloads", a.ReloadHistoryHandler())
+			r.Post("/config/reload", a.ReloadConfigHandler())
+			r.Get("/audit", a.AuditEventsHandler())
+			r.Get("/audit/verify", a.VerifyAuditHandler())
+			if a.cardVault != nil {
+				r.Get("/keys", a.KeysStatusHandler())
+				r.Post("/keys/rotation", a.StartKeyRotationHandler())
+				r.Delete("/keys/rotation", a.StopKeyRotationHandler())
+			}
+		})
+	}
+}
+
+// versionedRoutes registers the routes served by every API version.
+func (a *Api) versionedRoutes(r chi.Router) {
+	r.With(a.rateLimit(endpointGetPayment)).Get("/payments/{id}", a.GetPaymentHandler())
+	r.With(a.rateLimit(endpointCreatePayment)).Post("/payments", a.PostPaymentHandler())
+	r.With(a.rateLimit(endpointGetPaymentBatch)).Get("/payment-batches/{id}", a.GetPaymentBatchHandler())
+	r.With(a.rateLimit(endpointCreatePaymentBatch)).Post("/payment-batches", a.PostPaymentBatchHandler())
+	if a.tokensHandler != nil {
+		r.With(a.rateLimit(endpointCreateToken)).Post("/tokens", a.PostTokenHandler())
+	}
+	if a.subscriptionHandler != nil {
+		r.With(a.rateLimit(endpointCreateSubscription)).Post("/subscriptions", a.PostSubscriptionHandler())
+		r.With(a.rateLimit(endpointGetSubscription)).Get("/subscriptions/{id}", a.GetSubscriptionHandler())
+		r.With(a.rateLimit(endpointCancelSubscription)).Delete("/subscriptions/{id}", a.DeleteSubscriptionHandler())
+	}
+	if a.customerHandler != nil {
+		r.With(a.rateLimit(endpointCreateCustomer)).Post("/customers", a.PostCustomerHandler())
+		r.With(a.rateLimit(endpointGetCustomer)).Get("/customers/{id}", a.GetCustomerHandler())
+		r.With(a.rateLimit(endpointUpdateCustomer)).Patch("/customers/{id}", a.PatchCustomerHandler())
+		r.With(a.rateLimit(endpointDeleteCustomer)).Delete("/customers/{id}", a.DeleteCustomerHandler())
+		r.With(a.rateLimit(endpointUpdateCustomer)).Post("/customers/{id}/payment-methods", a.PostPaymentMethodHandler())
+		r.With(a.rateLimit(endpointUpdateCustomer)).Delete("/customers/{id}/payment-methods/{method_id}", a.DeletePaymentMethodHandler())
+		r.With(a.rateLimit(endpointListCustomerPayments)).Get("/customers/{id}/payments", a.GetCustomerPaymentsHandler())
+	}
+	if a.threeDS {
+		r.With(a.rateLimit(endpointCompleteAuthentication)).Post("/payments/{id}/3ds/complete", a.CompleteAuthenticationHandler())
+	}
 }
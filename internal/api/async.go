@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/jobqueue"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/webhook"
+)
+
+// enableAsync sets up the queue of asynchronous payments, journaled when
+// async.queue_file is set, and the merchant callbacks.
+func (a *Api) enableAsync(cfg *config.Config) error {
+	var queue payments.JobQueue = jobqueue.NewMemory(cfg.Async.QueueSize)
+	if cfg.Async.QueueFile != "" {
+		ring, err := keyRingFrom(cfg)
+		if err != nil {
+			return err
+		}
+		file, err := jobqueue.OpenFile(cfg.Async.QueueFile, cfg.Async.QueueSize, ring)
+		if err != nil {
+			return err
+		}
+		if n := len(file.Recovered()); n > 0 {
+			slog.Info("recovered queued payments", "count", n, "file", cfg.Async.QueueFile)
+		}
+		a.queueFile = file
+		queue = file
+	}
+
+	var notifier payments.Notifier
+	if len(cfg.Async.Callbacks) > 0 {
+		endpoints := make(map[string]webhook.Endpoint, len(cfg.Async.Callbacks))
+		for merchant, cb := range cfg.Async.Callbacks {
+			endpoints[merchant] = webhook.Endpoint{URL: cb.URL, Secret: []byte(cb.Secret.Value())}
+		}
+		// Callbacks are not tied to a request, so they use the oldest
+		// version still served.
+		a.notifier = webhook.New(endpoints, func(p *payments.Payment) any { return v1.PaymentFromDomain(p) })
+		notifier = a.notifier
+	}
+
+	a.jobQueue = queue
+	a.asyncWorkers = cfg.Async.Workers
+	a.queueDrainTimeout = cfg.Async.DrainTimeout.Std()
+	a.paymentsHandler.EnableAsync(queue, notifier)
+	return nil
+}
+
+// processQueue runs the async workers until stop is called or the queue is
+// closed and empty. It returns a channel closed once they have all stopped.
+func (a *Api) processQueue(ctx context.Context) (stop func(), done <-chan struct{}) {
+	finished := make(chan struct{})
+	if a.jobQueue == nil {
+		close(finished)
+		return func() {}, finished
+	}
+
+	ctx, stop = context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		defer close(finished)
+		a.paymentsHandler.ProcessQueue(ctx, a.asyncWorkers)
+	}()
+	return stop, finished
+}
+
+// drainQueue stops the queue taking payments and gives the workers up to
+// queueDrainTimeout to process those left, then stops them.
+func (a *Api) drainQueue(stop func(), done <-chan struct{}) error {
+	if a.jobQueue == nil {
+		return nil
+	}
+	a.jobQueue.Close()
+	slog.Info("draining payment queue", "pending", a.jobQueue.Len(), "timeout", a.queueDrainTimeout)
+
+	timer := time.NewTimer(a.queueDrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		stop()
+		<-done
+		if a.queueFile != nil {
+			slog.Warn("payment queue not drained, the payments left resume on restart", "pending", a.jobQueue.Len())
+		} else {
+			slog.Error("payment queue not drained, the payments left are lost", "pending", a.jobQueue.Len())
+		}
+	}
+	stop()
+
+	if a.queueFile != nil {
+		return a.queueFile.CloseJournal()
+	}
+	return nil
+}
+
+// closeNotifier gives the callbacks still queued, including those of the
+// payments drained from the queue, up to shutdownTimeout to be delivered.
+func (a *Api) closeNotifier() {
+	if a.notifier == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	if err := a.notifier.Close(ctx); err != nil {
+		slog.Warn("payment callbacks not delivered before shutdown", "error", err)
+	}
+}
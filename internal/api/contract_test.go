@@ -0,0 +1,463 @@
+package api_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/threeds"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adminToken = "contract-test-token"
+
+// schema is the subset of a Swagger 2.0 schema object generated by swag.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	AllOf                []*schema          `json:"allOf"`
+}
+
+type operation struct {
+	Responses map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"responses"`
+}
+
+type spec struct {
+	Paths       map[string]map[string]operation `json:"paths"`
+	Definitions map[string]*schema              `json:"definitions"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	data, err := os.ReadFile("../../docs/swagger.json")
+	require.NoError(t, err)
+	var s spec
+	require.NoError(t, json.Unmarshal(data, &s))
+	return &s
+}
+
+// validate returns every way v does not conform to s. Objects with declared
+// properties must not carry undeclared ones, so a field added to a model
+// without regenerating the docs is caught.
+func (sp *spec) validate(at string, v any, s *schema) []string {
+	if s.Ref != "" {
+		def, ok := sp.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown definition %s", at, s.Ref)}
+		}
+		return sp.validate(at, v, def)
+	}
+	var errs []string
+	for _, sub := range s.AllOf {
+		errs = append(errs, sp.validate(at, v, sub)...)
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, v) {
+		errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", at, v, s.Enum))
+	}
+
+	switch s.Type {
+	case "":
+		return errs
+	case "string":
+		if _, ok := v.(string); !ok {
+			errs = append(errs, fmt.Sprintf("%s: want string, got %T", at, v))
+		}
+	case "integer":
+		if n, ok := v.(json.Number); !ok || strings.ContainsAny(n.String(), ".eE") {
+			errs = append(errs, fmt.Sprintf("%s: want integer, got %v", at, v))
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			errs = append(errs, fmt.Sprintf("%s: want number, got %T", at, v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: want boolean, got %T", at, v))
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: want array, got %T", at, v))
+		}
+		for i, item := range items {
+			errs = append(errs, sp.validate(fmt.Sprintf("%s[%d]", at, i), item, s.Items)...)
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: want object, got %T", at, v))
+		}
+		for _, key := range sortedKeys(obj) {
+			switch prop, declared := s.Properties[key]; {
+			case declared:
+				errs = append(errs, sp.validate(at+"."+key, obj[key], prop)...)
+			case s.AdditionalProperties != nil:
+				errs = append(errs, sp.validate(at+"."+key, obj[key], s.AdditionalProperties)...)
+			default:
+				errs = append(errs, fmt.Sprintf("%s: undocumented property %q", at, key))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s: unsupported schema type %q", at, s.Type))
+	}
+	return errs
+}
+
+func contains(values []any, v any) bool {
+	for _, want := range values {
+		if fmt.Sprint(want) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fakeBank answers like the bank simulator: cards ending in an odd digit are
+// authorized, 2 is declined for insufficient funds and 0 is unavailable.
+// Stored-credential payments get a network transaction ID.
+func fakeBank(w http.ResponseWriter, r *http.Request) {
+	var req bank.BankPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch last := req.CardNumber[len(req.CardNumber)-1]; {
+	case last == '0':
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case (last-'0')%2 == 1:
+		resp := bank.BankPaymentResponse{Authorized: true, AuthorizationCode: "A1"}
+		if req.StoredCredential != nil {
+			resp.NetworkTransactionID = "NTI-" + req.CardNumber[len(req.CardNumber)-4:]
+		}
+		json.NewEncoder(w).Encode(resp)
+	default:
+		json.NewEncoder(w).Encode(bank.BankPaymentResponse{ErrorMessage: "Insufficient funds"})
+	}
+}
+
+// newGateway starts a gateway in front of fakeBank. extraConfig is appended
+// to its config file.
+func newGateway(t *testing.T, extraConfig string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newAPI(t, extraConfig).Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newAPI builds a gateway backed by a fake bank, with extraConfig appended
+// to its configuration file. Payments of 1000.00 USD or more are
+// authenticated by the 3-D Secure simulator.
+func newAPI(t *testing.T, extraConfig string) *api.Api {
+	t.Helper()
+	bankServer := httptest.NewServer(http.HandlerFunc(fakeBank))
+	t.Cleanup(bankServer.Close)
+	acsServer := httptest.NewServer(threeds.NewSimulator())
+	t.Cleanup(acsServer.Close)
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`
+bank:
+  acquirers:
+    - {name: primary, url: %q, weight: 1}
+payments:
+  allowed_currencies: [USD]
+risk:
+  duplicates: {action: reject}
+  three_ds:
+    currencies: [USD]
+    exempt_below: {USD: 100000}
+three_ds:
+  server_url: %q
+  public_url: http://gateway.test
+`, bankServer.URL, acsServer.URL)+extraConfig), 0o600))
+
+	environment := map[string]string{
+		"ADMIN_TOKEN":      adminToken,
+		"VAULT_MASTER_KEY": base64.StdEncoding.EncodeToString(key),
+		"FINGERPRINT_KEY":  base64.StdEncoding.EncodeToString(key),
+	}
+	load := func() (*config.Config, error) {
+		cfg, _, err := config.Load([]string{"--config", path}, func(k string) string { return environment[k] })
+		return cfg, err
+	}
+	cfg, err := load()
+	require.NoError(t, err)
+
+	gateway, err := api.New(config.NewReloader(cfg, load))
+	require.NoError(t, err)
+	return gateway
+}
+
+func TestContract(t *testing.T) {
+	sp := loadSpec(t)
+	server := newGateway(t, "")
+
+	send := func(method, path, body string, admin bool) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if strings.HasSuffix(path, "/3ds/complete") {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if admin {
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, data
+	}
+	payment := func(card string, amount int) string {
+		return fmt.Sprintf(`{"card_number":%q,"expiry_month":12,"expiry_year":2099,"currency":"USD","amount":%d,"cvv":"123"}`, card, amount)
+	}
+
+	_, created := send(http.MethodPost, "/api/payments", payment("2222405343248871", 100), false)
+	var stored struct{ ID string }
+	require.NoError(t, json.Unmarshal(created, &stored))
+	_, tokenBody := send(http.MethodPost, "/api/tokens", `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099}`, false)
+	var token struct{ Token string }
+	require.NoError(t, json.Unmarshal(tokenBody, &token))
+	batch := func(payments ...string) string {
+		return `{"payments":[` + strings.Join(payments, ",") + `]}`
+	}
+	_, batchBody := send(http.MethodPost, "/api/payment-batches", batch(payment("2222405343248871", 700), payment("2222405343248872", 700), payment("2222405343248871", -1)), false)
+	var storedBatch struct{ ID string }
+	require.NoError(t, json.Unmarshal(batchBody, &storedBatch))
+	_, initialBody := send(http.MethodPost, "/api/payments", `{"card_token":"`+token.Token+`","currency":"USD","amount":1000,"cvv":"123",
+		"stored_credential":{"initiator":"customer","sequence":"initial"}}`, false)
+	var initial struct{ ID string }
+	require.NoError(t, json.Unmarshal(initialBody, &initial))
+	subscription := func(initialPaymentID string) string {
+		return `{"card_token":"` + token.Token + `","amount":1000,"currency":"USD","interval":"month","initial_payment_id":"` + initialPaymentID + `"}`
+	}
+	_, subscriptionBody := send(http.MethodPost, "/api/subscriptions", subscription(initial.ID), false)
+	var storedSubscription struct{ ID string }
+	require.NoError(t, json.Unmarshal(subscriptionBody, &storedSubscription))
+	_, otherTokenBody := send(http.MethodPost, "/api/tokens", `{"card_number":"2222405343248879","expiry_month":4,"expiry_year":2099}`, false)
+	var otherToken struct{ Token string }
+	require.NoError(t, json.Unmarshal(otherTokenBody, &otherToken))
+	newCustomer := func() (customerID, methodID string) {
+		t.Helper()
+		_, body := send(http.MethodPost, "/api/customers", `{"name":"Ada Lovelace","email":"ada@example.com","currency":"USD"}`, false)
+		var c struct{ ID string }
+		require.NoError(t, json.Unmarshal(body, &c))
+		_, body = send(http.MethodPost, "/api/customers/"+c.ID+"/payment-methods", `{"card_token":"`+token.Token+`"}`, false)
+		var m struct{ ID string }
+		require.NoError(t, json.Unmarshal(body, &m))
+		return c.ID, m.ID
+	}
+	customerID, methodID := newCustomer()
+	otherCustomerID, otherMethodID := newCustomer()
+	spareCustomerID, _ := newCustomer()
+	paymentMethod := func(token string) string { return `{"card_token":"` + token + `"}` }
+	// challenged creates a payment whose cardholder is challenged, completes
+	// the challenge at the simulator and returns the challenge response the
+	// cardholder's browser would post back.
+	challenged := func(amount int) (paymentID, cres string) {
+		t.Helper()
+		_, body := send(http.MethodPost, "/api/payments", payment("2222405343248891", amount), false)
+		var p struct {
+			ID      string
+			ThreeDS struct {
+				ChallengeURL string `json:"challenge_url"`
+			} `json:"three_ds"`
+		}
+		require.NoError(t, json.Unmarshal(body, &p))
+		resp, err := http.PostForm(p.ThreeDS.ChallengeURL, url.Values{"otp": {threeds.SimulatorOTP}})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		page, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		m := regexp.MustCompile(`name="cres" value="([^"]+)"`).FindSubmatch(page)
+		require.NotNil(t, m, string(page))
+		return p.ID, "cres=" + string(m[1])
+	}
+	challengedID, challengeResponse := challenged(100000)
+	v1ChallengedID, v1ChallengeResponse := challenged(100100)
+
+	tests := []struct {
+		name       string
+		method     string
+		route      string
+		path       string
+		body       string
+		admin      bool
+		wantStatus int
+	}{
+		{"Ping", "GET", "/ping", "/ping", "", false, 200},
+		{"Liveness", "GET", "/healthz", "/healthz", "", false, 200},
+		{"Readiness before the acquirers are probed", "GET", "/readyz", "/readyz", "", false, 503},
+		{"Payment authorized", "POST", "/api/payments", "/api/payments", payment("2222405343248873", 200), false, 200},
+		{"Payment declined", "POST", "/api/payments", "/api/payments", payment("2222405343248872", 300), false, 200},
+		{"Payment with token", "POST", "/api/payments", "/api/payments", `{"card_token":"` + token.Token + `","currency":"USD","amount":400,"cvv":"123"}`, false, 200},
+		{"Payment malformed", "POST", "/api/payments", "/api/payments", "{", false, 400},
+		{"Payment invalid", "POST", "/api/payments", "/api/payments", payment("2222405343248871", -1), false, 400},
+		{"Payment unknown token", "POST", "/api/payments", "/api/payments", `{"card_token":"tok_missing","currency":"USD","amount":100,"cvv":"123"}`, false, 400},
+		{"Payment duplicate", "POST", "/api/payments", "/api/payments", payment("2222405343248871", 100), false, 409},
+		{"Payment bank unavailable", "POST", "/api/payments", "/api/payments", payment("2222405343248870", 100), false, 502},
+		{"Payment requires action", "POST", "/api/payments", "/api/payments", payment("2222405343248891", 100200), false, 200},
+		{"Complete authentication", "POST", "/api/payments/{id}/3ds/complete", "/api/payments/" + challengedID + "/3ds/complete", challengeResponse, false, 200},
+		{"Complete authentication twice", "POST", "/api/payments/{id}/3ds/complete", "/api/payments/" + challengedID + "/3ds/complete", challengeResponse, false, 409},
+		{"Complete authentication malformed", "POST", "/api/payments/{id}/3ds/complete", "/api/payments/" + v1ChallengedID + "/3ds/complete", "cres=%21", false, 400},
+		{"Complete authentication missing payment", "POST", "/api/payments/{id}/3ds/complete", "/api/payments/missing/3ds/complete", challengeResponse, false, 404},
+		{"v1 complete authentication", "POST", "/v1/payments/{id}/3ds/complete", "/v1/payments/" + v1ChallengedID + "/3ds/complete", v1ChallengeResponse, false, 200},
+		{"Get payment", "GET", "/api/payments/{id}", "/api/payments/" + stored.ID, "", false, 200},
+		{"Get missing payment", "GET", "/api/payments/{id}", "/api/payments/missing", "", false, 404},
+		{"Tokenize", "POST", "/api/tokens", "/api/tokens", `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099}`, false, 201},
+		{"Tokenize invalid", "POST", "/api/tokens", "/api/tokens", `{"card_number":"12","expiry_month":4,"expiry_year":2099}`, false, 400},
+		{"v1 payment authorized", "POST", "/v1/payments", "/v1/payments", payment("2222405343248875", 500), false, 200},
+		{"v1 payment declined", "POST", "/v1/payments", "/v1/payments", payment("2222405343248874", 600), false, 200},
+		{"v1 payment invalid", "POST", "/v1/payments", "/v1/payments", payment("2222405343248871", -1), false, 400},
+		{"v1 get payment", "GET", "/v1/payments/{id}", "/v1/payments/" + stored.ID, "", false, 200},
+		{"v1 get missing payment", "GET", "/v1/payments/{id}", "/v1/payments/missing", "", false, 404},
+		{"v1 tokenize", "POST", "/v1/tokens", "/v1/tokens", `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099}`, false, 201},
+		{"Batch", "POST", "/api/payment-batches", "/api/payment-batches", batch(payment("2222405343248873", 800)), false, 202},
+		{"Batch malformed", "POST", "/api/payment-batches", "/api/payment-batches", `{"payments":`, false, 400},
+		{"Batch empty", "POST", "/api/payment-batches", "/api/payment-batches", batch(), false, 400},
+		{"Get batch", "GET", "/api/payment-batches/{id}", "/api/payment-batches/" + storedBatch.ID, "", false, 200},
+		{"Get missing batch", "GET", "/api/payment-batches/{id}", "/api/payment-batches/missing", "", false, 404},
+		{"v1 batch", "POST", "/v1/payment-batches", "/v1/payment-batches", batch(payment("2222405343248875", 900)), false, 202},
+		{"v1 get batch", "GET", "/v1/payment-batches/{id}", "/v1/payment-batches/" + storedBatch.ID, "", false, 200},
+		{"Merchant-initiated payment", "POST", "/api/payments", "/api/payments", `{"card_token":"` + token.Token + `","currency":"USD","amount":1100,
+			"stored_credential":{"initiator":"merchant","sequence":"subsequent","reason":"unscheduled","network_transaction_id":"NTI-8877"}}`, false, 200},
+		{"Subscription", "POST", "/api/subscriptions", "/api/subscriptions", subscription(initial.ID), false, 201},
+		{"Subscription malformed", "POST", "/api/subscriptions", "/api/subscriptions", "{", false, 400},
+		{"Subscription without initial payment", "POST", "/api/subscriptions", "/api/subscriptions", subscription(stored.ID), false, 400},
+		{"Get subscription", "GET", "/api/subscriptions/{id}", "/api/subscriptions/" + storedSubscription.ID, "", false, 200},
+		{"Get missing subscription", "GET", "/api/subscriptions/{id}", "/api/subscriptions/missing", "", false, 404},
+		{"v1 subscription", "POST", "/v1/subscriptions", "/v1/subscriptions", subscription(initial.ID), false, 201},
+		{"v1 get subscription", "GET", "/v1/subscriptions/{id}", "/v1/subscriptions/" + storedSubscription.ID, "", false, 200},
+		{"Cancel subscription", "DELETE", "/api/subscriptions/{id}", "/api/subscriptions/" + storedSubscription.ID, "", false, 200},
+		{"Cancel missing subscription", "DELETE", "/api/subscriptions/{id}", "/api/subscriptions/missing", "", false, 404},
+		{"v1 cancel subscription", "DELETE", "/v1/subscriptions/{id}", "/v1/subscriptions/" + storedSubscription.ID, "", false, 200},
+		{"Customer", "POST", "/api/customers", "/api/customers", `{"name":"Grace Hopper","currency":"USD"}`, false, 201},
+		{"Customer malformed", "POST", "/api/customers", "/api/customers", "{", false, 400},
+		{"Customer invalid", "POST", "/api/customers", "/api/customers", `{"email":"not an email"}`, false, 400},
+		{"v1 customer", "POST", "/v1/customers", "/v1/customers", `{"name":"Grace Hopper"}`, false, 201},
+		{"Get customer", "GET", "/api/customers/{id}", "/api/customers/" + customerID, "", false, 200},
+		{"Get missing customer", "GET", "/api/customers/{id}", "/api/customers/missing", "", false, 404},
+		{"v1 get customer", "GET", "/v1/customers/{id}", "/v1/customers/" + customerID, "", false, 200},
+		{"Update customer", "PATCH", "/api/customers/{id}", "/api/customers/" + customerID, `{"name":"Ada King"}`, false, 200},
+		{"Update customer unknown default", "PATCH", "/api/customers/{id}", "/api/customers/" + customerID, `{"default_payment_method_id":"pm_missing"}`, false, 400},
+		{"Update missing customer", "PATCH", "/api/customers/{id}", "/api/customers/missing", `{"name":"Ada King"}`, false, 404},
+		{"v1 update customer", "PATCH", "/v1/customers/{id}", "/v1/customers/" + customerID, `{"email":"ada@example.org"}`, false, 200},
+		{"Save payment method", "POST", "/api/customers/{id}/payment-methods", "/api/customers/" + customerID + "/payment-methods", paymentMethod(otherToken.Token), false, 201},
+		{"Save payment method twice", "POST", "/api/customers/{id}/payment-methods", "/api/customers/" + customerID + "/payment-methods", paymentMethod(token.Token), false, 409},
+		{"Save payment method unknown token", "POST", "/api/customers/{id}/payment-methods", "/api/customers/" + customerID + "/payment-methods", paymentMethod("tok_missing"), false, 400},
+		{"Save payment method missing customer", "POST", "/api/customers/{id}/payment-methods", "/api/customers/missing/payment-methods", paymentMethod(token.Token), false, 404},
+		{"v1 save payment method", "POST", "/v1/customers/{id}/payment-methods", "/v1/customers/" + otherCustomerID + "/payment-methods", paymentMethod(otherToken.Token), false, 201},
+		{"Payment by customer", "POST", "/api/payments", "/api/payments", `{"customer_id":"` + customerID + `","amount":1200,"cvv":"123"}`, false, 200},
+		{"Payment by customer method", "POST", "/api/payments", "/api/payments", `{"customer_id":"` + customerID + `","payment_method_id":"` + methodID + `","amount":1300,"cvv":"123"}`, false, 200},
+		{"Payment by missing customer", "POST", "/api/payments", "/api/payments", `{"customer_id":"missing","amount":1200,"cvv":"123"}`, false, 400},
+		{"v1 payment by customer", "POST", "/v1/payments", "/v1/payments", `{"customer_id":"` + customerID + `","amount":1400,"cvv":"123"}`, false, 200},
+		{"Customer payments", "GET", "/api/customers/{id}/payments", "/api/customers/" + customerID + "/payments?limit=2", "", false, 200},
+		{"Customer payments invalid", "GET", "/api/customers/{id}/payments", "/api/customers/" + customerID + "/payments?limit=0", "", false, 400},
+		{"Missing customer payments", "GET", "/api/customers/{id}/payments", "/api/customers/missing/payments", "", false, 404},
+		{"v1 customer payments", "GET", "/v1/customers/{id}/payments", "/v1/customers/" + customerID + "/payments", "", false, 200},
+		{"Remove payment method", "DELETE", "/api/customers/{id}/payment-methods/{method_id}", "/api/customers/" + otherCustomerID + "/payment-methods/" + otherMethodID, "", false, 204},
+		{"Remove missing payment method", "DELETE", "/api/customers/{id}/payment-methods/{method_id}", "/api/customers/" + otherCustomerID + "/payment-methods/pm_missing", "", false, 404},
+		{"v1 remove payment method", "DELETE", "/v1/customers/{id}/payment-methods/{method_id}", "/v1/customers/" + customerID + "/payment-methods/" + methodID, "", false, 204},
+		{"Delete customer", "DELETE", "/api/customers/{id}", "/api/customers/" + spareCustomerID, "", false, 204},
+		{"Delete missing customer", "DELETE", "/api/customers/{id}", "/api/customers/missing", "", false, 404},
+		{"v1 delete customer", "DELETE", "/v1/customers/{id}", "/v1/customers/" + otherCustomerID, "", false, 204},
+		{"Reload history", "GET", "/admin/config/reloads", "/admin/config/reloads", "", true, 200},
+		{"Reload history without token", "GET", "/admin/config/reloads", "/admin/config/reloads", "", false, 401},
+		{"Reload", "POST", "/admin/config/reload", "/admin/config/reload", "", true, 200},
+		{"Keys", "GET", "/admin/keys", "/admin/keys", "", true, 200},
+		{"Start rotation", "POST", "/admin/keys/rotation", "/admin/keys/rotation", "", true, 202},
+		{"Stop rotation", "DELETE", "/admin/keys/rotation", "/admin/keys/rotation", "", true, 200},
+		{"Audit events", "GET", "/admin/audit", "/admin/audit?action=payment.created&limit=5", "", true, 200},
+		{"Audit events invalid", "GET", "/admin/audit", "/admin/audit?limit=0", "", true, 400},
+		{"Audit verify", "GET", "/admin/audit/verify", "/admin/audit/verify", "", true, 200},
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := send(tt.method, tt.path, tt.body, tt.admin)
+			require.Equal(t, tt.wantStatus, resp.StatusCode, string(body))
+
+			op, ok := sp.Paths[tt.route][strings.ToLower(tt.method)]
+			require.True(t, ok, "%s %s is not documented", tt.method, tt.route)
+			covered[tt.method+" "+tt.route] = true
+
+			documented, ok := op.Responses[strconv.Itoa(resp.StatusCode)]
+			require.True(t, ok, "status %d of %s %s is not documented", resp.StatusCode, tt.method, tt.route)
+			if documented.Schema == nil {
+				return
+			}
+			assert.Contains(t, resp.Header.Get("Content-Type"), "json")
+
+			var v any
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			require.NoError(t, decoder.Decode(&v))
+			assert.Empty(t, sp.validate("body", v, documented.Schema))
+		})
+	}
+
+	for path, methods := range sp.Paths {
+		for method := range methods {
+			assert.True(t, covered[strings.ToUpper(method)+" "+path], "%s %s has no contract test", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestContract_EveryRouteIsDocumented(t *testing.T) {
+	sp := loadSpec(t)
+	routes, ok := newGateway(t, "").Config.Handler.(chi.Routes)
+	require.True(t, ok)
+
+	// /metrics and /swagger serve their own formats and are not part of the
+	// API contract.
+	undocumented := map[string]bool{"/metrics": true, "/swagger/*": true}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if undocumented[route] {
+			return nil
+		}
+		_, ok := sp.Paths[route][strings.ToLower(method)]
+		assert.True(t, ok, "%s %s is served but not documented", method, route)
+		return nil
+	})
+	require.NoError(t, err)
+}
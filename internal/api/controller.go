@@ -6,7 +6,6 @@ import (
 	"net/http"
 
 	"github.com/LuizZucchi/payment-gateway-challenge-go/docs"
-	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
@@ -15,6 +14,11 @@ type pong struct {
 }
 
 // PingHandler returns an http.HandlerFunc that handles HTTP Ping GET requests.
+//
+//	@Summary	Ping the gateway
+//	@Produce	json
+//	@Success	200	{object}	api.pong
+//	@Router		/ping [get]
 func (a *Api) PingHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -33,12 +37,371 @@ func (a *Api) SwaggerHandler() http.HandlerFunc {
 }
 
 // GetPaymentHandler returns an http.HandlerFunc that handles Payments GET requests.
+//
+//	@Summary		Get a payment
+//	@Description	/api/payments/{id} is an alias answering in the version given by API-Version or Accept, v1 by default.
+//	@Tags			payments
+//	@Produce		json
+//	@Param			id			path		string	true	"Payment ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.Payment
+//	@Failure		404			{object}	problem.Problem	"payment_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/payments/{id} [get]
+//	@Router			/api/payments/{id} [get]
 func (a *Api) GetPaymentHandler() http.HandlerFunc {
-	h := payments.NewPaymentsHandler(a.paymentsRepo, a.bankClient)
-	return h.GetHandler()
+	return a.paymentsHandler.GetHandler()
 }
 
+// PostPaymentHandler returns an http.HandlerFunc that handles Payments POST requests.
+//
+//	@Summary		Process a payment
+//	@Description	Authorized and Declined payments both answer 200; declined ones carry decline_code and decline_type.
+//	@Description	With "Prefer: respond-async", when asynchronous payments are enabled, the payment is queued and answered 202 as Pending; poll the Location or wait for the merchant callback.
+//	@Description	When 3-D Secure is enabled and the risk rules or the issuer require it, the cardholder is authenticated first. A payment whose cardholder must complete a challenge answers 200 as RequiresAction: send the cardholder's browser to three_ds.challenge_url. Failed authentications are Declined with decline_code authentication_failed.
+//	@Description	/api/payments is an alias answering in the version given by API-Version or Accept, v1 by default.
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			payment			body		v1.PaymentRequest	true	"Card and amount, or a card token"
+//	@Param			X-Merchant-Id	header		string				false	"Merchant, for per-merchant duplicate rules"
+//	@Param			Prefer			header		string				false	"respond-async to process the payment asynchronously"
+//	@Param			API-Version		header		string				false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200				{object}	v1.Payment
+//	@Success		202				{object}	v1.Payment
+//	@Header			202				{string}	Location		"Path of the queued payment"
+//	@Failure		400				{object}	problem.Problem	"malformed_request, validation_failed, card_token_not_found or card_tokens_disabled"
+//	@Failure		406				{object}	problem.Problem	"unsupported_version"
+//	@Failure		409				{object}	problem.Problem	"duplicate_payment"
+//	@Failure		410				{object}	problem.Problem	"version_retired"
+//	@Failure		429				{object}	problem.Problem	"rate_limited"
+//	@Header			429				{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Failure		500				{object}	problem.Problem	"internal_error"
+//	@Failure		502				{object}	problem.Problem	"upstream_unavailable or authentication_unavailable"
+//	@Failure		503				{object}	problem.Problem	"upstream_overloaded or queue_full"
+//	@Header			503				{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/payments [post]
+//	@Router			/api/payments [post]
 func (a *Api) PostPaymentHandler() http.HandlerFunc {
-	h := payments.NewPaymentsHandler(a.paymentsRepo, a.bankClient)
-	return h.PostHandler()
+	return a.paymentsHandler.PostHandler()
+}
+
+// CompleteAuthenticationHandler returns an http.HandlerFunc that resumes a payment once its cardholder completed a 3-D Secure challenge.
+//
+//	@Summary		Complete a 3-D Secure challenge
+//	@Description	Notification URL of 3-D Secure challenges, to which the ACS posts the challenge response from the cardholder's browser. The outcome is fetched from the 3DS server, and the authenticated payment is sent to the acquirer.
+//	@Description	The cardholder is redirected to the payment's three_ds.return_url with the payment_id query parameter when it has one; the payment is answered otherwise. Challenges not completed in time fail the payment with authentication_expired.
+//	@Description	Served when 3-D Secure is enabled.
+//	@Tags			payments
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			id			path		string	true	"Payment ID"
+//	@Param			cres		formData	string	true	"Base64url-encoded challenge response (CRes)"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.Payment
+//	@Success		303			"Redirect to the return URL"
+//	@Header			303			{string}	Location		"three_ds.return_url with payment_id"
+//	@Failure		400			{object}	problem.Problem	"malformed_request or validation_failed"
+//	@Failure		404			{object}	problem.Problem	"payment_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		409			{object}	problem.Problem	"conflict or authentication_expired"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Failure		502			{object}	problem.Problem	"upstream_unavailable or authentication_unavailable"
+//	@Router			/v1/payments/{id}/3ds/complete [post]
+//	@Router			/api/payments/{id}/3ds/complete [post]
+func (a *Api) CompleteAuthenticationHandler() http.HandlerFunc {
+	return a.paymentsHandler.CompleteAuthenticationHandler()
+}
+
+// GetPaymentBatchHandler returns an http.HandlerFunc that reports the progress of a payment batch.
+//
+//	@Summary		Get a payment batch
+//	@Description	Progress counts and the outcome of every payment of the batch, in submission order. Stored payments can be retrieved with GET /payments/{id}.
+//	@Tags			payments
+//	@Produce		json
+//	@Param			id			path		string	true	"Batch ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.PaymentBatch
+//	@Failure		404			{object}	problem.Problem	"batch_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/payment-batches/{id} [get]
+//	@Router			/api/payment-batches/{id} [get]
+func (a *Api) GetPaymentBatchHandler() http.HandlerFunc {
+	return a.batchHandler.GetHandler()
+}
+
+// PostPaymentBatchHandler returns an http.HandlerFunc that accepts a payment batch.
+//
+//	@Summary		Submit a payment batch
+//	@Description	Accepts up to batches.max_items payments as a JSON body, NDJSON (application/x-ndjson, one payment request per line), CSV (text/csv, with a header row naming the payment request fields), or a file uploaded as multipart/form-data in the "file" field.
+//	@Description	Each payment is validated and authorized like POST /payments, in the background; poll the Location for progress.
+//	@Tags			payments
+//	@Accept			json
+//	@Accept			text/csv
+//	@Accept			application/x-ndjson
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			batch			body		v1.PaymentBatchRequest	true	"Payments, in JSON"
+//	@Param			X-Merchant-Id	header		string					false	"Merchant, for per-merchant duplicate rules"
+//	@Param			API-Version		header		string					false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		202				{object}	v1.PaymentBatch
+//	@Header			202				{string}	Location		"Path of the batch"
+//	@Failure		400				{object}	problem.Problem	"malformed_request or validation_failed"
+//	@Failure		406				{object}	problem.Problem	"unsupported_version"
+//	@Failure		410				{object}	problem.Problem	"version_retired"
+//	@Failure		413				{object}	problem.Problem	"batch_too_large"
+//	@Failure		415				{object}	problem.Problem	"unsupported_media_type"
+//	@Failure		429				{object}	problem.Problem	"rate_limited"
+//	@Header			429				{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/payment-batches [post]
+//	@Router			/api/payment-batches [post]
+func (a *Api) PostPaymentBatchHandler() http.HandlerFunc {
+	return a.batchHandler.PostHandler()
+}
+
+// PostTokenHandler returns an http.HandlerFunc that handles card tokenization requests.
+//
+//	@Summary		Tokenize a card
+//	@Description	Exchanges card data for an opaque token usable as card_token in payments. Only served when the vault is enabled. The CVV is never stored.
+//	@Tags			tokens
+//	@Accept			json
+//	@Produce		json
+//	@Param			card		body		v1.TokenRequest	true	"Card to tokenize"
+//	@Param			API-Version	header		string			false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		201			{object}	v1.Token
+//	@Failure		400			{object}	problem.Problem	"malformed_request or validation_failed"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Failure		500			{object}	problem.Problem	"internal_error"
+//	@Router			/v1/tokens [post]
+//	@Router			/api/tokens [post]
+func (a *Api) PostTokenHandler() http.HandlerFunc {
+	return a.tokensHandler.PostHandler()
+}
+
+// PostSubscriptionHandler returns an http.HandlerFunc that creates a subscription.
+//
+//	@Summary		Create a subscription
+//	@Description	Charges a card token the same amount every billing period, with merchant-initiated payments linked to an authorized initial payment sent with stored_credential {"initiator": "customer", "sequence": "initial"}. Only served when the vault is enabled.
+//	@Description	Charges softly declined or failed are retried after each delay of subscriptions.retry_schedule; the subscription is suspended when they are exhausted or a charge is declined for good.
+//	@Tags			subscriptions
+//	@Accept			json
+//	@Produce		json
+//	@Param			subscription	body		v1.SubscriptionRequest	true	"Card token, amount and billing period"
+//	@Param			X-Merchant-Id	header		string					false	"Merchant charging the subscription"
+//	@Param			API-Version		header		string					false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		201				{object}	v1.Subscription
+//	@Header			201				{string}	Location		"Path of the subscription"
+//	@Failure		400				{object}	problem.Problem	"malformed_request, validation_failed or card_token_not_found"
+//	@Failure		406				{object}	problem.Problem	"unsupported_version"
+//	@Failure		410				{object}	problem.Problem	"version_retired"
+//	@Failure		429				{object}	problem.Problem	"rate_limited"
+//	@Header			429				{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Failure		500				{object}	problem.Problem	"internal_error"
+//	@Router			/v1/subscriptions [post]
+//	@Router			/api/subscriptions [post]
+func (a *Api) PostSubscriptionHandler() http.HandlerFunc {
+	return a.subscriptionHandler.PostHandler()
+}
+
+// GetSubscriptionHandler returns an http.HandlerFunc that returns a subscription.
+//
+//	@Summary		Get a subscription
+//	@Description	The status and schedule of a subscription, and every charge made, oldest first.
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Param			id			path		string	true	"Subscription ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.Subscription
+//	@Failure		404			{object}	problem.Problem	"subscription_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/subscriptions/{id} [get]
+//	@Router			/api/subscriptions/{id} [get]
+func (a *Api) GetSubscriptionHandler() http.HandlerFunc {
+	return a.subscriptionHandler.GetHandler()
+}
+
+// DeleteSubscriptionHandler returns an http.HandlerFunc that cancels a subscription.
+//
+//	@Summary		Cancel a subscription
+//	@Description	Stops charging the subscription and returns it. Cancelling a cancelled subscription changes nothing.
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Param			id			path		string	true	"Subscription ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.Subscription
+//	@Failure		404			{object}	problem.Problem	"subscription_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/subscriptions/{id} [delete]
+//	@Router			/api/subscriptions/{id} [delete]
+func (a *Api) DeleteSubscriptionHandler() http.HandlerFunc {
+	return a.subscriptionHandler.DeleteHandler()
+}
+
+// PostCustomerHandler returns an http.HandlerFunc that creates a customer.
+//
+//	@Summary		Create a customer
+//	@Description	Creates a customer without payment methods; save them with POST /customers/{id}/payment-methods. Only served when the vault is enabled.
+//	@Tags			customers
+//	@Accept			json
+//	@Produce		json
+//	@Param			customer		body		v1.CustomerRequest	true	"Name, email and currency, all optional"
+//	@Param			X-Merchant-Id	header		string				false	"Merchant owning the customer"
+//	@Param			API-Version		header		string				false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		201				{object}	v1.Customer
+//	@Header			201				{string}	Location		"Path of the customer"
+//	@Failure		400				{object}	problem.Problem	"malformed_request or validation_failed"
+//	@Failure		406				{object}	problem.Problem	"unsupported_version"
+//	@Failure		410				{object}	problem.Problem	"version_retired"
+//	@Failure		429				{object}	problem.Problem	"rate_limited"
+//	@Header			429				{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/customers [post]
+//	@Router			/api/customers [post]
+func (a *Api) PostCustomerHandler() http.HandlerFunc {
+	return a.customerHandler.PostHandler()
+}
+
+// GetCustomerHandler returns an http.HandlerFunc that returns a customer.
+//
+//	@Summary		Get a customer
+//	@Description	The customer and its saved payment methods.
+//	@Tags			customers
+//	@Produce		json
+//	@Param			id			path		string	true	"Customer ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.Customer
+//	@Failure		404			{object}	problem.Problem	"customer_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/customers/{id} [get]
+//	@Router			/api/customers/{id} [get]
+func (a *Api) GetCustomerHandler() http.HandlerFunc {
+	return a.customerHandler.GetHandler()
+}
+
+// PatchCustomerHandler returns an http.HandlerFunc that updates a customer.
+//
+//	@Summary		Update a customer
+//	@Description	Changes the fields sent and leaves the others unchanged; an empty string clears a field. default_payment_method_id must name a saved payment method of the customer.
+//	@Tags			customers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string						true	"Customer ID"
+//	@Param			customer	body		v1.CustomerUpdateRequest	true	"Fields to change"
+//	@Param			API-Version	header		string						false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.Customer
+//	@Failure		400			{object}	problem.Problem	"malformed_request or validation_failed"
+//	@Failure		404			{object}	problem.Problem	"customer_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/customers/{id} [patch]
+//	@Router			/api/customers/{id} [patch]
+func (a *Api) PatchCustomerHandler() http.HandlerFunc {
+	return a.customerHandler.PatchHandler()
+}
+
+// DeleteCustomerHandler returns an http.HandlerFunc that deletes a customer.
+//
+//	@Summary		Delete a customer
+//	@Description	Deletes the customer and its payment methods. Its payments are kept and still name it; the card tokens stay in the vault.
+//	@Tags			customers
+//	@Param			id			path		string	true	"Customer ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		204
+//	@Failure		404	{object}	problem.Problem	"customer_not_found"
+//	@Failure		406	{object}	problem.Problem	"unsupported_version"
+//	@Failure		410	{object}	problem.Problem	"version_retired"
+//	@Failure		429	{object}	problem.Problem	"rate_limited"
+//	@Header			429	{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/customers/{id} [delete]
+//	@Router			/api/customers/{id} [delete]
+func (a *Api) DeleteCustomerHandler() http.HandlerFunc {
+	return a.customerHandler.DeleteHandler()
+}
+
+// PostPaymentMethodHandler returns an http.HandlerFunc that saves a payment method.
+//
+//	@Summary		Save a payment method
+//	@Description	Saves a card token from POST /tokens as a payment method of the customer. The first method saved, or one sent with default true, becomes the default. Saving a token twice answers 409.
+//	@Tags			customers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		string					true	"Customer ID"
+//	@Param			payment_method	body		v1.PaymentMethodRequest	true	"Card token"
+//	@Param			API-Version		header		string					false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		201				{object}	v1.PaymentMethod
+//	@Failure		400				{object}	problem.Problem	"malformed_request, validation_failed or card_token_not_found"
+//	@Failure		404				{object}	problem.Problem	"customer_not_found"
+//	@Failure		406				{object}	problem.Problem	"unsupported_version"
+//	@Failure		409				{object}	problem.Problem	"conflict"
+//	@Failure		410				{object}	problem.Problem	"version_retired"
+//	@Failure		429				{object}	problem.Problem	"rate_limited"
+//	@Header			429				{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Failure		500				{object}	problem.Problem	"internal_error"
+//	@Router			/v1/customers/{id}/payment-methods [post]
+//	@Router			/api/customers/{id}/payment-methods [post]
+func (a *Api) PostPaymentMethodHandler() http.HandlerFunc {
+	return a.customerHandler.PostPaymentMethodHandler()
+}
+
+// DeletePaymentMethodHandler returns an http.HandlerFunc that removes a payment method.
+//
+//	@Summary		Remove a payment method
+//	@Description	Removes the payment method from the customer. Removing the default one leaves the customer without a default. The card token stays in the vault.
+//	@Tags			customers
+//	@Param			id			path		string	true	"Customer ID"
+//	@Param			method_id	path		string	true	"Payment method ID"
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		204
+//	@Failure		404	{object}	problem.Problem	"customer_not_found or payment_method_not_found"
+//	@Failure		406	{object}	problem.Problem	"unsupported_version"
+//	@Failure		410	{object}	problem.Problem	"version_retired"
+//	@Failure		429	{object}	problem.Problem	"rate_limited"
+//	@Header			429	{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/customers/{id}/payment-methods/{method_id} [delete]
+//	@Router			/api/customers/{id}/payment-methods/{method_id} [delete]
+func (a *Api) DeletePaymentMethodHandler() http.HandlerFunc {
+	return a.customerHandler.DeletePaymentMethodHandler()
+}
+
+// GetCustomerPaymentsHandler returns an http.HandlerFunc that lists the payments of a customer.
+//
+//	@Summary		List a customer's payments
+//	@Description	The payments made with the customer's saved payment methods, newest first.
+//	@Tags			customers
+//	@Produce		json
+//	@Param			id			path		string	true	"Customer ID"
+//	@Param			limit		query		integer	false	"Maximum payments (1-100)"	default(20)
+//	@Param			API-Version	header		string	false	"API version of the /api alias; ignored on versioned paths"
+//	@Success		200			{object}	v1.PaymentList
+//	@Failure		400			{object}	problem.Problem	"validation_failed"
+//	@Failure		404			{object}	problem.Problem	"customer_not_found"
+//	@Failure		406			{object}	problem.Problem	"unsupported_version"
+//	@Failure		410			{object}	problem.Problem	"version_retired"
+//	@Failure		429			{object}	problem.Problem	"rate_limited"
+//	@Header			429			{integer}	Retry-After		"Seconds until a request can succeed"
+//	@Router			/v1/customers/{id}/payments [get]
+//	@Router			/api/customers/{id}/payments [get]
+func (a *Api) GetCustomerPaymentsHandler() http.HandlerFunc {
+	return a.customerHandler.PaymentsHandler()
 }
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MerchantIDHeader identifies the merchant on whose behalf a request is made.
+const MerchantIDHeader = payments.MerchantIDHeader
+
+// requestLogger attaches a request-scoped logger carrying the request and
+// merchant IDs to the request context and writes one access log line per
+// request. It must run after middleware.RequestID.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := middleware.GetReqID(r.Context())
+		w.Header().Set(middleware.RequestIDHeader, requestID)
+
+		logger := slog.Default().With(
+			"request_id", requestID,
+			"merchant_id", r.Header.Get(MerchantIDHeader),
+		)
+		ctx := logging.WithLogger(r.Context(), logger)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		logger.InfoContext(ctx, "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", ww.Status(),
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// proxiedClientIP replaces the connection address of requests with the
+// client IP a trusted proxy sent in header, so that logs, audit events and
+// rate limits see the client rather than the proxy. Proxies append to
+// X-Forwarded-For, so the last address in header is taken: earlier ones
+// were sent by the client and cannot be trusted. Requests without a valid
+// address keep the connection's. It does nothing when header is empty, and
+// must run before the middleware reading the client IP.
+func proxiedClientIP(header string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if header == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			values := r.Header.Values(header)
+			if len(values) > 0 {
+				addrs := strings.Split(values[len(values)-1], ",")
+				if ip := net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1])); ip != nil {
+					r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// anonymousActor is the audit actor of requests without a merchant ID.
+const anonymousActor = "anonymous"
+
+// adminActor is the audit actor of requests authenticated with the admin
+// token.
+const adminActor = "admin"
+
+// auditRequest stores who made the request in the context for audit events.
+// The actor is the merchant ID, which is not authenticated without mutual
+// TLS, so audit events rely on the source IP and request ID as well. It must
+// run after middleware.RequestID.
+func auditRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := anonymousActor
+		if merchant := r.Header.Get(MerchantIDHeader); merchant != "" {
+			actor = "merchant:" + merchant
+		}
+		ctx := audit.WithRequest(r.Context(), audit.Request{
+			Actor:     actor,
+			SourceIP:  clientIP(r),
+			RequestID: middleware.GetReqID(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// instrumentHTTP records handler latency in metrics.HTTPRequestDuration. The
+// route label is chi's route pattern (e.g. "/api/payments/{id}"), never the raw
+// path, so payment IDs do not end up as label values.
+func instrumentHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(methodLabel(r.Method), route, strconv.Itoa(ww.Status())).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel maps non-standard HTTP methods to "OTHER" to keep the method
+// label bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// traceRequests starts a server span per request, continuing any W3C trace
+// context sent by the caller, and returns the trace and span IDs in the
+// tracing.TraceIDHeader and tracing.SpanIDHeader response headers. The span
+// is renamed after routing so that it carries the route pattern.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Tracer().Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			w.Header().Set(tracing.TraceIDHeader, sc.TraceID().String())
+			w.Header().Set(tracing.SpanIDHeader, sc.SpanID().String())
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(ww.Status()))
+		if ww.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(ww.Status()))
+		}
+	})
+}
+
+// requireBearerToken rejects requests whose Authorization header does not
+// carry token as a bearer token.
+func requireBearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				problem.Write(w, r, problem.New(problem.CodeUnauthorized, "A valid admin bearer token is required"))
+				return
+			}
+			req := audit.RequestFromContext(r.Context())
+			req.Actor = adminActor
+			next.ServeHTTP(w, r.WithContext(audit.WithRequest(r.Context(), req)))
+		})
+	}
+}
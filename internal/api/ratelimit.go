@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/ratelimit"
+)
+
+// Endpoints throttled by rateLimit, as named in rate_limit.endpoints.
+const (
+	endpointCreatePayment = "create_payment"
+	endpointGetPayment    = "get_payment"
+	endpointCreateToken   = "create_token"
+
+	endpointCreatePaymentBatch = "create_payment_batch"
+	endpointGetPaymentBatch    = "get_payment_batch"
+
+	endpointCreateSubscription = "create_subscription"
+	endpointGetSubscription    = "get_subscription"
+	endpointCancelSubscription = "cancel_subscription"
+
+	// update_customer also throttles saving and removing payment methods.
+	endpointCreateCustomer       = "create_customer"
+	endpointGetCustomer          = "get_customer"
+	endpointUpdateCustomer       = "update_customer"
+	endpointDeleteCustomer       = "delete_customer"
+	endpointListCustomerPayments = "list_customer_payments"
+
+	endpointCompleteAuthentication = "complete_authentication"
+)
+
+// rateLimit throttles endpoint per merchant (X-Merchant-Id, or the merchant
+// of the client certificate) with the limits of the merchant's tier, so
+// merchants behind the same load balancer or NAT do not share a bucket.
+// Requests without a merchant ID are throttled per client IP, as set by
+// proxiedClientIP. Requests are let through when the limiter fails, so that
+// an unavailable limiter does not take payments down with it.
+func (a *Api) rateLimit(endpoint string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := a.rateLimits.Load()
+			merchant := r.Header.Get(MerchantIDHeader)
+			tier := policy.Tier(merchant)
+			limit, ok := policy.Limit(endpoint, tier)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := endpoint + "|merchant:" + merchant
+			if merchant == "" {
+				key = endpoint + "|ip:" + clientIP(r)
+			}
+			decision, err := a.limiter.Allow(r.Context(), key, limit)
+			if err != nil {
+				logging.FromContext(r.Context()).ErrorContext(r.Context(), "rate limiter failed, request not throttled",
+					"endpoint", endpoint, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(decision.Reset))
+			if !decision.Allowed {
+				w.Header().Set("Retry-After", seconds(decision.RetryAfter))
+				metrics.RateLimitedTotal.WithLabelValues(endpoint, tier).Inc()
+				problem.Write(w, r, problem.New(problem.CodeRateLimited,
+					fmt.Sprintf("Rate limit of %d requests per %s exceeded", limit.Requests, limit.Per)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats d as whole seconds, rounded up so that clients never
+// retry too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// clientIP returns the IP address of the client, which is the connection's
+// unless proxiedClientIP replaced it.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func rateLimitsFrom(cfg *config.Config) *ratelimit.Policy {
+	limits := func(limits map[string]config.LimitConfig) map[string]ratelimit.Limit {
+		out := make(map[string]ratelimit.Limit, len(limits))
+		for endpoint, limit := range limits {
+			out[endpoint] = ratelimit.Limit{Requests: limit.Requests, Per: limit.Per.Std(), Burst: limit.Burst}
+		}
+		return out
+	}
+
+	opts := []ratelimit.PolicyOption{ratelimit.WithMerchantTiers(cfg.RateLimit.Merchants)}
+	for tier, tierLimits := range cfg.RateLimit.Tiers {
+		opts = append(opts, ratelimit.WithTier(tier, limits(tierLimits)))
+	}
+	return ratelimit.NewPolicy(limits(cfg.RateLimit.Endpoints), opts...)
+}
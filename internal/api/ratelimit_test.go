@@ -0,0 +1,149 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	sp := loadSpec(t)
+	const config = `
+rate_limit:
+  endpoints:
+    get_payment: {requests: 2, per: 1m}
+  tiers:
+    gold:
+      get_payment: {requests: 60, per: 1m, burst: 4}
+  merchants: {acme: gold, hooli: gold}
+`
+
+	send := func(server *httptest.Server, path, merchant string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		if merchant != "" {
+			req.Header.Set(api.MerchantIDHeader, merchant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// Every request of a case comes from the same client IP, with the
+	// merchant ID of the same index, if any.
+	tests := []struct {
+		name      string
+		merchants []string
+		paths     []string
+		want      []int
+	}{
+		{
+			name:      "Default tier",
+			merchants: []string{"globex", "globex", "globex"},
+			paths:     []string{"/v1/payments/a", "/v1/payments/b", "/v1/payments/c"},
+			want:      []int{404, 404, 429},
+		},
+		{
+			name:      "Gold tier",
+			merchants: []string{"acme", "acme", "acme", "acme", "acme"},
+			paths:     []string{"/v1/payments/a", "/v1/payments/b", "/v1/payments/c", "/v1/payments/d", "/v1/payments/e"},
+			want:      []int{404, 404, 404, 404, 429},
+		},
+		{
+			name:      "Gold tier merchants sharing an IP",
+			merchants: []string{"acme", "acme", "acme", "acme", "hooli", "acme"},
+			paths:     []string{"/v1/payments/a", "/v1/payments/b", "/v1/payments/c", "/v1/payments/d", "/v1/payments/e", "/v1/payments/f"},
+			want:      []int{404, 404, 404, 404, 404, 429},
+		},
+		{
+			name:  "Requests without a merchant are limited by IP across versions",
+			paths: []string{"/api/payments/a", "/v1/payments/b", "/api/payments/c"},
+			want:  []int{404, 404, 429},
+		},
+		{
+			name:      "Default tier merchants sharing an IP",
+			merchants: []string{"initech", "initech", "umbrella", "umbrella", "", "initech"},
+			paths:     []string{"/v1/payments/a", "/v1/payments/b", "/v1/payments/c", "/v1/payments/d", "/v1/payments/e", "/v1/payments/f"},
+			want:      []int{404, 404, 404, 404, 404, 429},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGateway(t, config)
+			var got []int
+			for i, path := range tt.paths {
+				var merchant string
+				if i < len(tt.merchants) {
+					merchant = tt.merchants[i]
+				}
+				got = append(got, send(server, path, merchant).StatusCode)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Requests without a merchant are limited by the proxied client IP", func(t *testing.T) {
+		server := newGateway(t, config+`
+server:
+  client_ip_header: X-Forwarded-For
+`)
+		send := func(forwardedFor string) int {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/payments/a", nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, 404, send("198.51.100.1"))
+		assert.Equal(t, 404, send("203.0.113.9, 198.51.100.1"), "only the address appended by the proxy counts")
+		assert.Equal(t, 429, send("198.51.100.1"))
+		assert.Equal(t, 404, send("198.51.100.2"))
+	})
+
+	t.Run("Limited response", func(t *testing.T) {
+		server := newGateway(t, config)
+		resp := send(server, "/v1/payments/a", "initech")
+		assert.Equal(t, "2", resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(t, "1", resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", resp.Header.Get("RateLimit-Reset"))
+
+		send(server, "/v1/payments/a", "initech")
+		resp = send(server, "/v1/payments/a", "initech")
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+		retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		require.NoError(t, err)
+		assert.InDelta(t, 30, retryAfter, 1)
+
+		var body problem.Problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, problem.CodeRateLimited, body.Code)
+		_, documented := sp.Paths["/v1/payments/{id}"]["get"].Responses["429"]
+		assert.True(t, documented, "429 is documented")
+	})
+
+	t.Run("Endpoints without a limit are not throttled", func(t *testing.T) {
+		server := newGateway(t, config)
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/tokens",
+			strings.NewReader(`{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099}`))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("RateLimit-Limit"))
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig"
+)
+
+// serverTLSFrom returns the TLS configuration of the listener, already
+// checked by config.Validate, and the reloader serving its certificate. It
+// returns nil when TLS is not configured.
+func serverTLSFrom(cfg config.TLSConfig) (*tls.Config, *tlsconfig.CertReloader, error) {
+	if !cfg.Enabled() {
+		return nil, nil, nil
+	}
+	certs, err := tlsconfig.NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	minVersion, err := tlsconfig.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	suites, err := tlsconfig.ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     minVersion,
+	}
+	if len(suites) > 0 {
+		tlsConfig.CipherSuites = suites
+	}
+	if cfg.ClientCAFile != "" {
+		pool, err := tlsconfig.LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.ClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, certs, nil
+}
+
+// clientCertMerchant takes the merchant ID of requests made with a verified
+// client certificate from the certificate's identity, mapped through
+// merchants. Since the certificate authenticates the merchant, a different
+// X-Merchant-Id is refused. It must run before the middleware reading the
+// merchant ID.
+func clientCertMerchant(merchants map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !certifiedMerchant(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			merchant := tlsconfig.Identity(r.TLS.VerifiedChains[0][0])
+			if mapped, ok := merchants[merchant]; ok {
+				merchant = mapped
+			}
+			if sent := r.Header.Get(MerchantIDHeader); sent != "" && sent != merchant {
+				logging.FromContext(r.Context()).WarnContext(r.Context(), "merchant ID does not match client certificate",
+					"merchant_id", sent, "certificate_merchant_id", merchant)
+				problem.Write(w, r, problem.New(problem.CodeUnauthorized,
+					"X-Merchant-Id does not match the client certificate"))
+				return
+			}
+			r.Header.Set(MerchantIDHeader, merchant)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// certifiedMerchant reports whether the merchant ID of r was taken from a
+// verified client certificate by clientCertMerchant.
+func certifiedMerchant(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
@@ -0,0 +1,140 @@
+package api_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveTLS serves a gateway with the given server.tls block on a local
+// listener and returns its base URL.
+func serveTLS(t *testing.T, tlsConfig string) string {
+	t.Helper()
+	gateway := newAPI(t, "server:\n  drain_delay: 0s\n  tls: "+tlsConfig+"\n")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- gateway.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	return "https://" + ln.Addr().String()
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := tlstest.NewCA(t, "server-ca")
+	certPEM, keyPEM := serverCA.Issue(t, "gateway")
+	certFile := tlstest.WriteFile(t, dir, "gateway.pem", certPEM)
+	keyFile := tlstest.WriteFile(t, dir, "gateway-key.pem", keyPEM)
+
+	clientCA := tlstest.NewCA(t, "client-ca")
+	clientCAFile := tlstest.WriteFile(t, dir, "client-ca.pem", clientCA.PEM)
+	acmeCert, acmeKey := clientCA.Issue(t, "acme-prod")
+	acme, err := tls.X509KeyPair(acmeCert, acmeKey)
+	require.NoError(t, err)
+	strangerCert, strangerKey := tlstest.NewCA(t, "other-ca").Issue(t, "acme-prod")
+	stranger, err := tls.X509KeyPair(strangerCert, strangerKey)
+	require.NoError(t, err)
+
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      serverCA.Pool(),
+			Certificates: certs,
+		}}}
+	}
+
+	t.Run("Serves HTTPS", func(t *testing.T) {
+		base := serveTLS(t, fmt.Sprintf("{cert_file: %q, key_file: %q, min_version: \"1.3\"}", certFile, keyFile))
+
+		resp, err := client().Get(base + "/ping")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+
+		// net/http answers plain HTTP on a TLS listener with a bare 400.
+		plain, err := http.Get(strings.Replace(base, "https", "http", 1) + "/ping")
+		require.NoError(t, err)
+		defer plain.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, plain.StatusCode)
+	})
+
+	t.Run("Refuses TLS versions below the minimum", func(t *testing.T) {
+		base := serveTLS(t, fmt.Sprintf("{cert_file: %q, key_file: %q, min_version: \"1.3\"}", certFile, keyFile))
+
+		old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    serverCA.Pool(),
+			MaxVersion: tls.VersionTLS12,
+		}}}
+		_, err := old.Get(base + "/ping")
+		assert.Error(t, err)
+	})
+
+	mutual := fmt.Sprintf("{cert_file: %q, key_file: %q, client_ca_file: %q, client_merchants: {acme-prod: acme}}",
+		certFile, keyFile, clientCAFile)
+
+	t.Run("Mutual TLS refuses clients without a trusted certificate", func(t *testing.T) {
+		base := serveTLS(t, mutual)
+
+		_, err := client().Get(base + "/ping")
+		assert.Error(t, err, "no client certificate")
+		_, err = client(stranger).Get(base + "/ping")
+		assert.Error(t, err, "client certificate from an untrusted CA")
+	})
+
+	t.Run("Mutual TLS takes the merchant from the client certificate", func(t *testing.T) {
+		base := serveTLS(t, mutual)
+
+		resp, err := client(acme).Post(base+"/v1/payments", "application/json", strings.NewReader(
+			`{"card_number":"2222405343248877","expiry_month":12,"expiry_year":2099,"currency":"USD","amount":100,"cvv":"123"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		req, err := http.NewRequest(http.MethodGet, base+"/admin/audit?"+url.Values{
+			"action": {string(audit.ActionPaymentCreated)},
+			"actor":  {"merchant:acme"},
+		}.Encode(), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		resp, err = client(acme).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var events []audit.Event
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&events))
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("Mutual TLS refuses a different X-Merchant-Id", func(t *testing.T) {
+		base := serveTLS(t, mutual)
+
+		req, err := http.NewRequest(http.MethodGet, base+"/v1/payments/missing", nil)
+		require.NoError(t, err)
+		req.Header.Set(api.MerchantIDHeader, "globex")
+		resp, err := client(acme).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		var body problem.Problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, problem.CodeUnauthorized, body.Code)
+	})
+}
@@ -0,0 +1,178 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/batch"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+)
+
+// PaymentBatchRequest is the JSON body of POST /v1/payment-batches. The same
+// payments can be sent as NDJSON, one PaymentRequest per line, or as CSV
+// with a header row naming the PaymentRequest fields, and
+// stored_credential.<field> for the StoredCredential fields.
+type PaymentBatchRequest struct {
+	Payments []PaymentRequest `json:"payments"`
+}
+
+// PaymentBatch is a batch as returned by the v1 batch endpoints.
+type PaymentBatch struct {
+	Id string `json:"id"`
+	// Status is Processing, Completed, or Interrupted when a shutdown
+	// stopped the batch before every payment was processed.
+	Status      string        `json:"status"`
+	CreatedAt   time.Time     `json:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+	Progress    BatchProgress `json:"progress"`
+	Items       []BatchItem   `json:"items"`
+}
+
+// BatchProgress counts the payments of a batch by outcome.
+type BatchProgress struct {
+	Total      int `json:"total"`
+	Processed  int `json:"processed"`
+	Authorized int `json:"authorized"`
+	Declined   int `json:"declined"`
+	Rejected   int `json:"rejected"`
+	Failed     int `json:"failed"`
+	// RequiresAction counts payments awaiting 3-D Secure authentication by
+	// their cardholder.
+	RequiresAction int `json:"requires_action"`
+}
+
+// BatchItem is the outcome of one payment of a batch, in submission order.
+type BatchItem struct {
+	Index int `json:"index"`
+	// PaymentId identifies the stored payment, which GET /v1/payments/{id}
+	// returns. It is empty for Rejected and Failed payments.
+	PaymentId string `json:"payment_id,omitempty"`
+	// PaymentStatus is Pending until the payment is processed.
+	PaymentStatus string `json:"payment_status"`
+	// Code, Detail and Errors say why a Rejected or Failed payment was not
+	// stored, as in the problem POST /v1/payments would have answered.
+	Code   problem.Code         `json:"code,omitempty"`
+	Detail string               `json:"detail,omitempty"`
+	Errors []problem.FieldError `json:"errors,omitempty"`
+}
+
+// BatchFromDomain converts a batch to its v1 model.
+func BatchFromDomain(b *batch.Batch) *PaymentBatch {
+	p := b.Progress()
+	out := &PaymentBatch{
+		Id:          b.ID,
+		Status:      string(b.Status),
+		CreatedAt:   b.CreatedAt,
+		CompletedAt: b.CompletedAt,
+		Progress: BatchProgress{
+			Total:          p.Total,
+			Processed:      p.Processed,
+			Authorized:     p.Authorized,
+			Declined:       p.Declined,
+			Rejected:       p.Rejected,
+			Failed:         p.Failed,
+			RequiresAction: p.RequiresAction,
+		},
+		Items: make([]BatchItem, len(b.Items)),
+	}
+	for i, item := range b.Items {
+		out.Items[i] = BatchItem{
+			Index:         item.Index,
+			PaymentId:     item.PaymentID,
+			PaymentStatus: item.PaymentStatus,
+		}
+		if item.Problem != nil {
+			out.Items[i].Code = item.Problem.Code
+			out.Items[i].Detail = item.Problem.Detail
+			out.Items[i].Errors = item.Problem.Errors
+		}
+	}
+	return out
+}
+
+func (Codec) DecodeBatchPayment(data []byte) (*payments.PostPaymentRequest, error) {
+	var req PaymentRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) DecodeBatchRecord(record map[string]string) (*payments.PostPaymentRequest, error) {
+	var req PaymentRequest
+	for column, value := range record {
+		var err error
+		switch column {
+		case "card_number":
+			req.CardNumber = value
+		case "card_token":
+			req.CardToken = value
+		case "expiry_month":
+			req.ExpiryMonth, err = atoi(column, value)
+		case "expiry_year":
+			req.ExpiryYear, err = atoi(column, value)
+		case "currency":
+			req.Currency = value
+		case "amount":
+			req.Amount, err = atoi(column, value)
+		case "cvv":
+			req.Cvv = value
+		case "customer_id":
+			req.CustomerID = value
+		case "payment_method_id":
+			req.PaymentMethodID = value
+		case "stored_credential.initiator", "stored_credential.sequence",
+			"stored_credential.reason", "stored_credential.network_transaction_id":
+			setStoredCredential(&req, strings.TrimPrefix(column, "stored_credential."), value)
+		default:
+			err = fmt.Errorf("%w %q", batch.ErrUnknownColumn, column)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) EncodeBatch(b *batch.Batch) any {
+	return BatchFromDomain(b)
+}
+
+// setStoredCredential sets one field of the stored credential flags of req
+// from a stored_credential.<field> column. req gets flags only when such a
+// column has a value.
+func setStoredCredential(req *PaymentRequest, field, value string) {
+	if value == "" {
+		return
+	}
+	if req.StoredCredential == nil {
+		req.StoredCredential = &StoredCredential{}
+	}
+	c := req.StoredCredential
+	switch field {
+	case "initiator":
+		c.Initiator = payments.Initiator(value)
+	case "sequence":
+		c.Sequence = payments.Sequence(value)
+	case "reason":
+		c.Reason = payments.CredentialReason(value)
+	case "network_transaction_id":
+		c.NetworkTransactionID = value
+	}
+}
+
+// atoi parses the integer value of a CSV column, 0 when it is empty.
+func atoi(column, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not an integer", column, value)
+	}
+	return n, nil
+}
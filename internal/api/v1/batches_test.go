@@ -0,0 +1,77 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/batch"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeBatchRecord(t *testing.T) {
+	got, err := v1.Codec{}.DecodeBatchRecord(map[string]string{
+		"card_number": "2222405343248877", "expiry_month": "4", "expiry_year": " 2099",
+		"currency": "GBP", "amount": "100", "cvv": "123", "card_token": "",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2099, Currency: "GBP", Amount: 100, Cvv: "123"}, got)
+
+	got, err = v1.Codec{}.DecodeBatchRecord(map[string]string{
+		"card_token": "tok_1", "currency": "GBP", "amount": "100", "cvv": "",
+		"stored_credential.initiator": "merchant", "stored_credential.sequence": "subsequent",
+		"stored_credential.reason": "recurring", "stored_credential.network_transaction_id": "NTI-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &payments.StoredCredential{Initiator: payments.InitiatorMerchant, Sequence: payments.SequenceSubsequent,
+		Reason: payments.ReasonRecurring, NetworkTransactionID: "NTI-1"}, got.StoredCredential)
+
+	got, err = v1.Codec{}.DecodeBatchRecord(map[string]string{"customer_id": "c1", "payment_method_id": "pm_1", "amount": "100"})
+	require.NoError(t, err)
+	assert.Equal(t, &payments.PostPaymentRequest{CustomerID: "c1", PaymentMethodID: "pm_1", Amount: 100}, got)
+
+	got, err = v1.Codec{}.DecodeBatchRecord(map[string]string{"card_token": "tok_1", "stored_credential.initiator": ""})
+	require.NoError(t, err)
+	assert.Nil(t, got.StoredCredential, "empty columns add no stored credential")
+
+	_, err = v1.Codec{}.DecodeBatchRecord(map[string]string{"amount": "1e3"})
+	assert.EqualError(t, err, `amount: "1e3" is not an integer`)
+
+	_, err = v1.Codec{}.DecodeBatchRecord(map[string]string{"reference": "r1"})
+	assert.ErrorIs(t, err, batch.ErrUnknownColumn)
+}
+
+func TestCodec_EncodeBatch(t *testing.T) {
+	created := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	b := &batch.Batch{
+		ID:        "b1",
+		Merchant:  "acme",
+		Status:    batch.StatusProcessing,
+		CreatedAt: created,
+		Items: []batch.Item{
+			{Index: 0, PaymentID: "p1", PaymentStatus: "Authorized"},
+			{Index: 1, PaymentStatus: "Rejected", Problem: problem.New(problem.CodeValidationFailed, "amount must be positive").
+				WithErrors(problem.FieldError{Field: "amount", Message: "must be positive"}).WithPaymentStatus("Rejected")},
+			{Index: 2, PaymentStatus: batch.ItemPending},
+		},
+	}
+
+	data, err := json.Marshal(v1.Codec{}.EncodeBatch(b))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "b1",
+		"status": "Processing",
+		"created_at": "2026-10-01T02:00:00Z",
+		"progress": {"total": 3, "processed": 2, "authorized": 1, "declined": 0, "rejected": 1, "failed": 0, "requires_action": 0},
+		"items": [
+			{"index": 0, "payment_id": "p1", "payment_status": "Authorized"},
+			{"index": 1, "payment_status": "Rejected", "code": "validation_failed", "detail": "amount must be positive",
+			 "errors": [{"field": "amount", "message": "must be positive"}]},
+			{"index": 2, "payment_status": "Pending"}
+		]
+	}`, string(data))
+}
@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/customer"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// CustomerRequest is the body of POST /v1/customers.
+type CustomerRequest struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// Currency is used by payments of the customer that name none.
+	Currency string `json:"currency,omitempty"`
+}
+
+// CustomerUpdateRequest is the body of PATCH /v1/customers/{id}. Fields
+// left out are unchanged, and empty strings clear them.
+type CustomerUpdateRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	Currency *string `json:"currency,omitempty"`
+	// DefaultPaymentMethodID is the payment method paid with when a payment
+	// names only the customer.
+	DefaultPaymentMethodID *string `json:"default_payment_method_id,omitempty"`
+}
+
+// PaymentMethodRequest is the body of POST /v1/customers/{id}/payment-methods.
+type PaymentMethodRequest struct {
+	// CardToken is a token from POST /v1/tokens.
+	CardToken string `json:"card_token"`
+	// Default makes the method the customer's default one.
+	Default bool `json:"default,omitempty"`
+}
+
+// Customer is a customer as returned by the v1 customer endpoints.
+type Customer struct {
+	Id                     string          `json:"id"`
+	Name                   string          `json:"name,omitempty"`
+	Email                  string          `json:"email,omitempty"`
+	Currency               string          `json:"currency,omitempty"`
+	DefaultPaymentMethodID string          `json:"default_payment_method_id,omitempty"`
+	PaymentMethods         []PaymentMethod `json:"payment_methods"`
+	CreatedAt              time.Time       `json:"created_at"`
+	UpdatedAt              time.Time       `json:"updated_at"`
+}
+
+// PaymentMethod is a card saved for a customer. Payments send its id as
+// payment_method_id.
+type PaymentMethod struct {
+	Id                 string    `json:"id"`
+	CardToken          string    `json:"card_token"`
+	CardNumberLastFour string    `json:"card_number_last_four"`
+	ExpiryMonth        int       `json:"expiry_month"`
+	ExpiryYear         int       `json:"expiry_year"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// PaymentList is the body of GET /v1/customers/{id}/payments.
+type PaymentList struct {
+	// Payments are the customer's payments, newest first.
+	Payments []Payment `json:"payments"`
+}
+
+// ToDomain converts the request to the domain customer request.
+func (r *CustomerRequest) ToDomain() *customer.Request {
+	return &customer.Request{Name: r.Name, Email: r.Email, Currency: r.Currency}
+}
+
+// ToDomain converts the request to the domain customer update.
+func (r *CustomerUpdateRequest) ToDomain() *customer.Update {
+	return &customer.Update{
+		Name:                   r.Name,
+		Email:                  r.Email,
+		Currency:               r.Currency,
+		DefaultPaymentMethodID: r.DefaultPaymentMethodID,
+	}
+}
+
+// CustomerFromDomain converts a customer to its v1 model.
+func CustomerFromDomain(c *customer.Customer) *Customer {
+	out := &Customer{
+		Id:                     c.ID,
+		Name:                   c.Name,
+		Email:                  c.Email,
+		Currency:               c.Currency,
+		DefaultPaymentMethodID: c.DefaultPaymentMethodID,
+		PaymentMethods:         make([]PaymentMethod, len(c.PaymentMethods)),
+		CreatedAt:              c.CreatedAt,
+		UpdatedAt:              c.UpdatedAt,
+	}
+	for i := range c.PaymentMethods {
+		out.PaymentMethods[i] = *PaymentMethodFromDomain(&c.PaymentMethods[i])
+	}
+	return out
+}
+
+// PaymentMethodFromDomain converts a payment method to its v1 model.
+func PaymentMethodFromDomain(m *customer.PaymentMethod) *PaymentMethod {
+	return &PaymentMethod{
+		Id:                 m.ID,
+		CardToken:          m.CardToken,
+		CardNumberLastFour: m.CardNumberLastFour,
+		ExpiryMonth:        m.ExpiryMonth,
+		ExpiryYear:         m.ExpiryYear,
+		CreatedAt:          m.CreatedAt,
+	}
+}
+
+func (Codec) DecodeCustomerRequest(r io.Reader) (*customer.Request, error) {
+	var req CustomerRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) DecodeCustomerUpdate(r io.Reader) (*customer.Update, error) {
+	var req CustomerUpdateRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) DecodePaymentMethodRequest(r io.Reader) (*customer.PaymentMethodRequest, error) {
+	var req PaymentMethodRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	return &customer.PaymentMethodRequest{CardToken: req.CardToken, Default: req.Default}, nil
+}
+
+func (Codec) EncodeCustomer(c *customer.Customer) any {
+	return CustomerFromDomain(c)
+}
+
+func (Codec) EncodePaymentMethod(m *customer.PaymentMethod) any {
+	return PaymentMethodFromDomain(m)
+}
+
+func (Codec) EncodeCustomerPayments(p []payments.Payment) any {
+	out := &PaymentList{Payments: make([]Payment, len(p))}
+	for i := range p {
+		out.Payments[i] = *PaymentFromDomain(&p[i])
+	}
+	return out
+}
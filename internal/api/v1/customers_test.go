@@ -0,0 +1,77 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/customer"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeCustomerUpdate(t *testing.T) {
+	got, err := v1.Codec{}.DecodeCustomerUpdate(strings.NewReader(`{"email":"","default_payment_method_id":"pm_1"}`))
+	require.NoError(t, err)
+	email, method := "", "pm_1"
+	assert.Equal(t, &customer.Update{Email: &email, DefaultPaymentMethodID: &method}, got, "fields left out stay nil")
+
+	_, err = v1.Codec{}.DecodeCustomerUpdate(strings.NewReader(`{"name":1}`))
+	assert.Error(t, err)
+}
+
+func TestCodec_EncodeCustomer(t *testing.T) {
+	created := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	c := &customer.Customer{
+		ID:                     "c1",
+		Merchant:               "acme",
+		Name:                   "Ada Lovelace",
+		Email:                  "ada@example.com",
+		Currency:               "USD",
+		DefaultPaymentMethodID: "pm_1",
+		PaymentMethods: []customer.PaymentMethod{
+			{ID: "pm_1", CardToken: "tok_1", CardNumberLastFour: "8877", ExpiryMonth: 4, ExpiryYear: 2099, CreatedAt: created},
+		},
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+
+	data, err := json.Marshal(v1.Codec{}.EncodeCustomer(c))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "c1",
+		"name": "Ada Lovelace",
+		"email": "ada@example.com",
+		"currency": "USD",
+		"default_payment_method_id": "pm_1",
+		"payment_methods": [
+			{"id": "pm_1", "card_token": "tok_1", "card_number_last_four": "8877", "expiry_month": 4, "expiry_year": 2099,
+			 "created_at": "2026-10-01T02:00:00Z"}
+		],
+		"created_at": "2026-10-01T02:00:00Z",
+		"updated_at": "2026-10-01T02:00:00Z"
+	}`, string(data))
+}
+
+func TestCodec_EncodeCustomerPayments(t *testing.T) {
+	data, err := json.Marshal(v1.Codec{}.EncodeCustomerPayments([]payments.Payment{
+		{Id: "p1", PaymentStatus: "Authorized", CardNumberLastFour: "8877", ExpiryMonth: 4, ExpiryYear: 2099,
+			Currency: "USD", Amount: 100, CustomerID: "c1", PaymentMethodID: "pm_1"},
+	}))
+	require.NoError(t, err)
+
+	var got struct {
+		Payments []map[string]any `json:"payments"`
+	}
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Len(t, got.Payments, 1)
+	assert.Equal(t, "c1", got.Payments[0]["customer_id"])
+	assert.Equal(t, "pm_1", got.Payments[0]["payment_method_id"])
+
+	data, err = json.Marshal(v1.Codec{}.EncodeCustomerPayments(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"payments": []}`, string(data))
+}
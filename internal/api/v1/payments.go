@@ -0,0 +1,222 @@
+// Package v1 holds the wire models of version 1 of the gateway API and the
+// converters between them and the payments domain model. Once released, these
+// models only change compatibly; a breaking change needs a new version
+// package next to this one.
+package v1
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// PaymentRequest is the body of POST /v1/payments.
+type PaymentRequest struct {
+	CardNumber string `json:"card_number"`
+	// CardToken is a token from POST /v1/tokens, used instead of
+	// CardNumber.
+	CardToken   string `json:"card_token,omitempty"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+	Currency    string `json:"currency"`
+	Amount      int    `json:"amount"`
+	// Cvv may be omitted on merchant-initiated payments.
+	Cvv string `json:"cvv"`
+	// StoredCredential flags payments made with, or storing, a card kept on
+	// file.
+	StoredCredential *StoredCredential `json:"stored_credential,omitempty"`
+	// CustomerID pays with a saved payment method of the customer, instead
+	// of card_number or card_token: PaymentMethodID, or the customer's
+	// default one. Currency defaults to the customer's; Cvv is still
+	// required unless the payment is merchant-initiated.
+	CustomerID      string `json:"customer_id,omitempty"`
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+	// ThreeDS holds the 3-D Secure options of the payment.
+	ThreeDS *ThreeDSRequest `json:"three_ds,omitempty"`
+}
+
+// ThreeDSRequest holds the 3-D Secure options of a payment.
+type ThreeDSRequest struct {
+	// ReturnURL is where the cardholder's browser is redirected once a
+	// challenge ends, with the payment_id query parameter.
+	ReturnURL string `json:"return_url,omitempty"`
+}
+
+// StoredCredential flags a payment made with, or storing, a card kept on
+// file. The initial payment is customer-initiated and returns the
+// network_transaction_id that subsequent merchant-initiated payments send.
+type StoredCredential struct {
+	// Initiator is customer (CIT) or merchant (MIT).
+	Initiator payments.Initiator `json:"initiator"`
+	// Sequence is initial or subsequent.
+	Sequence payments.Sequence `json:"sequence"`
+	// Reason is recurring, installment or unscheduled. Merchant-initiated
+	// payments require it.
+	Reason payments.CredentialReason `json:"reason,omitempty"`
+	// NetworkTransactionID is the one returned with the initial payment.
+	// Merchant-initiated payments require it, and must be for the card,
+	// sent as card_number, card_token or customer_id, of an authorized
+	// initial payment of the same merchant.
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
+}
+
+// Payment is a processed payment as returned by the v1 payment endpoints.
+type Payment struct {
+	Id                 string `json:"id"`
+	PaymentStatus      string `json:"payment_status"`
+	CardNumberLastFour string `json:"card_number_last_four"`
+	// CardFingerprint is the same for every payment made with the same
+	// card, without revealing the card number.
+	CardFingerprint string `json:"card_fingerprint,omitempty"`
+	ExpiryMonth     int    `json:"expiry_month"`
+	ExpiryYear      int    `json:"expiry_year"`
+	Currency        string `json:"currency"`
+	Amount          int    `json:"amount"`
+	// DeclineCode is the normalized reason a Declined payment was declined.
+	DeclineCode payments.DeclineCode `json:"decline_code,omitempty"`
+	// DeclineType is soft when retrying the payment later may succeed and
+	// hard when it will be declined again.
+	DeclineType payments.DeclineType `json:"decline_type,omitempty"`
+	// DuplicateOf is the ID of an earlier payment with the same card,
+	// amount and currency, when the merchant's duplicate action is warn.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// FailureCode is the error code of a payment that Failed while
+	// processed asynchronously, such as upstream_unavailable.
+	FailureCode string `json:"failure_code,omitempty"`
+	// StoredCredential is the stored credential flags sent with the
+	// payment.
+	StoredCredential *StoredCredential `json:"stored_credential,omitempty"`
+	// NetworkTransactionID identifies the payment to the card scheme. The
+	// one of an initial payment is sent with its merchant-initiated
+	// payments.
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
+	// CustomerID and PaymentMethodID identify the saved payment method
+	// paid with.
+	CustomerID      string `json:"customer_id,omitempty"`
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+	// ThreeDS is the 3-D Secure authentication of the cardholder, when the
+	// payment was authenticated.
+	ThreeDS *Authentication `json:"three_ds,omitempty"`
+}
+
+// Authentication is the outcome of the 3-D Secure authentication of a
+// payment. The cryptogram sent to the acquirer is not returned.
+type Authentication struct {
+	TransactionID string `json:"transaction_id"`
+	Version       string `json:"version,omitempty"`
+	// Status is authenticated, attempted, failed, rejected, unavailable or
+	// challenge_required.
+	Status payments.AuthenticationStatus `json:"status"`
+	// ECI is the electronic commerce indicator given by the scheme.
+	ECI             string `json:"eci,omitempty"`
+	DSTransactionID string `json:"ds_transaction_id,omitempty"`
+	// LiabilityShift reports that fraud chargebacks are borne by the
+	// issuer rather than the merchant.
+	LiabilityShift bool `json:"liability_shift"`
+	// ChallengeURL is where to send the cardholder's browser while Status
+	// is challenge_required.
+	ChallengeURL string `json:"challenge_url,omitempty"`
+}
+
+// ToDomain converts the request to the domain payment request.
+func (r *PaymentRequest) ToDomain() *payments.PostPaymentRequest {
+	return &payments.PostPaymentRequest{
+		CardNumber:       r.CardNumber,
+		CardToken:        r.CardToken,
+		ExpiryMonth:      r.ExpiryMonth,
+		ExpiryYear:       r.ExpiryYear,
+		Currency:         r.Currency,
+		Amount:           r.Amount,
+		Cvv:              r.Cvv,
+		StoredCredential: r.StoredCredential.toDomain(),
+		CustomerID:       r.CustomerID,
+		PaymentMethodID:  r.PaymentMethodID,
+		ThreeDS:          r.ThreeDS.toDomain(),
+	}
+}
+
+func (t *ThreeDSRequest) toDomain() *payments.ThreeDSRequest {
+	if t == nil {
+		return nil
+	}
+	return &payments.ThreeDSRequest{ReturnURL: t.ReturnURL}
+}
+
+func authenticationFromDomain(a *payments.Authentication) *Authentication {
+	if a == nil {
+		return nil
+	}
+	return &Authentication{
+		TransactionID:   a.TransactionID,
+		Version:         a.Version,
+		Status:          a.Status,
+		ECI:             a.ECI,
+		DSTransactionID: a.DSTransactionID,
+		LiabilityShift:  a.LiabilityShift,
+		ChallengeURL:    a.ChallengeURL,
+	}
+}
+
+func (c *StoredCredential) toDomain() *payments.StoredCredential {
+	if c == nil {
+		return nil
+	}
+	return &payments.StoredCredential{
+		Initiator:            c.Initiator,
+		Sequence:             c.Sequence,
+		Reason:               c.Reason,
+		NetworkTransactionID: c.NetworkTransactionID,
+	}
+}
+
+func storedCredentialFromDomain(c *payments.StoredCredential) *StoredCredential {
+	if c == nil {
+		return nil
+	}
+	return &StoredCredential{
+		Initiator:            c.Initiator,
+		Sequence:             c.Sequence,
+		Reason:               c.Reason,
+		NetworkTransactionID: c.NetworkTransactionID,
+	}
+}
+
+// PaymentFromDomain converts a stored payment to its v1 model.
+func PaymentFromDomain(p *payments.Payment) *Payment {
+	return &Payment{
+		Id:                   p.Id,
+		PaymentStatus:        p.PaymentStatus,
+		CardNumberLastFour:   p.CardNumberLastFour,
+		CardFingerprint:      p.CardFingerprint,
+		ExpiryMonth:          p.ExpiryMonth,
+		ExpiryYear:           p.ExpiryYear,
+		Currency:             p.Currency,
+		Amount:               p.Amount,
+		DeclineCode:          p.DeclineCode,
+		DeclineType:          p.DeclineType,
+		DuplicateOf:          p.DuplicateOf,
+		FailureCode:          p.FailureCode,
+		StoredCredential:     storedCredentialFromDomain(p.StoredCredential),
+		NetworkTransactionID: p.NetworkTransactionID,
+		CustomerID:           p.CustomerID,
+		PaymentMethodID:      p.PaymentMethodID,
+		ThreeDS:              authenticationFromDomain(p.ThreeDS),
+	}
+}
+
+// Codec is the payments.Codec, vault.Codec, batch.Codec, subscription.Codec
+// and customer.Codec of version 1.
+type Codec struct{}
+
+func (Codec) DecodePaymentRequest(r io.Reader) (*payments.PostPaymentRequest, error) {
+	var req PaymentRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) EncodePayment(p *payments.Payment) any {
+	return PaymentFromDomain(p)
+}
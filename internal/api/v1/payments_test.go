@@ -0,0 +1,144 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodePaymentRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    *payments.PostPaymentRequest
+		wantErr bool
+	}{
+		{
+			name: "Card",
+			body: `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099,"currency":"GBP","amount":100,"cvv":"123"}`,
+			want: &payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2099, Currency: "GBP", Amount: 100, Cvv: "123"},
+		},
+		{
+			name: "Card token",
+			body: `{"card_token":"tok_1","currency":"USD","amount":5,"cvv":"123"}`,
+			want: &payments.PostPaymentRequest{CardToken: "tok_1", Currency: "USD", Amount: 5, Cvv: "123"},
+		},
+		{
+			name: "Merchant-initiated",
+			body: `{"card_token":"tok_1","currency":"USD","amount":5,"stored_credential":{"initiator":"merchant","sequence":"subsequent","reason":"recurring","network_transaction_id":"NTI-1"}}`,
+			want: &payments.PostPaymentRequest{CardToken: "tok_1", Currency: "USD", Amount: 5, StoredCredential: &payments.StoredCredential{
+				Initiator: payments.InitiatorMerchant, Sequence: payments.SequenceSubsequent, Reason: payments.ReasonRecurring, NetworkTransactionID: "NTI-1",
+			}},
+		},
+		{
+			name: "3-D Secure return URL",
+			body: `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099,"currency":"EUR","amount":100,"cvv":"123","three_ds":{"return_url":"https://shop.test/done"}}`,
+			want: &payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2099, Currency: "EUR", Amount: 100, Cvv: "123",
+				ThreeDS: &payments.ThreeDSRequest{ReturnURL: "https://shop.test/done"}},
+		},
+		{
+			name:    "Malformed",
+			body:    `{"amount":"100"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v1.Codec{}.DecodePaymentRequest(strings.NewReader(tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCodec_EncodePayment(t *testing.T) {
+	payment := &payments.Payment{
+		Id:                 "p1",
+		PaymentStatus:      "Declined",
+		CardNumberLastFour: "0042",
+		ExpiryMonth:        4,
+		ExpiryYear:         2099,
+		Currency:           "GBP",
+		Amount:             100,
+		DeclineCode:        payments.DeclineInsufficientFunds,
+		DeclineType:        payments.DeclineSoft,
+	}
+
+	data, err := json.Marshal(v1.Codec{}.EncodePayment(payment))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"id": "p1",
+		"payment_status": "Declined",
+		"card_number_last_four": "0042",
+		"expiry_month": 4,
+		"expiry_year": 2099,
+		"currency": "GBP",
+		"amount": 100,
+		"decline_code": "insufficient_funds",
+		"decline_type": "soft"
+	}`, string(data), "leading zeros of the last four digits are kept")
+}
+
+func TestCodec_EncodePayment_ThreeDS(t *testing.T) {
+	payment := &payments.Payment{
+		Id:                 "p1",
+		PaymentStatus:      "Authorized",
+		CardNumberLastFour: "8877",
+		ExpiryMonth:        4,
+		ExpiryYear:         2099,
+		Currency:           "EUR",
+		Amount:             100,
+		ThreeDS: &payments.Authentication{
+			TransactionID:   "t-1",
+			Version:         "2.2.0",
+			Status:          payments.AuthenticationSucceeded,
+			ECI:             "05",
+			CAVV:            "AAABBBCCC",
+			DSTransactionID: "ds-1",
+			LiabilityShift:  true,
+		},
+	}
+
+	data, err := json.Marshal(v1.Codec{}.EncodePayment(payment))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"id": "p1",
+		"payment_status": "Authorized",
+		"card_number_last_four": "8877",
+		"expiry_month": 4,
+		"expiry_year": 2099,
+		"currency": "EUR",
+		"amount": 100,
+		"three_ds": {
+			"transaction_id": "t-1",
+			"version": "2.2.0",
+			"status": "authenticated",
+			"eci": "05",
+			"ds_transaction_id": "ds-1",
+			"liability_shift": true
+		}
+	}`, string(data), "the CAVV is not returned")
+}
+
+func TestCodec_Tokens(t *testing.T) {
+	card, err := v1.Codec{}.DecodeTokenRequest(strings.NewReader(`{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099}`))
+	require.NoError(t, err)
+	assert.Equal(t, &vault.Card{Number: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2099}, card)
+
+	data, err := json.Marshal(v1.Codec{}.EncodeToken(&vault.Token{Token: "tok_1", LastFour: "8877", ExpiryMonth: 4, ExpiryYear: 2099}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"token":"tok_1","card_number_last_four":"8877","expiry_month":4,"expiry_year":2099}`, string(data))
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/subscription"
+)
+
+// SubscriptionRequest is the body of POST /v1/subscriptions.
+type SubscriptionRequest struct {
+	// CardToken is the token of the card stored by the initial payment.
+	CardToken string `json:"card_token"`
+	Amount    int    `json:"amount"`
+	Currency  string `json:"currency"`
+	// Interval is day, week, month or year.
+	Interval subscription.Interval `json:"interval"`
+	// IntervalCount is the number of intervals in a billing period; 1 when
+	// omitted.
+	IntervalCount int `json:"interval_count,omitempty"`
+	// InitialPaymentID is the authorized payment sent with stored_credential
+	// {"initiator": "customer", "sequence": "initial"}.
+	InitialPaymentID string `json:"initial_payment_id"`
+	// StartAt is the first charge; one billing period from now when
+	// omitted, the initial payment paying for the first.
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// Subscription is a subscription as returned by the v1 subscription
+// endpoints.
+type Subscription struct {
+	Id string `json:"id"`
+	// Status is Active, PastDue while a failed charge is retried,
+	// Suspended once charges stopped failing, or Cancelled.
+	Status               string                `json:"status"`
+	CardToken            string                `json:"card_token"`
+	Amount               int                   `json:"amount"`
+	Currency             string                `json:"currency"`
+	Interval             subscription.Interval `json:"interval"`
+	IntervalCount        int                   `json:"interval_count"`
+	InitialPaymentID     string                `json:"initial_payment_id"`
+	NetworkTransactionID string                `json:"network_transaction_id"`
+	CreatedAt            time.Time             `json:"created_at"`
+	StartAt              time.Time             `json:"start_at"`
+	PeriodsPaid          int                   `json:"periods_paid"`
+	// NextChargeAt is absent once the subscription is Suspended or
+	// Cancelled.
+	NextChargeAt *time.Time           `json:"next_charge_at,omitempty"`
+	CancelledAt  *time.Time           `json:"cancelled_at,omitempty"`
+	Charges      []SubscriptionCharge `json:"charges"`
+}
+
+// SubscriptionCharge is one merchant-initiated payment attempted for a
+// subscription.
+type SubscriptionCharge struct {
+	// Period is the billing period charged, from 1, and Attempt the charge
+	// of the period, from 1.
+	Period  int       `json:"period"`
+	Attempt int       `json:"attempt"`
+	At      time.Time `json:"at"`
+	// PaymentId identifies the stored payment, which GET /v1/payments/{id}
+	// returns. It is empty for Rejected and Failed charges.
+	PaymentId     string               `json:"payment_id,omitempty"`
+	PaymentStatus string               `json:"payment_status"`
+	DeclineCode   payments.DeclineCode `json:"decline_code,omitempty"`
+	// Code and Detail say why a Rejected or Failed charge stored no
+	// payment.
+	Code   problem.Code `json:"code,omitempty"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// ToDomain converts the request to the domain subscription request.
+func (r *SubscriptionRequest) ToDomain() *subscription.Request {
+	return &subscription.Request{
+		CardToken:        r.CardToken,
+		Amount:           r.Amount,
+		Currency:         r.Currency,
+		Interval:         r.Interval,
+		IntervalCount:    r.IntervalCount,
+		InitialPaymentID: r.InitialPaymentID,
+		StartAt:          r.StartAt,
+	}
+}
+
+// SubscriptionFromDomain converts a subscription to its v1 model.
+func SubscriptionFromDomain(s *subscription.Subscription) *Subscription {
+	out := &Subscription{
+		Id:                   s.ID,
+		Status:               string(s.Status),
+		CardToken:            s.CardToken,
+		Amount:               s.Amount,
+		Currency:             s.Currency,
+		Interval:             s.Interval,
+		IntervalCount:        s.IntervalCount,
+		InitialPaymentID:     s.InitialPaymentID,
+		NetworkTransactionID: s.NetworkTransactionID,
+		CreatedAt:            s.CreatedAt,
+		StartAt:              s.StartAt,
+		PeriodsPaid:          s.PeriodsPaid,
+		NextChargeAt:         s.NextChargeAt,
+		CancelledAt:          s.CancelledAt,
+		Charges:              make([]SubscriptionCharge, len(s.Charges)),
+	}
+	for i, c := range s.Charges {
+		out.Charges[i] = SubscriptionCharge{
+			Period:        c.Period,
+			Attempt:       c.Attempt,
+			At:            c.At,
+			PaymentId:     c.PaymentID,
+			PaymentStatus: c.PaymentStatus,
+			DeclineCode:   c.DeclineCode,
+		}
+		if c.Problem != nil {
+			out.Charges[i].Code = c.Problem.Code
+			out.Charges[i].Detail = c.Problem.Detail
+		}
+	}
+	return out
+}
+
+func (Codec) DecodeSubscriptionRequest(r io.Reader) (*subscription.Request, error) {
+	var req SubscriptionRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) EncodeSubscription(s *subscription.Subscription) any {
+	return SubscriptionFromDomain(s)
+}
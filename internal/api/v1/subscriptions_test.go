@@ -0,0 +1,80 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/subscription"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_DecodeSubscriptionRequest(t *testing.T) {
+	got, err := v1.Codec{}.DecodeSubscriptionRequest(strings.NewReader(`{"card_token":"tok_1","amount":1000,"currency":"USD",
+		"interval":"month","interval_count":3,"initial_payment_id":"p1","start_at":"2099-01-31T09:00:00Z"}`))
+	require.NoError(t, err)
+	start := time.Date(2099, 1, 31, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, &subscription.Request{CardToken: "tok_1", Amount: 1000, Currency: "USD", Interval: subscription.IntervalMonth,
+		IntervalCount: 3, InitialPaymentID: "p1", StartAt: &start}, got)
+
+	_, err = v1.Codec{}.DecodeSubscriptionRequest(strings.NewReader(`{"amount":"1000"}`))
+	assert.Error(t, err)
+}
+
+func TestCodec_EncodeSubscription(t *testing.T) {
+	created := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	next := time.Date(2026, 12, 1, 2, 0, 0, 0, time.UTC)
+	s := &subscription.Subscription{
+		ID:                   "s1",
+		Merchant:             "acme",
+		Status:               subscription.StatusPastDue,
+		CardToken:            "tok_1",
+		Amount:               1000,
+		Currency:             "USD",
+		Interval:             subscription.IntervalMonth,
+		IntervalCount:        1,
+		InitialPaymentID:     "p0",
+		NetworkTransactionID: "NTI-1",
+		CreatedAt:            created,
+		StartAt:              created,
+		PeriodsPaid:          1,
+		FailedAttempts:       1,
+		NextChargeAt:         &next,
+		Charges: []subscription.Charge{
+			{Period: 1, Attempt: 1, At: created, PaymentID: "p1", PaymentStatus: "Authorized"},
+			{Period: 2, Attempt: 1, At: created.AddDate(0, 1, 0), PaymentStatus: "Failed",
+				Problem: problem.New(problem.CodeUpstreamUnavailable, "bank unavailable").WithPaymentStatus("Failed")},
+			{Period: 2, Attempt: 2, At: next, PaymentID: "p2", PaymentStatus: "Declined", DeclineCode: payments.DeclineInsufficientFunds},
+		},
+	}
+
+	data, err := json.Marshal(v1.Codec{}.EncodeSubscription(s))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "s1",
+		"status": "PastDue",
+		"card_token": "tok_1",
+		"amount": 1000,
+		"currency": "USD",
+		"interval": "month",
+		"interval_count": 1,
+		"initial_payment_id": "p0",
+		"network_transaction_id": "NTI-1",
+		"created_at": "2026-10-01T02:00:00Z",
+		"start_at": "2026-10-01T02:00:00Z",
+		"periods_paid": 1,
+		"next_charge_at": "2026-12-01T02:00:00Z",
+		"charges": [
+			{"period": 1, "attempt": 1, "at": "2026-10-01T02:00:00Z", "payment_id": "p1", "payment_status": "Authorized"},
+			{"period": 2, "attempt": 1, "at": "2026-11-01T02:00:00Z", "payment_status": "Failed",
+			 "code": "upstream_unavailable", "detail": "bank unavailable"},
+			{"period": 2, "attempt": 2, "at": "2026-12-01T02:00:00Z", "payment_id": "p2", "payment_status": "Declined",
+			 "decline_code": "insufficient_funds"}
+		]
+	}`, string(data))
+}
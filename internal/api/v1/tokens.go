@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/vault"
+)
+
+// TokenRequest is the body of POST /v1/tokens. There is deliberately no CVV
+// field: it must not be stored, so it is not accepted.
+type TokenRequest struct {
+	CardNumber  string `json:"card_number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+// Token is a card token as returned by POST /v1/tokens.
+type Token struct {
+	Token              string `json:"token"`
+	CardNumberLastFour string `json:"card_number_last_four"`
+	ExpiryMonth        int    `json:"expiry_month"`
+	ExpiryYear         int    `json:"expiry_year"`
+}
+
+// ToDomain converts the request to the card accepted by the vault.
+func (r *TokenRequest) ToDomain() *vault.Card {
+	return &vault.Card{Number: r.CardNumber, ExpiryMonth: r.ExpiryMonth, ExpiryYear: r.ExpiryYear}
+}
+
+// TokenFromDomain converts an issued token to its v1 model.
+func TokenFromDomain(t *vault.Token) *Token {
+	return &Token{
+		Token:              t.Token,
+		CardNumberLastFour: t.LastFour,
+		ExpiryMonth:        t.ExpiryMonth,
+		ExpiryYear:         t.ExpiryYear,
+	}
+}
+
+func (Codec) DecodeTokenRequest(r io.Reader) (*vault.Card, error) {
+	var req TokenRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.ToDomain(), nil
+}
+
+func (Codec) EncodeToken(t *vault.Token) any {
+	return TokenFromDomain(t)
+}
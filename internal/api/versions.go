@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/batch"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/customer"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/subscription"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/vault"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+)
+
+// VersionHeader asks for an API version on the unversioned /api paths, and
+// reports the version that served a request on every versioned path.
+const VersionHeader = "API-Version"
+
+// versionMediaType is the Accept media type asking for a version, with %s
+// standing for the version name.
+const versionMediaType = "application/vnd.payment-gateway.%s+json"
+
+// unversioned names the /api alias in api.deprecations.
+const unversioned = "unversioned"
+
+// codec converts between one version's wire models and the domain models.
+type codec interface {
+	payments.Codec
+	vault.Codec
+	batch.Codec
+	subscription.Codec
+	customer.Codec
+}
+
+type apiVersion struct {
+	name  string
+	codec codec
+}
+
+// apiVersions lists the served versions, oldest first. The /api alias
+// defaults to the first one, which is what it served before versions
+// existed.
+var apiVersions = []apiVersion{
+	{name: "v1", codec: v1.Codec{}},
+}
+
+func findVersion(name string) (apiVersion, bool) {
+	for _, v := range apiVersions {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return apiVersion{}, false
+}
+
+func versionNames() string {
+	names := make([]string, len(apiVersions))
+	for i, v := range apiVersions {
+		names[i] = v.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// pinVersion serves the routes under /<v.name> with version v.
+func (a *Api) pinVersion(v apiVersion) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.deprecated(w, r, v.name, "") {
+				return
+			}
+			serveVersion(w, r, next, v)
+		})
+	}
+}
+
+// negotiateVersion serves the /api alias with the version asked for by the
+// API-Version header or the Accept media type, or the oldest version when
+// the request does not ask.
+func (a *Api) negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := requestedVersion(r)
+		v, ok := apiVersions[0], true
+		if name != "" {
+			v, ok = findVersion(name)
+		}
+		if !ok {
+			problem.Write(w, r, problem.New(problem.CodeUnsupportedVersion,
+				fmt.Sprintf("API version %q is not supported (supported: %s)", name, versionNames())))
+			return
+		}
+
+		successor := "/" + apiVersions[len(apiVersions)-1].name + strings.TrimPrefix(r.URL.Path, "/api")
+		if a.deprecated(w, r, unversioned, successor) || a.deprecated(w, r, v.name, "") {
+			return
+		}
+		serveVersion(w, r, next, v)
+	})
+}
+
+func serveVersion(w http.ResponseWriter, r *http.Request, next http.Handler, v apiVersion) {
+	w.Header().Set(VersionHeader, v.name)
+	ctx := wire.With[payments.Codec](r.Context(), v.codec)
+	ctx = wire.With[vault.Codec](ctx, v.codec)
+	ctx = wire.With[batch.Codec](ctx, v.codec)
+	ctx = wire.With[subscription.Codec](ctx, v.codec)
+	ctx = wire.With[customer.Codec](ctx, v.codec)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// requestedVersion returns the version named by the API-Version header,
+// which may omit the "v", or else by the Accept media type. It returns ""
+// when the request names none.
+func requestedVersion(r *http.Request) string {
+	if name := strings.TrimSpace(r.Header.Get(VersionHeader)); name != "" {
+		if !strings.HasPrefix(name, "v") {
+			name = "v" + name
+		}
+		return name
+	}
+
+	prefix, suffix, _ := strings.Cut(versionMediaType, "%s")
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			mediaType = strings.TrimSpace(mediaType)
+			if strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) {
+				return strings.TrimSuffix(strings.TrimPrefix(mediaType, prefix), suffix)
+			}
+		}
+	}
+	return ""
+}
+
+// deprecated writes the Deprecation and Sunset headers of a deprecated
+// version, and a Link to successor when there is one. It answers 410 Gone
+// and returns true once the sunset has passed.
+func (a *Api) deprecated(w http.ResponseWriter, r *http.Request, name, successor string) bool {
+	dep, ok := a.deprecations[name]
+	if !ok {
+		return false
+	}
+
+	// RFC 9745 and RFC 8594.
+	w.Header().Set("Deprecation", fmt.Sprintf("@%d", dep.Since.Unix()))
+	if !dep.Sunset.IsZero() {
+		w.Header().Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if successor != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+	}
+
+	if dep.Sunset.IsZero() || time.Now().Before(dep.Sunset) {
+		return false
+	}
+	problem.Write(w, r, problem.New(problem.CodeVersionRetired,
+		fmt.Sprintf("API version %s was retired on %s", name, dep.Sunset.UTC().Format(time.DateOnly))))
+	return true
+}
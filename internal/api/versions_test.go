@@ -0,0 +1,149 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersioning(t *testing.T) {
+	const (
+		deprecatedAlias = `
+api:
+  deprecations:
+    unversioned: {since: 2026-01-01T00:00:00Z, sunset: 2999-01-01T00:00:00Z}
+`
+		retiredV1 = `
+api:
+  deprecations:
+    v1: {since: 2020-01-01T00:00:00Z, sunset: 2021-01-01T00:00:00Z}
+`
+	)
+
+	tests := []struct {
+		name        string
+		config      string
+		path        string
+		header      http.Header
+		wantStatus  int
+		wantCode    problem.Code
+		wantVersion string
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "Alias defaults to v1",
+			path:        "/api/payments/missing",
+			wantStatus:  http.StatusNotFound,
+			wantCode:    problem.CodePaymentNotFound,
+			wantVersion: "v1",
+			wantHeaders: map[string]string{"Deprecation": "", "Sunset": ""},
+		},
+		{
+			name:        "Alias negotiates with API-Version",
+			path:        "/api/payments/missing",
+			header:      http.Header{api.VersionHeader: {"1"}},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    problem.CodePaymentNotFound,
+			wantVersion: "v1",
+		},
+		{
+			name:        "Alias negotiates with Accept",
+			path:        "/api/payments/missing",
+			header:      http.Header{"Accept": {"text/plain;q=0.5, application/vnd.payment-gateway.v1+json"}},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    problem.CodePaymentNotFound,
+			wantVersion: "v1",
+		},
+		{
+			name:       "Alias rejects an unknown API-Version",
+			path:       "/api/payments/missing",
+			header:     http.Header{api.VersionHeader: {"v9"}},
+			wantStatus: http.StatusNotAcceptable,
+			wantCode:   problem.CodeUnsupportedVersion,
+		},
+		{
+			name:       "Alias rejects an unknown Accept version",
+			path:       "/api/payments/missing",
+			header:     http.Header{"Accept": {"application/vnd.payment-gateway.v9+json"}},
+			wantStatus: http.StatusNotAcceptable,
+			wantCode:   problem.CodeUnsupportedVersion,
+		},
+		{
+			name:        "Versioned path ignores API-Version",
+			path:        "/v1/payments/missing",
+			header:      http.Header{api.VersionHeader: {"v9"}},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    problem.CodePaymentNotFound,
+			wantVersion: "v1",
+		},
+		{
+			name:        "Deprecated alias announces its sunset and successor",
+			config:      deprecatedAlias,
+			path:        "/api/payments/missing",
+			wantStatus:  http.StatusNotFound,
+			wantCode:    problem.CodePaymentNotFound,
+			wantVersion: "v1",
+			wantHeaders: map[string]string{
+				"Deprecation": "@1767225600",
+				"Sunset":      "Tue, 01 Jan 2999 00:00:00 GMT",
+				"Link":        `</v1/payments/missing>; rel="successor-version"`,
+			},
+		},
+		{
+			name:        "Deprecating the alias leaves the versioned path alone",
+			config:      deprecatedAlias,
+			path:        "/v1/payments/missing",
+			wantStatus:  http.StatusNotFound,
+			wantCode:    problem.CodePaymentNotFound,
+			wantVersion: "v1",
+			wantHeaders: map[string]string{"Deprecation": "", "Sunset": "", "Link": ""},
+		},
+		{
+			name:       "Retired version answers 410",
+			config:     retiredV1,
+			path:       "/v1/payments/missing",
+			wantStatus: http.StatusGone,
+			wantCode:   problem.CodeVersionRetired,
+			wantHeaders: map[string]string{
+				"Deprecation": "@1577836800",
+				"Sunset":      "Fri, 01 Jan 2021 00:00:00 GMT",
+			},
+		},
+		{
+			name:       "Retired version is retired on the alias too",
+			config:     retiredV1,
+			path:       "/api/payments/missing",
+			wantStatus: http.StatusGone,
+			wantCode:   problem.CodeVersionRetired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGateway(t, tt.config)
+			req, err := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			require.NoError(t, err)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			var body problem.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.Equal(t, tt.wantVersion, resp.Header.Get(api.VersionHeader))
+			for name, want := range tt.wantHeaders {
+				assert.Equal(t, want, resp.Header.Get(name), name)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+// Package audit keeps an append-only, tamper-evident record of state-changing
+// and administrative actions. Every event carries the hash of the previous
+// one, so removing, reordering or editing an event breaks the chain from that
+// point on and is reported by Verify.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+)
+
+// Action names what an event records.
+type Action string
+
+const (
+	ActionPaymentCreated        Action = "payment.created"
+	ActionTokenCreated          Action = "token.created"
+	ActionConfigReloaded        Action = "config.reloaded"
+	ActionKeyRotationStarted    Action = "keys.rotation_started"
+	ActionKeyRotationStopped    Action = "keys.rotation_stopped"
+	ActionSubscriptionCreated   Action = "subscription.created"
+	ActionSubscriptionCancelled Action = "subscription.cancelled"
+	ActionCustomerCreated       Action = "customer.created"
+	ActionCustomerUpdated       Action = "customer.updated"
+	ActionCustomerDeleted       Action = "customer.deleted"
+)
+
+// SystemActor is the actor of events not caused by an HTTP request, such as
+// reloads on SIGHUP or a config file change.
+const SystemActor = "system"
+
+// Event is one entry of the audit log.
+type Event struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Action    Action    `json:"action"`
+	Actor     string    `json:"actor"`
+	SourceIP  string    `json:"source_ip,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	// Resource identifies what the action applied to, e.g. "payment:<id>".
+	Resource string `json:"resource,omitempty"`
+	// Before and After summarise the state around the action. Card data is
+	// redacted before the event is recorded.
+	Before map[string]any `json:"before,omitempty"`
+	After  map[string]any `json:"after,omitempty"`
+	// PrevHash is the Hash of the previous event; it is empty for the first.
+	PrevHash string `json:"prev_hash,omitempty"`
+	// Hash is the SHA-256 of the event encoded as JSON without Hash.
+	Hash string `json:"hash"`
+}
+
+func (e Event) computeHash() string {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		// Summaries are normalised to plain JSON values before they are
+		// recorded, so this cannot happen.
+		panic(fmt.Sprintf("audit: failed to encode event: %v", err))
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log is an append-only, hash-chained audit log. Events are kept in memory
+// and, when the log was opened with a path, appended to that file as JSON
+// lines. A nil *Log records nothing.
+type Log struct {
+	mu     sync.Mutex
+	events []Event
+	file   *os.File
+	now    func() time.Time
+}
+
+// Open returns a Log writing to the file at path, creating it if needed. An
+// existing file is verified and the chain continues from its last event; a
+// file that fails verification is not opened. With an empty path the log is
+// kept in memory only.
+func Open(path string) (*Log, error) {
+	l := &Log{now: time.Now}
+	if path == "" {
+		return l, nil
+	}
+
+	events, err := ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := Verify(events); err != nil {
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+
+	l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.events = events
+	return l, nil
+}
+
+// Close closes the log file, if any.
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Record appends an event for action on resource. The actor, source IP and
+// request ID are taken from the Request in ctx. Failing to write the log
+// file is logged; the event is still kept in memory.
+func (l *Log) Record(ctx context.Context, action Action, resource string, before, after map[string]any) {
+	if l == nil {
+		return
+	}
+	req := RequestFromContext(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := Event{
+		Seq:       uint64(len(l.events)) + 1,
+		Time:      l.now().UTC(),
+		Action:    action,
+		Actor:     logging.Redact(req.Actor),
+		SourceIP:  req.SourceIP,
+		RequestID: req.RequestID,
+		Resource:  resource,
+		Before:    summary(before),
+		After:     summary(after),
+	}
+	if len(l.events) > 0 {
+		e.PrevHash = l.events[len(l.events)-1].Hash
+	}
+	e.Hash = e.computeHash()
+	l.events = append(l.events, e)
+
+	if l.file != nil {
+		line, _ := json.Marshal(e)
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			slog.ErrorContext(ctx, "failed to write audit event", "seq", e.Seq, "action", action, "error", err)
+		}
+	}
+}
+
+// Filter selects events in Query. Zero fields match everything.
+type Filter struct {
+	Action   Action
+	Actor    string
+	Resource string
+	Since    time.Time
+	Until    time.Time
+	// AfterSeq skips events up to and including this sequence number, for
+	// paging.
+	AfterSeq uint64
+	// Limit caps the number of events returned.
+	Limit int
+}
+
+func (f Filter) matches(e Event) bool {
+	return e.Seq > f.AfterSeq &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Resource == "" || e.Resource == f.Resource) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Query returns the events matching f, oldest first.
+func (l *Log) Query(f Filter) []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []Event
+	for _, e := range l.events[min(int(f.AfterSeq), len(l.events)):] {
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+		if f.matches(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Verify checks the chain of events held by l and returns how many there are.
+func (l *Log) Verify() (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events), Verify(l.events)
+}
+
+// Verify checks that events form an unbroken chain starting at sequence 1.
+// It reports the first event that does not match.
+func Verify(events []Event) error {
+	prev := ""
+	for i, e := range events {
+		if want := uint64(i) + 1; e.Seq != want {
+			return fmt.Errorf("event %d: sequence is %d, want %d", want, e.Seq, want)
+		}
+		if e.PrevHash != prev {
+			return fmt.Errorf("event %d: previous hash does not match event %d", e.Seq, e.Seq-1)
+		}
+		if e.Hash != e.computeHash() {
+			return fmt.Errorf("event %d: hash does not match its contents", e.Seq)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// ReadFile reads the events of an audit log file without verifying them.
+func ReadFile(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		e, err := decodeEvent(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, nil
+}
+
+// decodeEvent decodes one JSON event. Numbers are kept as json.Number so
+// that re-encoding the event, and therefore its hash, is exact.
+func decodeEvent(data []byte) (Event, error) {
+	var e Event
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&e)
+	return e, err
+}
+
+// summary copies m into plain JSON values, so that later changes by the
+// caller cannot alter a recorded event. Card data is redacted the same way
+// as in logs: sensitive keys are dropped and strings resembling a PAN or a
+// CVV are masked.
+func summary(m map[string]any) map[string]any {
+	if len(m) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return map[string]any{"error": "summary could not be encoded"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out map[string]any
+	if err := dec.Decode(&out); err != nil {
+		return map[string]any{"error": "summary could not be encoded"}
+	}
+	return redact(out).(map[string]any)
+}
+
+func redact(v any) any {
+	switch v := v.(type) {
+	case string:
+		return logging.Redact(v)
+	case map[string]any:
+		for k, item := range v {
+			if logging.IsSensitiveKey(k) {
+				delete(v, k)
+				continue
+			}
+			v[k] = redact(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return v
+}
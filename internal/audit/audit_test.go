@@ -0,0 +1,159 @@
+package audit_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func merchantCtx(merchant string) context.Context {
+	return audit.WithRequest(context.Background(), audit.Request{
+		Actor:     "merchant:" + merchant,
+		SourceIP:  "10.0.0.1",
+		RequestID: "req-" + merchant,
+	})
+}
+
+func TestLog_RecordAndQuery(t *testing.T) {
+	log, err := audit.Open("")
+	require.NoError(t, err)
+
+	log.Record(merchantCtx("acme"), audit.ActionPaymentCreated, "payment:1", nil, map[string]any{"amount": 100})
+	log.Record(merchantCtx("globex"), audit.ActionPaymentCreated, "payment:2", nil, map[string]any{"amount": 200})
+	log.Record(context.Background(), audit.ActionConfigReloaded, "config",
+		map[string]any{"log": "info"}, map[string]any{"log": "debug"})
+
+	all := log.Query(audit.Filter{})
+	require.Len(t, all, 3)
+	assert.Equal(t, uint64(1), all[0].Seq)
+	assert.Equal(t, "merchant:acme", all[0].Actor)
+	assert.Equal(t, "10.0.0.1", all[0].SourceIP)
+	assert.Equal(t, "req-acme", all[0].RequestID)
+	assert.Empty(t, all[0].PrevHash)
+	assert.Equal(t, all[0].Hash, all[1].PrevHash)
+	assert.Equal(t, audit.SystemActor, all[2].Actor, "events outside a request are made by the system")
+
+	tests := []struct {
+		name   string
+		filter audit.Filter
+		want   []uint64
+	}{
+		{name: "Action", filter: audit.Filter{Action: audit.ActionPaymentCreated}, want: []uint64{1, 2}},
+		{name: "Actor", filter: audit.Filter{Actor: "merchant:globex"}, want: []uint64{2}},
+		{name: "Resource", filter: audit.Filter{Resource: "config"}, want: []uint64{3}},
+		{name: "After", filter: audit.Filter{AfterSeq: 1}, want: []uint64{2, 3}},
+		{name: "Limit", filter: audit.Filter{Limit: 2}, want: []uint64{1, 2}},
+		{name: "Until", filter: audit.Filter{Until: all[0].Time}, want: nil},
+		{name: "Since", filter: audit.Filter{Since: time.Now().Add(time.Hour)}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint64
+			for _, e := range log.Query(tt.filter) {
+				got = append(got, e.Seq)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	n, err := log.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestLog_RedactsCardData(t *testing.T) {
+	log, err := audit.Open("")
+	require.NoError(t, err)
+
+	after := map[string]any{
+		"card_number": "4111111111111111",
+		"cvv":         "123",
+		"note":        "card 4111 1111 1111 1111 retried",
+		"nested":      map[string]any{"pan": "4111111111111111"},
+	}
+	log.Record(context.Background(), audit.ActionPaymentCreated, "payment:1", nil, after)
+	after["note"] = "changed by the caller"
+
+	e := log.Query(audit.Filter{})[0]
+	assert.Equal(t, map[string]any{
+		"note":   "card ************1111 retried",
+		"nested": map[string]any{},
+	}, e.After)
+	_, err = log.Verify()
+	assert.NoError(t, err, "the caller cannot alter a recorded event")
+}
+
+func TestLog_NilRecordsNothing(t *testing.T) {
+	var log *audit.Log
+	log.Record(context.Background(), audit.ActionPaymentCreated, "payment:1", nil, nil)
+	assert.Empty(t, log.Query(audit.Filter{}))
+	assert.NoError(t, log.Close())
+}
+
+func TestLog_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	log, err := audit.Open(path)
+	require.NoError(t, err)
+	log.Record(merchantCtx("acme"), audit.ActionPaymentCreated, "payment:1", nil, map[string]any{"amount": 100})
+	log.Record(merchantCtx("acme"), audit.ActionPaymentCreated, "payment:2", nil, map[string]any{"amount": 250})
+	require.NoError(t, log.Close())
+
+	// Reopening continues the chain from the file.
+	log, err = audit.Open(path)
+	require.NoError(t, err)
+	log.Record(merchantCtx("acme"), audit.ActionTokenCreated, "token:tok_1", nil, nil)
+	require.NoError(t, log.Close())
+
+	events, err := audit.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.NoError(t, audit.Verify(events))
+	assert.Equal(t, events[1].Hash, events[2].PrevHash)
+
+	original, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(original), "\n"), "\n")
+
+	tests := []struct {
+		name    string
+		tamper  func() string
+		wantErr string
+	}{
+		{
+			name:    "Edited event",
+			tamper:  func() string { return strings.Replace(string(original), `"amount":250`, `"amount":25`, 1) },
+			wantErr: "event 2: hash does not match its contents",
+		},
+		{
+			name:    "Removed event",
+			tamper:  func() string { return lines[0] + lines[2] },
+			wantErr: "event 2: sequence is 3, want 2",
+		},
+		{
+			name:    "Reordered events",
+			tamper:  func() string { return lines[1] + lines[0] + lines[2] },
+			wantErr: "event 1: sequence is 2, want 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := filepath.Join(t.TempDir(), "audit.log")
+			require.NoError(t, os.WriteFile(tampered, []byte(tt.tamper()), 0o600))
+
+			events, err := audit.ReadFile(tampered)
+			require.NoError(t, err)
+			assert.ErrorContains(t, audit.Verify(events), tt.wantErr)
+
+			_, err = audit.Open(tampered)
+			assert.ErrorContains(t, err, tt.wantErr, "a tampered log is not opened")
+		})
+	}
+}
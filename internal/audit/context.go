@@ -0,0 +1,28 @@
+package audit
+
+import "context"
+
+// Request describes who made the request an event is recorded for.
+type Request struct {
+	Actor     string
+	SourceIP  string
+	RequestID string
+}
+
+type ctxKey struct{}
+
+// WithRequest returns a copy of ctx carrying req.
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, ctxKey{}, req)
+}
+
+// RequestFromContext returns the Request stored in ctx. Without one, the
+// actor is SystemActor.
+func RequestFromContext(ctx context.Context) Request {
+	if req, ok := ctx.Value(ctxKey{}).(Request); ok && req.Actor != "" {
+		return req
+	}
+	req, _ := ctx.Value(ctxKey{}).(Request)
+	req.Actor = SystemActor
+	return req
+}
@@ -2,36 +2,224 @@ package bank
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bulkhead"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrBankUnavailable = errors.New("bank service is unavailable")
 
+// ErrBankOverloaded is returned when the acquirer's bulkhead refuses a call.
+var ErrBankOverloaded = payments.ErrBankOverloaded
+
+// DefaultAcquirer is the acquirer name used when none is configured.
+const DefaultAcquirer = "default"
+
 type Client interface {
-	ProcessPayment(req *payments.PostPaymentRequest) (*BankPaymentResponse, error)
+	ProcessPayment(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, error)
 }
 
+// DefaultTimeout bounds each call to the acquirer unless WithTimeout is given.
+const DefaultTimeout = 5 * time.Second
+
 type BankClient struct {
+	name       string
 	baseURL    string
 	httpClient *http.Client
+	// timeout is stored atomically so it can be changed by a config reload
+	// while payments are in flight.
+	timeout atomic.Int64
+	// declineCodes maps normalized decline reasons of this acquirer to
+	// payments.DeclineCode values.
+	declineCodes map[string]payments.DeclineCode
+	// signer signs payment request bodies; nil sends them unsigned.
+	signer Signer
+
+	transport TransportOptions
+	tlsConfig *tls.Config
+	// maxAttempts and attemptTimeout bound the attempts of one call, all of
+	// which share the overall timeout.
+	maxAttempts    int
+	attemptTimeout time.Duration
+	// bulkhead bounds concurrent calls; nil leaves them unbounded.
+	bulkhead *bulkhead.Bulkhead
+	// hedging is nil unless WithHedging is given. latencies feeds its
+	// delay, and idempotent records whether the acquirer echoed the last
+	// reference it answered.
+	hedging    *Hedging
+	latencies  *latencyWindow
+	idempotent atomic.Bool
+}
+
+// Option customises a BankClient built by NewBankClient.
+type Option func(*BankClient)
+
+// WithName sets the acquirer name reported in authorizations, logs and metrics.
+func WithName(name string) Option {
+	return func(c *BankClient) {
+		c.name = name
+	}
+}
+
+// WithTimeout sets the overall timeout of each call to the acquirer,
+// retries included.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *BankClient) {
+		c.SetTimeout(timeout)
+	}
 }
 
-func NewBankClient(baseURL string) *BankClient {
-	return &BankClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+// WithAttempts makes up to maxAttempts attempts per call, each bounded by
+// attemptTimeout (0 for the overall timeout only). An attempt is retried
+// only when the acquirer cannot have processed it: the request was never
+// sent, or the acquirer answered 503.
+func WithAttempts(maxAttempts int, attemptTimeout time.Duration) Option {
+	return func(c *BankClient) {
+		c.maxAttempts = maxAttempts
+		c.attemptTimeout = attemptTimeout
 	}
 }
 
-func (c *BankClient) ProcessPayment(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+// WithBulkhead bounds the concurrent calls to the acquirer with b. Calls it
+// refuses fail with ErrBankOverloaded without reaching the acquirer.
+func WithBulkhead(b *bulkhead.Bulkhead) Option {
+	return func(c *BankClient) {
+		c.bulkhead = b
+	}
+}
+
+func NewBankClient(baseURL string, opts ...Option) *BankClient {
+	c := &BankClient{
+		name:         DefaultAcquirer,
+		baseURL:      baseURL,
+		declineCodes: defaultDeclineTable(),
+		transport:    DefaultTransportOptions(),
+		maxAttempts:  1,
+	}
+	c.SetTimeout(DefaultTimeout)
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.httpClient = &http.Client{Transport: newTransport(c.transport, c.tlsConfig)}
+	if c.bulkhead != nil {
+		metrics.BankConcurrencyLimit.WithLabelValues(c.name).Set(float64(c.bulkhead.Limit()))
+	}
+	return c
+}
+
+// Name returns the acquirer name of this client.
+func (c *BankClient) Name() string {
+	return c.name
+}
+
+// Timeout returns the current per-call timeout.
+func (c *BankClient) Timeout() time.Duration {
+	return time.Duration(c.timeout.Load())
+}
+
+// SetTimeout changes the per-call timeout. Calls already in flight keep the
+// timeout they started with.
+func (c *BankClient) SetTimeout(timeout time.Duration) {
+	c.timeout.Store(int64(timeout))
+}
+
+func (c *BankClient) ProcessPayment(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "bank.ProcessPayment",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("bank.acquirer", c.name)),
+	)
+	defer span.End()
+
+	// The call is not cancelled with ctx: once the request is sent, the
+	// acquirer may authorize the payment even if the caller has gone, so its
+	// answer is always awaited, within the timeout.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.Timeout())
+	defer cancel()
+
+	if c.bulkhead != nil {
+		release, err := c.acquire(ctx)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		defer release()
+	}
+
+	start := time.Now()
+	auth, errClass, err := c.authorize(ctx, req)
+	duration := time.Since(start)
+
+	outcome := "error"
+	switch {
+	case err != nil:
+		metrics.BankErrorsTotal.WithLabelValues(c.name, errClass).Inc()
+		logging.FromContext(ctx).WarnContext(ctx, "bank request failed",
+			"acquirer", c.name, "class", errClass, "error", err, "duration", duration)
+		span.SetAttributes(attribute.String("bank.error_class", errClass))
+		span.SetStatus(codes.Error, logging.Redact(err.Error()))
+	case auth.Authorized:
+		outcome = "authorized"
+	default:
+		outcome = "declined"
+		span.SetAttributes(attribute.String("bank.decline_code", string(auth.DeclineCode)))
+	}
+	metrics.BankRequestDuration.WithLabelValues(c.name, outcome).Observe(duration.Seconds())
+	span.SetAttributes(attribute.String("bank.outcome", outcome))
+
+	return auth, err
+}
+
+// acquire takes a slot in the bulkhead. The returned release frees it and
+// updates the concurrency metrics.
+func (c *BankClient) acquire(ctx context.Context) (func(), error) {
+	release, err := c.bulkhead.Acquire(ctx)
+	if err != nil {
+		metrics.BankRejectedTotal.WithLabelValues(c.name).Inc()
+		logging.FromContext(ctx).WarnContext(ctx, "bank call refused by bulkhead",
+			"acquirer", c.name, "limit", c.bulkhead.Limit(), "error", err)
+		if errors.Is(err, bulkhead.ErrFull) {
+			return nil, ErrBankOverloaded
+		}
+		return nil, ErrBankUnavailable
+	}
+
+	inFlight := metrics.BankInFlight.WithLabelValues(c.name)
+	inFlight.Inc()
+	return func() {
+		release()
+		inFlight.Dec()
+		metrics.BankConcurrencyLimit.WithLabelValues(c.name).Set(float64(c.bulkhead.Limit()))
+	}, nil
+}
+
+// maxDrain bounds the unread response bytes discarded to keep a connection
+// reusable; larger leftovers close it instead.
+const maxDrain = 4 << 10
+
+// retryBackoff is the pause before the second attempt of a call, doubled
+// for each further attempt.
+const retryBackoff = 50 * time.Millisecond
+
+// authorize performs the HTTP calls. On failure it also returns the error
+// class reported in metrics.BankErrorsTotal.
+func (c *BankClient) authorize(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, string, error) {
 	bankReq := BankPaymentRequest{
 		CardNumber: req.CardNumber,
 		ExpiryDate: c.formatExpiryDate(req.ExpiryMonth, req.ExpiryYear),
@@ -39,51 +227,169 @@ func (c *BankClient) ProcessPayment(req *payments.PostPaymentRequest) (*payments
 		Amount:     req.Amount,
 		Cvv:        req.Cvv,
 	}
+	if sc := req.StoredCredential; sc != nil {
+		bankReq.StoredCredential = &BankStoredCredential{
+			Initiator:            string(sc.Initiator),
+			Sequence:             string(sc.Sequence),
+			Reason:               string(sc.Reason),
+			NetworkTransactionID: sc.NetworkTransactionID,
+		}
+	}
+	if auth := req.Authentication; auth != nil {
+		bankReq.ThreeDS = &BankThreeDS{
+			Version:         auth.Version,
+			Status:          string(auth.Status),
+			ECI:             auth.ECI,
+			CAVV:            auth.CAVV,
+			DSTransactionID: auth.DSTransactionID,
+		}
+	}
 
 	requestBody, err := json.Marshal(bankReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal bank request: %w", err)
+		return nil, metrics.BankErrorTransport, fmt.Errorf("failed to marshal bank request: %w", err)
+	}
+
+	// Every attempt of the call carries the same reference, so that an
+	// idempotent acquirer authorizes it at most once.
+	reference := req.Reference
+	if reference == "" && c.hedging != nil {
+		reference = newReference()
 	}
 
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		auth, errClass, retry, err := c.hedgedAttempt(ctx, requestBody, reference)
+		if err == nil || !retry || attempt >= c.maxAttempts {
+			return auth, errClass, err
+		}
+
+		metrics.BankRetriesTotal.WithLabelValues(c.name, errClass).Inc()
+		logging.FromContext(ctx).DebugContext(ctx, "retrying bank request",
+			"acquirer", c.name, "attempt", attempt, "class", errClass)
+		select {
+		case <-ctx.Done():
+			return auth, errClass, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt sends one authorization request, with the reference in
+// IdempotencyKeyHeader when it is not empty. It reports retry when the
+// request failed without the acquirer processing it, so that sending it
+// again cannot authorize the payment twice.
+func (c *BankClient) attempt(ctx context.Context, requestBody []byte, reference string) (auth *payments.BankAuthorization, errClass string, retry bool, err error) {
+	if c.attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.attemptTimeout)
+		defer cancel()
+	}
+	var sent atomic.Bool
+	traceCtx := httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteHeaders: func() { sent.Store(true) },
+	})
+
 	url := fmt.Sprintf("%s/payments", c.baseURL)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	httpReq, err := http.NewRequestWithContext(traceCtx, "POST", url, bytes.NewReader(requestBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, metrics.BankErrorTransport, false, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	if reference != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, reference)
+	}
+	tracing.Inject(ctx, httpReq.Header)
+	if c.signer != nil {
+		if err := c.signer.Sign(httpReq.Header, requestBody); err != nil {
+			return nil, metrics.BankErrorTransport, false, fmt.Errorf("failed to sign bank request: %w", err)
+		}
+	}
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, ErrBankUnavailable
+		logging.FromContext(ctx).DebugContext(ctx, "bank transport error", "error", err)
+		if isTimeout(err) {
+			return nil, metrics.BankErrorTimeout, !sent.Load(), ErrBankUnavailable
+		}
+		return nil, metrics.BankErrorTransport, !sent.Load(), ErrBankUnavailable
+	}
+	defer func() {
+		// The connection is only reused once the body has been read to
+		// the end.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+		resp.Body.Close()
+	}()
+
+	if c.hedging != nil && resp.StatusCode == http.StatusOK {
+		c.latencies.observe(time.Since(start))
+		c.idempotent.Store(resp.Header.Get(IdempotencyKeyHeader) == reference)
 	}
-	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusOK:
 		var bankResp BankPaymentResponse
 		if err := json.NewDecoder(resp.Body).Decode(&bankResp); err != nil {
-			return nil, fmt.Errorf("failed to decode bank response: %w", err)
+			return nil, metrics.BankErrorDecode, false, fmt.Errorf("failed to decode bank response: %w", err)
 		}
 
-		return &payments.BankAuthorization{
-			Authorized:        bankResp.Authorized,
-			AuthorizationCode: bankResp.AuthorizationCode,
-			ErrorMessage:      bankResp.ErrorMessage,
-		}, nil
+		auth := &payments.BankAuthorization{
+			Authorized:           bankResp.Authorized,
+			AuthorizationCode:    bankResp.AuthorizationCode,
+			ErrorMessage:         bankResp.ErrorMessage,
+			Acquirer:             c.name,
+			NetworkTransactionID: bankResp.NetworkTransactionID,
+		}
+		if !auth.Authorized {
+			code, ok := c.declineCode(bankResp.ErrorMessage)
+			if !ok {
+				logging.FromContext(ctx).WarnContext(ctx, "unmapped decline reason, reporting do_not_honor",
+					"acquirer", c.name, "reason", bankResp.ErrorMessage)
+			}
+			auth.DeclineCode = code
+		}
+		return auth, "", false, nil
 
 	case http.StatusBadRequest:
 		var errorResp map[string]interface{}
 		_ = json.NewDecoder(resp.Body).Decode(&errorResp)
-		return nil, fmt.Errorf("bank rejected request (400): %v", errorResp)
+		return nil, metrics.BankErrorBadRequest, false, fmt.Errorf("bank rejected request (400): %v", errorResp)
 
 	case http.StatusServiceUnavailable:
-		return nil, ErrBankUnavailable
+		return nil, metrics.BankErrorUnavailable, true, ErrBankUnavailable
 
 	default:
-		return nil, fmt.Errorf("unexpected status code from bank: %d", resp.StatusCode)
+		return nil, metrics.BankErrorUnexpected, false, fmt.Errorf("unexpected status code from bank: %d", resp.StatusCode)
+	}
+}
+
+// Ping reports whether the acquirer can be reached. Any HTTP response counts
+// as reachable; only transport failures and timeouts are errors.
+func (c *BankClient) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("acquirer %s unreachable: %w", c.name, err)
 	}
+	resp.Body.Close()
+
+	return nil
 }
 
 func (c *BankClient) formatExpiryDate(month, year int) string {
 	return fmt.Sprintf("%02d/%d", month, year)
 }
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
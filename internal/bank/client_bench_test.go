@@ -0,0 +1,60 @@
+package bank_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// BenchmarkBankClient_ProcessPayment measures throughput and allocations of
+// ProcessPayment against an in-process acquirer, with the tuned transport
+// and with net/http's default of two idle connections per host. Run with
+//
+//	go test ./internal/bank -run '^$' -bench ProcessPayment -cpu 8
+func BenchmarkBankClient_ProcessPayment(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"authorized": true, "authorization_code": "A1"}`))
+	}))
+	defer server.Close()
+
+	netHTTPDefaults := bank.DefaultTransportOptions()
+	netHTTPDefaults.MaxIdleConnsPerHost = 2
+
+	transports := []struct {
+		name string
+		opts bank.TransportOptions
+	}{
+		{"tuned", bank.DefaultTransportOptions()},
+		{"two_idle_per_host", netHTTPDefaults},
+	}
+	req := &payments.PostPaymentRequest{
+		CardNumber:  "2222405343248877",
+		ExpiryMonth: 4,
+		ExpiryYear:  2099,
+		Currency:    "GBP",
+		Amount:      100,
+		Cvv:         "123",
+	}
+
+	for _, tt := range transports {
+		b.Run(tt.name, func(b *testing.B) {
+			client := bank.NewBankClient(server.URL, bank.WithTransport(tt.opts))
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := client.ProcessPayment(context.Background(), req); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "payments/s")
+		})
+	}
+}
@@ -1,14 +1,22 @@
 package bank_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bulkhead"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	// Ajuste o import abaixo para o caminho correto do seu pacote bank
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
 )
@@ -128,7 +136,7 @@ func TestBankClient_ProcessPayment(t *testing.T) {
 
 			client := bank.NewBankClient(server.URL)
 
-			resp, err := client.ProcessPayment(tt.inputRequest)
+			resp, err := client.ProcessPayment(context.Background(), tt.inputRequest)
 
 			if tt.expectedError != nil {
 				if err != tt.expectedError {
@@ -160,3 +168,459 @@ func TestBankClient_ProcessPayment(t *testing.T) {
 		})
 	}
 }
+
+func TestBankClient_ProcessPayment_Metrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		acquirer    string
+		mockHandler func(w http.ResponseWriter, r *http.Request)
+		class       string
+	}{
+		{
+			name:     "Authorized",
+			acquirer: "metrics-authorized",
+			mockHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"authorized": true, "authorization_code": "A"}`))
+			},
+		},
+		{
+			name:     "Bad Request",
+			acquirer: "metrics-400",
+			mockHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			class: metrics.BankErrorBadRequest,
+		},
+		{
+			name:     "Service Unavailable",
+			acquirer: "metrics-503",
+			mockHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			class: metrics.BankErrorUnavailable,
+		},
+		{
+			name:     "Decode Failure",
+			acquirer: "metrics-decode",
+			mockHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{not-json`))
+			},
+			class: metrics.BankErrorDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(tt.mockHandler))
+			defer server.Close()
+
+			client := bank.NewBankClient(server.URL, bank.WithName(tt.acquirer))
+			_, _ = client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+
+			if tt.class != "" {
+				if got := testutil.ToFloat64(metrics.BankErrorsTotal.WithLabelValues(tt.acquirer, tt.class)); got != 1 {
+					t.Errorf("Expected 1 %s error, got %v", tt.class, got)
+				}
+			}
+			if got := testutil.CollectAndCount(metrics.BankRequestDuration); got == 0 {
+				t.Error("Expected bank latency to be observed")
+			}
+			if got := testutil.ToFloat64(metrics.BankErrorsTotal.WithLabelValues(tt.acquirer, metrics.BankErrorTimeout)); got != 0 {
+				t.Errorf("Expected no timeouts, got %v", got)
+			}
+		})
+	}
+}
+
+func TestBankClient_ProcessPayment_TimeoutMetric(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := bank.NewBankClient(server.URL, bank.WithName("metrics-timeout"), bank.WithTimeout(50*time.Millisecond))
+	_, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+
+	if err != bank.ErrBankUnavailable {
+		t.Errorf("Expected ErrBankUnavailable, got %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.BankErrorsTotal.WithLabelValues("metrics-timeout", metrics.BankErrorTimeout)); got != 1 {
+		t.Errorf("Expected 1 timeout, got %v", got)
+	}
+}
+
+func TestBankClient_ProcessPayment_CallerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte(`{"authorized": true}`))
+	}))
+	defer server.Close()
+
+	client := bank.NewBankClient(server.URL)
+	auth, err := client.ProcessPayment(ctx, &payments.PostPaymentRequest{Amount: 100})
+
+	if err != nil {
+		t.Fatalf("Expected the answer of the acquirer, got %v", err)
+	}
+	if !auth.Authorized {
+		t.Errorf("Expected an authorized payment")
+	}
+}
+
+func TestBankClient_ProcessPayment_PropagatesTraceContext(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"authorized": true}`))
+	}))
+	defer server.Close()
+
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+	ctx, span := provider.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	client := bank.NewBankClient(server.URL)
+	if _, err := client.ProcessPayment(ctx, &payments.PostPaymentRequest{Amount: 100}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantPrefix := "00-" + span.SpanContext().TraceID().String() + "-"
+	if !strings.HasPrefix(traceparent, wantPrefix) {
+		t.Errorf("Expected traceparent with prefix %q, got %q", wantPrefix, traceparent)
+	}
+}
+
+func TestBankClient_ProcessPayment_StoredCredential(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"authorized": true, "network_transaction_id": "NTI-1"}`))
+	}))
+	defer server.Close()
+
+	client := bank.NewBankClient(server.URL)
+	auth, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{
+		CardNumber: "2222405343248877",
+		Amount:     100,
+		StoredCredential: &payments.StoredCredential{
+			Initiator:            payments.InitiatorMerchant,
+			Sequence:             payments.SequenceSubsequent,
+			Reason:               payments.ReasonRecurring,
+			NetworkTransactionID: "NTI-0",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := body["cvv"]; ok {
+		t.Errorf("Expected no cvv on a merchant-initiated payment, got %v", body["cvv"])
+	}
+	want := map[string]any{
+		"initiator":              "merchant",
+		"sequence":               "subsequent",
+		"reason":                 "recurring",
+		"network_transaction_id": "NTI-0",
+	}
+	if !reflect.DeepEqual(body["stored_credential"], want) {
+		t.Errorf("Expected stored_credential %v, got %v", want, body["stored_credential"])
+	}
+	if auth.NetworkTransactionID != "NTI-1" {
+		t.Errorf("Expected network transaction ID NTI-1, got %q", auth.NetworkTransactionID)
+	}
+}
+
+func TestBankClient_ProcessPayment_ThreeDS(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"authorized": true}`))
+	}))
+	defer server.Close()
+
+	client := bank.NewBankClient(server.URL)
+	_, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{
+		CardNumber: "2222405343248877",
+		Amount:     100,
+		Cvv:        "123",
+		Authentication: &payments.Authentication{
+			TransactionID:   "3ds-1",
+			Version:         "2.2.0",
+			Status:          payments.AuthenticationSucceeded,
+			ECI:             "05",
+			CAVV:            "AAABBEg0VhI0VniQEjRWAAAAAAA=",
+			DSTransactionID: "ds-1",
+			LiabilityShift:  true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string]any{
+		"version":           "2.2.0",
+		"status":            "authenticated",
+		"eci":               "05",
+		"cavv":              "AAABBEg0VhI0VniQEjRWAAAAAAA=",
+		"ds_transaction_id": "ds-1",
+	}
+	if !reflect.DeepEqual(body["three_ds"], want) {
+		t.Errorf("Expected three_ds %v, got %v", want, body["three_ds"])
+	}
+}
+
+func TestBankClient_Ping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	client := bank.NewBankClient(server.URL)
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Expected reachable bank, got %v", err)
+	}
+
+	server.Close()
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("Expected error for unreachable bank, got nil")
+	}
+}
+
+func TestBankClient_ProcessPayment_DeclineCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		opts   []bank.Option
+		want   payments.DeclineCode
+	}{
+		{name: "Simulator reason", reason: "Insufficient funds", want: payments.DeclineInsufficientFunds},
+		{name: "ISO 8583 code", reason: "54", want: payments.DeclineExpiredCard},
+		{name: "Strong customer authentication required", reason: "1A", want: payments.DeclineAuthenticationRequired},
+		{name: "Reason is trimmed and case-insensitive", reason: "  SUSPECTED FRAUD ", want: payments.DeclineSuspectedFraud},
+		{name: "No reason", reason: "", want: payments.DeclineDoNotHonor},
+		{name: "Unmapped reason", reason: "card on fire", want: payments.DeclineDoNotHonor},
+		{
+			name:   "Acquirer-specific reason",
+			reason: "R01",
+			opts:   []bank.Option{bank.WithDeclineCodes(map[string]payments.DeclineCode{"r01": payments.DeclineLostOrStolen})},
+			want:   payments.DeclineLostOrStolen,
+		},
+		{
+			name:   "Acquirer override of a default",
+			reason: "05",
+			opts:   []bank.Option{bank.WithDeclineCodes(map[string]payments.DeclineCode{"05": payments.DeclineNotPermitted})},
+			want:   payments.DeclineNotPermitted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(bank.BankPaymentResponse{ErrorMessage: tt.reason})
+			}))
+			defer server.Close()
+
+			auth, err := bank.NewBankClient(server.URL, tt.opts...).ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if auth.DeclineCode != tt.want {
+				t.Errorf("Expected DeclineCode=%s, got %s", tt.want, auth.DeclineCode)
+			}
+			if auth.ErrorMessage != tt.reason {
+				t.Errorf("Expected the raw reason %q to be kept, got %q", tt.reason, auth.ErrorMessage)
+			}
+		})
+	}
+
+	t.Run("Authorized payments have no decline code", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(bank.BankPaymentResponse{Authorized: true, AuthorizationCode: "A1"})
+		}))
+		defer server.Close()
+
+		auth, err := bank.NewBankClient(server.URL).ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if auth.DeclineCode != "" {
+			t.Errorf("Expected no DeclineCode, got %s", auth.DeclineCode)
+		}
+	})
+}
+
+func TestDefaultDeclineCodes_AreValid(t *testing.T) {
+	for reason, code := range bank.DefaultDeclineCodes {
+		if !code.Valid() {
+			t.Errorf("reason %q maps to unknown code %q", reason, code)
+		}
+	}
+}
+
+func TestBankClient_ProcessPayment_Attempts(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxAttempts    int
+		attemptTimeout time.Duration
+		handler        func(call int32, w http.ResponseWriter)
+		closed         bool
+		wantErr        error
+		wantCalls      int32
+		wantRetries    float64
+		retryClass     string
+	}{
+		{
+			name:        "Retries a 503",
+			maxAttempts: 3,
+			handler: func(call int32, w http.ResponseWriter) {
+				if call == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"authorized": true}`))
+			},
+			wantCalls:   2,
+			wantRetries: 1,
+			retryClass:  metrics.BankErrorUnavailable,
+		},
+		{
+			name:        "Single attempt by default",
+			maxAttempts: 1,
+			handler: func(call int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantErr:   bank.ErrBankUnavailable,
+			wantCalls: 1,
+		},
+		{
+			name:        "Gives up after the last attempt",
+			maxAttempts: 2,
+			handler: func(call int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantErr:     bank.ErrBankUnavailable,
+			wantCalls:   2,
+			wantRetries: 1,
+			retryClass:  metrics.BankErrorUnavailable,
+		},
+		{
+			name:           "Does not retry an attempt the acquirer received",
+			maxAttempts:    3,
+			attemptTimeout: 20 * time.Millisecond,
+			handler: func(call int32, w http.ResponseWriter) {
+				time.Sleep(200 * time.Millisecond)
+			},
+			wantErr:   bank.ErrBankUnavailable,
+			wantCalls: 1,
+		},
+		{
+			name:        "Retries a request that was never sent",
+			maxAttempts: 2,
+			closed:      true,
+			wantErr:     bank.ErrBankUnavailable,
+			wantRetries: 1,
+			retryClass:  metrics.BankErrorTransport,
+		},
+		{
+			name:        "Does not retry a 400",
+			maxAttempts: 3,
+			handler: func(call int32, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			wantCalls: 1,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(calls.Add(1), w)
+			}))
+			defer server.Close()
+			if tt.closed {
+				server.Close()
+			}
+
+			name := fmt.Sprintf("attempts-%d", i)
+			client := bank.NewBankClient(server.URL,
+				bank.WithName(name),
+				bank.WithAttempts(tt.maxAttempts, tt.attemptTimeout),
+			)
+			start := time.Now()
+			auth, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && tt.wantCalls == 2 && (err != nil || !auth.Authorized) {
+				t.Errorf("Expected authorized payment, got %+v, %v", auth, err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, got)
+			}
+			if tt.retryClass != "" {
+				if got := testutil.ToFloat64(metrics.BankRetriesTotal.WithLabelValues(name, tt.retryClass)); got != tt.wantRetries {
+					t.Errorf("Expected %v retries, got %v", tt.wantRetries, got)
+				}
+			}
+			if tt.attemptTimeout > 0 && time.Since(start) > 150*time.Millisecond {
+				t.Errorf("Expected the attempt timeout to end the call, took %v", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestBankClient_ProcessPayment_Bulkhead(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.Write([]byte(`{"authorized": true}`))
+	}))
+	defer server.Close()
+
+	client := bank.NewBankClient(server.URL,
+		bank.WithName("bulkhead"),
+		bank.WithBulkhead(bulkhead.New(1, bulkhead.WithQueue(1, 20*time.Millisecond))))
+
+	if got := testutil.ToFloat64(metrics.BankConcurrencyLimit.WithLabelValues("bulkhead")); got != 1 {
+		t.Errorf("Expected a limit of 1, got %v", got)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+		done <- err
+	}()
+	<-entered
+
+	if got := testutil.ToFloat64(metrics.BankInFlight.WithLabelValues("bulkhead")); got != 1 {
+		t.Errorf("Expected 1 call in flight, got %v", got)
+	}
+
+	start := time.Now()
+	_, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+	if err != bank.ErrBankOverloaded {
+		t.Errorf("Expected ErrBankOverloaded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a quick rejection, took %v", elapsed)
+	}
+	if got := testutil.ToFloat64(metrics.BankRejectedTotal.WithLabelValues("bulkhead")); got != 1 {
+		t.Errorf("Expected 1 rejection, got %v", got)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Expected the first call to succeed, got %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.BankInFlight.WithLabelValues("bulkhead")); got != 0 {
+		t.Errorf("Expected no calls in flight, got %v", got)
+	}
+}
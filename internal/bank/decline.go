@@ -0,0 +1,70 @@
+package bank
+
+import (
+	"strings"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// DefaultDeclineCodes maps the decline reasons reported by the bank
+// simulator, and the ISO 8583 response codes most acquirers use, to
+// normalized decline codes. Keys are matched case-insensitively.
+var DefaultDeclineCodes = map[string]payments.DeclineCode{
+	"insufficient funds":      payments.DeclineInsufficientFunds,
+	"do not honor":            payments.DeclineDoNotHonor,
+	"expired card":            payments.DeclineExpiredCard,
+	"suspected fraud":         payments.DeclineSuspectedFraud,
+	"invalid cvv":             payments.DeclineInvalidCVV,
+	"authentication required": payments.DeclineAuthenticationRequired,
+
+	"05": payments.DeclineDoNotHonor,
+	"14": payments.DeclineInvalidCard,
+	"41": payments.DeclineLostOrStolen,
+	"43": payments.DeclineLostOrStolen,
+	"51": payments.DeclineInsufficientFunds,
+	"54": payments.DeclineExpiredCard,
+	"57": payments.DeclineNotPermitted,
+	"59": payments.DeclineSuspectedFraud,
+	"61": payments.DeclineLimitExceeded,
+	"82": payments.DeclineInvalidCVV,
+	"91": payments.DeclineIssuerUnavailable,
+	"n7": payments.DeclineInvalidCVV,
+	"1a": payments.DeclineAuthenticationRequired,
+}
+
+// WithDeclineCodes adds acquirer-specific decline reasons to
+// DefaultDeclineCodes, replacing the defaults for the same reasons.
+func WithDeclineCodes(codes map[string]payments.DeclineCode) Option {
+	return func(c *BankClient) {
+		for reason, code := range codes {
+			c.declineCodes[normalizeReason(reason)] = code
+		}
+	}
+}
+
+func defaultDeclineTable() map[string]payments.DeclineCode {
+	table := make(map[string]payments.DeclineCode, len(DefaultDeclineCodes))
+	for reason, code := range DefaultDeclineCodes {
+		table[normalizeReason(reason)] = code
+	}
+	return table
+}
+
+// declineCode returns the normalized code for an acquirer decline reason.
+// Missing and unmapped reasons are reported as do_not_honor, the generic
+// decline; ok is false for unmapped ones so they can be logged and added to
+// the table.
+func (c *BankClient) declineCode(reason string) (code payments.DeclineCode, ok bool) {
+	key := normalizeReason(reason)
+	if key == "" {
+		return payments.DeclineDoNotHonor, true
+	}
+	if code, ok := c.declineCodes[key]; ok {
+		return code, true
+	}
+	return payments.DeclineDoNotHonor, false
+}
+
+func normalizeReason(reason string) string {
+	return strings.ToLower(strings.TrimSpace(reason))
+}
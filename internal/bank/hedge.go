@@ -0,0 +1,168 @@
+package bank
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IdempotencyKeyHeader carries the authorization reference shared by every
+// attempt of a call. Acquirers that authorize a reference at most once echo
+// it in their responses, which is how they advertise idempotency.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Hedging configures hedged attempts: when an attempt has not been answered
+// within the Percentile (0-100) of recent latencies, bounded by MinDelay and
+// MaxDelay, the request is sent again with the same reference and the first
+// answer is used.
+type Hedging struct {
+	Percentile float64
+	MinDelay   time.Duration
+	// MaxDelay is also the delay used until enough latencies are known.
+	MaxDelay time.Duration
+}
+
+// WithHedging hedges slow attempts. Calls without a
+// payments.PostPaymentRequest.Reference get a random one. Hedged attempts are
+// only sent once the acquirer has echoed a reference, and stop again as soon
+// as it answers without it.
+func WithHedging(h Hedging) Option {
+	return func(c *BankClient) {
+		c.hedging = &h
+		c.latencies = &latencyWindow{samples: make([]time.Duration, 0, latencyWindowSize)}
+	}
+}
+
+// Idempotent reports whether the acquirer last advertised idempotent
+// authorization references.
+func (c *BankClient) Idempotent() bool {
+	return c.idempotent.Load()
+}
+
+// latencyWindowSize is the number of recent attempt latencies the hedge
+// delay is computed from, and minLatencySamples how many are needed before
+// MaxDelay stops being used.
+const (
+	latencyWindowSize = 256
+	minLatencySamples = 20
+)
+
+// latencyWindow keeps the latencies of the last answered attempts.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+}
+
+// percentile returns the p-th percentile of the window, or false while it
+// holds too few samples.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	if len(w.samples) < minLatencySamples {
+		w.mu.Unlock()
+		return 0, false
+	}
+	sorted := slices.Clone(w.samples)
+	w.mu.Unlock()
+
+	slices.Sort(sorted)
+	i := int(p / 100 * float64(len(sorted)))
+	return sorted[min(i, len(sorted)-1)], true
+}
+
+// hedgeDelay is how long an attempt may go unanswered before it is hedged.
+func (c *BankClient) hedgeDelay() time.Duration {
+	d, ok := c.latencies.percentile(c.hedging.Percentile)
+	if !ok {
+		return c.hedging.MaxDelay
+	}
+	return min(max(d, c.hedging.MinDelay), c.hedging.MaxDelay)
+}
+
+// newReference returns a random authorization reference.
+func newReference() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// attemptResult is the outcome of one attempt of a hedged call.
+type attemptResult struct {
+	auth     *payments.BankAuthorization
+	errClass string
+	retry    bool
+	err      error
+	hedge    bool
+}
+
+// hedgedAttempt sends the request and, when the acquirer advertises
+// idempotency and has not answered within the hedge delay, sends it again
+// with the same reference. The first successful answer wins and the other
+// attempt is cancelled; a failure is only returned once both have failed,
+// and is retryable only if neither attempt was processed.
+func (c *BankClient) hedgedAttempt(ctx context.Context, requestBody []byte, reference string) (*payments.BankAuthorization, string, bool, error) {
+	if c.hedging == nil || !c.idempotent.Load() {
+		return c.attempt(ctx, requestBody, reference)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan attemptResult, 2)
+	send := func(hedge bool) {
+		auth, errClass, retry, err := c.attempt(ctx, requestBody, reference)
+		results <- attemptResult{auth: auth, errClass: errClass, retry: retry, err: err, hedge: hedge}
+	}
+	go send(false)
+
+	timer := time.NewTimer(c.hedgeDelay())
+	defer timer.Stop()
+	hedged, pending := false, 1
+	retry := true
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			pending++
+			metrics.BankHedgedTotal.WithLabelValues(c.name).Inc()
+			logging.FromContext(ctx).DebugContext(ctx, "hedging bank request", "acquirer", c.name)
+			trace.SpanFromContext(ctx).AddEvent("bank.hedge")
+			go send(true)
+
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if hedged {
+					winner := metrics.HedgeWinnerPrimary
+					if r.hedge {
+						winner = metrics.HedgeWinnerHedge
+					}
+					metrics.BankHedgeWinsTotal.WithLabelValues(c.name, winner).Inc()
+				}
+				return r.auth, r.errClass, false, nil
+			}
+			retry = retry && r.retry
+			if pending == 0 {
+				return r.auth, r.errClass, retry, r.err
+			}
+		}
+	}
+}
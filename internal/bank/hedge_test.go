@@ -0,0 +1,140 @@
+package bank_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// hedgingAcquirer answers the first request of each reference after delay
+// (when slow is set) and later ones at once, echoing the reference when
+// echo is set.
+type hedgingAcquirer struct {
+	echo  atomic.Bool
+	slow  atomic.Bool
+	delay time.Duration
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (a *hedgingAcquirer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get(bank.IdempotencyKeyHeader)
+	a.mu.Lock()
+	a.seen[key]++
+	first := a.seen[key] == 1
+	a.mu.Unlock()
+
+	if first && a.slow.Load() {
+		// The request context is only cancelled once the body is read.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-time.After(a.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if a.echo.Load() {
+		w.Header().Set(bank.IdempotencyKeyHeader, key)
+	}
+	w.Write([]byte(`{"authorized": true, "authorization_code": "auth-1"}`))
+}
+
+func (a *hedgingAcquirer) requests() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for _, count := range a.seen {
+		n += count
+	}
+	return n
+}
+
+func newHedgingClient(t *testing.T, name string, acquirer *hedgingAcquirer) *bank.BankClient {
+	t.Helper()
+	acquirer.seen = map[string]int{}
+	server := httptest.NewServer(acquirer)
+	t.Cleanup(server.Close)
+	return bank.NewBankClient(server.URL,
+		bank.WithName(name),
+		bank.WithHedging(bank.Hedging{Percentile: 95, MinDelay: 10 * time.Millisecond, MaxDelay: 30 * time.Millisecond}))
+}
+
+func TestBankClient_ProcessPayment_Hedging(t *testing.T) {
+	acquirer := &hedgingAcquirer{delay: time.Second}
+	acquirer.echo.Store(true)
+	client := newHedgingClient(t, "hedging", acquirer)
+	req := &payments.PostPaymentRequest{Amount: 100}
+
+	if _, err := client.ProcessPayment(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !client.Idempotent() {
+		t.Fatal("Expected the echoed reference to advertise idempotency")
+	}
+
+	acquirer.slow.Store(true)
+	start := time.Now()
+	auth, err := client.ProcessPayment(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= acquirer.delay {
+		t.Errorf("Expected the hedge to answer before the slow attempt, took %v", elapsed)
+	}
+	if !auth.Authorized || auth.AuthorizationCode != "auth-1" {
+		t.Errorf("Unexpected authorization %+v", auth)
+	}
+	if got := acquirer.requests(); got != 3 {
+		t.Errorf("Expected 3 requests, got %d", got)
+	}
+	if got := testutil.ToFloat64(metrics.BankHedgedTotal.WithLabelValues("hedging")); got != 1 {
+		t.Errorf("Expected 1 hedged attempt, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.BankHedgeWinsTotal.WithLabelValues("hedging", metrics.HedgeWinnerHedge)); got != 1 {
+		t.Errorf("Expected the hedge to win once, got %v", got)
+	}
+}
+
+func TestBankClient_ProcessPayment_HedgingNeedsIdempotency(t *testing.T) {
+	acquirer := &hedgingAcquirer{delay: 100 * time.Millisecond}
+	acquirer.echo.Store(true)
+	client := newHedgingClient(t, "hedging-withdrawn", acquirer)
+	req := &payments.PostPaymentRequest{Amount: 100}
+
+	if _, err := client.ProcessPayment(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The acquirer stops echoing references: the next answer withdraws
+	// idempotency, and slow calls are no longer hedged.
+	acquirer.echo.Store(false)
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			acquirer.slow.Store(true)
+		}
+		if _, err := client.ProcessPayment(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if client.Idempotent() {
+			t.Fatal("Expected idempotency to be withdrawn")
+		}
+	}
+
+	if got := acquirer.requests(); got != 4 {
+		t.Errorf("Expected 4 requests, got %d", got)
+	}
+	if got := testutil.ToFloat64(metrics.BankHedgedTotal.WithLabelValues("hedging-withdrawn")); got != 0 {
+		t.Errorf("Expected no hedged attempts, got %v", got)
+	}
+}
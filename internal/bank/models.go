@@ -5,11 +5,39 @@ type BankPaymentRequest struct {
 	ExpiryDate string `json:"expiry_date"` // Format: "MM/YYYY"
 	Currency   string `json:"currency"`
 	Amount     int    `json:"amount"`
-	Cvv        string `json:"cvv"`
+	// Cvv is omitted on merchant-initiated payments.
+	Cvv              string                `json:"cvv,omitempty"`
+	StoredCredential *BankStoredCredential `json:"stored_credential,omitempty"`
+	ThreeDS          *BankThreeDS          `json:"three_ds,omitempty"`
+}
+
+// BankStoredCredential carries the stored credential indicators of a
+// payment: who initiated it, whether it is the first use of the card on
+// file, and the network transaction ID linking it to that first use.
+type BankStoredCredential struct {
+	Initiator            string `json:"initiator"`
+	Sequence             string `json:"sequence"`
+	Reason               string `json:"reason,omitempty"`
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
+}
+
+// BankThreeDS carries the 3-D Secure authentication of the cardholder: the
+// ECI and CAVV prove it to the issuer, which then bears the liability for
+// fraud.
+type BankThreeDS struct {
+	Version         string `json:"version,omitempty"`
+	Status          string `json:"status"`
+	ECI             string `json:"eci,omitempty"`
+	CAVV            string `json:"cavv,omitempty"`
+	DSTransactionID string `json:"ds_transaction_id,omitempty"`
 }
 
 type BankPaymentResponse struct {
 	Authorized        bool   `json:"authorized"`
 	AuthorizationCode string `json:"authorization_code"`
 	ErrorMessage      string `json:"error_message,omitempty"`
+	// NetworkTransactionID identifies the payment to the card scheme.
+	// Merchant-initiated payments refer to the one of their initial
+	// payment.
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
 }
@@ -0,0 +1,179 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// Route pairs an acquirer client with its share of traffic.
+type Route struct {
+	Client *BankClient
+	Weight int
+}
+
+// Router implements payments.BankGateway by sending each payment to one of
+// several acquirers, chosen at random in proportion to their weights.
+// Weights and timeouts can be changed at runtime.
+type Router struct {
+	clients []*BankClient
+	weights atomic.Pointer[[]int]
+	// probe holds the outcome of the last background Ping; see Probe.
+	probe atomic.Pointer[probeResult]
+}
+
+// probeResult is the outcome of a background Ping.
+type probeResult struct {
+	err error
+	at  time.Time
+}
+
+// errNotProbed is reported by Health until the first probe completes.
+var errNotProbed = errors.New("acquirers not probed yet")
+
+func NewRouter(routes ...Route) *Router {
+	r := &Router{clients: make([]*BankClient, len(routes))}
+	weights := make([]int, len(routes))
+	for i, route := range routes {
+		r.clients[i] = route.Client
+		weights[i] = route.Weight
+	}
+	r.weights.Store(&weights)
+	return r
+}
+
+// Weights returns the current weight of every acquirer by name.
+func (r *Router) Weights() map[string]int {
+	weights := *r.weights.Load()
+	out := make(map[string]int, len(r.clients))
+	for i, c := range r.clients {
+		out[c.Name()] = weights[i]
+	}
+	return out
+}
+
+// SetWeights replaces the weights of the named acquirers; acquirers missing
+// from weights keep their current weight. It fails without changing anything
+// when a name is unknown or no acquirer would be left in rotation.
+func (r *Router) SetWeights(weights map[string]int) error {
+	next := append([]int(nil), *r.weights.Load()...)
+	seen := 0
+	for i, c := range r.clients {
+		if w, ok := weights[c.Name()]; ok {
+			next[i] = w
+			seen++
+		}
+	}
+	if seen != len(weights) {
+		return errors.New("unknown acquirer in weights")
+	}
+	if total(next) <= 0 {
+		return errors.New("at least one acquirer must have a positive weight")
+	}
+	r.weights.Store(&next)
+	return nil
+}
+
+// SetTimeout changes the per-call timeout of every acquirer.
+func (r *Router) SetTimeout(timeout time.Duration) {
+	for _, c := range r.clients {
+		c.SetTimeout(timeout)
+	}
+}
+
+func (r *Router) ProcessPayment(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+	client := r.pick()
+	if client == nil {
+		return nil, ErrBankUnavailable
+	}
+	return client.ProcessPayment(ctx, req)
+}
+
+// Ping succeeds when at least one acquirer in rotation is reachable.
+func (r *Router) Ping(ctx context.Context) error {
+	weights := *r.weights.Load()
+	var errs []error
+	for i, c := range r.clients {
+		if weights[i] <= 0 {
+			continue
+		}
+		err := c.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return errors.New("no acquirer in rotation")
+	}
+	return errors.Join(errs...)
+}
+
+// Probe pings the acquirers every interval, each ping bounded by timeout,
+// and keeps the outcome for Health, until ctx is done.
+func (r *Router) Probe(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		r.probe.Store(&probeResult{err: err, at: time.Now()})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health reports the outcome of the last probe without calling the
+// acquirers, so readiness probes do not add load to them.
+func (r *Router) Health(context.Context) error {
+	result := r.probe.Load()
+	if result == nil {
+		return errNotProbed
+	}
+	if result.err != nil {
+		return fmt.Errorf("as of %s: %w", result.at.UTC().Format(time.RFC3339), result.err)
+	}
+	return nil
+}
+
+func (r *Router) pick() *BankClient {
+	weights := *r.weights.Load()
+	sum := total(weights)
+	if sum <= 0 {
+		return nil
+	}
+	n := rand.Intn(sum)
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if n < w {
+			return r.clients[i]
+		}
+		n -= w
+	}
+	return nil
+}
+
+func total(weights []int) int {
+	sum := 0
+	for _, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+	}
+	return sum
+}
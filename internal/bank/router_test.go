@@ -0,0 +1,185 @@
+package bank_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// countingBank returns a test acquirer that counts the payments it receives.
+func countingBank(t *testing.T, count *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Write([]byte(`{"authorized": true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRouter_ProcessPayment_Weights(t *testing.T) {
+	var primaryCount, secondaryCount atomic.Int64
+	primary := countingBank(t, &primaryCount)
+	secondary := countingBank(t, &secondaryCount)
+
+	router := bank.NewRouter(
+		bank.Route{Client: bank.NewBankClient(primary.URL, bank.WithName("router-primary")), Weight: 3},
+		bank.Route{Client: bank.NewBankClient(secondary.URL, bank.WithName("router-secondary")), Weight: 1},
+	)
+
+	const attempts = 400
+	for i := 0; i < attempts; i++ {
+		auth, err := router.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if auth.Acquirer != "router-primary" && auth.Acquirer != "router-secondary" {
+			t.Fatalf("Unexpected acquirer %q", auth.Acquirer)
+		}
+	}
+
+	// 3:1 split; allow a wide margin so the test is not flaky.
+	if got := primaryCount.Load(); got < 240 || got > 360 {
+		t.Errorf("Expected about 300 payments on primary, got %d", got)
+	}
+	if got := primaryCount.Load() + secondaryCount.Load(); got != attempts {
+		t.Errorf("Expected %d payments in total, got %d", attempts, got)
+	}
+
+	if err := router.SetWeights(map[string]int{"router-primary": 0}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	primaryCount.Store(0)
+	for i := 0; i < 50; i++ {
+		if _, err := router.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if got := primaryCount.Load(); got != 0 {
+		t.Errorf("Expected no payments on an acquirer with weight 0, got %d", got)
+	}
+}
+
+func TestRouter_SetWeights_Errors(t *testing.T) {
+	router := bank.NewRouter(
+		bank.Route{Client: bank.NewBankClient("http://a", bank.WithName("a")), Weight: 1},
+		bank.Route{Client: bank.NewBankClient("http://b", bank.WithName("b")), Weight: 1},
+	)
+
+	tests := []struct {
+		name    string
+		weights map[string]int
+	}{
+		{name: "Unknown acquirer", weights: map[string]int{"c": 1}},
+		{name: "Nothing left in rotation", weights: map[string]int{"a": 0, "b": 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := router.SetWeights(tt.weights); err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if got := router.Weights(); got["a"] != 1 || got["b"] != 1 {
+				t.Errorf("Expected weights to be unchanged, got %v", got)
+			}
+		})
+	}
+}
+
+func TestRouter_SetTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := bank.NewBankClient(server.URL, bank.WithName("router-timeout"), bank.WithTimeout(time.Minute))
+	router := bank.NewRouter(bank.Route{Client: client, Weight: 1})
+	router.SetTimeout(20 * time.Millisecond)
+
+	if client.Timeout() != 20*time.Millisecond {
+		t.Errorf("Expected timeout 20ms, got %s", client.Timeout())
+	}
+
+	start := time.Now()
+	_, err := router.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+	if err != bank.ErrBankUnavailable {
+		t.Errorf("Expected ErrBankUnavailable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the new timeout to apply, call took %s", elapsed)
+	}
+}
+
+func TestRouter_Ping(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	router := bank.NewRouter(
+		bank.Route{Client: bank.NewBankClient(down.URL, bank.WithName("down")), Weight: 1},
+		bank.Route{Client: bank.NewBankClient(up.URL, bank.WithName("up")), Weight: 1},
+	)
+	if err := router.Ping(context.Background()); err != nil {
+		t.Errorf("Expected ready while one acquirer is up, got %v", err)
+	}
+
+	if err := router.SetWeights(map[string]int{"up": 0}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := router.Ping(context.Background()); err == nil {
+		t.Error("Expected error when only unreachable acquirers are in rotation, got nil")
+	}
+}
+
+func TestRouter_Probe(t *testing.T) {
+	var calls atomic.Int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer up.Close()
+
+	router := bank.NewRouter(bank.Route{Client: bank.NewBankClient(up.URL, bank.WithName("up")), Weight: 1})
+	if err := router.Health(context.Background()); err == nil {
+		t.Error("Expected an error before the first probe, got nil")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.Probe(ctx, 10*time.Millisecond, time.Second)
+	}()
+	for deadline := time.Now().Add(time.Second); router.Health(context.Background()) != nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the acquirer to be probed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	seen := calls.Load()
+	for i := 0; i < 10; i++ {
+		router.Health(context.Background())
+	}
+	if calls.Load() > seen+1 {
+		t.Errorf("Expected Health to use the last probe, the acquirer got %d calls", calls.Load()-seen)
+	}
+
+	up.Close()
+	for deadline := time.Now().Add(time.Second); router.Health(context.Background()) == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the outage to be reported")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,149 @@
+package bank
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers carrying request signatures.
+const (
+	// SignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of
+	// "<timestamp>.<body>" under the shared key.
+	SignatureHeader = "X-Signature"
+	// SignatureTimestampHeader carries the Unix time the HMAC covers, so
+	// acquirers can refuse replays.
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	// SignatureKeyIDHeader names the HMAC key when a key ID is configured.
+	SignatureKeyIDHeader = "X-Signature-Key-Id"
+	// JWSHeader carries a JWS with detached payload (RFC 7515 appendix F)
+	// over the body.
+	JWSHeader = "X-JWS-Signature"
+)
+
+// Signer adds a signature of the request body to the headers of payment
+// requests sent to an acquirer.
+type Signer interface {
+	Sign(header http.Header, body []byte) error
+}
+
+// WithSigner signs every payment request with s.
+func WithSigner(s Signer) Option {
+	return func(c *BankClient) {
+		c.signer = s
+	}
+}
+
+// HMACSigner signs requests with a key shared with the acquirer.
+type HMACSigner struct {
+	keyID string
+	key   []byte
+	now   func() time.Time
+}
+
+// NewHMACSigner returns a signer using key, announced as keyID when it is
+// not empty.
+func NewHMACSigner(keyID string, key []byte) *HMACSigner {
+	return &HMACSigner{keyID: keyID, key: key, now: time.Now}
+}
+
+func (s *HMACSigner) Sign(header http.Header, body []byte) error {
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	header.Set(SignatureTimestampHeader, timestamp)
+	header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if s.keyID != "" {
+		header.Set(SignatureKeyIDHeader, s.keyID)
+	}
+	return nil
+}
+
+// JWSSigner signs requests with a private key, as a compact JWS whose
+// payload is the body and left out of the header.
+type JWSSigner struct {
+	protected string
+	key       crypto.Signer
+	hash      crypto.Hash
+	// size is the length of r and s in ECDSA signatures, 0 for other keys.
+	size int
+}
+
+// NewJWSSigner returns a signer using key, announced as keyID when it is not
+// empty. The algorithm follows the key: ES256 or ES384 for ECDSA P-256 or
+// P-384 keys, RS256 for RSA keys and EdDSA for Ed25519 keys.
+func NewJWSSigner(keyID string, key crypto.Signer) (*JWSSigner, error) {
+	s := &JWSSigner{key: key}
+	var alg string
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			alg, s.hash, s.size = "ES256", crypto.SHA256, 32
+		case elliptic.P384():
+			alg, s.hash, s.size = "ES384", crypto.SHA384, 48
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+	case *rsa.PrivateKey:
+		alg, s.hash = "RS256", crypto.SHA256
+	case ed25519.PrivateKey:
+		alg = "EdDSA"
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	protected, err := json.Marshal(struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid,omitempty"`
+	}{alg, keyID})
+	if err != nil {
+		return nil, err
+	}
+	s.protected = base64.RawURLEncoding.EncodeToString(protected)
+	return s, nil
+}
+
+func (s *JWSSigner) Sign(header http.Header, body []byte) error {
+	input := []byte(s.protected + "." + base64.RawURLEncoding.EncodeToString(body))
+	digest := input
+	if s.hash != 0 {
+		h := s.hash.New()
+		h.Write(input)
+		digest = h.Sum(nil)
+	}
+
+	sig, err := s.key.Sign(rand.Reader, digest, s.hash)
+	if err != nil {
+		return err
+	}
+	if s.size > 0 {
+		// JWS encodes ECDSA signatures as fixed-size r || s, not ASN.1.
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+			return err
+		}
+		sig = make([]byte, 2*s.size)
+		rs.R.FillBytes(sig[:s.size])
+		rs.S.FillBytes(sig[s.size:])
+	}
+
+	header.Set(JWSHeader, s.protected+".."+base64.RawURLEncoding.EncodeToString(sig))
+	return nil
+}
@@ -0,0 +1,136 @@
+package bank_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// signedBank records the headers and body of the last payment request.
+func signedBank(t *testing.T) (*httptest.Server, *http.Header, *[]byte) {
+	t.Helper()
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"authorized": true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &header, &body
+}
+
+func TestHMACSigner(t *testing.T) {
+	server, header, body := signedBank(t)
+	key := []byte("shared-secret")
+
+	client := bank.NewBankClient(server.URL, bank.WithSigner(bank.NewHMACSigner("k1", key)))
+	if _, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	timestamp := header.Get(bank.SignatureTimestampHeader)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(*body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if timestamp == "" {
+		t.Error("Expected a signature timestamp")
+	}
+	if got := header.Get(bank.SignatureHeader); got != want {
+		t.Errorf("Expected signature %q, got %q", want, got)
+	}
+	if got := header.Get(bank.SignatureKeyIDHeader); got != "k1" {
+		t.Errorf("Expected key ID k1, got %q", got)
+	}
+}
+
+func TestJWSSigner(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name    string
+		key     crypto.Signer
+		wantAlg string
+		verify  func(input, sig []byte) bool
+	}{
+		{"ES256", ecKey, "ES256", func(input, sig []byte) bool {
+			sum := sha256.Sum256(input)
+			return len(sig) == 64 && ecdsa.Verify(&ecKey.PublicKey, sum[:],
+				new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+		}},
+		{"ES384", ec384Key, "ES384", func(input, sig []byte) bool {
+			sum := sha512.Sum384(input)
+			return len(sig) == 96 && ecdsa.Verify(&ec384Key.PublicKey, sum[:],
+				new(big.Int).SetBytes(sig[:48]), new(big.Int).SetBytes(sig[48:]))
+		}},
+		{"RS256", rsaKey, "RS256", func(input, sig []byte) bool {
+			sum := sha256.Sum256(input)
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, sum[:], sig) == nil
+		}},
+		{"EdDSA", edKey, "EdDSA", func(input, sig []byte) bool {
+			return ed25519.Verify(edKey.Public().(ed25519.PublicKey), input, sig)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, header, body := signedBank(t)
+			signer, err := bank.NewJWSSigner("acquirer-key", tt.key)
+			if err != nil {
+				t.Fatalf("NewJWSSigner: %v", err)
+			}
+			client := bank.NewBankClient(server.URL, bank.WithSigner(signer))
+			if _, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			parts := strings.Split(header.Get(bank.JWSHeader), ".")
+			if len(parts) != 3 || parts[1] != "" {
+				t.Fatalf("Expected a detached compact JWS, got %q", header.Get(bank.JWSHeader))
+			}
+			var protected struct{ Alg, Kid string }
+			raw, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			if err := json.Unmarshal(raw, &protected); err != nil {
+				t.Fatalf("Invalid protected header %q: %v", raw, err)
+			}
+			if protected.Alg != tt.wantAlg || protected.Kid != "acquirer-key" {
+				t.Errorf("Expected alg %s and kid acquirer-key, got %+v", tt.wantAlg, protected)
+			}
+
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			input := []byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString(*body))
+			if !tt.verify(input, sig) {
+				t.Error("Signature does not verify against the request body")
+			}
+		})
+	}
+
+	p224Key, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if _, err := bank.NewJWSSigner("", p224Key); err == nil {
+		t.Error("Expected error for an unsupported curve, got nil")
+	}
+}
@@ -0,0 +1,157 @@
+package bank
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig"
+)
+
+// ErrCertificateNotPinned is returned, wrapped, when an acquirer presents a
+// certificate chain matching none of the pinned fingerprints.
+var ErrCertificateNotPinned = errors.New("acquirer certificate matches no pinned fingerprint")
+
+// TLSOptions configures the TLS connection to an acquirer. The zero value
+// verifies the acquirer against the system roots and presents no client
+// certificate.
+type TLSOptions struct {
+	// CertFile and KeyFile hold the client certificate presented to
+	// acquirers requiring mutual TLS.
+	CertFile string
+	KeyFile  string
+	// CAFile is a PEM bundle of the CAs trusted to sign the acquirer's
+	// certificate, replacing the system roots.
+	CAFile string
+	// PinnedCerts lists hex SHA-256 fingerprints of certificates the
+	// acquirer may present. When set, the verified chain must contain one
+	// of them, so a certificate from another trusted CA is refused.
+	PinnedCerts []string
+}
+
+// NewTLSConfig returns the client TLS configuration described by opts.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if opts.CAFile != "" {
+		pool, err := tlsconfig.LoadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(opts.PinnedCerts) > 0 {
+		pins := make([][]byte, len(opts.PinnedCerts))
+		for i, pin := range opts.PinnedCerts {
+			sum, err := tlsconfig.ParseFingerprint(pin)
+			if err != nil {
+				return nil, err
+			}
+			pins[i] = sum
+		}
+		// VerifyConnection runs after the chain is verified, on resumed
+		// sessions too.
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					sum := tlsconfig.Fingerprint(cert)
+					for _, pin := range pins {
+						if bytes.Equal(sum, pin) {
+							return nil
+						}
+					}
+				}
+			}
+			return ErrCertificateNotPinned
+		}
+	}
+	return cfg, nil
+}
+
+// WithTLSConfig connects to the acquirer with cfg, typically built by
+// NewTLSConfig.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *BankClient) {
+		c.tlsConfig = cfg
+	}
+}
+
+// TransportOptions tunes the connection pool to an acquirer. Payments go to
+// a handful of hosts, so the pool keeps as many idle connections per host as
+// in total instead of net/http's default of two, which makes connections
+// churn under load.
+type TransportOptions struct {
+	// MaxIdleConns and MaxIdleConnsPerHost bound the idle connections kept
+	// for reuse. MaxConnsPerHost bounds all connections to a host, 0 for no
+	// limit; requests beyond it wait for a connection.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	// IdleConnTimeout closes connections idle for that long.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the TCP keep-alive period, 0 to disable keep-alive
+	// probes.
+	KeepAlive time.Duration
+	// DialTimeout and TLSHandshakeTimeout bound connection set-up, so a
+	// dead address fails fast instead of using the whole call timeout.
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// HTTP2 negotiates HTTP/2 with acquirers served over TLS that support
+	// it. Plain HTTP acquirers are always reached over HTTP/1.1.
+	HTTP2 bool
+}
+
+// DefaultTransportOptions returns the options used unless WithTransport is
+// given.
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+		KeepAlive:           30 * time.Second,
+		DialTimeout:         2 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+		HTTP2:               true,
+	}
+}
+
+// WithTransport replaces DefaultTransportOptions.
+func WithTransport(opts TransportOptions) Option {
+	return func(c *BankClient) {
+		c.transport = opts
+	}
+}
+
+// newTransport returns an http.Transport configured with opts, connecting
+// over TLS with tlsConfig when it is not nil.
+func newTransport(opts TransportOptions, tlsConfig *tls.Config) *http.Transport {
+	keepAlive := opts.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = -1 // net.Dialer disables keep-alive on negative values
+	}
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: keepAlive}
+
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: opts.TLSHandshakeTimeout,
+		MaxIdleConns:        opts.MaxIdleConns,
+		MaxIdleConnsPerHost: opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     opts.MaxConnsPerHost,
+		IdleConnTimeout:     opts.IdleConnTimeout,
+		ForceAttemptHTTP2:   opts.HTTP2,
+	}
+}
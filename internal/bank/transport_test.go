@@ -0,0 +1,214 @@
+package bank_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bank"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig/tlstest"
+)
+
+func TestBankClient_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCA := tlstest.NewCA(t, "acquirer-client-ca")
+	certPEM, keyPEM := clientCA.Issue(t, "gateway")
+	certFile := tlstest.WriteFile(t, dir, "client.pem", certPEM)
+	keyFile := tlstest.WriteFile(t, dir, "client-key.pem", keyPEM)
+	otherCert, otherKey := tlstest.NewCA(t, "other-ca").Issue(t, "gateway")
+	otherCertFile := tlstest.WriteFile(t, dir, "other.pem", otherCert)
+	otherKeyFile := tlstest.WriteFile(t, dir, "other-key.pem", otherKey)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"authorized": true, "authorization_code": "A1"}`))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCA.Pool(),
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := tlstest.WriteFile(t, dir, "acquirer-ca.pem",
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	pin := hex.EncodeToString(tlsconfig.Fingerprint(server.Certificate()))
+	wrongPin := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name       string
+		opts       bank.TLSOptions
+		wantErr    bool
+		wantPinErr bool
+	}{
+		{
+			name: "Client certificate and CA bundle",
+			opts: bank.TLSOptions{CertFile: certFile, KeyFile: keyFile, CAFile: caFile},
+		},
+		{
+			name: "Matching pin",
+			opts: bank.TLSOptions{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, PinnedCerts: []string{wrongPin, pin}},
+		},
+		{
+			name:       "No matching pin",
+			opts:       bank.TLSOptions{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, PinnedCerts: []string{wrongPin}},
+			wantErr:    true,
+			wantPinErr: true,
+		},
+		{
+			name:    "No client certificate",
+			opts:    bank.TLSOptions{CAFile: caFile},
+			wantErr: true,
+		},
+		{
+			name:    "Client certificate from an untrusted CA",
+			opts:    bank.TLSOptions{CertFile: otherCertFile, KeyFile: otherKeyFile, CAFile: caFile},
+			wantErr: true,
+		},
+		{
+			name:    "Acquirer not trusted without its CA",
+			opts:    bank.TLSOptions{CertFile: certFile, KeyFile: keyFile},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := bank.NewTLSConfig(tt.opts)
+			if err != nil {
+				t.Fatalf("NewTLSConfig: %v", err)
+			}
+			client := bank.NewBankClient(server.URL, bank.WithTLSConfig(tlsConfig))
+
+			auth, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100})
+			if tt.wantErr {
+				if err != bank.ErrBankUnavailable {
+					t.Errorf("Expected ErrBankUnavailable, got %v", err)
+				}
+			} else if err != nil || !auth.Authorized {
+				t.Errorf("Expected authorized payment, got %+v, %v", auth, err)
+			}
+
+			err = client.Ping(context.Background())
+			if got := errors.Is(err, bank.ErrCertificateNotPinned); got != tt.wantPinErr {
+				t.Errorf("Expected pin error %v, got %v", tt.wantPinErr, err)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		opts    bank.TLSOptions
+		wantErr string
+	}{
+		{"Certificate without key", bank.TLSOptions{CertFile: dir + "/missing.pem"}, "failed to load client certificate"},
+		{"Missing CA file", bank.TLSOptions{CAFile: dir + "/missing.pem"}, "failed to read CA file"},
+		{"Invalid pin", bank.TLSOptions{PinnedCerts: []string{"abc"}}, "is not a hex SHA-256 fingerprint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bank.NewTLSConfig(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBankClient_Transport(t *testing.T) {
+	t.Run("HTTP/2 over TLS", func(t *testing.T) {
+		for _, http2 := range []bool{true, false} {
+			var proto atomic.Value
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proto.Store(r.Proto)
+				w.Write([]byte(`{"authorized": true}`))
+			}))
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+
+			tlsConfig := &tls.Config{RootCAs: x509.NewCertPool()}
+			tlsConfig.RootCAs.AddCert(server.Certificate())
+			opts := bank.DefaultTransportOptions()
+			opts.HTTP2 = http2
+			client := bank.NewBankClient(server.URL, bank.WithTLSConfig(tlsConfig), bank.WithTransport(opts))
+			if _, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			want := "HTTP/1.1"
+			if http2 {
+				want = "HTTP/2.0"
+			}
+			if got := proto.Load(); got != want {
+				t.Errorf("HTTP2=%v: expected %s, got %v", http2, want, got)
+			}
+		}
+	})
+
+	t.Run("Connections are pooled", func(t *testing.T) {
+		var conns atomic.Int32
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"authorized": true}`))
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		server.Start()
+		defer server.Close()
+
+		opts := bank.DefaultTransportOptions()
+		opts.MaxConnsPerHost = 4
+		client := bank.NewBankClient(server.URL, bank.WithTransport(opts))
+
+		var wg sync.WaitGroup
+		for round := 0; round < 10; round++ {
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100}); err != nil {
+						t.Errorf("Unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+		}
+
+		if got := conns.Load(); got > 4 {
+			t.Errorf("Expected at most 4 connections for 100 payments, got %d", got)
+		}
+	})
+
+	t.Run("Dial timeout", func(t *testing.T) {
+		opts := bank.DefaultTransportOptions()
+		opts.DialTimeout = 50 * time.Millisecond
+		// 192.0.2.0/24 is reserved for documentation and never answers.
+		client := bank.NewBankClient("http://192.0.2.1", bank.WithTransport(opts))
+
+		start := time.Now()
+		if _, err := client.ProcessPayment(context.Background(), &payments.PostPaymentRequest{Amount: 100}); err != bank.ErrBankUnavailable {
+			t.Errorf("Expected ErrBankUnavailable, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the dial timeout to end the call, took %v", elapsed)
+		}
+	})
+}
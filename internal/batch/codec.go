@@ -0,0 +1,24 @@
+package batch
+
+import (
+	"errors"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// ErrUnknownColumn is returned by Codec.DecodeBatchRecord for a CSV column
+// that is not a field of the payment request.
+var ErrUnknownColumn = errors.New("unknown column")
+
+// Codec translates between the wire models of one API version and batches,
+// so that the handler does not depend on any API version.
+type Codec interface {
+	// DecodeBatchPayment reads one payment request of a JSON or NDJSON
+	// batch.
+	DecodeBatchPayment(data []byte) (*payments.PostPaymentRequest, error)
+	// DecodeBatchRecord reads one CSV row, keyed by the column names of
+	// the header. Empty values are left unset.
+	DecodeBatchRecord(record map[string]string) (*payments.PostPaymentRequest, error)
+	// EncodeBatch returns the response model to send for b.
+	EncodeBatch(b *Batch) any
+}
@@ -0,0 +1,216 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// Format is the encoding of a submitted batch.
+type Format string
+
+const (
+	// FormatJSON is an object whose "payments" member lists the payment
+	// requests.
+	FormatJSON Format = "json"
+	// FormatNDJSON holds one payment request object per line.
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV holds one payment per row after a header row naming the
+	// request fields.
+	FormatCSV Format = "csv"
+)
+
+// UploadField is the multipart/form-data field holding an uploaded batch
+// file.
+const UploadField = "file"
+
+var (
+	errUnsupportedFormat = errors.New("unsupported batch format")
+	errTooManyItems      = errors.New("too many payments in batch")
+)
+
+// entry is one payment of a submitted batch: its request, or the error that
+// kept it from being read.
+type entry struct {
+	req *payments.PostPaymentRequest
+	err error
+}
+
+// formatOf returns the format of a media type.
+func formatOf(mediaType string) (Format, bool) {
+	switch mediaType {
+	case "application/json":
+		return FormatJSON, true
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, true
+	case "text/csv":
+		return FormatCSV, true
+	}
+	return "", false
+}
+
+// formatOfFile returns the format of an uploaded file from its name.
+func formatOfFile(name string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON, true
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, true
+	case ".csv":
+		return FormatCSV, true
+	}
+	return "", false
+}
+
+// requestBody returns the reader holding the batch of r and its format:
+// the request body itself, or the UploadField file of a multipart/form-data
+// upload, whose format is given by its content type or file name.
+func requestBody(r *http.Request) (io.Reader, Format, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", errUnsupportedFormat, err)
+	}
+	if mediaType != "multipart/form-data" {
+		format, ok := formatOf(mediaType)
+		if !ok {
+			return nil, "", fmt.Errorf("%w: %s", errUnsupportedFormat, mediaType)
+		}
+		return r.Body, format, nil
+	}
+
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, "", fmt.Errorf("no %q file in the upload", UploadField)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() != UploadField {
+			continue
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		format, ok := formatOf(partType)
+		if !ok {
+			format, ok = formatOfFile(part.FileName())
+		}
+		if !ok {
+			return nil, "", fmt.Errorf("%w: file %q", errUnsupportedFormat, part.FileName())
+		}
+		return part, format, nil
+	}
+}
+
+// decode reads the payments of a batch in format. Payments that cannot be
+// read are returned with their error so that the others are still
+// processed; an error is only returned when the batch itself is unreadable
+// or holds more than maxItems payments.
+func decode(r io.Reader, format Format, codec Codec, maxItems int) ([]entry, error) {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(r, codec, maxItems)
+	case FormatNDJSON:
+		return decodeNDJSON(r, codec, maxItems)
+	case FormatCSV:
+		return decodeCSV(r, codec, maxItems)
+	}
+	return nil, errUnsupportedFormat
+}
+
+func decodeJSON(r io.Reader, codec Codec, maxItems int) ([]entry, error) {
+	var body struct {
+		Payments []json.RawMessage `json:"payments"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Payments) > maxItems {
+		return nil, errTooManyItems
+	}
+	entries := make([]entry, len(body.Payments))
+	for i, raw := range body.Payments {
+		entries[i].req, entries[i].err = codec.DecodeBatchPayment(raw)
+	}
+	return entries, nil
+}
+
+func decodeNDJSON(r io.Reader, codec Codec, maxItems int) ([]entry, error) {
+	var entries []entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(entries) == maxItems {
+			return nil, errTooManyItems
+		}
+		var e entry
+		e.req, e.err = codec.DecodeBatchPayment(line)
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func decodeCSV(r io.Reader, codec Codec, maxItems int) ([]entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	blank := make(map[string]string, len(header))
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		blank[header[i]] = ""
+	}
+	// Reject unknown columns once rather than on every row.
+	if _, err := codec.DecodeBatchRecord(blank); errors.Is(err, ErrUnknownColumn) {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == maxItems {
+			return nil, errTooManyItems
+		}
+		var e entry
+		if len(row) != len(header) {
+			e.err = fmt.Errorf("row has %d fields, the header has %d", len(row), len(header))
+		} else {
+			record := make(map[string]string, len(row))
+			for i, value := range row {
+				record[header[i]] = value
+			}
+			e.req, e.err = codec.DecodeBatchRecord(record)
+		}
+		entries = append(entries, e)
+	}
+}
@@ -0,0 +1,216 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/semaphore"
+)
+
+// maxItemBytes bounds the average size of a payment in a submitted batch, so
+// that the request body is bounded too.
+const maxItemBytes = 4 << 10
+
+// Submitter processes one payment exactly as POST /payments does.
+// payments.PaymentsHandler implements it.
+type Submitter interface {
+	Submit(ctx context.Context, merchant string, req payments.PostPaymentRequest) (*payments.Payment, *problem.Problem)
+}
+
+type Handler struct {
+	store     *store
+	submitter Submitter
+	maxItems  int
+	// inFlight bounds the payments sent at once across every batch.
+	inFlight *semaphore.Weighted
+
+	// stopping is done once Close is called; batches then stop starting
+	// payments.
+	stopping context.Context
+	stop     context.CancelFunc
+	running  sync.WaitGroup
+}
+
+// NewHandler returns a Handler processing up to concurrency payments at once,
+// across every batch, and accepting batches of up to maxItems payments.
+func NewHandler(submitter Submitter, concurrency, maxItems int) *Handler {
+	stopping, stop := context.WithCancel(context.Background())
+	return &Handler{
+		store:     newStore(),
+		submitter: submitter,
+		maxItems:  maxItems,
+		inFlight:  semaphore.NewWeighted(int64(concurrency)),
+		stopping:  stopping,
+		stop:      stop,
+	}
+}
+
+// PostHandler returns an http.HandlerFunc that accepts a batch, answers 202
+// with the batch and its Location, and processes its payments in the
+// background.
+func (h *Handler) PostHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		codec := wire.From[Codec](ctx)
+
+		r.Body = http.MaxBytesReader(w, r.Body, int64(h.maxItems)*maxItemBytes)
+		body, format, err := requestBody(r)
+		if errors.Is(err, errUnsupportedFormat) {
+			problem.Write(w, r, problem.New(problem.CodeUnsupportedMedia,
+				err.Error()+"; send application/json, application/x-ndjson or text/csv, or upload a file as multipart/form-data"))
+			return
+		}
+		var entries []entry
+		if err == nil {
+			entries, err = decode(body, format, codec, h.maxItems)
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.Is(err, errTooManyItems) || errors.As(err, &tooLarge) {
+			logger.InfoContext(ctx, "rejected oversized payment batch", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeBatchTooLarge,
+				fmt.Sprintf("A batch holds at most %d payments", h.maxItems)))
+			return
+		}
+		if err != nil {
+			logger.InfoContext(ctx, "rejected malformed payment batch", "format", format, "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid batch: "+err.Error()))
+			return
+		}
+		if len(entries) == 0 {
+			problem.Write(w, r, problem.New(problem.CodeValidationFailed, "The batch holds no payments").
+				WithErrors(problem.FieldError{Field: "payments", Message: "must not be empty"}))
+			return
+		}
+
+		batch := &Batch{
+			ID:        uuid.New().String(),
+			Merchant:  r.Header.Get(payments.MerchantIDHeader),
+			Status:    StatusProcessing,
+			CreatedAt: time.Now().UTC(),
+			Items:     make([]Item, len(entries)),
+		}
+		for i := range batch.Items {
+			batch.Items[i] = Item{Index: i, PaymentStatus: ItemPending}
+		}
+		h.store.add(batch)
+		logger.InfoContext(ctx, "payment batch accepted", "batch_id", batch.ID, "format", format, "size", len(entries))
+
+		h.running.Add(1)
+		go h.process(context.WithoutCancel(ctx), batch.ID, batch.Merchant, entries)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", r.URL.Path+"/"+batch.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(codec.EncodeBatch(batch))
+	}
+}
+
+// GetHandler returns an http.HandlerFunc that returns the progress of a
+// batch of the requesting merchant and the outcome of each of its payments.
+func (h *Handler) GetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		batch := h.store.get(r.Header.Get(payments.MerchantIDHeader), id)
+		if batch == nil {
+			problem.Write(w, r, problem.New(problem.CodeBatchNotFound, "No payment batch with ID "+id))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(wire.From[Codec](r.Context()).EncodeBatch(batch))
+	}
+}
+
+// Close stops batches from starting new payments and waits, until ctx is
+// done, for those in flight. Batches stopped this way are Interrupted.
+func (h *Handler) Close(ctx context.Context) error {
+	h.stop()
+	done := make(chan struct{})
+	go func() {
+		h.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// process submits the payments of a batch, at most as many at once as the
+// handler allows across batches, and records each outcome.
+func (h *Handler) process(ctx context.Context, batchID, merchant string, entries []entry) {
+	defer h.running.Done()
+	ctx, span := tracing.Tracer().Start(ctx, "batch.Process", trace.WithAttributes(
+		attribute.String("batch.id", batchID),
+		attribute.Int("batch.size", len(entries)),
+	))
+	defer span.End()
+	logger := logging.FromContext(ctx).With("batch_id", batchID)
+	ctx = logging.WithLogger(ctx, logger)
+
+	metrics.BatchesInProgress.Inc()
+	defer metrics.BatchesInProgress.Dec()
+
+	status := StatusCompleted
+	var items sync.WaitGroup
+	for i, e := range entries {
+		// Acquire may succeed once stopping is done, so check it first.
+		if h.stopping.Err() != nil || h.inFlight.Acquire(h.stopping, 1) != nil {
+			status = StatusInterrupted
+			break
+		}
+		items.Add(1)
+		go func(i int, e entry) {
+			defer items.Done()
+			defer h.inFlight.Release(1)
+			item := h.processItem(ctx, merchant, i, e)
+			metrics.BatchItemsTotal.WithLabelValues(item.PaymentStatus).Inc()
+			h.store.setItem(batchID, item)
+		}(i, e)
+	}
+	items.Wait()
+
+	h.store.finish(batchID, status, time.Now().UTC())
+	if status == StatusInterrupted {
+		logger.WarnContext(ctx, "payment batch interrupted by shutdown")
+		return
+	}
+	logger.InfoContext(ctx, "payment batch completed", "size", len(entries))
+}
+
+// processItem submits one payment of a batch and returns its outcome.
+func (h *Handler) processItem(ctx context.Context, merchant string, index int, e entry) Item {
+	logger := logging.FromContext(ctx).With("batch_item", index)
+	ctx = logging.WithLogger(ctx, logger)
+
+	if e.err != nil {
+		logger.InfoContext(ctx, "rejected malformed batch payment", "error", e.err)
+		p := problem.New(problem.CodeMalformedRequest, e.err.Error()).WithPaymentStatus("Rejected")
+		return Item{Index: index, PaymentStatus: p.PaymentStatus, Problem: p}
+	}
+
+	payment, p := h.submitter.Submit(ctx, merchant, *e.req)
+	if p != nil {
+		return Item{Index: index, PaymentStatus: p.PaymentStatus, Problem: p}
+	}
+	return Item{Index: index, PaymentID: payment.Id, PaymentStatus: payment.PaymentStatus}
+}
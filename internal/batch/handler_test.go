@@ -0,0 +1,268 @@
+package batch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/batch"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withV1 serves next with the codec of API v1, as the router does.
+func withV1(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(wire.With[batch.Codec](r.Context(), v1.Codec{})))
+	})
+}
+
+// fakeSubmitter authorizes even amounts, declines odd ones and rejects
+// negative ones. It records the largest number of concurrent calls.
+type fakeSubmitter struct {
+	mu        sync.Mutex
+	merchants []string
+	delay     time.Duration
+	// release, when set, is received from before each payment returns.
+	release chan struct{}
+
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (f *fakeSubmitter) Submit(ctx context.Context, merchant string, req payments.PostPaymentRequest) (*payments.Payment, *problem.Problem) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		max := f.maxInFlight.Load()
+		if n <= max || f.maxInFlight.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+	if f.release != nil {
+		<-f.release
+	}
+
+	f.mu.Lock()
+	f.merchants = append(f.merchants, merchant)
+	f.mu.Unlock()
+
+	if req.Amount < 0 {
+		return nil, problem.New(problem.CodeValidationFailed, "amount must be positive").WithPaymentStatus("Rejected")
+	}
+	status := "Authorized"
+	if req.Amount%2 == 1 {
+		status = "Declined"
+	}
+	return &payments.Payment{Id: "pay-" + req.CardNumber, PaymentStatus: status, Amount: req.Amount}, nil
+}
+
+func newRouter(h *batch.Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(withV1)
+	r.Post("/api/payment-batches", h.PostHandler())
+	r.Get("/api/payment-batches/{id}", h.GetHandler())
+	return r
+}
+
+func submit(t *testing.T, r http.Handler, contentType string, body []byte) (*httptest.ResponseRecorder, v1.PaymentBatch) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/payment-batches", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(payments.MerchantIDHeader, "acme")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var b v1.PaymentBatch
+	if w.Code == http.StatusAccepted {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+	}
+	return w, b
+}
+
+// get requests the batch with id as merchant.
+func get(r http.Handler, merchant, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/payment-batches/"+id, nil)
+	req.Header.Set(payments.MerchantIDHeader, merchant)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// waitFor polls the batch until it is no longer Processing.
+func waitFor(t *testing.T, r http.Handler, id string) v1.PaymentBatch {
+	t.Helper()
+	var b v1.PaymentBatch
+	require.Eventually(t, func() bool {
+		w := get(r, "acme", id)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		return b.Status != string(batch.StatusProcessing)
+	}, 5*time.Second, 5*time.Millisecond)
+	return b
+}
+
+func TestHandler_Formats(t *testing.T) {
+	const (
+		jsonBatch = `{"payments":[
+			{"card_number":"1111","amount":100},
+			{"card_number":"2222","amount":101},
+			{"card_number":"3333","amount":"lots"},
+			{"card_number":"4444","amount":-1}
+		]}`
+		ndjsonBatch = `{"card_number":"1111","amount":100}
+{"card_number":"2222","amount":101}
+{"card_number":"3333","amount":
+{"card_number":"4444","amount":-1}
+`
+		csvBatch = "card_number,expiry_month,expiry_year,currency,amount,cvv\n" +
+			"1111,4,2030,USD,100,123\n" +
+			"2222,4,2030,USD,101,123\n" +
+			"3333,4,2030,USD,lots,123\n" +
+			"4444,4,2030,USD,-1,123\n"
+	)
+	multipartBatch := func(name, contentType, content string) (string, []byte) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		require.NoError(t, mw.WriteField("note", "nightly run"))
+		header := make(map[string][]string)
+		header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + name + `"`}
+		if contentType != "" {
+			header["Content-Type"] = []string{contentType}
+		}
+		part, err := mw.CreatePart(header)
+		require.NoError(t, err)
+		part.Write([]byte(content))
+		require.NoError(t, mw.Close())
+		return mw.FormDataContentType(), body.Bytes()
+	}
+
+	csvUploadType, csvUpload := multipartBatch("nightly.csv", "", csvBatch)
+	ndjsonUploadType, ndjsonUpload := multipartBatch("nightly.txt", "application/x-ndjson", ndjsonBatch)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{name: "JSON", contentType: "application/json", body: []byte(jsonBatch)},
+		{name: "NDJSON", contentType: "application/x-ndjson", body: []byte(ndjsonBatch)},
+		{name: "CSV", contentType: "text/csv; charset=utf-8", body: []byte(csvBatch)},
+		{name: "CSV upload", contentType: csvUploadType, body: csvUpload},
+		{name: "NDJSON upload", contentType: ndjsonUploadType, body: ndjsonUpload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submitter := &fakeSubmitter{}
+			r := newRouter(batch.NewHandler(submitter, 2, 10))
+
+			w, accepted := submit(t, r, tt.contentType, tt.body)
+			require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+			assert.Equal(t, "/api/payment-batches/"+accepted.Id, w.Header().Get("Location"))
+			assert.Equal(t, string(batch.StatusProcessing), accepted.Status)
+			require.Len(t, accepted.Items, 4)
+
+			done := waitFor(t, r, accepted.Id)
+			assert.Equal(t, string(batch.StatusCompleted), done.Status)
+			require.NotNil(t, done.CompletedAt)
+			assert.Equal(t, v1.BatchProgress{Total: 4, Processed: 4, Authorized: 1, Declined: 1, Rejected: 2}, done.Progress)
+
+			assert.Equal(t, v1.BatchItem{Index: 0, PaymentId: "pay-1111", PaymentStatus: "Authorized"}, done.Items[0])
+			assert.Equal(t, v1.BatchItem{Index: 1, PaymentId: "pay-2222", PaymentStatus: "Declined"}, done.Items[1])
+			assert.Equal(t, "Rejected", done.Items[2].PaymentStatus)
+			assert.Equal(t, problem.CodeMalformedRequest, done.Items[2].Code, "an unreadable payment does not fail the batch")
+			assert.Equal(t, problem.CodeValidationFailed, done.Items[3].Code)
+			assert.Equal(t, []string{"acme", "acme", "acme"}, submitter.merchants)
+		})
+	}
+}
+
+func TestHandler_Rejections(t *testing.T) {
+	r := newRouter(batch.NewHandler(&fakeSubmitter{}, 1, 2))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    problem.Code
+	}{
+		{"Malformed JSON", "application/json", `{"payments":`, http.StatusBadRequest, problem.CodeMalformedRequest},
+		{"Empty", "application/json", `{"payments":[]}`, http.StatusBadRequest, problem.CodeValidationFailed},
+		{"Too many payments", "application/x-ndjson", "{}\n{}\n{}\n", http.StatusRequestEntityTooLarge, problem.CodeBatchTooLarge},
+		{"Body too large", "application/x-ndjson", strings.Repeat(" ", 8<<10+1), http.StatusRequestEntityTooLarge, problem.CodeBatchTooLarge},
+		{"Unknown CSV column", "text/csv", "card_number,ammount\n1111,100\n", http.StatusBadRequest, problem.CodeMalformedRequest},
+		{"Unsupported format", "application/xml", "<payments/>", http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia},
+		{"Upload without file", "multipart/form-data; boundary=x", "--x--\r\n", http.StatusBadRequest, problem.CodeMalformedRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := submit(t, r, tt.contentType, []byte(tt.body))
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.wantCode, p.Code)
+		})
+	}
+
+	t.Run("Unknown batch", func(t *testing.T) {
+		w := get(r, "acme", "missing")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), string(problem.CodeBatchNotFound))
+	})
+
+	t.Run("Batch of another merchant", func(t *testing.T) {
+		w, accepted := submit(t, r, "application/x-ndjson", []byte(`{"card_number":"1","amount":2}`+"\n"))
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		w = get(r, "hooli", accepted.Id)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), string(problem.CodeBatchNotFound))
+		waitFor(t, r, accepted.Id)
+	})
+}
+
+func TestHandler_BoundsConcurrencyAcrossBatches(t *testing.T) {
+	submitter := &fakeSubmitter{delay: 5 * time.Millisecond}
+	r := newRouter(batch.NewHandler(submitter, 3, 100))
+	body := []byte(strings.Repeat(`{"card_number":"1111","amount":100}`+"\n", 20))
+
+	_, first := submit(t, r, "application/x-ndjson", body)
+	_, second := submit(t, r, "application/x-ndjson", body)
+	assert.Equal(t, string(batch.StatusCompleted), waitFor(t, r, first.Id).Status)
+	assert.Equal(t, string(batch.StatusCompleted), waitFor(t, r, second.Id).Status)
+	assert.Equal(t, int32(3), submitter.maxInFlight.Load())
+}
+
+func TestHandler_Close(t *testing.T) {
+	submitter := &fakeSubmitter{release: make(chan struct{})}
+	h := batch.NewHandler(submitter, 1, 10)
+	r := newRouter(h)
+
+	_, accepted := submit(t, r, "application/x-ndjson", []byte(strings.Repeat(`{"card_number":"1111","amount":100}`+"\n", 3)))
+	require.Eventually(t, func() bool { return submitter.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, h.Close(ctx), context.DeadlineExceeded, "the payment in flight is waited for")
+
+	close(submitter.release)
+	require.NoError(t, h.Close(context.Background()))
+	done := waitFor(t, r, accepted.Id)
+	assert.Equal(t, string(batch.StatusInterrupted), done.Status)
+	assert.Equal(t, v1.BatchProgress{Total: 3, Processed: 1, Authorized: 1}, done.Progress)
+	assert.Equal(t, batch.ItemPending, done.Items[2].PaymentStatus)
+}
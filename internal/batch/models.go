@@ -0,0 +1,89 @@
+// Package batch processes payment batches: lists of payments submitted in
+// one request, as JSON, NDJSON or CSV, and authorized in the background with
+// bounded concurrency through the same path as single payments.
+package batch
+
+import (
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+)
+
+// Status is the state of a batch.
+type Status string
+
+const (
+	StatusProcessing Status = "Processing"
+	StatusCompleted  Status = "Completed"
+	// StatusInterrupted batches were stopped by a shutdown before every
+	// payment was processed. Their remaining items stay Pending.
+	StatusInterrupted Status = "Interrupted"
+)
+
+// ItemPending is the payment status of items not processed yet.
+const ItemPending = "Pending"
+
+// Batch is a submitted batch and the outcome of each of its payments.
+type Batch struct {
+	ID          string     `json:"id"`
+	Merchant    string     `json:"merchant,omitempty"`
+	Status      Status     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Items       []Item     `json:"items"`
+}
+
+// Item is the outcome of one payment of a batch, in submission order.
+type Item struct {
+	Index int `json:"index"`
+	// PaymentID identifies the stored payment, which can be retrieved like
+	// any other. It is empty when no payment was stored.
+	PaymentID     string `json:"payment_id,omitempty"`
+	PaymentStatus string `json:"payment_status"`
+	// Problem says why no payment was stored: the item was Rejected or
+	// Failed.
+	Problem *problem.Problem `json:"problem,omitempty"`
+}
+
+// Progress counts the items of a batch by outcome.
+type Progress struct {
+	Total      int
+	Processed  int
+	Authorized int
+	Declined   int
+	Rejected   int
+	Failed     int
+	// RequiresAction counts payments awaiting 3-D Secure authentication by
+	// their cardholder.
+	RequiresAction int
+}
+
+// Progress returns the item counts of b.
+func (b *Batch) Progress() Progress {
+	p := Progress{Total: len(b.Items)}
+	for _, item := range b.Items {
+		switch item.PaymentStatus {
+		case ItemPending:
+			continue
+		case "Authorized":
+			p.Authorized++
+		case "Declined":
+			p.Declined++
+		case "Rejected":
+			p.Rejected++
+		case "Failed":
+			p.Failed++
+		case "RequiresAction":
+			p.RequiresAction++
+		}
+		p.Processed++
+	}
+	return p
+}
+
+// clone returns a copy of b that shares no items with it.
+func (b *Batch) clone() *Batch {
+	c := *b
+	c.Items = append([]Item(nil), b.Items...)
+	return &c
+}
@@ -0,0 +1,102 @@
+package batch
+
+import "time"
+
+type getBatchRequest struct {
+	merchant, id string
+	respChan     chan *Batch
+}
+
+type itemUpdate struct {
+	batchID string
+	item    Item
+}
+
+type finishRequest struct {
+	batchID string
+	status  Status
+	at      time.Time
+}
+
+// store keeps batches in memory. Like the payments repository it owns its
+// state in a single monitor goroutine. Payments themselves are stored by
+// the payments repository; a batch only refers to them by ID. Batches are
+// only found by the merchant that submitted them; to any other, they do not
+// exist.
+type store struct {
+	addChan    chan *Batch
+	itemChan   chan itemUpdate
+	finishChan chan finishRequest
+	getChan    chan getBatchRequest
+}
+
+func newStore() *store {
+	s := &store{
+		addChan:    make(chan *Batch),
+		itemChan:   make(chan itemUpdate),
+		finishChan: make(chan finishRequest),
+		getChan:    make(chan getBatchRequest),
+	}
+
+	go s.monitor()
+
+	return s
+}
+
+func (s *store) monitor() {
+	batches := make(map[string]*Batch)
+
+	for {
+		select {
+		case b := <-s.addChan:
+			batches[b.ID] = b
+
+		case u := <-s.itemChan:
+			if b, ok := batches[u.batchID]; ok && u.item.Index < len(b.Items) {
+				b.Items[u.item.Index] = u.item
+			}
+
+		case req := <-s.finishChan:
+			if b, ok := batches[req.batchID]; ok {
+				b.Status = req.status
+				at := req.at
+				b.CompletedAt = &at
+			}
+
+		case req := <-s.getChan:
+			var found *Batch
+			if b, ok := batches[req.id]; ok && b.Merchant == req.merchant {
+				found = b.clone()
+			}
+			req.respChan <- found
+		}
+	}
+}
+
+// add stores a copy of b.
+func (s *store) add(b *Batch) {
+	s.addChan <- b.clone()
+}
+
+// setItem records the outcome of one item of a batch.
+func (s *store) setItem(batchID string, item Item) {
+	s.itemChan <- itemUpdate{batchID: batchID, item: item}
+}
+
+// finish sets the final status of a batch.
+func (s *store) finish(batchID string, status Status, at time.Time) {
+	s.finishChan <- finishRequest{batchID: batchID, status: status, at: at}
+}
+
+// get returns the batch of merchant with id, or nil when there is none.
+func (s *store) get(merchant, id string) *Batch {
+	respChan := make(chan *Batch)
+
+	s.getChan <- getBatchRequest{
+		merchant: merchant,
+		id:       id,
+		respChan: respChan,
+	}
+
+	return <-respChan
+}
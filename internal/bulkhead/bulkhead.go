@@ -0,0 +1,199 @@
+// Package bulkhead bounds the number of concurrent calls to a dependency, so
+// that a slow dependency makes the gateway refuse work quickly instead of
+// piling up goroutines until it runs out of memory.
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrFull is returned by Acquire when the limit is reached and the call
+// cannot wait in the queue, or waited for longer than the queue timeout.
+var ErrFull = errors.New("too many concurrent calls")
+
+// decreaseFactor scales the adaptive limit down after a slow call.
+const decreaseFactor = 0.9
+
+// Adaptive makes the limit follow the latency of the dependency, between
+// MinLimit and MaxLimit. Every call slower than LatencyTarget shrinks the
+// limit by 10%, at most once per batch of calls started together; calls
+// within the target grow it by one per limit calls while the bulkhead is at
+// least half full (additive increase, multiplicative decrease).
+type Adaptive struct {
+	MinLimit      int
+	MaxLimit      int
+	LatencyTarget time.Duration
+}
+
+// Bulkhead is a semaphore with a bounded FIFO queue.
+type Bulkhead struct {
+	now          func() time.Time
+	queueSize    int
+	queueTimeout time.Duration
+	adaptive     *Adaptive
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  []*waiter
+	// lastDecrease is when the adaptive limit last shrank. Calls started
+	// before it do not shrink it again.
+	lastDecrease time.Time
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Option configures a Bulkhead.
+type Option func(*Bulkhead)
+
+// WithQueue lets up to size calls wait up to timeout for a slot when the
+// limit is reached. Without it, such calls are refused at once.
+func WithQueue(size int, timeout time.Duration) Option {
+	return func(b *Bulkhead) {
+		b.queueSize = size
+		b.queueTimeout = timeout
+	}
+}
+
+// WithAdaptive adjusts the limit to the observed latency. The limit given to
+// New is the starting point.
+func WithAdaptive(a Adaptive) Option {
+	return func(b *Bulkhead) {
+		b.adaptive = &a
+	}
+}
+
+// WithClock makes the bulkhead read the time from now instead of time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(b *Bulkhead) {
+		b.now = now
+	}
+}
+
+// New returns a bulkhead allowing limit concurrent calls.
+func New(limit int, opts ...Option) *Bulkhead {
+	b := &Bulkhead{now: time.Now, limit: float64(limit)}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.adaptive != nil {
+		b.limit = clamp(b.limit, float64(b.adaptive.MinLimit), float64(b.adaptive.MaxLimit))
+	}
+	return b
+}
+
+// Acquire takes a slot, waiting in the queue if there is one. The returned
+// release must be called once the call is over; it also reports the call's
+// latency to the adaptive limit.
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	b.mu.Lock()
+	if b.inFlight < b.capacity() && len(b.waiters) == 0 {
+		b.inFlight++
+		b.mu.Unlock()
+		return b.releaser(), nil
+	}
+	if len(b.waiters) >= b.queueSize {
+		b.mu.Unlock()
+		return nil, ErrFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	b.waiters = append(b.waiters, w)
+	b.mu.Unlock()
+
+	timer := time.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return b.releaser(), nil
+	case <-timer.C:
+		err = ErrFull
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w.granted {
+		// The slot was handed over while timing out; keep it.
+		return b.releaser(), nil
+	}
+	for i, other := range b.waiters {
+		if other == w {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			break
+		}
+	}
+	return nil, err
+}
+
+// Limit returns the current limit.
+func (b *Bulkhead) Limit() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.capacity()
+}
+
+// InFlight returns the number of calls holding a slot.
+func (b *Bulkhead) InFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+func (b *Bulkhead) releaser() func() {
+	start := b.now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.release(start) })
+	}
+}
+
+func (b *Bulkhead) release(start time.Time) {
+	now := b.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.adaptive != nil {
+		b.adapt(start, now)
+	}
+	b.inFlight--
+	for len(b.waiters) > 0 && b.inFlight < b.capacity() {
+		w := b.waiters[0]
+		b.waiters = b.waiters[1:]
+		w.granted = true
+		b.inFlight++
+		close(w.ready)
+	}
+}
+
+// adapt updates the limit after a call that started at start. b.mu must be
+// held.
+func (b *Bulkhead) adapt(start, now time.Time) {
+	a := b.adaptive
+	if now.Sub(start) > a.LatencyTarget {
+		if !start.Before(b.lastDecrease) {
+			b.limit = clamp(b.limit*decreaseFactor, float64(a.MinLimit), float64(a.MaxLimit))
+			b.lastDecrease = now
+		}
+		return
+	}
+	if 2*b.inFlight >= b.capacity() {
+		b.limit = clamp(b.limit+1/b.limit, float64(a.MinLimit), float64(a.MaxLimit))
+	}
+}
+
+// capacity is the whole number of slots. b.mu must be held.
+func (b *Bulkhead) capacity() int {
+	return int(b.limit)
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return max(lo, min(v, hi))
+}
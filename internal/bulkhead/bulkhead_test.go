@@ -0,0 +1,191 @@
+package bulkhead_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/bulkhead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestBulkhead_Acquire(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Refuses calls over the limit without a queue", func(t *testing.T) {
+		b := bulkhead.New(2)
+		release1, err := b.Acquire(ctx)
+		require.NoError(t, err)
+		_, err = b.Acquire(ctx)
+		require.NoError(t, err)
+
+		_, err = b.Acquire(ctx)
+		assert.ErrorIs(t, err, bulkhead.ErrFull)
+		assert.Equal(t, 2, b.InFlight())
+
+		release1()
+		release1() // releasing twice frees one slot only
+		assert.Equal(t, 1, b.InFlight())
+		_, err = b.Acquire(ctx)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Queued calls get released slots in order", func(t *testing.T) {
+		b := bulkhead.New(1, bulkhead.WithQueue(2, time.Second))
+		release, err := b.Acquire(ctx)
+		require.NoError(t, err)
+
+		var order []int
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for i := 1; i <= 2; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				release, err := b.Acquire(ctx)
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				release()
+			}(i)
+			// Let the goroutine join the queue before the next one.
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		_, err = b.Acquire(ctx)
+		assert.ErrorIs(t, err, bulkhead.ErrFull, "the queue is full")
+
+		release()
+		wg.Wait()
+		assert.Equal(t, []int{1, 2}, order)
+		assert.Equal(t, 0, b.InFlight())
+	})
+
+	t.Run("Queued calls give up after the queue timeout", func(t *testing.T) {
+		b := bulkhead.New(1, bulkhead.WithQueue(1, 20*time.Millisecond))
+		_, err := b.Acquire(ctx)
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = b.Acquire(ctx)
+		assert.ErrorIs(t, err, bulkhead.ErrFull)
+		assert.Less(t, time.Since(start), time.Second)
+
+		_, err = b.Acquire(ctx)
+		assert.ErrorIs(t, err, bulkhead.ErrFull, "the timed out call left the queue")
+	})
+
+	t.Run("Queued calls stop with their context", func(t *testing.T) {
+		b := bulkhead.New(1, bulkhead.WithQueue(1, time.Minute))
+		_, err := b.Acquire(ctx)
+		require.NoError(t, err)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = b.Acquire(cancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Never exceeds the limit", func(t *testing.T) {
+		b := bulkhead.New(3, bulkhead.WithQueue(100, time.Second))
+		var current, peak atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := b.Acquire(ctx)
+				if !assert.NoError(t, err) {
+					return
+				}
+				n := current.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				current.Add(-1)
+				release()
+			}()
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, peak.Load(), int32(3))
+		assert.Equal(t, 0, b.InFlight())
+	})
+}
+
+func TestBulkhead_Adaptive(t *testing.T) {
+	ctx := context.Background()
+	clk := &clock{now: time.Unix(0, 0)}
+	b := bulkhead.New(10,
+		bulkhead.WithAdaptive(bulkhead.Adaptive{MinLimit: 2, MaxLimit: 12, LatencyTarget: 100 * time.Millisecond}),
+		bulkhead.WithClock(clk.Now),
+	)
+
+	// call runs n concurrent calls lasting latency.
+	call := func(n int, latency time.Duration) {
+		releases := make([]func(), n)
+		for i := range releases {
+			release, err := b.Acquire(ctx)
+			require.NoError(t, err)
+			releases[i] = release
+		}
+		clk.Advance(latency)
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	call(10, 200*time.Millisecond)
+	assert.Equal(t, 9, b.Limit(), "a batch of slow calls shrinks the limit once")
+
+	call(9, 200*time.Millisecond)
+	call(8, 200*time.Millisecond)
+	assert.Equal(t, 7, b.Limit())
+
+	for i := 0; i < 20; i++ {
+		call(2, 300*time.Millisecond)
+	}
+	assert.Equal(t, 2, b.Limit(), "the limit stops at MinLimit")
+
+	for i := 0; i < 10; i++ {
+		call(b.Limit(), 10*time.Millisecond)
+	}
+	assert.Greater(t, b.Limit(), 4, "fast calls grow the limit back")
+
+	for i := 0; i < 200; i++ {
+		call(b.Limit(), 10*time.Millisecond)
+	}
+	assert.Equal(t, 12, b.Limit(), "the limit stops at MaxLimit")
+
+	for i := 0; i < 50; i++ {
+		call(1, 10*time.Millisecond)
+	}
+	assert.Equal(t, 12, b.Limit(), "an idle bulkhead does not grow")
+}
@@ -0,0 +1,1168 @@
+// Package config loads the gateway configuration. Values are resolved in
+// increasing order of precedence from built-in defaults, an optional YAML or
+// JSON file, environment variables and command-line flags, and the result is
+// validated before any subsystem starts.
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Storage backends accepted in StorageConfig.Backend.
+const StorageMemory = "memory"
+
+// Log levels accepted in LogConfig.Level.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// Trace exporters accepted in TracingConfig.Exporter.
+var traceExporters = []string{"none", "stdout", "console", "otlp"}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var binPrefix = regexp.MustCompile(`^[0-9]{6,8}$`)
+
+type Config struct {
+	Server        ServerConfig        `json:"server" yaml:"server"`
+	Admin         AdminConfig         `json:"admin" yaml:"admin"`
+	API           APIConfig           `json:"api" yaml:"api"`
+	Bank          BankConfig          `json:"bank" yaml:"bank"`
+	Payments      PaymentsConfig      `json:"payments" yaml:"payments"`
+	Async         AsyncConfig         `json:"async" yaml:"async"`
+	Batches       BatchesConfig       `json:"batches" yaml:"batches"`
+	Subscriptions SubscriptionsConfig `json:"subscriptions" yaml:"subscriptions"`
+	Risk          RiskConfig          `json:"risk" yaml:"risk"`
+	ThreeDS       ThreeDSConfig       `json:"three_ds" yaml:"three_ds"`
+	RateLimit     RateLimitConfig     `json:"rate_limit" yaml:"rate_limit"`
+	Fingerprint   FingerprintConfig   `json:"fingerprint" yaml:"fingerprint"`
+	Vault         VaultConfig         `json:"vault" yaml:"vault"`
+	Audit         AuditConfig         `json:"audit" yaml:"audit"`
+	Storage       StorageConfig       `json:"storage" yaml:"storage"`
+	Log           LogConfig           `json:"log" yaml:"log"`
+	Tracing       TracingConfig       `json:"tracing" yaml:"tracing"`
+	Health        HealthConfig        `json:"health" yaml:"health"`
+	Reload        ReloadConfig        `json:"reload" yaml:"reload"`
+}
+
+type ServerConfig struct {
+	Addr            string   `json:"addr" yaml:"addr"`
+	DrainDelay      Duration `json:"drain_delay" yaml:"drain_delay"`
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// ClientIPHeader names the header in which a trusted proxy in front of
+	// the gateway sends the client IP, such as X-Forwarded-For, whose last
+	// address is used. The connection's address is used when it is empty,
+	// or when a request lacks a valid address in the header.
+	ClientIPHeader string    `json:"client_ip_header" yaml:"client_ip_header"`
+	TLS            TLSConfig `json:"tls" yaml:"tls"`
+}
+
+type TLSConfig struct {
+	// CertFile and KeyFile hold the PEM certificate chain and key of the
+	// listener, which serves plain HTTP when they are empty. Changed files
+	// are picked up every ReloadInterval without a restart.
+	CertFile       string   `json:"cert_file" yaml:"cert_file"`
+	KeyFile        string   `json:"key_file" yaml:"key_file"`
+	ReloadInterval Duration `json:"reload_interval" yaml:"reload_interval"`
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string `json:"min_version" yaml:"min_version"`
+	// CipherSuites restricts the TLS 1.2 cipher suites, named as in
+	// crypto/tls. TLS 1.3 suites are not configurable.
+	CipherSuites []string `json:"cipher_suites,omitempty" yaml:"cipher_suites,omitempty"`
+	// ClientCAFile enables mutual TLS: client certificates must chain to
+	// one of the PEM certificates in this file.
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file"`
+	// ClientAuth is "require", or "optional" to also serve clients without
+	// a certificate.
+	ClientAuth string `json:"client_auth" yaml:"client_auth"`
+	// ClientMerchants maps client certificate identities (subject common
+	// name, else first DNS name) to merchant IDs. Identities not listed are
+	// the merchant ID themselves.
+	ClientMerchants map[string]string `json:"client_merchants,omitempty" yaml:"client_merchants,omitempty"`
+}
+
+// Enabled reports whether a certificate is configured.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// Client authentication modes accepted in TLSConfig.ClientAuth.
+var clientAuthModes = []string{"require", "optional"}
+
+type AdminConfig struct {
+	// Token is the bearer token required by /admin endpoints. The admin API
+	// is disabled when it is empty.
+	Token Secret `json:"token" yaml:"token"`
+}
+
+type APIConfig struct {
+	// Deprecations announces the retirement of API versions, keyed by
+	// version ("v1") or by "unversioned" for the /api alias.
+	Deprecations map[string]DeprecationConfig `json:"deprecations,omitempty" yaml:"deprecations,omitempty"`
+}
+
+// API versions accepted as keys of APIConfig.Deprecations. Keep in sync
+// with the versions served by the api package.
+var apiVersions = []string{"unversioned", "v1"}
+
+type DeprecationConfig struct {
+	// Since is when the version was deprecated, sent in the Deprecation
+	// header.
+	Since time.Time `json:"since" yaml:"since"`
+	// Sunset is when the version stops being served, sent in the Sunset
+	// header. Requests after it are answered with 410 Gone. Zero announces
+	// no date.
+	Sunset time.Time `json:"sunset,omitempty" yaml:"sunset,omitempty"`
+}
+
+type BankConfig struct {
+	// Timeout bounds each call to an acquirer, retries included.
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+	// MaxAttempts is the number of attempts per call. Only attempts the
+	// acquirer did not process (never sent, or answered 503) are retried.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+	// AttemptTimeout bounds each attempt; 0 leaves only Timeout.
+	AttemptTimeout Duration          `json:"attempt_timeout" yaml:"attempt_timeout"`
+	Transport      TransportConfig   `json:"transport" yaml:"transport"`
+	Concurrency    ConcurrencyConfig `json:"concurrency" yaml:"concurrency"`
+	Hedging        HedgingConfig     `json:"hedging" yaml:"hedging"`
+	Acquirers      []AcquirerConfig  `json:"acquirers" yaml:"acquirers"`
+}
+
+// HedgingConfig sends a second attempt of a call, with the same
+// authorization reference, when the first has not been answered within the
+// Percentile of recent latencies, bounded by MinDelay and MaxDelay. It only
+// applies to acquirers that echo the reference, advertising idempotency.
+type HedgingConfig struct {
+	Enabled    bool     `json:"enabled" yaml:"enabled"`
+	Percentile float64  `json:"percentile" yaml:"percentile"`
+	MinDelay   Duration `json:"min_delay" yaml:"min_delay"`
+	// MaxDelay is also the delay used until enough latencies are known.
+	MaxDelay Duration `json:"max_delay" yaml:"max_delay"`
+}
+
+// Concurrency limiter modes accepted in ConcurrencyConfig.Mode.
+var concurrencyModes = []string{"static", "adaptive"}
+
+// ConcurrencyConfig bounds the concurrent calls to each acquirer. Calls over
+// the limit wait in a queue, and are refused with 503 when the queue is full
+// or they waited QueueTimeout.
+type ConcurrencyConfig struct {
+	// Limit is the maximum of concurrent calls per acquirer, or the
+	// starting limit in adaptive mode. 0 disables the limiter.
+	Limit        int      `json:"limit" yaml:"limit"`
+	QueueSize    int      `json:"queue_size" yaml:"queue_size"`
+	QueueTimeout Duration `json:"queue_timeout" yaml:"queue_timeout"`
+	// Mode is "static", or "adaptive" to move the limit between MinLimit
+	// and MaxLimit, shrinking it while calls are slower than LatencyTarget.
+	Mode          string   `json:"mode" yaml:"mode"`
+	MinLimit      int      `json:"min_limit" yaml:"min_limit"`
+	MaxLimit      int      `json:"max_limit" yaml:"max_limit"`
+	LatencyTarget Duration `json:"latency_target" yaml:"latency_target"`
+}
+
+// TransportConfig tunes the connection pool of each acquirer.
+type TransportConfig struct {
+	MaxIdleConns        int `json:"max_idle_conns" yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host"`
+	// MaxConnsPerHost bounds the connections to an acquirer, 0 for no
+	// limit.
+	MaxConnsPerHost int      `json:"max_conns_per_host" yaml:"max_conns_per_host"`
+	IdleConnTimeout Duration `json:"idle_conn_timeout" yaml:"idle_conn_timeout"`
+	// KeepAlive is the TCP keep-alive period, 0 to disable probes.
+	KeepAlive           Duration `json:"keep_alive" yaml:"keep_alive"`
+	DialTimeout         Duration `json:"dial_timeout" yaml:"dial_timeout"`
+	TLSHandshakeTimeout Duration `json:"tls_handshake_timeout" yaml:"tls_handshake_timeout"`
+	// HTTP2 negotiates HTTP/2 with acquirers served over TLS.
+	HTTP2 bool `json:"http2" yaml:"http2"`
+}
+
+type AcquirerConfig struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+	// Weight is the relative share of payments routed to this acquirer.
+	// Zero takes the acquirer out of rotation.
+	Weight int `json:"weight" yaml:"weight"`
+	// DeclineCodes maps the acquirer's own decline reasons to normalized
+	// decline codes, on top of the built-in table.
+	DeclineCodes map[string]string `json:"decline_codes,omitempty" yaml:"decline_codes,omitempty"`
+	// TLS configures the connection to the acquirer.
+	TLS AcquirerTLSConfig `json:"tls" yaml:"tls"`
+	// Signing configures the signature of payment request bodies.
+	Signing SigningConfig `json:"signing" yaml:"signing"`
+}
+
+type AcquirerTLSConfig struct {
+	// CertFile and KeyFile hold the client certificate presented to an
+	// acquirer requiring mutual TLS.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	// CAFile is a PEM bundle of the CAs trusted to sign the acquirer's
+	// certificate instead of the system roots.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+	// PinnedCerts lists hex SHA-256 fingerprints of certificates, one of
+	// which must be in the acquirer's verified chain.
+	PinnedCerts []string `json:"pinned_certs,omitempty" yaml:"pinned_certs,omitempty"`
+}
+
+// Enabled reports whether any TLS setting is configured.
+func (t AcquirerTLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.CAFile != "" || len(t.PinnedCerts) > 0
+}
+
+// Signing methods accepted in SigningConfig.Method.
+const (
+	SigningHMAC = "hmac"
+	SigningJWS  = "jws"
+)
+
+var signingMethods = []string{SigningHMAC, SigningJWS}
+
+type SigningConfig struct {
+	// Method is empty to send requests unsigned, "hmac" for an
+	// HMAC-SHA256 under a shared key or "jws" for a detached JWS under a
+	// private key.
+	Method string `json:"method" yaml:"method"`
+	// KeyID announces the key to the acquirer, if it asks for one.
+	KeyID string `json:"key_id" yaml:"key_id"`
+	// Key is the shared HMAC key. KeyFile names a file holding it instead,
+	// or the PEM private key of the jws method.
+	Key     Secret `json:"key" yaml:"key"`
+	KeyFile string `json:"key_file" yaml:"key_file"`
+}
+
+// LoadHMACKey reads the shared key of the hmac method. Surrounding
+// whitespace in KeyFile is ignored.
+func (s SigningConfig) LoadHMACKey() ([]byte, error) {
+	if s.Key != "" {
+		return []byte(s.Key.Value()), nil
+	}
+	data, err := os.ReadFile(s.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("signing key file %s is empty", s.KeyFile)
+	}
+	return key, nil
+}
+
+// Decline codes accepted as values of AcquirerConfig.DeclineCodes. Keep in
+// sync with payments.DeclineCodes.
+var declineCodes = []string{
+	"insufficient_funds", "do_not_honor", "expired_card", "suspected_fraud", "invalid_cvv",
+	"invalid_card", "lost_or_stolen", "limit_exceeded", "transaction_not_permitted", "issuer_unavailable",
+	"authentication_required", "authentication_failed",
+}
+
+type PaymentsConfig struct {
+	AllowedCurrencies []string `json:"allowed_currencies" yaml:"allowed_currencies"`
+	// MaxAmount caps the amount, in minor units, accepted per currency.
+	// Currencies without an entry are not capped.
+	MaxAmount map[string]int `json:"max_amount" yaml:"max_amount"`
+}
+
+type RiskConfig struct {
+	// BlockedBINs lists card number prefixes (6 to 8 digits) that are
+	// rejected before reaching the acquirer.
+	BlockedBINs []string `json:"blocked_bins" yaml:"blocked_bins"`
+	// Duplicates configures the detection of payments identical to a
+	// recent one.
+	Duplicates DuplicatesConfig `json:"duplicates" yaml:"duplicates"`
+	// ThreeDS selects the payments authenticated with 3-D Secure before
+	// reaching the acquirer.
+	ThreeDS ThreeDSRulesConfig `json:"three_ds" yaml:"three_ds"`
+}
+
+// Duplicate actions accepted in DuplicatesConfig.
+var duplicateActions = []string{"warn", "reject"}
+
+type DuplicatesConfig struct {
+	// Window is how long an authorized payment is remembered. Zero disables
+	// duplicate detection.
+	Window Duration `json:"window" yaml:"window"`
+	// Action is "warn" (the payment goes through and reports the earlier
+	// one) or "reject". Merchants overrides it per X-Merchant-Id.
+	Action    string            `json:"action" yaml:"action"`
+	Merchants map[string]string `json:"merchants" yaml:"merchants"`
+}
+
+type ThreeDSRulesConfig struct {
+	// Currencies lists the currencies whose payments are authenticated.
+	// Merchant-initiated payments are never authenticated.
+	Currencies []string `json:"currencies,omitempty" yaml:"currencies,omitempty"`
+	// ExemptBelow exempts payments under an amount, in minor units, per
+	// currency.
+	ExemptBelow map[string]int `json:"exempt_below,omitempty" yaml:"exempt_below,omitempty"`
+}
+
+// ThreeDSConfig connects the gateway to a 3DS server. Cardholders are
+// authenticated when risk.three_ds requires it, and when an issuer declines
+// a payment for lack of authentication.
+type ThreeDSConfig struct {
+	// ServerURL is the base URL of the 3DS server. 3-D Secure is disabled
+	// when it is empty.
+	ServerURL string `json:"server_url" yaml:"server_url"`
+	// PublicURL is the base URL of the gateway as reached by cardholders'
+	// browsers, to which the ACS posts challenge responses.
+	PublicURL string   `json:"public_url" yaml:"public_url"`
+	Timeout   Duration `json:"timeout" yaml:"timeout"`
+	// ChallengeTimeout is how long a payment awaits its cardholder before
+	// it fails.
+	ChallengeTimeout Duration `json:"challenge_timeout" yaml:"challenge_timeout"`
+}
+
+// Enabled reports whether a 3DS server is configured.
+func (t ThreeDSConfig) Enabled() bool {
+	return t.ServerURL != ""
+}
+
+type RateLimitConfig struct {
+	// Endpoints limits requests per merchant (X-Merchant-Id), or per client
+	// IP without one, by endpoint. Endpoints without a limit are not
+	// throttled.
+	Endpoints map[string]LimitConfig `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+	// Tiers replaces Endpoints limits for the merchants of a tier.
+	Tiers map[string]map[string]LimitConfig `json:"tiers,omitempty" yaml:"tiers,omitempty"`
+	// Merchants assigns merchants to tiers.
+	Merchants map[string]string `json:"merchants,omitempty" yaml:"merchants,omitempty"`
+}
+
+// Endpoints accepted as keys of RateLimitConfig.Endpoints and tiers. Keep in
+// sync with the routes throttled by the api package.
+var rateLimitEndpoints = []string{
+	"create_payment", "get_payment", "create_token",
+	"create_payment_batch", "get_payment_batch",
+	"create_subscription", "get_subscription", "cancel_subscription",
+	"create_customer", "get_customer", "update_customer", "delete_customer", "list_customer_payments",
+	"complete_authentication",
+}
+
+// LimitConfig is a token bucket: Requests are allowed every Per, in bursts
+// of up to Burst (Requests when zero).
+type LimitConfig struct {
+	Requests int      `json:"requests" yaml:"requests"`
+	Per      Duration `json:"per" yaml:"per"`
+	Burst    int      `json:"burst,omitempty" yaml:"burst,omitempty"`
+}
+
+type FingerprintConfig struct {
+	// Key is the base64-encoded 32-byte HMAC key of card fingerprints, or
+	// KeyFile names a file holding it. Without either, a random key is
+	// generated at startup and fingerprints change on every restart.
+	Key     Secret `json:"key" yaml:"key"`
+	KeyFile string `json:"key_file" yaml:"key_file"`
+}
+
+// LoadKey reads the configured key material. It returns nil when no key is
+// configured.
+func (f FingerprintConfig) LoadKey() ([]byte, error) {
+	switch {
+	case f.Key != "":
+		return keyring.DecodeKey(f.Key.Value())
+	case f.KeyFile != "":
+		return keyring.LoadKeyFile(f.KeyFile)
+	}
+	return nil, nil
+}
+
+// AsyncConfig enables asynchronous payments, requested with
+// "Prefer: respond-async" and authorized by a pool of workers.
+type AsyncConfig struct {
+	Enabled   bool `json:"enabled" yaml:"enabled"`
+	Workers   int  `json:"workers" yaml:"workers"`
+	QueueSize int  `json:"queue_size" yaml:"queue_size"`
+	// QueueFile journals the queue so that it survives restarts. Jobs hold
+	// card data and are encrypted with the vault keys, which it requires.
+	// The queue is kept in memory when empty.
+	QueueFile string `json:"queue_file" yaml:"queue_file"`
+	// DrainTimeout bounds how long queued payments are processed on
+	// shutdown. Those left are processed on the next start with a
+	// QueueFile, and lost without one.
+	DrainTimeout Duration `json:"drain_timeout" yaml:"drain_timeout"`
+	// Callbacks are the endpoints notified of asynchronous payments, by
+	// X-Merchant-Id.
+	Callbacks map[string]CallbackConfig `json:"callbacks,omitempty" yaml:"callbacks,omitempty"`
+}
+
+type CallbackConfig struct {
+	URL string `json:"url" yaml:"url"`
+	// Secret signs callbacks with HMAC-SHA256; they are unsigned when
+	// empty.
+	Secret Secret `json:"secret" yaml:"secret"`
+}
+
+// BatchesConfig bounds the processing of payment batches.
+type BatchesConfig struct {
+	// Concurrency is the number of batch payments sent to the acquirers at
+	// once, across every batch, so that batches leave room for single
+	// payments.
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// MaxItems is the largest number of payments accepted in one batch.
+	MaxItems int `json:"max_items" yaml:"max_items"`
+}
+
+// SubscriptionsConfig schedules the charges of subscriptions, which are
+// served when the vault is enabled.
+type SubscriptionsConfig struct {
+	// PollInterval is how often due subscriptions are charged.
+	PollInterval Duration `json:"poll_interval" yaml:"poll_interval"`
+	// Concurrency is the number of subscriptions charged at once.
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// RetrySchedule lists the delays before each retry of a charge that
+	// failed or was softly declined. The subscription is suspended when the
+	// last retry fails too.
+	RetrySchedule []Duration `json:"retry_schedule" yaml:"retry_schedule"`
+}
+
+type VaultConfig struct {
+	// MasterKey is a single base64-encoded 32-byte key wrapping the vault's
+	// data keys. MasterKeyFile names a file holding the same encoding
+	// instead. Both are shorthands for a ring with one key named
+	// DefaultVaultKeyID; use Keys to rotate.
+	MasterKey     Secret `json:"master_key" yaml:"master_key"`
+	MasterKeyFile string `json:"master_key_file" yaml:"master_key_file"`
+	// Keys lists versioned master keys, each read from its own file. New
+	// data keys are wrapped with ActiveKey; the others are kept to unwrap
+	// existing records until they have been re-encrypted.
+	Keys      []VaultKeyConfig `json:"keys" yaml:"keys"`
+	ActiveKey string           `json:"active_key" yaml:"active_key"`
+}
+
+type VaultKeyConfig struct {
+	ID   string `json:"id" yaml:"id"`
+	File string `json:"file" yaml:"file"`
+}
+
+// DefaultVaultKeyID names the key given by master_key or master_key_file.
+const DefaultVaultKeyID = "default"
+
+// Enabled reports whether a master key is configured. The token vault is
+// disabled otherwise.
+func (v VaultConfig) Enabled() bool {
+	return v.MasterKey != "" || v.MasterKeyFile != "" || len(v.Keys) > 0
+}
+
+// LoadKeys reads the configured key material and returns it with the ID of
+// the active key.
+func (v VaultConfig) LoadKeys() ([]keyring.Key, string, error) {
+	switch {
+	case v.MasterKey != "":
+		key, err := keyring.DecodeKey(v.MasterKey.Value())
+		if err != nil {
+			return nil, "", err
+		}
+		return []keyring.Key{{ID: DefaultVaultKeyID, Material: key}}, DefaultVaultKeyID, nil
+	case v.MasterKeyFile != "":
+		key, err := keyring.LoadKeyFile(v.MasterKeyFile)
+		if err != nil {
+			return nil, "", err
+		}
+		return []keyring.Key{{ID: DefaultVaultKeyID, Material: key}}, DefaultVaultKeyID, nil
+	}
+
+	keys := make([]keyring.Key, 0, len(v.Keys))
+	for _, k := range v.Keys {
+		material, err := keyring.LoadKeyFile(k.File)
+		if err != nil {
+			return nil, "", fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		keys = append(keys, keyring.Key{ID: k.ID, Material: material})
+	}
+	return keys, v.ActiveKey, nil
+}
+
+type AuditConfig struct {
+	// File is the JSON lines file the audit log is appended to. The log is
+	// kept in memory only when it is empty.
+	File string `json:"file" yaml:"file"`
+}
+
+type StorageConfig struct {
+	Backend string `json:"backend" yaml:"backend"`
+}
+
+type LogConfig struct {
+	Level string `json:"level" yaml:"level"`
+}
+
+type TracingConfig struct {
+	Exporter string `json:"exporter" yaml:"exporter"`
+}
+
+type HealthConfig struct {
+	CheckTimeout Duration `json:"check_timeout" yaml:"check_timeout"`
+	// BankProbeInterval is how often the acquirers are pinged in the
+	// background; readiness reports the last outcome.
+	BankProbeInterval Duration `json:"bank_probe_interval" yaml:"bank_probe_interval"`
+}
+
+type ReloadConfig struct {
+	// WatchInterval is how often the config file is polled for changes.
+	// Zero disables polling; SIGHUP and the admin endpoint still work.
+	WatchInterval Duration `json:"watch_interval" yaml:"watch_interval"`
+}
+
+// Default returns the configuration used when nothing else is provided. It
+// matches the values that used to be hardcoded.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8090",
+			DrainDelay:      Duration(5 * time.Second),
+			ShutdownTimeout: Duration(10 * time.Second),
+			TLS: TLSConfig{
+				ReloadInterval: Duration(time.Minute),
+				MinVersion:     "1.2",
+				ClientAuth:     "require",
+			},
+		},
+		Bank: BankConfig{
+			Timeout:     Duration(5 * time.Second),
+			MaxAttempts: 1,
+			Transport: TransportConfig{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 100,
+				IdleConnTimeout:     Duration(90 * time.Second),
+				KeepAlive:           Duration(30 * time.Second),
+				DialTimeout:         Duration(2 * time.Second),
+				TLSHandshakeTimeout: Duration(5 * time.Second),
+				HTTP2:               true,
+			},
+			Concurrency: ConcurrencyConfig{
+				QueueTimeout:  Duration(100 * time.Millisecond),
+				Mode:          "static",
+				MinLimit:      1,
+				LatencyTarget: Duration(time.Second),
+			},
+			Hedging: HedgingConfig{
+				Percentile: 95,
+				MinDelay:   Duration(10 * time.Millisecond),
+				MaxDelay:   Duration(time.Second),
+			},
+			Acquirers: []AcquirerConfig{
+				{Name: "default", URL: "http://localhost:8080", Weight: 1},
+			},
+		},
+		Payments: PaymentsConfig{
+			AllowedCurrencies: []string{"USD", "EUR", "BRL"},
+		},
+		Async: AsyncConfig{
+			Workers:      4,
+			QueueSize:    1000,
+			DrainTimeout: Duration(30 * time.Second),
+		},
+		Batches: BatchesConfig{Concurrency: 8, MaxItems: 1000},
+		Subscriptions: SubscriptionsConfig{
+			PollInterval:  Duration(time.Minute),
+			Concurrency:   4,
+			RetrySchedule: []Duration{Duration(24 * time.Hour), Duration(72 * time.Hour), Duration(120 * time.Hour)},
+		},
+		Risk: RiskConfig{
+			Duplicates: DuplicatesConfig{Window: Duration(10 * time.Minute), Action: "warn"},
+		},
+		ThreeDS: ThreeDSConfig{
+			Timeout:          Duration(5 * time.Second),
+			ChallengeTimeout: Duration(10 * time.Minute),
+		},
+		Storage: StorageConfig{Backend: StorageMemory},
+		Log:     LogConfig{Level: "info"},
+		Tracing: TracingConfig{Exporter: "none"},
+		Health:  HealthConfig{CheckTimeout: Duration(time.Second), BankProbeInterval: Duration(10 * time.Second)},
+		Reload:  ReloadConfig{WatchInterval: Duration(5 * time.Second)},
+	}
+}
+
+// LoadFile overlays the YAML (.yaml, .yml) or JSON (.json) file at path onto
+// c. Unknown keys are rejected so typos do not go unnoticed.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q (use .yaml, .yml or .json)", ext)
+	}
+
+	return nil
+}
+
+// Validate checks every setting and returns all problems at once, one per
+// line, each prefixed with the setting's file key.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Addr == "" {
+		fail("server.addr", "must not be empty")
+	}
+	if c.Server.DrainDelay < 0 {
+		fail("server.drain_delay", "must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		fail("server.shutdown_timeout", "must be positive")
+	}
+	c.validateTLS(fail)
+
+	for _, version := range sortedKeys(c.API.Deprecations) {
+		key := "api.deprecations." + version
+		dep := c.API.Deprecations[version]
+		if !contains(apiVersions, version) {
+			fail("api.deprecations", "unknown API version %q (supported: %s)", version, strings.Join(apiVersions, ", "))
+		}
+		if dep.Since.IsZero() {
+			fail(key+".since", "must be set")
+		}
+		if !dep.Sunset.IsZero() && !dep.Sunset.After(dep.Since) {
+			fail(key+".sunset", "must be after since")
+		}
+	}
+
+	if c.Bank.Timeout <= 0 {
+		fail("bank.timeout", "must be positive")
+	}
+	if c.Bank.MaxAttempts < 1 {
+		fail("bank.max_attempts", "must be at least 1")
+	}
+	if c.Bank.AttemptTimeout < 0 {
+		fail("bank.attempt_timeout", "must not be negative")
+	} else if c.Bank.AttemptTimeout > c.Bank.Timeout {
+		fail("bank.attempt_timeout", "must not exceed bank.timeout")
+	}
+	c.validateTransport(fail)
+	c.validateConcurrency(fail)
+	c.validateHedging(fail)
+	if len(c.Bank.Acquirers) == 0 {
+		fail("bank.acquirers", "must contain at least one acquirer")
+	}
+	names := make(map[string]bool, len(c.Bank.Acquirers))
+	totalWeight := 0
+	for i, acq := range c.Bank.Acquirers {
+		key := fmt.Sprintf("bank.acquirers[%d]", i)
+		if acq.Name == "" {
+			fail(key+".name", "must not be empty")
+		} else if names[acq.Name] {
+			fail(key+".name", "duplicate acquirer %q", acq.Name)
+		}
+		names[acq.Name] = true
+		if u, err := url.Parse(acq.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail(key+".url", "must be an absolute http or https URL, got %q", redactURL(acq.URL))
+		}
+		if acq.Weight < 0 {
+			fail(key+".weight", "must not be negative")
+		}
+		for _, reason := range sortedKeys(acq.DeclineCodes) {
+			if code := acq.DeclineCodes[reason]; !contains(declineCodes, code) {
+				fail(key+".decline_codes", "unsupported decline code %q for %q", code, reason)
+			}
+		}
+		validateAcquirerTLS(key+".tls", acq, fail)
+		validateSigning(key+".signing", acq.Signing, fail)
+		totalWeight += acq.Weight
+	}
+	if len(c.Bank.Acquirers) > 0 && totalWeight == 0 {
+		fail("bank.acquirers", "at least one acquirer must have a positive weight")
+	}
+
+	if len(c.Payments.AllowedCurrencies) == 0 {
+		fail("payments.allowed_currencies", "must contain at least one currency")
+	}
+	for _, currency := range c.Payments.AllowedCurrencies {
+		if !currencyCode.MatchString(currency) {
+			fail("payments.allowed_currencies", "%q is not an upper-case ISO 4217 code", currency)
+		}
+	}
+	for _, currency := range sortedKeys(c.Payments.MaxAmount) {
+		if !contains(c.Payments.AllowedCurrencies, currency) {
+			fail("payments.max_amount", "%q is not an allowed currency", currency)
+		}
+		if c.Payments.MaxAmount[currency] <= 0 {
+			fail("payments.max_amount", "limit for %s must be positive", currency)
+		}
+	}
+
+	for _, bin := range c.Risk.BlockedBINs {
+		if !binPrefix.MatchString(bin) {
+			fail("risk.blocked_bins", "%q must be 6 to 8 digits", bin)
+		}
+	}
+	if c.Risk.Duplicates.Window < 0 {
+		fail("risk.duplicates.window", "must not be negative")
+	}
+	if !contains(duplicateActions, c.Risk.Duplicates.Action) {
+		fail("risk.duplicates.action", "unsupported action %q (supported: %s)", c.Risk.Duplicates.Action, strings.Join(duplicateActions, ", "))
+	}
+	for _, merchant := range sortedKeys(c.Risk.Duplicates.Merchants) {
+		if action := c.Risk.Duplicates.Merchants[merchant]; !contains(duplicateActions, action) {
+			fail("risk.duplicates.merchants", "unsupported action %q for %q (supported: %s)", action, merchant, strings.Join(duplicateActions, ", "))
+		}
+	}
+
+	c.validateThreeDS(fail)
+	c.validateRateLimit(fail)
+
+	if c.Fingerprint.Key != "" && c.Fingerprint.KeyFile != "" {
+		fail("fingerprint", "set only one of key and key_file")
+	} else if _, err := c.Fingerprint.LoadKey(); err != nil {
+		fail("fingerprint", "%v", err)
+	}
+
+	c.validateVault(fail)
+	c.validateAsync(fail)
+	if c.Batches.Concurrency < 1 {
+		fail("batches.concurrency", "must be at least 1")
+	}
+	if c.Batches.MaxItems < 1 {
+		fail("batches.max_items", "must be at least 1")
+	}
+	if c.Subscriptions.PollInterval <= 0 {
+		fail("subscriptions.poll_interval", "must be positive")
+	}
+	if c.Subscriptions.Concurrency < 1 {
+		fail("subscriptions.concurrency", "must be at least 1")
+	}
+	for _, delay := range c.Subscriptions.RetrySchedule {
+		if delay <= 0 {
+			fail("subscriptions.retry_schedule", "delay %s must be positive", delay)
+		}
+	}
+
+	if c.Storage.Backend != StorageMemory {
+		fail("storage.backend", "unsupported backend %q (supported: %s)", c.Storage.Backend, StorageMemory)
+	}
+	if !contains(logLevels, c.Log.Level) {
+		fail("log.level", "unsupported level %q (supported: %s)", c.Log.Level, strings.Join(logLevels, ", "))
+	}
+	if !contains(traceExporters, c.Tracing.Exporter) {
+		fail("tracing.exporter", "unsupported exporter %q (supported: %s)", c.Tracing.Exporter, strings.Join(traceExporters, ", "))
+	}
+	if c.Health.CheckTimeout <= 0 {
+		fail("health.check_timeout", "must be positive")
+	}
+	if c.Health.BankProbeInterval <= 0 {
+		fail("health.bank_probe_interval", "must be positive")
+	}
+	if c.Reload.WatchInterval < 0 {
+		fail("reload.watch_interval", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateTLS(fail func(key, format string, args ...any)) {
+	t := c.Server.TLS
+	version, err := tlsconfig.ParseVersion(t.MinVersion)
+	if err != nil {
+		fail("server.tls.min_version", "%v", err)
+	}
+	if _, err := tlsconfig.ParseCipherSuites(t.CipherSuites); err != nil {
+		fail("server.tls.cipher_suites", "%v", err)
+	} else if len(t.CipherSuites) > 0 && version == tls.VersionTLS13 {
+		fail("server.tls.cipher_suites", "cannot be set with min_version 1.3")
+	}
+	if !contains(clientAuthModes, t.ClientAuth) {
+		fail("server.tls.client_auth", "unsupported mode %q (supported: %s)", t.ClientAuth, strings.Join(clientAuthModes, ", "))
+	}
+	if t.ReloadInterval <= 0 {
+		fail("server.tls.reload_interval", "must be positive")
+	}
+
+	if !t.Enabled() {
+		if t.ClientCAFile != "" {
+			fail("server.tls.client_ca_file", "requires cert_file and key_file")
+		}
+		return
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		fail("server.tls", "set both cert_file and key_file")
+	} else if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+		fail("server.tls", "%v", err)
+	}
+	if t.ClientCAFile != "" {
+		if _, err := tlsconfig.LoadCertPool(t.ClientCAFile); err != nil {
+			fail("server.tls.client_ca_file", "%v", err)
+		}
+	} else if len(t.ClientMerchants) > 0 {
+		fail("server.tls.client_merchants", "requires client_ca_file")
+	}
+}
+
+func (c *Config) validateTransport(fail func(key, format string, args ...any)) {
+	t := c.Bank.Transport
+	if t.MaxIdleConns < 0 {
+		fail("bank.transport.max_idle_conns", "must not be negative")
+	}
+	if t.MaxIdleConnsPerHost < 0 {
+		fail("bank.transport.max_idle_conns_per_host", "must not be negative")
+	}
+	if t.MaxConnsPerHost < 0 {
+		fail("bank.transport.max_conns_per_host", "must not be negative")
+	}
+	if t.IdleConnTimeout < 0 {
+		fail("bank.transport.idle_conn_timeout", "must not be negative")
+	}
+	if t.KeepAlive < 0 {
+		fail("bank.transport.keep_alive", "must not be negative")
+	}
+	if t.DialTimeout <= 0 {
+		fail("bank.transport.dial_timeout", "must be positive")
+	}
+	if t.TLSHandshakeTimeout <= 0 {
+		fail("bank.transport.tls_handshake_timeout", "must be positive")
+	}
+}
+
+func (c *Config) validateConcurrency(fail func(key, format string, args ...any)) {
+	cc := c.Bank.Concurrency
+	if cc.Limit < 0 {
+		fail("bank.concurrency.limit", "must not be negative")
+	}
+	if cc.QueueSize < 0 {
+		fail("bank.concurrency.queue_size", "must not be negative")
+	}
+	if cc.QueueTimeout < 0 {
+		fail("bank.concurrency.queue_timeout", "must not be negative")
+	} else if cc.Limit > 0 && cc.QueueTimeout >= c.Bank.Timeout {
+		fail("bank.concurrency.queue_timeout", "must be shorter than bank.timeout")
+	}
+	if !contains(concurrencyModes, cc.Mode) {
+		fail("bank.concurrency.mode", "unsupported mode %q (supported: %s)", cc.Mode, strings.Join(concurrencyModes, ", "))
+	}
+	if cc.Mode != "adaptive" || cc.Limit == 0 {
+		return
+	}
+	if cc.MinLimit < 1 {
+		fail("bank.concurrency.min_limit", "must be at least 1")
+	}
+	if cc.MaxLimit < cc.Limit || cc.Limit < cc.MinLimit {
+		fail("bank.concurrency", "adaptive mode needs min_limit <= limit <= max_limit")
+	}
+	if cc.LatencyTarget <= 0 {
+		fail("bank.concurrency.latency_target", "must be positive")
+	}
+}
+
+func (c *Config) validateHedging(fail func(key, format string, args ...any)) {
+	h := c.Bank.Hedging
+	if !h.Enabled {
+		return
+	}
+	if h.Percentile <= 0 || h.Percentile >= 100 {
+		fail("bank.hedging.percentile", "must be between 0 and 100")
+	}
+	if h.MinDelay <= 0 {
+		fail("bank.hedging.min_delay", "must be positive")
+	}
+	if h.MaxDelay < h.MinDelay {
+		fail("bank.hedging.max_delay", "must not be shorter than min_delay")
+	} else if h.MaxDelay >= c.Bank.Timeout {
+		fail("bank.hedging.max_delay", "must be shorter than bank.timeout")
+	}
+}
+
+func validateAcquirerTLS(key string, acq AcquirerConfig, fail func(key, format string, args ...any)) {
+	t := acq.TLS
+	if !t.Enabled() {
+		return
+	}
+	if u, err := url.Parse(acq.URL); err == nil && u.Scheme != "https" {
+		fail(key, "requires an https url")
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			fail(key, "set both cert_file and key_file")
+		} else if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			fail(key, "%v", err)
+		}
+	}
+	if t.CAFile != "" {
+		if _, err := tlsconfig.LoadCertPool(t.CAFile); err != nil {
+			fail(key+".ca_file", "%v", err)
+		}
+	}
+	for _, pin := range t.PinnedCerts {
+		if _, err := tlsconfig.ParseFingerprint(pin); err != nil {
+			fail(key+".pinned_certs", "%v", err)
+		}
+	}
+}
+
+func validateSigning(key string, s SigningConfig, fail func(key, format string, args ...any)) {
+	switch s.Method {
+	case "":
+		if s.Key != "" || s.KeyFile != "" {
+			fail(key+".method", "must be set when a key is configured")
+		}
+	case SigningHMAC:
+		if (s.Key == "") == (s.KeyFile == "") {
+			fail(key, "set exactly one of key and key_file")
+		} else if _, err := s.LoadHMACKey(); err != nil {
+			fail(key, "%v", err)
+		}
+	case SigningJWS:
+		if s.Key != "" {
+			fail(key+".key", "is only used by the hmac method; set key_file to a PEM private key")
+		}
+		if s.KeyFile == "" {
+			fail(key+".key_file", "must not be empty")
+		} else if _, err := tlsconfig.LoadPrivateKey(s.KeyFile); err != nil {
+			fail(key+".key_file", "%v", err)
+		}
+	default:
+		fail(key+".method", "unsupported method %q (supported: %s)", s.Method, strings.Join(signingMethods, ", "))
+	}
+}
+
+func (c *Config) validateRateLimit(fail func(key, format string, args ...any)) {
+	checkLimits := func(key string, limits map[string]LimitConfig) {
+		for _, endpoint := range sortedKeys(limits) {
+			limit := limits[endpoint]
+			if !contains(rateLimitEndpoints, endpoint) {
+				fail(key, "unknown endpoint %q (supported: %s)", endpoint, strings.Join(rateLimitEndpoints, ", "))
+				continue
+			}
+			if limit.Requests <= 0 {
+				fail(key+"."+endpoint+".requests", "must be positive")
+			}
+			if limit.Per <= 0 {
+				fail(key+"."+endpoint+".per", "must be positive")
+			}
+			if limit.Burst < 0 {
+				fail(key+"."+endpoint+".burst", "must not be negative")
+			}
+		}
+	}
+
+	checkLimits("rate_limit.endpoints", c.RateLimit.Endpoints)
+	for _, tier := range sortedKeys(c.RateLimit.Tiers) {
+		checkLimits("rate_limit.tiers."+tier, c.RateLimit.Tiers[tier])
+	}
+	for _, merchant := range sortedKeys(c.RateLimit.Merchants) {
+		if tier := c.RateLimit.Merchants[merchant]; c.RateLimit.Tiers[tier] == nil {
+			fail("rate_limit.merchants", "unknown tier %q for %q", tier, merchant)
+		}
+	}
+}
+
+func (c *Config) validateAsync(fail func(key, format string, args ...any)) {
+	a := c.Async
+	if a.Workers < 1 {
+		fail("async.workers", "must be at least 1")
+	}
+	if a.QueueSize < 1 {
+		fail("async.queue_size", "must be at least 1")
+	}
+	if a.DrainTimeout < 0 {
+		fail("async.drain_timeout", "must not be negative")
+	}
+	if a.QueueFile != "" && !c.Vault.Enabled() {
+		fail("async.queue_file", "requires vault keys to encrypt queued card data")
+	}
+	for _, merchant := range sortedKeys(a.Callbacks) {
+		cb := a.Callbacks[merchant]
+		if u, err := url.Parse(cb.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("async.callbacks."+merchant+".url", "must be an absolute http or https URL, got %q", redactURL(cb.URL))
+		}
+	}
+}
+
+func (c *Config) validateThreeDS(fail func(key, format string, args ...any)) {
+	t := c.ThreeDS
+	if t.Enabled() {
+		if u, err := url.Parse(t.ServerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("three_ds.server_url", "must be an absolute http or https URL, got %q", redactURL(t.ServerURL))
+		}
+		if u, err := url.Parse(t.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("three_ds.public_url", "must be an absolute http or https URL, got %q", t.PublicURL)
+		}
+	}
+	if t.Timeout <= 0 {
+		fail("three_ds.timeout", "must be positive")
+	}
+	if t.ChallengeTimeout <= 0 {
+		fail("three_ds.challenge_timeout", "must be positive")
+	}
+
+	rules := c.Risk.ThreeDS
+	if len(rules.Currencies) > 0 && !t.Enabled() {
+		fail("risk.three_ds.currencies", "requires three_ds.server_url")
+	}
+	for _, currency := range rules.Currencies {
+		if !contains(c.Payments.AllowedCurrencies, currency) {
+			fail("risk.three_ds.currencies", "%q is not an allowed currency", currency)
+		}
+	}
+	for _, currency := range sortedKeys(rules.ExemptBelow) {
+		if !contains(rules.Currencies, currency) {
+			fail("risk.three_ds.exempt_below", "%q is not one of risk.three_ds.currencies", currency)
+		}
+		if rules.ExemptBelow[currency] <= 0 {
+			fail("risk.three_ds.exempt_below", "threshold for %s must be positive", currency)
+		}
+	}
+}
+
+func (c *Config) validateVault(fail func(key, format string, args ...any)) {
+	forms := 0
+	for _, set := range []bool{c.Vault.MasterKey != "", c.Vault.MasterKeyFile != "", len(c.Vault.Keys) > 0} {
+		if set {
+			forms++
+		}
+	}
+	if forms > 1 {
+		fail("vault", "set only one of master_key, master_key_file and keys")
+		return
+	}
+
+	if len(c.Vault.Keys) == 0 {
+		if c.Vault.ActiveKey != "" {
+			fail("vault.active_key", "requires vault.keys")
+		}
+	} else {
+		ids := make(map[string]bool, len(c.Vault.Keys))
+		for i, k := range c.Vault.Keys {
+			key := fmt.Sprintf("vault.keys[%d]", i)
+			if k.ID == "" {
+				fail(key+".id", "must not be empty")
+			} else if ids[k.ID] {
+				fail(key+".id", "duplicate key %q", k.ID)
+			}
+			ids[k.ID] = true
+			if k.File == "" {
+				fail(key+".file", "must not be empty")
+			}
+		}
+		if !ids[c.Vault.ActiveKey] {
+			fail("vault.active_key", "%q is not one of vault.keys", c.Vault.ActiveKey)
+		}
+	}
+
+	if c.Vault.Enabled() {
+		if _, _, err := c.Vault.LoadKeys(); err != nil {
+			fail("vault.keys", "%v", err)
+		}
+	}
+}
+
+// Redacted returns a copy of c that is safe to print: credentials embedded
+// in URLs are masked. Secret fields mask themselves when marshalled.
+func (c *Config) Redacted() *Config {
+	clone := c.Clone()
+	for i := range clone.Bank.Acquirers {
+		clone.Bank.Acquirers[i].URL = redactURL(clone.Bank.Acquirers[i].URL)
+	}
+	for merchant, cb := range clone.Async.Callbacks {
+		cb.URL = redactURL(cb.URL)
+		clone.Async.Callbacks[merchant] = cb
+	}
+	clone.ThreeDS.ServerURL = redactURL(clone.ThreeDS.ServerURL)
+	return clone
+}
+
+// Clone returns a deep copy of c.
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Bank.Acquirers = append([]AcquirerConfig(nil), c.Bank.Acquirers...)
+	for i := range clone.Bank.Acquirers {
+		clone.Bank.Acquirers[i].DeclineCodes = cloneMap(c.Bank.Acquirers[i].DeclineCodes)
+		clone.Bank.Acquirers[i].TLS.PinnedCerts = append([]string(nil), c.Bank.Acquirers[i].TLS.PinnedCerts...)
+	}
+	clone.Server.TLS.CipherSuites = append([]string(nil), c.Server.TLS.CipherSuites...)
+	clone.Server.TLS.ClientMerchants = cloneMap(c.Server.TLS.ClientMerchants)
+	clone.API.Deprecations = cloneMap(c.API.Deprecations)
+	clone.Payments.AllowedCurrencies = append([]string(nil), c.Payments.AllowedCurrencies...)
+	clone.Risk.BlockedBINs = append([]string(nil), c.Risk.BlockedBINs...)
+	clone.Risk.ThreeDS.Currencies = append([]string(nil), c.Risk.ThreeDS.Currencies...)
+	clone.Subscriptions.RetrySchedule = append([]Duration(nil), c.Subscriptions.RetrySchedule...)
+	clone.Vault.Keys = append([]VaultKeyConfig(nil), c.Vault.Keys...)
+	clone.Payments.MaxAmount = cloneMap(c.Payments.MaxAmount)
+	clone.Risk.Duplicates.Merchants = cloneMap(c.Risk.Duplicates.Merchants)
+	clone.Risk.ThreeDS.ExemptBelow = cloneMap(c.Risk.ThreeDS.ExemptBelow)
+	clone.RateLimit.Endpoints = cloneMap(c.RateLimit.Endpoints)
+	clone.RateLimit.Tiers = cloneMap(c.RateLimit.Tiers)
+	for tier, limits := range clone.RateLimit.Tiers {
+		clone.RateLimit.Tiers[tier] = cloneMap(limits)
+	}
+	clone.RateLimit.Merchants = cloneMap(c.RateLimit.Merchants)
+	clone.Async.Callbacks = cloneMap(c.Async.Callbacks)
+	return &clone
+}
+
+func cloneMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// Print writes the redacted configuration to w as YAML.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ConfigFileEnv names the environment variable holding the config file path
+// when --config is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// setting binds one configuration value to its environment variable and
+// command-line flag. Both sources parse the raw string with set. Secrets have
+// no flag so they do not show up in process listings.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{env: "LISTEN_ADDR", flag: "addr", usage: "HTTP listen address", set: func(c *Config, v string) error {
+		c.Server.Addr = v
+		return nil
+	}},
+	{env: "DRAIN_DELAY", flag: "drain-delay", usage: "time readiness fails before the listener closes", set: func(c *Config, v string) error {
+		return c.Server.DrainDelay.UnmarshalText([]byte(v))
+	}},
+	{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "time in-flight requests get to finish on shutdown", set: func(c *Config, v string) error {
+		return c.Server.ShutdownTimeout.UnmarshalText([]byte(v))
+	}},
+	{env: "TLS_CERT_FILE", flag: "tls-cert-file", usage: "PEM certificate chain served by the listener", set: func(c *Config, v string) error {
+		c.Server.TLS.CertFile = v
+		return nil
+	}},
+	{env: "TLS_KEY_FILE", flag: "tls-key-file", usage: "PEM key of the listener certificate", set: func(c *Config, v string) error {
+		c.Server.TLS.KeyFile = v
+		return nil
+	}},
+	{env: "TLS_CLIENT_CA_FILE", flag: "tls-client-ca-file", usage: "PEM CA bundle enabling mutual TLS", set: func(c *Config, v string) error {
+		c.Server.TLS.ClientCAFile = v
+		return nil
+	}},
+	{env: "ADMIN_TOKEN", usage: "bearer token for the /admin API", set: func(c *Config, v string) error {
+		c.Admin.Token = Secret(v)
+		return nil
+	}},
+	{env: "BANK_NAME", flag: "bank-name", usage: "name of the first acquirer", set: func(c *Config, v string) error {
+		firstAcquirer(c).Name = v
+		return nil
+	}},
+	{env: "BANK_URL", flag: "bank-url", usage: "base URL of the first acquirer", set: func(c *Config, v string) error {
+		firstAcquirer(c).URL = v
+		return nil
+	}},
+	{env: "BANK_TIMEOUT", flag: "bank-timeout", usage: "timeout for calls to the acquiring bank", set: func(c *Config, v string) error {
+		return c.Bank.Timeout.UnmarshalText([]byte(v))
+	}},
+	{env: "ALLOWED_CURRENCIES", flag: "allowed-currencies", usage: "comma-separated list of accepted currencies", set: func(c *Config, v string) error {
+		c.Payments.AllowedCurrencies = splitList(v)
+		return nil
+	}},
+	{env: "THREE_DS_SERVER_URL", flag: "three-ds-server-url", usage: "base URL of the 3DS server (empty disables 3-D Secure)", set: func(c *Config, v string) error {
+		c.ThreeDS.ServerURL = v
+		return nil
+	}},
+	{env: "THREE_DS_PUBLIC_URL", flag: "three-ds-public-url", usage: "base URL of the gateway as reached by cardholders' browsers", set: func(c *Config, v string) error {
+		c.ThreeDS.PublicURL = v
+		return nil
+	}},
+	{env: "FINGERPRINT_KEY", usage: "base64-encoded 32-byte card fingerprint HMAC key", set: func(c *Config, v string) error {
+		c.Fingerprint.Key = Secret(v)
+		return nil
+	}},
+	{env: "FINGERPRINT_KEY_FILE", flag: "fingerprint-key-file", usage: "file holding the base64-encoded card fingerprint HMAC key", set: func(c *Config, v string) error {
+		c.Fingerprint.KeyFile = v
+		return nil
+	}},
+	{env: "VAULT_MASTER_KEY", usage: "base64-encoded 32-byte token vault master key", set: func(c *Config, v string) error {
+		c.Vault.MasterKey = Secret(v)
+		return nil
+	}},
+	{env: "VAULT_MASTER_KEY_FILE", flag: "vault-master-key-file", usage: "file holding the base64-encoded token vault master key", set: func(c *Config, v string) error {
+		c.Vault.MasterKeyFile = v
+		return nil
+	}},
+	{env: "AUDIT_FILE", flag: "audit-file", usage: "file the audit log is appended to", set: func(c *Config, v string) error {
+		c.Audit.File = v
+		return nil
+	}},
+	{env: "STORAGE_BACKEND", flag: "storage-backend", usage: "payments storage backend", set: func(c *Config, v string) error {
+		c.Storage.Backend = v
+		return nil
+	}},
+	{env: "LOG_LEVEL", flag: "log-level", usage: "log level (debug, info, warn, error)", set: func(c *Config, v string) error {
+		c.Log.Level = strings.ToLower(v)
+		return nil
+	}},
+	{env: "OTEL_TRACES_EXPORTER", flag: "tracing-exporter", usage: "trace exporter (none, stdout, otlp)", set: func(c *Config, v string) error {
+		c.Tracing.Exporter = v
+		return nil
+	}},
+	{env: "HEALTH_CHECK_TIMEOUT", flag: "health-check-timeout", usage: "timeout of each health check", set: func(c *Config, v string) error {
+		return c.Health.CheckTimeout.UnmarshalText([]byte(v))
+	}},
+	{env: "HEALTH_BANK_PROBE_INTERVAL", flag: "health-bank-probe-interval", usage: "how often the acquirers are probed for readiness", set: func(c *Config, v string) error {
+		return c.Health.BankProbeInterval.UnmarshalText([]byte(v))
+	}},
+	{env: "RELOAD_WATCH_INTERVAL", flag: "reload-watch-interval", usage: "how often the config file is checked for changes (0 disables)", set: func(c *Config, v string) error {
+		return c.Reload.WatchInterval.UnmarshalText([]byte(v))
+	}},
+}
+
+// firstAcquirer returns the first configured acquirer, creating it when the
+// list is empty, so that BANK_URL and BANK_NAME keep working with a single
+// bank.
+func firstAcquirer(c *Config) *AcquirerConfig {
+	if len(c.Bank.Acquirers) == 0 {
+		c.Bank.Acquirers = []AcquirerConfig{{Weight: 1}}
+	}
+	return &c.Bank.Acquirers[0]
+}
+
+// Options are command-line switches that are not configuration values.
+type Options struct {
+	// ConfigFile is the file the configuration was loaded from, if any.
+	ConfigFile string
+	// PrintConfig asks the process to print the effective configuration
+	// and exit.
+	PrintConfig bool
+}
+
+// Load builds the effective configuration from defaults, the config file
+// (--config or CONFIG_FILE), environment variables read through getenv and
+// the flags in args, then validates it. It returns flag.ErrHelp when args
+// contain -h or --help.
+func Load(args []string, getenv func(string) string) (*Config, Options, error) {
+	var opts Options
+
+	fs := flag.NewFlagSet("payment-gateway", flag.ContinueOnError)
+	fs.StringVar(&opts.ConfigFile, "config", "", "path to a YAML or JSON config file (env "+ConfigFileEnv+")")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets masked and exit")
+
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		if s.flag != "" {
+			flagValues[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	cfg := Default()
+
+	if opts.ConfigFile == "" {
+		opts.ConfigFile = getenv(ConfigFileEnv)
+	}
+	if opts.ConfigFile != "" {
+		if err := cfg.LoadFile(opts.ConfigFile); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.set(cfg, v); err != nil {
+				return nil, opts, fmt.Errorf("env %s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		value, ok := flagValues[f.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.set(cfg, *value); err != nil {
+					flagErr = fmt.Errorf("flag --%s: %w", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, opts, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, opts, nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
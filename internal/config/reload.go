@@ -0,0 +1,337 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Trigger identifies what started a reload.
+type Trigger string
+
+const (
+	TriggerSignal Trigger = "sighup"
+	TriggerFile   Trigger = "file"
+	TriggerAdmin  Trigger = "admin"
+)
+
+// ReloadStatus is the outcome of a reload.
+type ReloadStatus string
+
+const (
+	// ReloadApplied means at least one runtime setting changed.
+	ReloadApplied ReloadStatus = "applied"
+	// ReloadUnchanged means the new configuration is valid but no runtime
+	// setting changed.
+	ReloadUnchanged ReloadStatus = "unchanged"
+	// ReloadRejected means the new configuration failed to load or validate
+	// and the running configuration was kept.
+	ReloadRejected ReloadStatus = "rejected"
+)
+
+// maxReloadHistory caps the number of events kept by a Reloader.
+const maxReloadHistory = 20
+
+// ReloadEvent records the outcome of one reload attempt.
+type ReloadEvent struct {
+	Time    time.Time    `json:"time"`
+	Trigger Trigger      `json:"trigger"`
+	Status  ReloadStatus `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	// Changed lists the runtime settings that were applied.
+	Changed []string `json:"changed,omitempty"`
+	// RestartRequired lists changed settings that only take effect after a
+	// restart. They are ignored until then.
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// Reloader owns the running configuration and replaces its runtime settings
+// when asked to reload. Only currencies and amount limits, risk rules and
+// duplicate detection, rate limits, acquirer weights, the bank timeout, the
+// log level and vault keys are applied at runtime; every other change is
+// reported as requiring a restart.
+type Reloader struct {
+	load func() (*Config, error)
+
+	mu          sync.Mutex
+	current     *Config
+	validators  []func(*Config) error
+	subscribers []func(*Config)
+	observers   []func(ctx context.Context, event ReloadEvent, before, after *Config)
+	history     []ReloadEvent
+}
+
+// NewReloader returns a Reloader starting from current. load must return a
+// freshly loaded and validated configuration, usually by calling Load with
+// the process arguments and environment again.
+func NewReloader(current *Config, load func() (*Config, error)) *Reloader {
+	return &Reloader{load: load, current: current}
+}
+
+// Current returns the running configuration. Callers must not modify it.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// AddValidator registers fn to check a configuration before it is applied,
+// for constraints that depend on runtime state rather than on the file
+// alone. A reload is rejected when any validator fails.
+func (r *Reloader) AddValidator(fn func(*Config) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validators = append(r.validators, fn)
+}
+
+// Subscribe registers fn to be called with the new configuration after every
+// applied reload. Calls are serialised.
+func (r *Reloader) Subscribe(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Observe registers fn to be called after every reload attempt, including
+// rejected ones, with the configuration before and after it. ctx is the one
+// passed to Reload. Calls are serialised.
+func (r *Reloader) Observe(fn func(ctx context.Context, event ReloadEvent, before, after *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observers = append(r.observers, fn)
+}
+
+// History returns past reload events, oldest first.
+func (r *Reloader) History() []ReloadEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ReloadEvent(nil), r.history...)
+}
+
+// Reload loads the configuration again and applies its runtime settings.
+// Nothing is applied unless the whole configuration is valid.
+func (r *Reloader) Reload(ctx context.Context, trigger Trigger) ReloadEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := r.current
+	event := r.reload(trigger)
+	r.history = append(r.history, event)
+	if len(r.history) > maxReloadHistory {
+		r.history = r.history[len(r.history)-maxReloadHistory:]
+	}
+	for _, fn := range r.observers {
+		fn(ctx, event, before, r.current)
+	}
+	return event
+}
+
+// reload does the work of Reload. r.mu must be held.
+func (r *Reloader) reload(trigger Trigger) ReloadEvent {
+	event := ReloadEvent{Time: time.Now().UTC(), Trigger: trigger}
+
+	next, err := r.load()
+	if err != nil {
+		event.Status = ReloadRejected
+		event.Error = err.Error()
+		slog.Error("configuration reload rejected, keeping the running configuration",
+			"trigger", trigger, "error", err)
+		return event
+	}
+
+	applied := r.current.Clone()
+	event.Changed = applyRuntime(applied, next)
+	event.RestartRequired = restartRequired(r.current, next)
+
+	for _, validate := range r.validators {
+		if err := validate(applied); err != nil {
+			event.Status = ReloadRejected
+			event.Error = err.Error()
+			event.Changed = nil
+			slog.Error("configuration reload rejected, keeping the running configuration",
+				"trigger", trigger, "error", err)
+			return event
+		}
+	}
+
+	if len(event.Changed) == 0 {
+		event.Status = ReloadUnchanged
+		slog.Info("configuration reloaded, no runtime changes",
+			"trigger", trigger, "restart_required", event.RestartRequired)
+		return event
+	}
+
+	r.current = applied
+	for _, fn := range r.subscribers {
+		fn(applied)
+	}
+
+	event.Status = ReloadApplied
+	slog.Info("configuration reloaded",
+		"trigger", trigger, "changed", event.Changed, "restart_required", event.RestartRequired)
+	return event
+}
+
+// Watch reloads on every signal received from signals and, when path is set
+// and interval is positive, whenever the file at path changes. It returns
+// when ctx is done.
+func (r *Reloader) Watch(ctx context.Context, path string, interval time.Duration, signals <-chan os.Signal) {
+	var tick <-chan time.Time
+	if path != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	last := stat(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			r.Reload(ctx, TriggerSignal)
+		case <-tick:
+			if current := stat(path); current != last {
+				last = current
+				r.Reload(ctx, TriggerFile)
+			}
+		}
+	}
+}
+
+// fileState is what Watch compares to detect a changed file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(path string) fileState {
+	if path == "" {
+		return fileState{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}
+}
+
+// applyRuntime copies the runtime settings of next into c and returns the
+// file keys of those that changed.
+func applyRuntime(c, next *Config) []string {
+	var changed []string
+	check := func(key string, a, b any) {
+		if differ(a, b) {
+			changed = append(changed, key)
+		}
+	}
+	check("payments.allowed_currencies", c.Payments.AllowedCurrencies, next.Payments.AllowedCurrencies)
+	check("payments.max_amount", c.Payments.MaxAmount, next.Payments.MaxAmount)
+	check("risk.blocked_bins", c.Risk.BlockedBINs, next.Risk.BlockedBINs)
+	check("risk.duplicates.window", c.Risk.Duplicates.Window, next.Risk.Duplicates.Window)
+	check("risk.duplicates.action", c.Risk.Duplicates.Action, next.Risk.Duplicates.Action)
+	check("risk.duplicates.merchants", c.Risk.Duplicates.Merchants, next.Risk.Duplicates.Merchants)
+	check("risk.three_ds.currencies", c.Risk.ThreeDS.Currencies, next.Risk.ThreeDS.Currencies)
+	check("risk.three_ds.exempt_below", c.Risk.ThreeDS.ExemptBelow, next.Risk.ThreeDS.ExemptBelow)
+	check("rate_limit.endpoints", c.RateLimit.Endpoints, next.RateLimit.Endpoints)
+	check("rate_limit.tiers", c.RateLimit.Tiers, next.RateLimit.Tiers)
+	check("rate_limit.merchants", c.RateLimit.Merchants, next.RateLimit.Merchants)
+	check("bank.timeout", c.Bank.Timeout, next.Bank.Timeout)
+	check("log.level", c.Log.Level, next.Log.Level)
+	// Keys can be rotated at runtime, but the vault can only be switched on
+	// or off with a restart.
+	rotateKeys := c.Vault.Enabled() == next.Vault.Enabled()
+	if rotateKeys {
+		check("vault", c.Vault, next.Vault)
+	}
+
+	next = next.Clone()
+	c.Payments = next.Payments
+	c.Risk = next.Risk
+	c.RateLimit = next.RateLimit
+	c.Bank.Timeout = next.Bank.Timeout
+	c.Log.Level = next.Log.Level
+	if rotateKeys {
+		c.Vault = next.Vault
+	}
+
+	// Weights can only be applied to the acquirers already running.
+	if sameAcquirers(c.Bank.Acquirers, next.Bank.Acquirers) {
+		for i, acq := range next.Bank.Acquirers {
+			if c.Bank.Acquirers[i].Weight != acq.Weight {
+				c.Bank.Acquirers[i].Weight = acq.Weight
+				changed = append(changed, fmt.Sprintf("bank.acquirers[%d].weight", i))
+			}
+		}
+	}
+	return changed
+}
+
+// restartRequired returns the file keys of changed settings that cannot be
+// applied at runtime.
+func restartRequired(c, next *Config) []string {
+	var keys []string
+	check := func(key string, a, b any) {
+		if differ(a, b) {
+			keys = append(keys, key)
+		}
+	}
+	check("server", c.Server, next.Server)
+	check("admin", c.Admin, next.Admin)
+	check("api", c.API, next.API)
+	check("async", c.Async, next.Async)
+	check("batches", c.Batches, next.Batches)
+	check("subscriptions", c.Subscriptions, next.Subscriptions)
+	check("three_ds", c.ThreeDS, next.ThreeDS)
+	check("fingerprint", c.Fingerprint, next.Fingerprint)
+	if c.Vault.Enabled() != next.Vault.Enabled() {
+		keys = append(keys, "vault")
+	}
+	check("audit", c.Audit, next.Audit)
+	check("storage", c.Storage, next.Storage)
+	check("tracing", c.Tracing, next.Tracing)
+	check("health", c.Health, next.Health)
+	check("reload", c.Reload, next.Reload)
+	check("bank.max_attempts", c.Bank.MaxAttempts, next.Bank.MaxAttempts)
+	check("bank.attempt_timeout", c.Bank.AttemptTimeout, next.Bank.AttemptTimeout)
+	check("bank.transport", c.Bank.Transport, next.Bank.Transport)
+	check("bank.concurrency", c.Bank.Concurrency, next.Bank.Concurrency)
+	check("bank.hedging", c.Bank.Hedging, next.Bank.Hedging)
+	if !sameAcquirers(c.Bank.Acquirers, next.Bank.Acquirers) {
+		keys = append(keys, "bank.acquirers")
+	}
+	return keys
+}
+
+// differ reports whether a and b hold different values. Empty and nil
+// slices and maps are the same setting.
+func differ(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Slice, reflect.Map:
+		if va.Len() == 0 && vb.Len() == 0 {
+			return false
+		}
+	}
+	return !reflect.DeepEqual(a, b)
+}
+
+// sameAcquirers reports whether a and b list the same acquirers in the same
+// order, ignoring weights. Acquirer TLS and signing files are only read at
+// startup.
+func sameAcquirers(a, b []AcquirerConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].URL != b[i].URL || differ(a[i].DeclineCodes, b[i].DeclineCodes) ||
+			differ(a[i].TLS, b[i].TLS) || differ(a[i].Signing, b[i].Signing) {
+			return false
+		}
+	}
+	return true
+}
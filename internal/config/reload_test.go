@@ -0,0 +1,362 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseConfig = `
+bank:
+  timeout: 2s
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+`
+
+// newReloader writes contents to a temporary config file and returns a
+// Reloader that loads it, together with the file path.
+func newReloader(t *testing.T, contents string) (*config.Reloader, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	load := func() (*config.Config, error) {
+		cfg, _, err := config.Load([]string{"--config", path}, env(nil))
+		return cfg, err
+	}
+	cfg, err := load()
+	require.NoError(t, err)
+	return config.NewReloader(cfg, load), path
+}
+
+func TestReloader_Reload(t *testing.T) {
+	tests := []struct {
+		name                string
+		contents            string
+		wantStatus          config.ReloadStatus
+		wantChanged         []string
+		wantRestartRequired []string
+		wantErr             string
+	}{
+		{
+			name:       "Unchanged",
+			contents:   baseConfig,
+			wantStatus: config.ReloadUnchanged,
+		},
+		{
+			name: "Runtime settings",
+			contents: `
+bank:
+  timeout: 3s
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 0}
+payments:
+  allowed_currencies: [USD, GBP]
+  max_amount: {GBP: 5000}
+risk:
+  blocked_bins: ["400000"]
+  duplicates: {action: reject}
+rate_limit:
+  endpoints: {create_payment: {requests: 10, per: 1s}}
+log:
+  level: debug
+`,
+			wantStatus: config.ReloadApplied,
+			wantChanged: []string{
+				"payments.allowed_currencies",
+				"payments.max_amount",
+				"risk.blocked_bins",
+				"risk.duplicates.action",
+				"rate_limit.endpoints",
+				"bank.timeout",
+				"log.level",
+				"bank.acquirers[1].weight",
+			},
+		},
+		{
+			name: "Restart-only settings are reported, not applied",
+			contents: `
+server:
+  addr: ":9999"
+bank:
+  timeout: 2s
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+`,
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"server", "bank.acquirers"},
+		},
+		{
+			name: "Enabling the vault needs a restart",
+			contents: baseConfig + `
+vault:
+  master_key_file: testdata/master.key
+`,
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"vault"},
+		},
+		{
+			name: "Changing decline codes needs a restart",
+			contents: `
+bank:
+  timeout: 2s
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1, decline_codes: {R01: lost_or_stolen}}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+`,
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"bank.acquirers"},
+		},
+		{
+			name: "Tuning the bank transport needs a restart",
+			contents: `
+bank:
+  timeout: 2s
+  max_attempts: 2
+  transport: {max_conns_per_host: 50}
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+`,
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"bank.max_attempts", "bank.transport"},
+		},
+		{
+			name: "Bounding bank concurrency or hedging needs a restart",
+			contents: `
+bank:
+  timeout: 2s
+  concurrency: {limit: 20, queue_size: 10}
+  hedging: {enabled: true}
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+`,
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"bank.concurrency", "bank.hedging"},
+		},
+		{
+			name: "Signing bank requests needs a restart",
+			contents: `
+bank:
+  timeout: 2s
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1, signing: {method: hmac, key: secret}}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+`,
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"bank.acquirers"},
+		},
+		{
+			name:                "Deprecating an API version needs a restart",
+			contents:            baseConfig + "api:\n  deprecations:\n    unversioned: {since: 2026-01-01T00:00:00Z}\n",
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"api"},
+		},
+		{
+			name:                "Changing the fingerprint key needs a restart",
+			contents:            baseConfig + "fingerprint:\n  key_file: testdata/master.key\n",
+			wantStatus:          config.ReloadUnchanged,
+			wantRestartRequired: []string{"fingerprint"},
+		},
+		{
+			name: "Invalid configuration is rejected",
+			contents: `
+bank:
+  timeout: 3s
+payments:
+  allowed_currencies: [usd]
+`,
+			wantStatus: config.ReloadRejected,
+			wantErr:    "payments.allowed_currencies",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloader, path := newReloader(t, baseConfig)
+			before := reloader.Current()
+
+			var notified *config.Config
+			reloader.Subscribe(func(cfg *config.Config) { notified = cfg })
+
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+			event := reloader.Reload(context.Background(), config.TriggerAdmin)
+
+			assert.Equal(t, tt.wantStatus, event.Status)
+			assert.Equal(t, config.TriggerAdmin, event.Trigger)
+			assert.Equal(t, tt.wantChanged, event.Changed)
+			assert.Equal(t, tt.wantRestartRequired, event.RestartRequired)
+			if tt.wantErr != "" {
+				assert.Contains(t, event.Error, tt.wantErr)
+			}
+			assert.Equal(t, []config.ReloadEvent{event}, reloader.History())
+
+			if tt.wantStatus != config.ReloadApplied {
+				assert.Nil(t, notified, "subscribers are only called when something is applied")
+				assert.Same(t, before, reloader.Current())
+				return
+			}
+			assert.Same(t, notified, reloader.Current())
+			assert.Equal(t, ":8090", notified.Server.Addr)
+		})
+	}
+}
+
+func TestReloader_RestartOnlySettingsAreKept(t *testing.T) {
+	reloader, path := newReloader(t, baseConfig)
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  addr: ":9999"
+bank:
+  timeout: 2s
+  acquirers:
+    - {name: primary, url: "http://bank.internal:8080", weight: 1}
+    - {name: secondary, url: "http://bank2.internal:8080", weight: 1}
+payments:
+  allowed_currencies: [USD]
+log:
+  level: warn
+async:
+  workers: 8
+`), 0o600))
+	event := reloader.Reload(context.Background(), config.TriggerSignal)
+
+	assert.Equal(t, config.ReloadApplied, event.Status)
+	assert.Equal(t, []string{"log.level"}, event.Changed)
+	assert.Equal(t, []string{"server", "async"}, event.RestartRequired)
+	assert.Equal(t, "warn", reloader.Current().Log.Level)
+	assert.Equal(t, ":8090", reloader.Current().Server.Addr)
+	assert.Equal(t, 4, reloader.Current().Async.Workers)
+}
+
+func TestReloader_Watch(t *testing.T) {
+	reloader, path := newReloader(t, baseConfig)
+	applied := make(chan *config.Config, 1)
+	reloader.Subscribe(func(cfg *config.Config) { applied <- cfg })
+
+	signals := make(chan os.Signal, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reloader.Watch(ctx, path, 10*time.Millisecond, signals)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// A signal round-trip guarantees Watch has taken its first snapshot of
+	// the file before it is modified.
+	signals <- os.Interrupt
+	require.Eventually(t, func() bool { return len(reloader.History()) == 1 }, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+"log:\n  level: error\n"), 0o600))
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, "error", cfg.Log.Level)
+	case <-time.After(5 * time.Second):
+		t.Fatal("file change was not picked up")
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+"log:\n  level: warn\n  extra: 1\n"), 0o600))
+	signals <- os.Interrupt
+	require.Eventually(t, func() bool {
+		history := reloader.History()
+		return len(history) >= 3 && history[len(history)-1].Status == config.ReloadRejected
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "error", reloader.Current().Log.Level, "a rejected reload keeps the running configuration")
+}
+
+func TestReloader_RotatesVaultKeys(t *testing.T) {
+	reloader, path := newReloader(t, baseConfig+`
+vault:
+  active_key: v1
+  keys:
+    - {id: v1, file: testdata/master.key}
+`)
+
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+`
+vault:
+  active_key: v2
+  keys:
+    - {id: v1, file: testdata/master.key}
+    - {id: v2, file: testdata/master2.key}
+`), 0o600))
+	event := reloader.Reload(context.Background(), config.TriggerAdmin)
+
+	assert.Equal(t, config.ReloadApplied, event.Status)
+	assert.Equal(t, []string{"vault"}, event.Changed)
+	assert.Empty(t, event.RestartRequired)
+	assert.Equal(t, "v2", reloader.Current().Vault.ActiveKey)
+}
+
+func TestReloader_Validators(t *testing.T) {
+	reloader, path := newReloader(t, baseConfig)
+	reloader.AddValidator(func(cfg *config.Config) error {
+		if cfg.Log.Level == "debug" {
+			return errors.New("debug logging is not allowed")
+		}
+		return nil
+	})
+	var notified bool
+	reloader.Subscribe(func(*config.Config) { notified = true })
+
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+"log:\n  level: debug\n"), 0o600))
+	event := reloader.Reload(context.Background(), config.TriggerAdmin)
+
+	assert.Equal(t, config.ReloadRejected, event.Status)
+	assert.Equal(t, "debug logging is not allowed", event.Error)
+	assert.False(t, notified)
+	assert.Equal(t, "info", reloader.Current().Log.Level)
+}
+
+func TestReloader_Observe(t *testing.T) {
+	reloader, path := newReloader(t, baseConfig)
+
+	type observed struct {
+		event         config.ReloadEvent
+		before, after *config.Config
+	}
+	var calls []observed
+	reloader.Observe(func(ctx context.Context, event config.ReloadEvent, before, after *config.Config) {
+		calls = append(calls, observed{event, before, after})
+	})
+
+	initial := reloader.Current()
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+"log:\n  level: debug\n"), 0o600))
+	reloader.Reload(context.Background(), config.TriggerAdmin)
+	require.NoError(t, os.WriteFile(path, []byte("payments:\n  allowed_currencies: [usd]\n"), 0o600))
+	reloader.Reload(context.Background(), config.TriggerSignal)
+
+	require.Len(t, calls, 2, "observers see rejected reloads too")
+	assert.Equal(t, config.ReloadApplied, calls[0].event.Status)
+	assert.Same(t, initial, calls[0].before)
+	assert.Equal(t, "debug", calls[0].after.Log.Level)
+
+	assert.Equal(t, config.ReloadRejected, calls[1].event.Status)
+	assert.Same(t, calls[1].before, calls[1].after)
+}
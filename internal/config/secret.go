@@ -0,0 +1,28 @@
+package config
+
+// Secret is a string that never prints its value: it masks itself when
+// formatted or marshalled, so configs can be logged and printed safely.
+type Secret string
+
+const maskedSecret = "*****"
+
+// Value returns the secret in clear text.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return maskedSecret
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
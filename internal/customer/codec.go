@@ -0,0 +1,106 @@
+package customer
+
+import (
+	"io"
+	"net/mail"
+	"regexp"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// maxNameLength bounds customer names, in bytes.
+const maxNameLength = 200
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Request is the domain request creating a customer.
+type Request struct {
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// Update is the domain request changing a customer. Nil fields are left
+// unchanged; empty strings clear them.
+type Update struct {
+	Name                   *string `json:"name,omitempty"`
+	Email                  *string `json:"email,omitempty"`
+	Currency               *string `json:"currency,omitempty"`
+	DefaultPaymentMethodID *string `json:"default_payment_method_id,omitempty"`
+}
+
+// PaymentMethodRequest is the domain request saving a payment method.
+type PaymentMethodRequest struct {
+	// CardToken is a token from POST /api/tokens.
+	CardToken string `json:"card_token"`
+	// Default makes the method the customer's default one.
+	Default bool `json:"default,omitempty"`
+}
+
+func (req *Request) validate() error {
+	return validateFields(req.Name, req.Email, req.Currency)
+}
+
+func (u *Update) validate() error {
+	return validateFields(deref(u.Name), deref(u.Email), deref(u.Currency))
+}
+
+// validateFields checks the fields a customer is created or updated with.
+// Empty fields are valid.
+func validateFields(name, email, currency string) error {
+	if len(name) > maxNameLength {
+		return invalid("name", "name must not be longer than 200 characters")
+	}
+	if email != "" {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return invalid("email", "email must be a valid email address")
+		}
+	}
+	if currency != "" && !currencyCode.MatchString(currency) {
+		return invalid("currency", "currency must be a three-letter ISO 4217 code")
+	}
+	return nil
+}
+
+// fields names the fields u changes.
+func (u *Update) fields() []string {
+	fields := []string{}
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{{"name", u.Name}, {"email", u.Email}, {"currency", u.Currency}, {"default_payment_method_id", u.DefaultPaymentMethodID}} {
+		if f.value != nil {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func invalid(field, message string) error {
+	return &payments.ValidationError{Field: field, Message: message}
+}
+
+// Codec translates between the wire models of one API version and
+// customers, so that the handler does not depend on any API version.
+type Codec interface {
+	// DecodeCustomerRequest reads a body creating a customer.
+	DecodeCustomerRequest(r io.Reader) (*Request, error)
+	// DecodeCustomerUpdate reads a body changing a customer.
+	DecodeCustomerUpdate(r io.Reader) (*Update, error)
+	// DecodePaymentMethodRequest reads a body saving a payment method.
+	DecodePaymentMethodRequest(r io.Reader) (*PaymentMethodRequest, error)
+	// EncodeCustomer returns the response model to send for c.
+	EncodeCustomer(c *Customer) any
+	// EncodePaymentMethod returns the response model to send for m.
+	EncodePaymentMethod(m *PaymentMethod) any
+	// EncodeCustomerPayments returns the response model listing the
+	// payments of a customer.
+	EncodeCustomerPayments(p []payments.Payment) any
+}
@@ -0,0 +1,395 @@
+package customer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Limits of GET /customers/{id}/payments.
+const (
+	defaultPaymentsLimit = 20
+	maxPaymentsLimit     = 100
+)
+
+var (
+	errNotFound              = errors.New("customer not found")
+	errPaymentMethodNotFound = errors.New("payment method not found")
+	errPaymentMethodSaved    = errors.New("the card token is already a payment method of the customer")
+)
+
+// PaymentHistory returns the payments of customers.
+// payments.PaymentsRepository implements it.
+type PaymentHistory interface {
+	CustomerPayments(customerID string, limit int) []payments.Payment
+}
+
+type Handler struct {
+	store    *store
+	vault    payments.CardVault
+	history  PaymentHistory
+	auditLog *audit.Log
+}
+
+// NewHandler returns a Handler saving card tokens of vault as payment
+// methods, and listing the payments of customers from history.
+func NewHandler(vault payments.CardVault, history PaymentHistory) *Handler {
+	return &Handler{store: newStore(), vault: vault, history: history}
+}
+
+// SetAuditLog records every created, updated and deleted customer in log.
+func (h *Handler) SetAuditLog(log *audit.Log) {
+	h.auditLog = log
+}
+
+// PaymentMethod implements payments.CustomerDirectory.
+func (h *Handler) PaymentMethod(ctx context.Context, merchant, customerID, methodID string) (*payments.SavedPaymentMethod, error) {
+	c := h.store.get(merchant, customerID)
+	if c == nil {
+		return nil, payments.ErrCustomerNotFound
+	}
+	if methodID == "" {
+		if c.DefaultPaymentMethodID == "" {
+			return nil, payments.ErrNoDefaultPaymentMethod
+		}
+		methodID = c.DefaultPaymentMethodID
+	}
+	m := c.paymentMethod(methodID)
+	if m == nil {
+		return nil, payments.ErrPaymentMethodNotFound
+	}
+	return &payments.SavedPaymentMethod{ID: m.ID, CardToken: m.CardToken, Currency: c.Currency}, nil
+}
+
+// PostHandler returns an http.HandlerFunc that creates a customer without
+// payment methods.
+func (h *Handler) PostHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		codec := wire.From[Codec](ctx)
+
+		req, err := codec.DecodeCustomerRequest(r.Body)
+		if err != nil {
+			logging.FromContext(ctx).InfoContext(ctx, "rejected malformed customer request", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid request body format"))
+			return
+		}
+		if err := req.validate(); err != nil {
+			writeInvalid(w, r, err)
+			return
+		}
+
+		now := time.Now().UTC()
+		c := &Customer{
+			ID:             uuid.New().String(),
+			Merchant:       r.Header.Get(payments.MerchantIDHeader),
+			Name:           req.Name,
+			Email:          req.Email,
+			Currency:       req.Currency,
+			PaymentMethods: []PaymentMethod{},
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		h.store.add(c)
+
+		logging.FromContext(ctx).InfoContext(ctx, "customer created", "customer_id", c.ID)
+		// Names and emails are personal data and are kept out of the audit
+		// log; it records which were given.
+		h.auditLog.Record(ctx, audit.ActionCustomerCreated, "customer:"+c.ID, nil, map[string]any{
+			"fields":   setFields(req.Name, req.Email, req.Currency),
+			"currency": c.Currency,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", r.URL.Path+"/"+c.ID)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(codec.EncodeCustomer(c))
+	}
+}
+
+// GetHandler returns an http.HandlerFunc that returns a customer and its
+// payment methods.
+func (h *Handler) GetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		c := h.store.get(r.Header.Get(payments.MerchantIDHeader), id)
+		if c == nil {
+			writeNotFound(w, r, id)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(wire.From[Codec](r.Context()).EncodeCustomer(c))
+	}
+}
+
+// PatchHandler returns an http.HandlerFunc that changes the fields of a
+// customer sent in the body, and leaves the others unchanged.
+func (h *Handler) PatchHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		codec := wire.From[Codec](ctx)
+		id := chi.URLParam(r, "id")
+
+		u, err := codec.DecodeCustomerUpdate(r.Body)
+		if err != nil {
+			logging.FromContext(ctx).InfoContext(ctx, "rejected malformed customer update", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid request body format"))
+			return
+		}
+		if err := u.validate(); err != nil {
+			writeInvalid(w, r, err)
+			return
+		}
+
+		var before string
+		c, err := h.store.update(r.Header.Get(payments.MerchantIDHeader), id, func(c *Customer) error {
+			before = c.DefaultPaymentMethodID
+			if u.DefaultPaymentMethodID != nil {
+				if *u.DefaultPaymentMethodID != "" && c.paymentMethod(*u.DefaultPaymentMethodID) == nil {
+					return invalid("default_payment_method_id", "default_payment_method_id does not match any payment method of the customer")
+				}
+				c.DefaultPaymentMethodID = *u.DefaultPaymentMethodID
+			}
+			if u.Name != nil {
+				c.Name = *u.Name
+			}
+			if u.Email != nil {
+				c.Email = *u.Email
+			}
+			if u.Currency != nil {
+				c.Currency = *u.Currency
+			}
+			c.UpdatedAt = time.Now().UTC()
+			return nil
+		})
+		if errors.Is(err, errNotFound) {
+			writeNotFound(w, r, id)
+			return
+		}
+		if err != nil {
+			writeInvalid(w, r, err)
+			return
+		}
+
+		logging.FromContext(ctx).InfoContext(ctx, "customer updated", "customer_id", id)
+		h.auditLog.Record(ctx, audit.ActionCustomerUpdated, "customer:"+id,
+			map[string]any{"default_payment_method_id": before},
+			map[string]any{"default_payment_method_id": c.DefaultPaymentMethodID, "fields": u.fields()})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(codec.EncodeCustomer(c))
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that deletes a customer and its
+// payment methods. Its payments are kept, and the card tokens stay in the
+// vault.
+func (h *Handler) DeleteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+		merchant := r.Header.Get(payments.MerchantIDHeader)
+		c := h.store.get(merchant, id)
+		if c == nil || !h.store.delete(merchant, id) {
+			writeNotFound(w, r, id)
+			return
+		}
+
+		logging.FromContext(ctx).InfoContext(ctx, "customer deleted", "customer_id", id)
+		h.auditLog.Record(ctx, audit.ActionCustomerDeleted, "customer:"+id,
+			map[string]any{"payment_methods": len(c.PaymentMethods)}, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PostPaymentMethodHandler returns an http.HandlerFunc that saves a card
+// token as a payment method of a customer. Only tokens the same merchant
+// created are accepted; to any other merchant, a token does not exist.
+func (h *Handler) PostPaymentMethodHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		codec := wire.From[Codec](ctx)
+		id := chi.URLParam(r, "id")
+		merchant := r.Header.Get(payments.MerchantIDHeader)
+
+		req, err := codec.DecodePaymentMethodRequest(r.Body)
+		if err != nil {
+			logger.InfoContext(ctx, "rejected malformed payment method request", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid request body format"))
+			return
+		}
+		if req.CardToken == "" {
+			writeInvalid(w, r, invalid("card_token", "card_token is required"))
+			return
+		}
+		if h.store.get(merchant, id) == nil {
+			writeNotFound(w, r, id)
+			return
+		}
+
+		card, err := h.vault.Detokenize(ctx, req.CardToken)
+		if err == nil && card.Merchant != merchant {
+			err = payments.ErrCardTokenNotFound
+		}
+		if errors.Is(err, payments.ErrCardTokenNotFound) {
+			problem.Write(w, r, problem.New(problem.CodeCardTokenNotFound, "card_token not found"))
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "card token lookup failed", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeInternalError, "Card token could not be resolved"))
+			return
+		}
+
+		m := PaymentMethod{
+			ID:                 "pm_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+			CardToken:          req.CardToken,
+			CardNumberLastFour: card.CardNumber[len(card.CardNumber)-4:],
+			ExpiryMonth:        card.ExpiryMonth,
+			ExpiryYear:         card.ExpiryYear,
+			CreatedAt:          time.Now().UTC(),
+		}
+		var before string
+		c, err := h.store.update(merchant, id, func(c *Customer) error {
+			for _, saved := range c.PaymentMethods {
+				if saved.CardToken == m.CardToken {
+					return fmt.Errorf("%w as %s", errPaymentMethodSaved, saved.ID)
+				}
+			}
+			before = c.DefaultPaymentMethodID
+			c.PaymentMethods = append(c.PaymentMethods, m)
+			if req.Default || c.DefaultPaymentMethodID == "" {
+				c.DefaultPaymentMethodID = m.ID
+			}
+			c.UpdatedAt = m.CreatedAt
+			return nil
+		})
+		switch {
+		case errors.Is(err, errNotFound):
+			writeNotFound(w, r, id)
+			return
+		case errors.Is(err, errPaymentMethodSaved):
+			problem.Write(w, r, problem.New(problem.CodeConflict, err.Error()))
+			return
+		}
+
+		logger.InfoContext(ctx, "payment method saved", "customer_id", id, "payment_method_id", m.ID)
+		h.auditLog.Record(ctx, audit.ActionCustomerUpdated, "customer:"+id,
+			map[string]any{"default_payment_method_id": before},
+			map[string]any{"default_payment_method_id": c.DefaultPaymentMethodID, "payment_method_added": m.ID})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(codec.EncodePaymentMethod(&m))
+	}
+}
+
+// DeletePaymentMethodHandler returns an http.HandlerFunc that removes a
+// payment method from a customer. Removing the default one leaves the
+// customer without a default; the card token stays in the vault.
+func (h *Handler) DeletePaymentMethodHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+		methodID := chi.URLParam(r, "method_id")
+
+		var before string
+		c, err := h.store.update(r.Header.Get(payments.MerchantIDHeader), id, func(c *Customer) error {
+			if c.paymentMethod(methodID) == nil {
+				return errPaymentMethodNotFound
+			}
+			before = c.DefaultPaymentMethodID
+			c.PaymentMethods = slices.DeleteFunc(c.PaymentMethods, func(m PaymentMethod) bool { return m.ID == methodID })
+			if c.DefaultPaymentMethodID == methodID {
+				c.DefaultPaymentMethodID = ""
+			}
+			c.UpdatedAt = time.Now().UTC()
+			return nil
+		})
+		switch {
+		case errors.Is(err, errNotFound):
+			writeNotFound(w, r, id)
+			return
+		case errors.Is(err, errPaymentMethodNotFound):
+			problem.Write(w, r, problem.New(problem.CodePaymentMethodNotFound,
+				fmt.Sprintf("Customer %s has no payment method %s", id, methodID)))
+			return
+		}
+
+		logging.FromContext(ctx).InfoContext(ctx, "payment method removed", "customer_id", id, "payment_method_id", methodID)
+		h.auditLog.Record(ctx, audit.ActionCustomerUpdated, "customer:"+id,
+			map[string]any{"default_payment_method_id": before},
+			map[string]any{"default_payment_method_id": c.DefaultPaymentMethodID, "payment_method_removed": methodID})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PaymentsHandler returns an http.HandlerFunc that lists the payments of a
+// customer, newest first, up to the limit query parameter.
+func (h *Handler) PaymentsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		limit := defaultPaymentsLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxPaymentsLimit {
+				writeInvalid(w, r, invalid("limit", fmt.Sprintf("limit must be between 1 and %d", maxPaymentsLimit)))
+				return
+			}
+			limit = n
+		}
+		if h.store.get(r.Header.Get(payments.MerchantIDHeader), id) == nil {
+			writeNotFound(w, r, id)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(wire.From[Codec](r.Context()).EncodeCustomerPayments(h.history.CustomerPayments(id, limit)))
+	}
+}
+
+func writeNotFound(w http.ResponseWriter, r *http.Request, id string) {
+	problem.Write(w, r, problem.New(problem.CodeCustomerNotFound, "No customer with ID "+id))
+}
+
+// writeInvalid answers with the validation problem of err, a
+// *payments.ValidationError.
+func writeInvalid(w http.ResponseWriter, r *http.Request, err error) {
+	p := problem.New(problem.CodeValidationFailed, err.Error())
+	var invalid *payments.ValidationError
+	if errors.As(err, &invalid) {
+		p = p.WithErrors(problem.FieldError{Field: invalid.Field, Message: invalid.Message})
+	}
+	problem.Write(w, r, p)
+}
+
+// setFields names the fields given a value, in the order name, email,
+// currency.
+func setFields(name, email, currency string) []string {
+	fields := []string{}
+	for _, f := range []struct{ name, value string }{{"name", name}, {"email", email}, {"currency", currency}} {
+		if f.value != "" {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,304 @@
+package customer_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/customer"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withV1 serves next with the codec of API v1, as the router does.
+func withV1(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(wire.With[customer.Codec](r.Context(), v1.Codec{})))
+	})
+}
+
+type fakeVault map[string]payments.VaultCard
+
+func (f fakeVault) Detokenize(ctx context.Context, token string) (*payments.VaultCard, error) {
+	card, ok := f[token]
+	if !ok {
+		return nil, payments.ErrCardTokenNotFound
+	}
+	return &card, nil
+}
+
+// fakeHistory returns its payments for every customer, and records the
+// limits asked for.
+type fakeHistory struct {
+	payments []payments.Payment
+	limits   []int
+}
+
+func (f *fakeHistory) CustomerPayments(customerID string, limit int) []payments.Payment {
+	f.limits = append(f.limits, limit)
+	return f.payments
+}
+
+func newHandler(history *fakeHistory) (*customer.Handler, *chi.Mux) {
+	h := customer.NewHandler(fakeVault{
+		"tok_1":     {CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2099, Merchant: "acme"},
+		"tok_2":     {CardNumber: "2222405343241111", ExpiryMonth: 4, ExpiryYear: 2099, Merchant: "acme"},
+		"tok_hooli": {CardNumber: "2222405343245555", ExpiryMonth: 4, ExpiryYear: 2099, Merchant: "hooli"},
+	}, history)
+	r := chi.NewRouter()
+	r.Use(withV1)
+	r.Post("/api/customers", h.PostHandler())
+	r.Get("/api/customers/{id}", h.GetHandler())
+	r.Patch("/api/customers/{id}", h.PatchHandler())
+	r.Delete("/api/customers/{id}", h.DeleteHandler())
+	r.Post("/api/customers/{id}/payment-methods", h.PostPaymentMethodHandler())
+	r.Delete("/api/customers/{id}/payment-methods/{method_id}", h.DeletePaymentMethodHandler())
+	r.Get("/api/customers/{id}/payments", h.PaymentsHandler())
+	return h, r
+}
+
+func send(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	return sendAs(r, "acme", method, path, body)
+}
+
+func sendAs(r http.Handler, merchant, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(payments.MerchantIDHeader, merchant)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v), w.Body.String())
+	return v
+}
+
+func create(t *testing.T, r http.Handler) v1.Customer {
+	t.Helper()
+	w := send(r, http.MethodPost, "/api/customers", `{"name":"Ada Lovelace","email":"ada@example.com","currency":"USD"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	return decode[v1.Customer](t, w)
+}
+
+func save(t *testing.T, r http.Handler, customerID, body string) v1.PaymentMethod {
+	t.Helper()
+	w := send(r, http.MethodPost, "/api/customers/"+customerID+"/payment-methods", body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	return decode[v1.PaymentMethod](t, w)
+}
+
+func TestHandler_Post(t *testing.T) {
+	_, r := newHandler(&fakeHistory{})
+
+	w := send(r, http.MethodPost, "/api/customers", `{"name":"Ada Lovelace","email":"ada@example.com","currency":"USD"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	c := decode[v1.Customer](t, w)
+	assert.Equal(t, "/api/customers/"+c.Id, w.Header().Get("Location"))
+	assert.Equal(t, "Ada Lovelace", c.Name)
+	assert.Equal(t, "ada@example.com", c.Email)
+	assert.Equal(t, "USD", c.Currency)
+	assert.Empty(t, c.PaymentMethods)
+	assert.Empty(t, c.DefaultPaymentMethodID)
+
+	w = send(r, http.MethodGet, "/api/customers/"+c.Id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, c, decode[v1.Customer](t, w))
+
+	w = send(r, http.MethodGet, "/api/customers/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.CodeCustomerNotFound, decode[problem.Problem](t, w).Code)
+}
+
+func TestHandler_Post_Invalid(t *testing.T) {
+	_, r := newHandler(&fakeHistory{})
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  problem.Code
+		wantField string
+	}{
+		{"Malformed", "{", problem.CodeMalformedRequest, ""},
+		{"Name too long", `{"name":"` + strings.Repeat("a", 201) + `"}`, problem.CodeValidationFailed, "name"},
+		{"Invalid email", `{"email":"ada"}`, problem.CodeValidationFailed, "email"},
+		{"Email with display name", `{"email":"Ada <ada@example.com>"}`, problem.CodeValidationFailed, "email"},
+		{"Invalid currency", `{"currency":"usd"}`, problem.CodeValidationFailed, "currency"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(r, http.MethodPost, "/api/customers", tt.body)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			p := decode[problem.Problem](t, w)
+			assert.Equal(t, tt.wantCode, p.Code)
+			if tt.wantField != "" {
+				require.Len(t, p.Errors, 1)
+				assert.Equal(t, tt.wantField, p.Errors[0].Field)
+			}
+		})
+	}
+}
+
+func TestHandler_Patch(t *testing.T) {
+	_, r := newHandler(&fakeHistory{})
+	c := create(t, r)
+	save(t, r, c.Id, `{"card_token":"tok_1"}`)
+	second := save(t, r, c.Id, `{"card_token":"tok_2"}`)
+
+	w := send(r, http.MethodPatch, "/api/customers/"+c.Id, `{"email":"","default_payment_method_id":"`+second.Id+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	got := decode[v1.Customer](t, w)
+	assert.Equal(t, "Ada Lovelace", got.Name, "fields left out are unchanged")
+	assert.Empty(t, got.Email, "empty fields are cleared")
+	assert.Equal(t, second.Id, got.DefaultPaymentMethodID)
+
+	w = send(r, http.MethodPatch, "/api/customers/"+c.Id, `{"name":"Ada King","default_payment_method_id":"pm_missing"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "default_payment_method_id", decode[problem.Problem](t, w).Errors[0].Field)
+	w = send(r, http.MethodGet, "/api/customers/"+c.Id, "")
+	assert.Equal(t, "Ada Lovelace", decode[v1.Customer](t, w).Name, "rejected updates change nothing")
+
+	w = send(r, http.MethodPatch, "/api/customers/"+c.Id, `{"currency":"dollars"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(r, http.MethodPatch, "/api/customers/missing", `{"name":"Ada King"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_PaymentMethods(t *testing.T) {
+	h, r := newHandler(&fakeHistory{})
+	c := create(t, r)
+
+	first := save(t, r, c.Id, `{"card_token":"tok_1"}`)
+	assert.True(t, strings.HasPrefix(first.Id, "pm_"), first.Id)
+	assert.Equal(t, "tok_1", first.CardToken)
+	assert.Equal(t, "8877", first.CardNumberLastFour)
+	assert.Equal(t, 4, first.ExpiryMonth)
+	assert.Equal(t, 2099, first.ExpiryYear)
+
+	w := send(r, http.MethodGet, "/api/customers/"+c.Id, "")
+	got := decode[v1.Customer](t, w)
+	assert.Equal(t, first.Id, got.DefaultPaymentMethodID, "the first method saved becomes the default")
+	assert.NotContains(t, w.Body.String(), "2222405343248877", "customers never hold card numbers")
+
+	second := save(t, r, c.Id, `{"card_token":"tok_2"}`)
+	saved, err := h.PaymentMethod(context.Background(), "acme", c.Id, "")
+	require.NoError(t, err)
+	assert.Equal(t, &payments.SavedPaymentMethod{ID: first.Id, CardToken: "tok_1", Currency: "USD"}, saved)
+	saved, err = h.PaymentMethod(context.Background(), "acme", c.Id, second.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "tok_2", saved.CardToken)
+
+	w = send(r, http.MethodPost, "/api/customers/"+c.Id+"/payment-methods", `{"card_token":"tok_1"}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "a token is saved once")
+	w = send(r, http.MethodPost, "/api/customers/"+c.Id+"/payment-methods", `{"card_token":"tok_missing"}`)
+	assert.Equal(t, problem.CodeCardTokenNotFound, decode[problem.Problem](t, w).Code)
+	w = send(r, http.MethodPost, "/api/customers/"+c.Id+"/payment-methods", `{"card_token":"tok_hooli"}`)
+	assert.Equal(t, problem.CodeCardTokenNotFound, decode[problem.Problem](t, w).Code, "tokens of another merchant are not found")
+	w = send(r, http.MethodPost, "/api/customers/"+c.Id+"/payment-methods", `{}`)
+	assert.Equal(t, problem.CodeValidationFailed, decode[problem.Problem](t, w).Code)
+	w = send(r, http.MethodPost, "/api/customers/missing/payment-methods", `{"card_token":"tok_1"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = send(r, http.MethodDelete, "/api/customers/"+c.Id+"/payment-methods/"+first.Id, "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	_, err = h.PaymentMethod(context.Background(), "acme", c.Id, "")
+	assert.ErrorIs(t, err, payments.ErrNoDefaultPaymentMethod, "removing the default method leaves no default")
+	_, err = h.PaymentMethod(context.Background(), "acme", c.Id, first.Id)
+	assert.ErrorIs(t, err, payments.ErrPaymentMethodNotFound)
+
+	w = send(r, http.MethodDelete, "/api/customers/"+c.Id+"/payment-methods/"+first.Id, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.CodePaymentMethodNotFound, decode[problem.Problem](t, w).Code)
+
+	third := save(t, r, c.Id, `{"card_token":"tok_1","default":true}`)
+	saved, err = h.PaymentMethod(context.Background(), "acme", c.Id, "")
+	require.NoError(t, err)
+	assert.Equal(t, third.Id, saved.ID, "a method saved as default replaces the default")
+}
+
+func TestHandler_Delete(t *testing.T) {
+	h, r := newHandler(&fakeHistory{})
+	c := create(t, r)
+	save(t, r, c.Id, `{"card_token":"tok_1"}`)
+
+	w := send(r, http.MethodDelete, "/api/customers/"+c.Id, "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = send(r, http.MethodGet, "/api/customers/"+c.Id, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	_, err := h.PaymentMethod(context.Background(), "acme", c.Id, "")
+	assert.ErrorIs(t, err, payments.ErrCustomerNotFound)
+	w = send(r, http.MethodDelete, "/api/customers/"+c.Id, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_Payments(t *testing.T) {
+	history := &fakeHistory{payments: []payments.Payment{{Id: "pay-2"}, {Id: "pay-1"}}}
+	_, r := newHandler(history)
+	c := create(t, r)
+
+	w := send(r, http.MethodGet, "/api/customers/"+c.Id+"/payments", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	got := decode[struct{ Payments []payments.Payment }](t, w)
+	assert.Equal(t, history.payments, got.Payments)
+
+	w = send(r, http.MethodGet, "/api/customers/"+c.Id+"/payments?limit=5", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int{20, 5}, history.limits)
+
+	for _, limit := range []string{"0", "101", "many"} {
+		w = send(r, http.MethodGet, "/api/customers/"+c.Id+"/payments?limit="+limit, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+	}
+	w = send(r, http.MethodGet, "/api/customers/missing/payments", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_OtherMerchant(t *testing.T) {
+	history := &fakeHistory{payments: []payments.Payment{{Id: "pay-1"}}}
+	h, r := newHandler(history)
+	c := create(t, r)
+	m := save(t, r, c.Id, `{"card_token":"tok_1"}`)
+
+	tests := []struct {
+		name, method, path, body string
+	}{
+		{"Get", http.MethodGet, "/api/customers/" + c.Id, ""},
+		{"Patch", http.MethodPatch, "/api/customers/" + c.Id, `{"name":"Mallory"}`},
+		{"Delete", http.MethodDelete, "/api/customers/" + c.Id, ""},
+		{"Save payment method", http.MethodPost, "/api/customers/" + c.Id + "/payment-methods", `{"card_token":"tok_2"}`},
+		{"Remove payment method", http.MethodDelete, "/api/customers/" + c.Id + "/payment-methods/" + m.Id, ""},
+		{"Payments", http.MethodGet, "/api/customers/" + c.Id + "/payments", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendAs(r, "hooli", tt.method, tt.path, tt.body)
+			require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+			assert.Equal(t, problem.CodeCustomerNotFound, decode[problem.Problem](t, w).Code)
+		})
+	}
+
+	t.Run("Payment method", func(t *testing.T) {
+		_, err := h.PaymentMethod(context.Background(), "hooli", c.Id, "")
+		assert.ErrorIs(t, err, payments.ErrCustomerNotFound)
+		_, err = h.PaymentMethod(context.Background(), "hooli", c.Id, m.Id)
+		assert.ErrorIs(t, err, payments.ErrCustomerNotFound)
+	})
+
+	w := send(r, http.MethodGet, "/api/customers/"+c.Id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, c.Name, decode[v1.Customer](t, w).Name, "the customer is unchanged")
+	assert.Len(t, decode[v1.Customer](t, w).PaymentMethods, 1)
+	assert.Empty(t, history.limits, "no payments were listed")
+}
@@ -0,0 +1,51 @@
+// Package customer keeps the customers of merchants and their saved payment
+// methods. Payment methods are card tokens from the vault, never card
+// numbers, and a payment can name a customer instead of a card.
+package customer
+
+import (
+	"slices"
+	"time"
+)
+
+// Customer is a merchant's customer and its saved payment methods.
+type Customer struct {
+	ID       string `json:"id"`
+	Merchant string `json:"merchant,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	// Currency is used by payments of the customer that name none.
+	Currency string `json:"currency,omitempty"`
+	// DefaultPaymentMethodID is the method paid with when a payment names
+	// none. The first method saved becomes the default.
+	DefaultPaymentMethodID string          `json:"default_payment_method_id,omitempty"`
+	PaymentMethods         []PaymentMethod `json:"payment_methods"`
+	CreatedAt              time.Time       `json:"created_at"`
+	UpdatedAt              time.Time       `json:"updated_at"`
+}
+
+// PaymentMethod is a card saved for a customer, held by the vault under
+// CardToken.
+type PaymentMethod struct {
+	ID                 string    `json:"id"`
+	CardToken          string    `json:"card_token"`
+	CardNumberLastFour string    `json:"card_number_last_four"`
+	ExpiryMonth        int       `json:"expiry_month"`
+	ExpiryYear         int       `json:"expiry_year"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// paymentMethod returns the payment method with id, or nil.
+func (c *Customer) paymentMethod(id string) *PaymentMethod {
+	i := slices.IndexFunc(c.PaymentMethods, func(m PaymentMethod) bool { return m.ID == id })
+	if i < 0 {
+		return nil
+	}
+	return &c.PaymentMethods[i]
+}
+
+func (c *Customer) clone() *Customer {
+	clone := *c
+	clone.PaymentMethods = slices.Clone(c.PaymentMethods)
+	return &clone
+}
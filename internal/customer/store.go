@@ -0,0 +1,125 @@
+package customer
+
+type getRequest struct {
+	merchant, id string
+	respChan     chan *Customer
+}
+
+type updateRequest struct {
+	merchant, id string
+	// change edits the stored customer in place, in the monitor goroutine.
+	// The customer is left unchanged when it returns an error.
+	change   func(*Customer) error
+	respChan chan updateResponse
+}
+
+type updateResponse struct {
+	customer *Customer
+	err      error
+}
+
+type deleteRequest struct {
+	merchant, id string
+	respChan     chan bool
+}
+
+// store keeps customers in memory. Like the payments repository it owns its
+// state in a single monitor goroutine, and hands out copies. Customers are
+// only found by the merchant that created them; to any other, they do not
+// exist.
+type store struct {
+	addChan    chan *Customer
+	getChan    chan getRequest
+	updateChan chan updateRequest
+	deleteChan chan deleteRequest
+}
+
+func newStore() *store {
+	s := &store{
+		addChan:    make(chan *Customer),
+		getChan:    make(chan getRequest),
+		updateChan: make(chan updateRequest),
+		deleteChan: make(chan deleteRequest),
+	}
+
+	go s.monitor()
+
+	return s
+}
+
+func (s *store) monitor() {
+	customers := make(map[string]*Customer)
+	find := func(merchant, id string) (*Customer, bool) {
+		c, ok := customers[id]
+		if !ok || c.Merchant != merchant {
+			return nil, false
+		}
+		return c, true
+	}
+
+	for {
+		select {
+		case c := <-s.addChan:
+			customers[c.ID] = c
+
+		case req := <-s.getChan:
+			var found *Customer
+			if c, ok := find(req.merchant, req.id); ok {
+				found = c.clone()
+			}
+			req.respChan <- found
+
+		case req := <-s.updateChan:
+			c, ok := find(req.merchant, req.id)
+			if !ok {
+				req.respChan <- updateResponse{err: errNotFound}
+				continue
+			}
+			changed := c.clone()
+			if err := req.change(changed); err != nil {
+				req.respChan <- updateResponse{err: err}
+				continue
+			}
+			customers[req.id] = changed
+			req.respChan <- updateResponse{customer: changed.clone()}
+
+		case req := <-s.deleteChan:
+			_, ok := find(req.merchant, req.id)
+			if ok {
+				delete(customers, req.id)
+			}
+			req.respChan <- ok
+		}
+	}
+}
+
+// add stores a copy of c.
+func (s *store) add(c *Customer) {
+	s.addChan <- c.clone()
+}
+
+// get returns the customer of merchant with id, or nil.
+func (s *store) get(merchant, id string) *Customer {
+	respChan := make(chan *Customer)
+	s.getChan <- getRequest{merchant: merchant, id: id, respChan: respChan}
+	return <-respChan
+}
+
+// update applies change to the customer of merchant with id and returns the
+// result. Updates are serialized, so change sees the latest customer. It
+// returns errNotFound for unknown customers, and the error of change, if
+// any, without storing anything.
+func (s *store) update(merchant, id string, change func(*Customer) error) (*Customer, error) {
+	respChan := make(chan updateResponse)
+	s.updateChan <- updateRequest{merchant: merchant, id: id, change: change, respChan: respChan}
+	resp := <-respChan
+	return resp.customer, resp.err
+}
+
+// delete removes the customer of merchant with id and reports whether there
+// was one.
+func (s *store) delete(merchant, id string) bool {
+	respChan := make(chan bool)
+	s.deleteChan <- deleteRequest{merchant: merchant, id: id, respChan: respChan}
+	return <-respChan
+}
@@ -0,0 +1,217 @@
+// Package health serves the liveness (/healthz) and readiness (/readyz)
+// endpoints from a set of named dependency checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds a check registered without its own timeout.
+const DefaultTimeout = time.Second
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusDegraded reports a failed informational check, which does not
+	// fail readiness.
+	StatusDegraded = "degraded"
+)
+
+// ErrDraining is reported by the readiness "draining" component once
+// shutdown has started.
+var ErrDraining = errors.New("server is draining")
+
+// Check reports whether a dependency is healthy. It must honour ctx, which
+// carries the check timeout.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name    string
+	timeout time.Duration
+	check   Check
+	// informational checks are reported without failing readiness.
+	informational bool
+}
+
+// ComponentStatus is the result of a single check.
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the body returned by the liveness and readiness handlers.
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Checker holds the registered liveness and readiness checks and the
+// draining flag flipped during graceful shutdown.
+type Checker struct {
+	defaultTimeout time.Duration
+	liveness       []namedCheck
+	readiness      []namedCheck
+	draining       atomic.Bool
+}
+
+// NewChecker returns a Checker whose checks time out after defaultTimeout
+// unless registered with their own timeout. A zero value means DefaultTimeout.
+func NewChecker(defaultTimeout time.Duration) *Checker {
+	if defaultTimeout <= 0 {
+		defaultTimeout = DefaultTimeout
+	}
+	return &Checker{defaultTimeout: defaultTimeout}
+}
+
+// AddLivenessCheck registers a check that must pass for the process to be
+// considered alive. A zero timeout uses the Checker default.
+func (c *Checker) AddLivenessCheck(name string, timeout time.Duration, check Check) {
+	c.liveness = append(c.liveness, namedCheck{name: name, timeout: timeout, check: check})
+}
+
+// AddReadinessCheck registers a check that must pass for the process to
+// receive traffic. A zero timeout uses the Checker default.
+func (c *Checker) AddReadinessCheck(name string, timeout time.Duration, check Check) {
+	c.readiness = append(c.readiness, namedCheck{name: name, timeout: timeout, check: check})
+}
+
+// AddReadinessInfo registers a check reported by readiness that does not fail
+// it: a failure marks the component and the report degraded. It suits
+// upstreams shared by every instance, whose outage taking all of them out of
+// rotation would only make things worse. A zero timeout uses the Checker
+// default.
+func (c *Checker) AddReadinessInfo(name string, timeout time.Duration, check Check) {
+	c.readiness = append(c.readiness, namedCheck{name: name, timeout: timeout, check: check, informational: true})
+}
+
+// SetDraining marks the server as draining, which fails readiness so load
+// balancers stop routing new requests to it.
+func (c *Checker) SetDraining(draining bool) {
+	c.draining.Store(draining)
+}
+
+// Liveness runs the liveness checks.
+func (c *Checker) Liveness(ctx context.Context) Report {
+	return c.run(ctx, c.liveness)
+}
+
+// Readiness runs the readiness checks plus the draining component.
+func (c *Checker) Readiness(ctx context.Context) Report {
+	checks := append([]namedCheck{{name: "draining", check: c.checkDraining}}, c.readiness...)
+	return c.run(ctx, checks)
+}
+
+// LivenessHandler serves Liveness as JSON, with 503 when any check fails.
+//
+//	@Summary	Liveness probe
+//	@Tags		health
+//	@Produce	json
+//	@Success	200	{object}	Report
+//	@Failure	503	{object}	Report
+//	@Router		/healthz [get]
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Liveness(r.Context()))
+	}
+}
+
+// ReadinessHandler serves Readiness as JSON, with 503 when any check other
+// than an informational one fails.
+//
+//	@Summary		Readiness probe
+//	@Description	Fails while the server drains during shutdown, and while no acquirer is reachable according to a background probe.
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	Report
+//	@Failure		503	{object}	Report
+//	@Router			/readyz [get]
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Readiness(r.Context()))
+	}
+}
+
+func (c *Checker) checkDraining(context.Context) error {
+	if c.draining.Load() {
+		return ErrDraining
+	}
+	return nil
+}
+
+func (c *Checker) run(ctx context.Context, checks []namedCheck) Report {
+	report := Report{Status: StatusOK, Components: make(map[string]ComponentStatus, len(checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, nc := range checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			status := c.runOne(ctx, nc)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case status.Status == StatusOK:
+			case nc.informational:
+				status.Status = StatusDegraded
+				if report.Status == StatusOK {
+					report.Status = StatusDegraded
+				}
+			default:
+				report.Status = StatusFail
+			}
+			report.Components[nc.name] = status
+		}(nc)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) runOne(ctx context.Context, nc namedCheck) ComponentStatus {
+	timeout := nc.timeout
+	if timeout <= 0 {
+		timeout = c.defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- nc.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	status := ComponentStatus{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = StatusFail
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	code := http.StatusOK
+	if report.Status == StatusFail {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,146 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func ok(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("connection refused") }
+
+func wedged(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func serve(t *testing.T, h http.HandlerFunc) (int, health.Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/", nil))
+
+	var report health.Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestChecker_Readiness(t *testing.T) {
+	tests := []struct {
+		name           string
+		setup          func(c *health.Checker)
+		expectedStatus int
+		expectedReport string
+		expected       map[string]string
+	}{
+		{
+			name: "All components healthy",
+			setup: func(c *health.Checker) {
+				c.AddReadinessCheck("storage", 0, ok)
+				c.AddReadinessCheck("bank", 0, ok)
+			},
+			expectedStatus: http.StatusOK,
+			expectedReport: health.StatusOK,
+			expected:       map[string]string{"storage": "ok", "bank": "ok", "draining": "ok"},
+		},
+		{
+			name: "Storage unreachable",
+			setup: func(c *health.Checker) {
+				c.AddReadinessCheck("storage", 0, failing)
+				c.AddReadinessCheck("bank", 0, ok)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.StatusFail,
+			expected:       map[string]string{"storage": "fail", "bank": "ok", "draining": "ok"},
+		},
+		{
+			name: "Bank unreachable",
+			setup: func(c *health.Checker) {
+				c.AddReadinessCheck("storage", 0, ok)
+				c.AddReadinessCheck("bank", 0, failing)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.StatusFail,
+			expected:       map[string]string{"storage": "ok", "bank": "fail", "draining": "ok"},
+		},
+		{
+			name: "Informational check failing",
+			setup: func(c *health.Checker) {
+				c.AddReadinessCheck("storage", 0, ok)
+				c.AddReadinessInfo("cache", 0, failing)
+			},
+			expectedStatus: http.StatusOK,
+			expectedReport: health.StatusDegraded,
+			expected:       map[string]string{"storage": "ok", "cache": "degraded", "draining": "ok"},
+		},
+		{
+			name: "Informational check failing while draining",
+			setup: func(c *health.Checker) {
+				c.AddReadinessInfo("cache", 0, failing)
+				c.SetDraining(true)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.StatusFail,
+			expected:       map[string]string{"cache": "degraded", "draining": "fail"},
+		},
+		{
+			name: "Check exceeds its timeout",
+			setup: func(c *health.Checker) {
+				c.AddReadinessCheck("storage", 10*time.Millisecond, wedged)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.StatusFail,
+			expected:       map[string]string{"storage": "fail", "draining": "ok"},
+		},
+		{
+			name: "Draining",
+			setup: func(c *health.Checker) {
+				c.AddReadinessCheck("storage", 0, ok)
+				c.SetDraining(true)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.StatusFail,
+			expected:       map[string]string{"storage": "ok", "draining": "fail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := health.NewChecker(time.Second)
+			tt.setup(c)
+
+			code, report := serve(t, c.ReadinessHandler())
+
+			assert.Equal(t, tt.expectedStatus, code)
+			assert.Equal(t, tt.expectedReport, report.Status)
+			assert.Len(t, report.Components, len(tt.expected))
+			for name, status := range tt.expected {
+				assert.Equal(t, status, report.Components[name].Status, "component %s", name)
+			}
+		})
+	}
+}
+
+func TestChecker_Liveness(t *testing.T) {
+	c := health.NewChecker(20 * time.Millisecond)
+	c.AddLivenessCheck("repository_monitor", 0, ok)
+	c.SetDraining(true)
+
+	code, report := serve(t, c.LivenessHandler())
+	assert.Equal(t, http.StatusOK, code, "draining must not fail liveness")
+	assert.Equal(t, health.StatusOK, report.Status)
+
+	c.AddLivenessCheck("wedged", 0, wedged)
+	start := time.Now()
+	code, report = serve(t, c.LivenessHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["wedged"].Error)
+	assert.Less(t, time.Since(start), time.Second, "default timeout must apply")
+}
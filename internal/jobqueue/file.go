@@ -0,0 +1,301 @@
+package jobqueue
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// Journal operations.
+const (
+	opPush = "push"
+	opAck  = "ack"
+)
+
+// compactAfter is the number of acknowledged jobs after which the journal is
+// rewritten with the pending jobs only.
+const compactAfter = 1000
+
+// record is one line of the journal. Job is the sealed JSON of a
+// payments.Job, authenticated with the payment ID.
+type record struct {
+	Op    string `json:"op"`
+	ID    string `json:"id"`
+	KeyID string `json:"key_id,omitempty"`
+	Job   []byte `json:"job,omitempty"`
+}
+
+// File is a payments.JobQueue journaled to a file, so that jobs not yet
+// acknowledged are recovered after a restart. Jobs hold card data, so they
+// are sealed with a keyring.Ring; only payment IDs are stored in clear.
+type File struct {
+	mem  *Memory
+	size int
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	ring   *keyring.Ring
+	closed bool
+	// pending holds the push records of jobs not acknowledged yet, by
+	// payment ID, with their position in the journal.
+	pending   map[string]pendingRecord
+	seq       uint64
+	acked     int
+	recovered []payments.Job
+}
+
+type pendingRecord struct {
+	seq uint64
+	rec record
+}
+
+// OpenFile returns a queue holding up to size jobs, journaled to the file at
+// path. Jobs left in an existing journal are recovered; they must have been
+// sealed with a key of ring.
+func OpenFile(path string, size int, ring *keyring.Ring) (*File, error) {
+	records, err := readJournal(path)
+	if err != nil {
+		return nil, fmt.Errorf("payment queue %s: %w", path, err)
+	}
+
+	q := &File{
+		size:    size,
+		path:    path,
+		ring:    ring,
+		pending: make(map[string]pendingRecord, len(records)),
+	}
+	for _, rec := range records {
+		plaintext, err := ring.Open(rec.KeyID, rec.Job, []byte(rec.ID))
+		if err != nil {
+			return nil, fmt.Errorf("payment queue %s: job %s: %w", path, rec.ID, err)
+		}
+		var job payments.Job
+		if err := json.Unmarshal(plaintext, &job); err != nil {
+			return nil, fmt.Errorf("payment queue %s: job %s: %w", path, rec.ID, err)
+		}
+		q.recovered = append(q.recovered, job)
+		q.seq++
+		q.pending[rec.ID] = pendingRecord{seq: q.seq, rec: rec}
+	}
+
+	// Recovered jobs may exceed size; they are queued all the same.
+	q.mem = NewMemory(size + len(q.recovered))
+	for _, job := range q.recovered {
+		_ = q.mem.Push(job)
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// readJournal returns the push records of the journal at path that were not
+// acknowledged, in order. A missing journal is empty, and a truncated last
+// line, left by a crash while writing it, is ignored.
+func readJournal(path string) ([]record, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch rec.Op {
+		case opPush:
+			records = append(records, rec)
+		case opAck:
+			records = slices.DeleteFunc(records, func(r record) bool { return r.ID == rec.ID })
+		default:
+			return nil, fmt.Errorf("line %d: unknown operation %q", i+1, rec.Op)
+		}
+	}
+	return records, nil
+}
+
+// SetKeyRing makes the queue seal new jobs with the active key of ring.
+// Queued jobs stay sealed with the key they were pushed with, so it must
+// remain in ring until they are processed.
+func (q *File) SetKeyRing(ring *keyring.Ring) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ring = ring
+}
+
+// CheckKeyRing reports whether ring holds every key sealing a queued job.
+func (q *File) CheckKeyRing(ring *keyring.Ring) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, p := range q.pending {
+		if !ring.Has(p.rec.KeyID) {
+			return fmt.Errorf("vault key %q still seals queued payments; wait for the queue to drain before removing it", p.rec.KeyID)
+		}
+	}
+	return nil
+}
+
+func (q *File) Push(job payments.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return payments.ErrQueueClosed
+	}
+	if q.mem.Len() >= q.size {
+		return payments.ErrQueueFull
+	}
+
+	plaintext, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	keyID, sealed, err := q.ring.Seal(plaintext, []byte(job.Payment.Id))
+	if err != nil {
+		return err
+	}
+	rec := record{Op: opPush, ID: job.Payment.Id, KeyID: keyID, Job: sealed}
+	if err := q.write(rec); err != nil {
+		return err
+	}
+	q.seq++
+	q.pending[rec.ID] = pendingRecord{seq: q.seq, rec: rec}
+	return q.mem.Push(job)
+}
+
+func (q *File) Pop(ctx context.Context) (payments.Job, error) {
+	return q.mem.Pop(ctx)
+}
+
+func (q *File) Ack(paymentID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.write(record{Op: opAck, ID: paymentID}); err != nil {
+		return err
+	}
+	delete(q.pending, paymentID)
+	q.acked++
+	if q.acked >= compactAfter {
+		return q.compact()
+	}
+	return nil
+}
+
+func (q *File) Recovered() []payments.Job {
+	return slices.Clone(q.recovered)
+}
+
+func (q *File) Len() int {
+	return q.mem.Len()
+}
+
+func (q *File) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.mem.Close()
+}
+
+// CloseJournal closes the journal file. Call it once the workers have
+// stopped acknowledging jobs.
+func (q *File) CloseJournal() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
+
+// write appends rec to the journal and syncs it to disk, so that a job is
+// neither lost nor processed twice after a crash.
+func (q *File) write(rec record) error {
+	if q.file == nil {
+		return errors.New("payment queue journal is closed")
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := q.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write payment queue journal: %w", err)
+	}
+	return q.file.Sync()
+}
+
+// compact replaces the journal with the push records of the pending jobs.
+func (q *File) compact() error {
+	pending := make([]pendingRecord, 0, len(q.pending))
+	for _, p := range q.pending {
+		pending = append(pending, p)
+	}
+	slices.SortFunc(pending, func(a, b pendingRecord) int { return cmp.Compare(a.seq, b.seq) })
+
+	var buf bytes.Buffer
+	for _, p := range pending {
+		line, err := json.Marshal(p.rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp := q.path + ".tmp"
+	if err := writeSynced(tmp, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compact payment queue journal: %w", err)
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("failed to compact payment queue journal: %w", err)
+	}
+	if q.file != nil {
+		q.file.Close()
+	}
+	file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		q.file = nil
+		return fmt.Errorf("failed to open payment queue journal: %w", err)
+	}
+	q.file = file
+	q.acked = 0
+	return nil
+}
+
+// writeSynced writes data to a new file at path and syncs it to disk.
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,114 @@
+package jobqueue_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/jobqueue"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ring(t *testing.T, active string, ids ...string) *keyring.Ring {
+	t.Helper()
+	keys := make([]keyring.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = keyring.Key{ID: id, Material: bytes.Repeat([]byte{byte(i + 1)}, keyring.KeySize)}
+	}
+	r, err := keyring.New(active, keys...)
+	require.NoError(t, err)
+	return r
+}
+
+func TestFile_RecoversPendingJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	v1 := ring(t, "v1", "v1")
+
+	q, err := jobqueue.OpenFile(path, 10, v1)
+	require.NoError(t, err)
+	assert.Empty(t, q.Recovered())
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(job(id)))
+	}
+	popped, err := q.Pop(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, q.Ack(popped.Payment.Id))
+	// b is popped but not acknowledged, as when the process dies while
+	// authorizing it.
+	_, err = q.Pop(context.Background())
+	require.NoError(t, err)
+	q.Close()
+	require.NoError(t, q.CloseJournal())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "2222405343248877", "card numbers are sealed")
+	assert.NotContains(t, string(data), `"123"`, "CVVs are sealed")
+
+	q, err = jobqueue.OpenFile(path, 10, v1)
+	require.NoError(t, err)
+	defer q.CloseJournal()
+	recovered := q.Recovered()
+	require.Len(t, recovered, 2)
+	assert.Equal(t, job("b"), recovered[0])
+	assert.Equal(t, "c", recovered[1].Payment.Id)
+	assert.Equal(t, 2, q.Len())
+
+	popped, err = q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "b", popped.Payment.Id)
+}
+
+func TestFile_Full(t *testing.T) {
+	q, err := jobqueue.OpenFile(filepath.Join(t.TempDir(), "queue.jsonl"), 1, ring(t, "v1", "v1"))
+	require.NoError(t, err)
+	defer q.CloseJournal()
+
+	require.NoError(t, q.Push(job("a")))
+	assert.ErrorIs(t, q.Push(job("b")), payments.ErrQueueFull)
+	q.Close()
+	assert.ErrorIs(t, q.Push(job("b")), payments.ErrQueueClosed)
+}
+
+func TestFile_KeyRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	q, err := jobqueue.OpenFile(path, 10, ring(t, "v1", "v1"))
+	require.NoError(t, err)
+	require.NoError(t, q.Push(job("a")))
+
+	q.SetKeyRing(ring(t, "v2", "v1", "v2"))
+	require.NoError(t, q.Push(job("b")))
+	assert.NoError(t, q.CheckKeyRing(ring(t, "v2", "v1", "v2")))
+	assert.EqualError(t, q.CheckKeyRing(ring(t, "v2", "v2")),
+		`vault key "v1" still seals queued payments; wait for the queue to drain before removing it`)
+	require.NoError(t, q.CloseJournal())
+
+	_, err = jobqueue.OpenFile(path, 10, ring(t, "v2", "v2"))
+	assert.ErrorContains(t, err, "job a")
+}
+
+func TestFile_IgnoresTruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	v1 := ring(t, "v1", "v1")
+	q, err := jobqueue.OpenFile(path, 10, v1)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(job("a")))
+	require.NoError(t, q.CloseJournal())
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"push","id":"b","key_`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q, err = jobqueue.OpenFile(path, 10, v1)
+	require.NoError(t, err)
+	defer q.CloseJournal()
+	require.Len(t, q.Recovered(), 1)
+	assert.Equal(t, "a", q.Recovered()[0].Payment.Id)
+}
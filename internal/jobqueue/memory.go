@@ -0,0 +1,68 @@
+// Package jobqueue holds payments accepted for asynchronous processing until
+// the payments workers authorize them: in memory, or in an encrypted journal
+// file that survives restarts.
+package jobqueue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// Memory is a bounded FIFO payments.JobQueue. Its jobs are lost on restart.
+type Memory struct {
+	jobs chan payments.Job
+
+	// mu guards closed so that Push never sends on a closed channel.
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewMemory returns a queue holding up to size jobs.
+func NewMemory(size int) *Memory {
+	return &Memory{jobs: make(chan payments.Job, size)}
+}
+
+func (q *Memory) Push(job payments.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return payments.ErrQueueClosed
+	}
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return payments.ErrQueueFull
+	}
+}
+
+func (q *Memory) Pop(ctx context.Context) (payments.Job, error) {
+	select {
+	case job, ok := <-q.jobs:
+		if !ok {
+			return payments.Job{}, payments.ErrQueueClosed
+		}
+		return job, nil
+	case <-ctx.Done():
+		return payments.Job{}, ctx.Err()
+	}
+}
+
+// Ack does nothing: popped jobs are already gone.
+func (q *Memory) Ack(string) error { return nil }
+
+// Recovered returns nil: nothing survives a restart.
+func (q *Memory) Recovered() []payments.Job { return nil }
+
+func (q *Memory) Len() int { return len(q.jobs) }
+
+func (q *Memory) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+}
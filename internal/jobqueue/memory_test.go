@@ -0,0 +1,51 @@
+package jobqueue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/jobqueue"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func job(id string) payments.Job {
+	return payments.Job{
+		Payment: payments.Payment{Id: id, PaymentStatus: "Pending", Currency: "GBP", Amount: 100},
+		Request: payments.PostPaymentRequest{
+			CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030,
+			Currency: "GBP", Amount: 100, Cvv: "123", Reference: id,
+		},
+		Merchant: "acme",
+	}
+}
+
+func TestMemory(t *testing.T) {
+	q := jobqueue.NewMemory(2)
+	require.NoError(t, q.Push(job("a")))
+	require.NoError(t, q.Push(job("b")))
+	assert.ErrorIs(t, q.Push(job("c")), payments.ErrQueueFull)
+	assert.Equal(t, 2, q.Len())
+
+	got, err := q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Payment.Id, "jobs are popped in order")
+
+	q.Close()
+	assert.ErrorIs(t, q.Push(job("c")), payments.ErrQueueClosed)
+	got, err = q.Pop(context.Background())
+	require.NoError(t, err, "jobs left are still popped once closed")
+	assert.Equal(t, "b", got.Payment.Id)
+	_, err = q.Pop(context.Background())
+	assert.ErrorIs(t, err, payments.ErrQueueClosed)
+}
+
+func TestMemory_PopWaits(t *testing.T) {
+	q := jobqueue.NewMemory(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := q.Pop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
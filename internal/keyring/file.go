@@ -0,0 +1,35 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DecodeKey decodes base64 key material, ignoring surrounding whitespace.
+func DecodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.New("key is not valid base64")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must decode to %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadKeyFile reads base64 key material from path, as written by
+// `openssl rand -base64 32 > path`.
+func LoadKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := DecodeKey(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,221 @@
+package keyring
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Rotatable is a set of records encrypted under a Ring that can be moved to
+// the ring's active key one record at a time.
+type Rotatable interface {
+	// Stale returns, in ascending order, up to limit record IDs greater than
+	// after whose records are not encrypted under the active key.
+	Stale(ctx context.Context, after string, limit int) ([]string, error)
+	// Rotate re-encrypts the record with the given ID under the active key.
+	Rotate(ctx context.Context, id string) error
+}
+
+// ErrJobRunning is returned by Start while a run is in progress.
+var ErrJobRunning = errors.New("re-encryption job is already running")
+
+// JobState is the lifecycle state of a Job.
+type JobState string
+
+const (
+	JobIdle      JobState = "idle"
+	JobRunning   JobState = "running"
+	JobStopped   JobState = "stopped"
+	JobCompleted JobState = "completed"
+	JobFailed    JobState = "failed"
+)
+
+// DefaultBatchSize is the number of records a Job asks for at a time.
+const DefaultBatchSize = 100
+
+// Progress reports what a Job has done so far.
+type Progress struct {
+	State JobState `json:"state"`
+	// Rotated and Failed count records since the run started, including
+	// runs it resumed.
+	Rotated int `json:"rotated"`
+	Failed  int `json:"failed"`
+	// Cursor is the last record ID processed. A stopped or failed run
+	// resumes after it.
+	Cursor     string     `json:"cursor,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Job re-encrypts the records of a Rotatable under the active key, in
+// batches and in ID order. It keeps a cursor so that a stopped or failed
+// run resumes where it left off instead of starting over. Records that fail
+// to rotate are counted and skipped; the next full run retries them.
+type Job struct {
+	name      string
+	target    Rotatable
+	batchSize int
+
+	mu       sync.Mutex
+	progress Progress
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewJob returns an idle Job over target. name is used in logs.
+func NewJob(name string, target Rotatable, batchSize int) *Job {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Job{
+		name:      name,
+		target:    target,
+		batchSize: batchSize,
+		progress:  Progress{State: JobIdle},
+	}
+}
+
+// Progress returns a snapshot of the job's progress.
+func (j *Job) Progress() Progress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+// Start runs the job in the background. A stopped or failed run is resumed
+// from its cursor; otherwise a new run starts from the first record.
+func (j *Job) Start(ctx context.Context) error {
+	ctx, err := j.begin(ctx)
+	if err != nil {
+		return err
+	}
+	go j.run(ctx)
+	return nil
+}
+
+// Run is like Start but blocks until the run ends.
+func (j *Job) Run(ctx context.Context) (Progress, error) {
+	ctx, err := j.begin(ctx)
+	if err != nil {
+		return j.Progress(), err
+	}
+	j.run(ctx)
+	p := j.Progress()
+	if p.State == JobFailed {
+		return p, errors.New(p.LastError)
+	}
+	return p, nil
+}
+
+// Stop cancels a running job and waits for it to stop. The cursor is kept
+// so the next Start resumes.
+func (j *Job) Stop() {
+	j.mu.Lock()
+	cancel, done := j.cancel, j.done
+	j.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (j *Job) begin(ctx context.Context) (context.Context, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.progress.State == JobRunning {
+		return nil, ErrJobRunning
+	}
+
+	resume := j.progress.State == JobStopped || j.progress.State == JobFailed
+	if !resume {
+		now := time.Now().UTC()
+		j.progress = Progress{StartedAt: &now}
+	}
+	j.progress.State = JobRunning
+	j.progress.FinishedAt = nil
+	j.progress.LastError = ""
+
+	ctx, j.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	j.done = make(chan struct{})
+
+	slog.Info("re-encryption job started", "job", j.name, "resume", resume, "cursor", j.progress.Cursor)
+	return ctx, nil
+}
+
+func (j *Job) run(ctx context.Context) {
+	defer func() {
+		j.mu.Lock()
+		j.cancel()
+		close(j.done)
+		j.cancel, j.done = nil, nil
+		j.mu.Unlock()
+	}()
+
+	for {
+		if ctx.Err() != nil {
+			j.finish(JobStopped, nil)
+			return
+		}
+
+		ids, err := j.target.Stale(ctx, j.Progress().Cursor, j.batchSize)
+		if err != nil {
+			j.finish(JobFailed, err)
+			return
+		}
+		if len(ids) == 0 {
+			j.finish(JobCompleted, nil)
+			return
+		}
+
+		for _, id := range ids {
+			err := j.target.Rotate(ctx, id)
+			if ctx.Err() != nil {
+				// Interrupted by Stop: leave the cursor before this record so
+				// the resumed run tries it again.
+				break
+			}
+
+			j.mu.Lock()
+			j.progress.Cursor = id
+			if err != nil {
+				j.progress.Failed++
+				j.progress.LastError = err.Error()
+			} else {
+				j.progress.Rotated++
+			}
+			j.mu.Unlock()
+
+			if err != nil {
+				slog.Warn("failed to re-encrypt record", "job", j.name, "id", id, "error", err)
+			}
+		}
+
+		p := j.Progress()
+		slog.Info("re-encryption progress", "job", j.name, "rotated", p.Rotated, "failed", p.Failed, "cursor", p.Cursor)
+	}
+}
+
+func (j *Job) finish(state JobState, err error) {
+	j.mu.Lock()
+	now := time.Now().UTC()
+	j.progress.State = state
+	j.progress.FinishedAt = &now
+	if err != nil {
+		j.progress.LastError = err.Error()
+	}
+	if state == JobCompleted {
+		// The next run starts over so it retries records that failed.
+		j.progress.Cursor = ""
+	}
+	p := j.progress
+	j.mu.Unlock()
+
+	slog.Info("re-encryption job finished", "job", j.name, "state", p.State,
+		"rotated", p.Rotated, "failed", p.Failed, "error", p.LastError)
+}
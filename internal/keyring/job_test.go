@@ -0,0 +1,133 @@
+package keyring_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRecords is a Rotatable holding the key ID of each record.
+type fakeRecords struct {
+	mu      sync.Mutex
+	keys    map[string]string
+	active  string
+	failing map[string]bool
+	// block, when set, is received from before each rotation.
+	block chan struct{}
+}
+
+func newFakeRecords(n int, keyID string) *fakeRecords {
+	f := &fakeRecords{keys: make(map[string]string), active: keyID, failing: make(map[string]bool)}
+	for i := 0; i < n; i++ {
+		f.keys[fmt.Sprintf("rec-%03d", i)] = keyID
+	}
+	return f
+}
+
+func (f *fakeRecords) Stale(ctx context.Context, after string, limit int) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for id, k := range f.keys {
+		if id > after && k != f.active {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (f *fakeRecords) Rotate(ctx context.Context, id string) error {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[id] {
+		return errors.New("boom")
+	}
+	f.keys[id] = f.active
+	return nil
+}
+
+func (f *fakeRecords) usage() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	usage := make(map[string]int)
+	for _, k := range f.keys {
+		usage[k]++
+	}
+	return usage
+}
+
+func TestJob_Run(t *testing.T) {
+	records := newFakeRecords(25, "v1")
+	records.active = "v2"
+	records.failing["rec-007"] = true
+
+	job := keyring.NewJob("test", records, 10)
+	assert.Equal(t, keyring.JobIdle, job.Progress().State)
+
+	progress, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, keyring.JobCompleted, progress.State)
+	assert.Equal(t, 24, progress.Rotated)
+	assert.Equal(t, 1, progress.Failed)
+	assert.Equal(t, "boom", progress.LastError)
+	assert.NotNil(t, progress.StartedAt)
+	assert.NotNil(t, progress.FinishedAt)
+	assert.Equal(t, map[string]int{"v1": 1, "v2": 24}, records.usage())
+
+	// A new run starts over and retries the record that failed.
+	delete(records.failing, "rec-007")
+	progress, err = job.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, progress.Rotated)
+	assert.Equal(t, 0, progress.Failed)
+	assert.Equal(t, map[string]int{"v2": 25}, records.usage())
+}
+
+func TestJob_StopAndResume(t *testing.T) {
+	records := newFakeRecords(10, "v1")
+	records.active = "v2"
+	records.block = make(chan struct{})
+
+	job := keyring.NewJob("test", records, 3)
+	require.NoError(t, job.Start(context.Background()))
+	assert.ErrorIs(t, job.Start(context.Background()), keyring.ErrJobRunning)
+
+	// Let four records through, then stop.
+	for i := 0; i < 4; i++ {
+		records.block <- struct{}{}
+	}
+	require.Eventually(t, func() bool { return job.Progress().Rotated == 4 }, time.Second, time.Millisecond)
+	job.Stop()
+
+	progress := job.Progress()
+	assert.Equal(t, keyring.JobStopped, progress.State)
+	assert.Equal(t, "rec-003", progress.Cursor, "the record interrupted by Stop is retried on resume")
+	assert.Equal(t, 0, progress.Failed)
+
+	// Resuming continues after the cursor instead of starting over.
+	records.block = nil
+	progress, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, keyring.JobCompleted, progress.State)
+	assert.Equal(t, 10, progress.Rotated)
+	assert.Equal(t, map[string]int{"v2": 10}, records.usage())
+}
@@ -0,0 +1,121 @@
+// Package keyring holds versioned encryption keys. Data is always encrypted
+// with the active key and can be decrypted with any key still in the ring,
+// so keys can be rotated without downtime: add a new key, make it active,
+// re-encrypt existing records with a Job, then retire the old key.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the length in bytes of every key (AES-256).
+const KeySize = 32
+
+// ErrUnknownKey is returned when data was encrypted with a key that is no
+// longer in the ring.
+var ErrUnknownKey = errors.New("unknown key")
+
+// Key is one version of key material.
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+// Ring is an immutable set of keys with one active key. Build a new Ring to
+// change keys.
+type Ring struct {
+	active string
+	ids    []string
+	aeads  map[string]cipher.AEAD
+}
+
+// New returns a Ring holding keys that encrypts with the key named active.
+func New(active string, keys ...Key) (*Ring, error) {
+	r := &Ring{active: active, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, errors.New("key ID must not be empty")
+		}
+		if _, dup := r.aeads[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key %q", k.ID)
+		}
+		aead, err := NewAEAD(k.Material)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		r.aeads[k.ID] = aead
+		r.ids = append(r.ids, k.ID)
+	}
+	if _, ok := r.aeads[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the ring", active)
+	}
+	return r, nil
+}
+
+// ActiveID returns the ID of the key used for encryption.
+func (r *Ring) ActiveID() string {
+	return r.active
+}
+
+// IDs returns the IDs of every key in the ring, in the order given to New.
+func (r *Ring) IDs() []string {
+	return append([]string(nil), r.ids...)
+}
+
+// Has reports whether the ring holds the key named id.
+func (r *Ring) Has(id string) bool {
+	_, ok := r.aeads[id]
+	return ok
+}
+
+// Seal encrypts plaintext with the active key and returns that key's ID
+// together with the nonce-prefixed ciphertext. aad is authenticated but not
+// encrypted.
+func (r *Ring) Seal(plaintext, aad []byte) (keyID string, sealed []byte, err error) {
+	sealed, err = Seal(r.aeads[r.active], plaintext, aad)
+	return r.active, sealed, err
+}
+
+// Open decrypts data sealed with the key named keyID.
+func (r *Ring) Open(keyID string, sealed, aad []byte) ([]byte, error) {
+	aead, ok := r.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return Open(aead, sealed, aad)
+}
+
+// NewAEAD returns AES-256-GCM for key.
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext with aead under a random nonce, which is prepended
+// to the result.
+func Seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open reverses Seal.
+func Open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
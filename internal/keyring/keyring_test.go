@@ -0,0 +1,85 @@
+package keyring_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func key(id string, b byte) keyring.Key {
+	return keyring.Key{ID: id, Material: bytes.Repeat([]byte{b}, keyring.KeySize)}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  string
+		keys    []keyring.Key
+		wantErr string
+	}{
+		{name: "Active key missing", active: "v2", keys: []keyring.Key{key("v1", 1)}, wantErr: `active key "v2" is not in the ring`},
+		{name: "Duplicate key", active: "v1", keys: []keyring.Key{key("v1", 1), key("v1", 2)}, wantErr: `duplicate key "v1"`},
+		{name: "Empty ID", active: "", keys: []keyring.Key{key("", 1)}, wantErr: "key ID must not be empty"},
+		{name: "Short key", active: "v1", keys: []keyring.Key{{ID: "v1", Material: []byte("short")}}, wantErr: "key must be 32 bytes, got 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := keyring.New(tt.active, tt.keys...)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRing_SealOpen(t *testing.T) {
+	old, err := keyring.New("v1", key("v1", 1))
+	require.NoError(t, err)
+	rotated, err := keyring.New("v2", key("v1", 1), key("v2", 2))
+	require.NoError(t, err)
+
+	oldID, oldSealed, err := old.Seal([]byte("secret"), []byte("aad"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", oldID)
+
+	newID, newSealed, err := rotated.Seal([]byte("secret"), []byte("aad"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", newID)
+	assert.Equal(t, []string{"v1", "v2"}, rotated.IDs())
+
+	// The rotated ring still decrypts data sealed with the retired key.
+	plaintext, err := rotated.Open(oldID, oldSealed, []byte("aad"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	_, err = old.Open(newID, newSealed, []byte("aad"))
+	assert.ErrorIs(t, err, keyring.ErrUnknownKey)
+
+	_, err = rotated.Open(oldID, oldSealed, []byte("other aad"))
+	assert.Error(t, err)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.key")
+	require.NoError(t, os.WriteFile(valid, []byte("AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=\n"), 0o600))
+	short := filepath.Join(dir, "short.key")
+	require.NoError(t, os.WriteFile(short, []byte("c2hvcnQ="), 0o600))
+	garbage := filepath.Join(dir, "garbage.key")
+	require.NoError(t, os.WriteFile(garbage, []byte("not base64!"), 0o600))
+
+	material, err := keyring.LoadKeyFile(valid)
+	require.NoError(t, err)
+	assert.Len(t, material, keyring.KeySize)
+
+	_, err = keyring.LoadKeyFile(short)
+	assert.ErrorContains(t, err, "key must decode to 32 bytes, got 5")
+	_, err = keyring.LoadKeyFile(garbage)
+	assert.ErrorContains(t, err, "key is not valid base64")
+	_, err = keyring.LoadKeyFile(filepath.Join(dir, "missing.key"))
+	assert.ErrorContains(t, err, "failed to read key file")
+}
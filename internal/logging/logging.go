@@ -0,0 +1,31 @@
+// Package logging builds the structured JSON logger used across the gateway and
+// carries request-scoped loggers through a context.Context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+type ctxKey struct{}
+
+// New returns a JSON logger writing to w. Every record goes through the
+// redacting handler, so card data never reaches the output.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewRedactingHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, falling back to
+// slog.Default when there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,257 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// panPattern matches runs of 13 or more digits, optionally grouped with
+	// runs of spaces or dashes ("4111 1111 1111 1111"). Runs are not bounded
+	// by word boundaries, so PANs next to letters or underscores
+	// ("card_4111111111111111") match. maskPAN masks only the card numbers
+	// among them.
+	panPattern = regexp.MustCompile(`\d(?:[ -]*\d){12,}`)
+
+	// separators matches the separators between the digit groups of a run.
+	separators = regexp.MustCompile(`[ -]+`)
+
+	// cvvPattern matches a CVV/CVC value next to its key, as found in JSON
+	// bodies (`"cvv":"123"`), query strings (`cvv=123`) and Go maps (`cvv:123`).
+	cvvPattern = regexp.MustCompile(`(?i)("?\b(?:cvv2?|cvc2?|security_code)"?\s*[:=]\s*"?)\d{3,4}\b`)
+
+	// sensitiveKeys are attribute keys whose values are dropped entirely,
+	// compared after lower-casing and removing '_' and '-'.
+	sensitiveKeys = map[string]bool{
+		"cvv":          true,
+		"cvv2":         true,
+		"cvc":          true,
+		"cvc2":         true,
+		"securitycode": true,
+		"cardnumber":   true,
+		"pan":          true,
+	}
+)
+
+// Redact masks anything in s that resembles a PAN or a CVV. PANs keep their
+// last four digits so that log lines can still be correlated with payments.
+func Redact(s string) string {
+	s = panPattern.ReplaceAllStringFunc(s, maskPAN)
+	return cvvPattern.ReplaceAllString(s, "${1}***")
+}
+
+// maskPAN masks the card numbers in a run matched by panPattern: one or
+// more consecutive digit groups holding 13 to 19 digits that pass the Luhn
+// check. Other numbers, such as timestamps, amounts and longer IDs, are
+// left as they are.
+func maskPAN(match string) string {
+	groups := separators.Split(match, -1)
+	seps := separators.FindAllString(match, -1)
+
+	var b strings.Builder
+	for i := 0; i < len(groups); {
+		end := i + 1
+		if n := panGroups(groups[i:]); n > 0 {
+			end = i + n
+			digits := strings.Join(groups[i:end], "")
+			b.WriteString(strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:])
+		} else {
+			b.WriteString(groups[i])
+		}
+		if end-1 < len(seps) {
+			b.WriteString(seps[end-1])
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// panGroups returns how many of the leading groups form a card number, or
+// 0 when they do not start with one.
+func panGroups(groups []string) int {
+	var digits string
+	for n, group := range groups {
+		digits += group
+		if len(digits) > 19 {
+			break
+		}
+		if len(digits) >= 13 && luhn(digits) {
+			return n + 1
+		}
+	}
+	return 0
+}
+
+// luhn reports whether digits pass the Luhn check of card numbers.
+func luhn(digits string) bool {
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// IsSensitiveKey reports whether values under key must never be recorded,
+// such as "card_number" or "cvv".
+func IsSensitiveKey(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[key]
+}
+
+// RedactingHandler is a slog.Handler that masks card data in the message and
+// in every attribute before handing the record to the wrapped handler. When
+// the record is logged with a context carrying a span, the trace and span IDs
+// are added as trace_id and span_id.
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler wraps next with PAN/CVV redaction.
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		clean.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redactAttr(a)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(clean)}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return slog.Attr{Key: a.Key, Value: redactValue(a.Value.Resolve())}
+}
+
+func redactValue(v slog.Value) slog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.StringValue(Redact(v.String()))
+
+	case slog.KindInt64, slog.KindUint64:
+		// A PAN fits in 64 bits, so numbers are not safe either.
+		s := v.String()
+		if masked := Redact(s); masked != s {
+			return slog.StringValue(masked)
+		}
+		return v
+
+	case slog.KindGroup:
+		attrs := v.Group()
+		clean := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			clean[i] = redactAttr(a)
+		}
+		return slog.GroupValue(clean...)
+
+	case slog.KindAny:
+		return redactAny(v.Any())
+
+	default:
+		return v
+	}
+}
+
+// redactAny handles arbitrary values. Errors and Stringers are rendered and
+// redacted as text; everything else is marshalled to JSON, redacted, and
+// emitted as raw JSON so that structs keep their shape in the output.
+func redactAny(x any) slog.Value {
+	switch t := x.(type) {
+	case nil:
+		return slog.AnyValue(nil)
+	case error:
+		return slog.StringValue(Redact(t.Error()))
+	case fmt.Stringer:
+		return slog.StringValue(Redact(t.String()))
+	case []byte:
+		return slog.StringValue(Redact(string(t)))
+	}
+
+	raw, err := json.Marshal(x)
+	if err != nil {
+		return slog.StringValue(Redact(fmt.Sprintf("%+v", x)))
+	}
+
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return slog.StringValue(Redact(string(raw)))
+	}
+	clean, err := json.Marshal(redactJSON(doc))
+	if err != nil {
+		return slog.StringValue(redacted)
+	}
+	return slog.AnyValue(json.RawMessage(clean))
+}
+
+// redactJSON walks a decoded JSON document applying the same key and value
+// rules as redactAttr.
+func redactJSON(doc any) any {
+	switch t := doc.(type) {
+	case map[string]any:
+		for k, v := range t {
+			if IsSensitiveKey(k) {
+				t[k] = redacted
+				continue
+			}
+			t[k] = redactJSON(v)
+		}
+		return t
+	case []any:
+		for i, v := range t {
+			t[i] = redactJSON(v)
+		}
+		return t
+	case string:
+		return Redact(t)
+	case json.Number:
+		if masked := Redact(t.String()); masked != t.String() {
+			return masked
+		}
+		return t
+	default:
+		return t
+	}
+}
@@ -0,0 +1,202 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const pan = "4111111111111111"
+
+type cardDetails struct {
+	Number string `json:"number"`
+	Holder string `json:"holder"`
+	Cvv    string `json:"cvv"`
+}
+
+type stringerCard struct{ number string }
+
+func (s stringerCard) String() string { return "card " + s.number }
+
+// assertNoPAN fails when the output contains the PAN, including when its
+// digits are separated by the spaces or dashes a formatter may have added.
+func assertNoPAN(t *testing.T, out string) {
+	t.Helper()
+	digitsOnly := strings.NewReplacer(" ", "", "-", "").Replace(out)
+	assert.NotContains(t, digitsOnly, pan, "PAN leaked: %s", out)
+}
+
+func TestLogger_NeverEmitsPAN(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+	}{
+		{
+			name: "In message",
+			log:  func(l *slog.Logger) { l.Info("charging card " + pan) },
+		},
+		{
+			name: "Formatted with spaces",
+			log:  func(l *slog.Logger) { l.Info("charging card 4111 1111 1111 1111") },
+		},
+		{
+			name: "Formatted with dashes",
+			log:  func(l *slog.Logger) { l.Info("x", "card", "4111-1111-1111-1111") },
+		},
+		{
+			name: "String attribute",
+			log:  func(l *slog.Logger) { l.Info("x", "input", pan) },
+		},
+		{
+			name: "Sensitive key",
+			log:  func(l *slog.Logger) { l.Info("x", "card_number", pan) },
+		},
+		{
+			name: "Integer attribute",
+			log:  func(l *slog.Logger) { l.Info("x", "number", int64(4111111111111111)) },
+		},
+		{
+			name: "Error attribute",
+			log: func(l *slog.Logger) {
+				l.Error("x", "error", fmt.Errorf("bank rejected request (400): %v", map[string]any{"card_number": pan}))
+			},
+		},
+		{
+			name: "Wrapped error",
+			log: func(l *slog.Logger) {
+				l.Error("x", "error", fmt.Errorf("outer: %w", errors.New("invalid pan "+pan)))
+			},
+		},
+		{
+			name: "Struct attribute",
+			log:  func(l *slog.Logger) { l.Info("x", "card", cardDetails{Number: pan, Holder: "A"}) },
+		},
+		{
+			name: "Map attribute",
+			log:  func(l *slog.Logger) { l.Info("x", "body", map[string]any{"pan": pan, "nested": []any{pan}}) },
+		},
+		{
+			name: "Stringer attribute",
+			log:  func(l *slog.Logger) { l.Info("x", "card", stringerCard{number: pan}) },
+		},
+		{
+			name: "Byte slice attribute",
+			log:  func(l *slog.Logger) { l.Info("x", "raw", []byte(`{"card_number":"`+pan+`"}`)) },
+		},
+		{
+			name: "Group attribute",
+			log:  func(l *slog.Logger) { l.Info("x", slog.Group("req", slog.String("card", pan))) },
+		},
+		{
+			name: "With attributes",
+			log:  func(l *slog.Logger) { l.With("card", pan).Info("x") },
+		},
+		{
+			name: "With group",
+			log:  func(l *slog.Logger) { l.WithGroup("req").Info("x", "card", pan) },
+		},
+		{
+			name: "LogValuer attribute",
+			log: func(l *slog.Logger) {
+				l.Info("x", "card", slog.StringValue(pan))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(logging.New(&buf, slog.LevelDebug))
+
+			assert.NotEmpty(t, buf.String())
+			assertNoPAN(t, buf.String())
+		})
+	}
+}
+
+func TestLogger_NeverEmitsCVV(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelDebug)
+
+	logger.Info("x", "cvv", "123")
+	logger.Info("x", "card", cardDetails{Cvv: "456"})
+	logger.Info("x", "error", errors.New(`bank rejected request (400): {"cvv":"789"}`))
+	logger.Info("x", "error", fmt.Errorf("bank rejected request (400): %v", map[string]any{"cvv": "321"}))
+
+	// The timestamps are digits too, and may hold a CVV by chance.
+	out := regexp.MustCompile(`"time":"[^"]*"`).ReplaceAllString(buf.String(), "")
+	for _, cvv := range []string{"123", "456", "789", "321"} {
+		assert.NotContains(t, out, cvv)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "card " + pan, want: "card ************1111"},
+		{in: "card 4111 1111 1111 1111 ok", want: "card ************1111 ok"},
+		{in: "card_" + pan, want: "card_************1111"},
+		{in: "pan" + pan, want: "pan************1111"},
+		{in: pan + "x", want: "************1111x"},
+		{in: "4111  1111 1111 1111", want: "************1111"},
+		{in: "4111--1111-1111-1111.", want: "************1111."},
+		{in: pan + " 1000", want: "************1111 1000"},
+		{in: "4111111111111112", want: "4111111111111112"},
+		{in: "order 12345678901234567890", want: "order 12345678901234567890"},
+		{in: "at 1760862000123 ms", want: "at 1760862000123 ms"},
+		{in: "at 20261019083000", want: "at 20261019083000"},
+		{in: "amount 1234567890123.45", want: "amount 1234567890123.45"},
+		{in: `{"cvv":"123"}`, want: `{"cvv":"***"}`},
+		{in: "cvv=1234&x=1", want: "cvv=***&x=1"},
+		{in: "amount 1000 on 2030-12", want: "amount 1000 on 2030-12"},
+		{in: "last four 3456", want: "last four 3456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, logging.Redact(tt.in))
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo).With("request_id", "req-1")
+
+	ctx := logging.WithLogger(context.Background(), logger)
+	logging.FromContext(ctx).Info("hello")
+
+	assert.Contains(t, buf.String(), `"request_id":"req-1"`)
+	assert.Equal(t, slog.Default(), logging.FromContext(context.Background()))
+}
+
+func TestLogger_AddsTraceContext(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+	logger.InfoContext(ctx, "with span")
+	logger.Info("without span")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+	assert.Contains(t, lines[0], `"span_id":"00f067aa0ba902b7"`)
+	assert.NotContains(t, lines[1], "trace_id")
+}
@@ -0,0 +1,238 @@
+// Package metrics defines the Prometheus collectors exposed by the gateway on
+// /metrics. Metric names and label sets are part of the operational contract
+// (see DesignDecisions.md) and must not change without a CHANGELOG entry.
+//
+// Labels must have bounded cardinality: never use card data, payment IDs or
+// raw URL paths as label values.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "payment_gateway"
+
+// Bank error classes used as the "class" label of BankErrorsTotal.
+const (
+	BankErrorTimeout     = "timeout"
+	BankErrorUnavailable = "unavailable"
+	BankErrorBadRequest  = "bad_request"
+	BankErrorDecode      = "decode"
+	BankErrorUnexpected  = "unexpected_status"
+	BankErrorTransport   = "transport"
+)
+
+// Attempts used as the "winner" label of BankHedgeWinsTotal.
+const (
+	HedgeWinnerPrimary = "primary"
+	HedgeWinnerHedge   = "hedge"
+)
+
+// ThreeDSError is the "status" label of ThreeDSAuthenticationsTotal for
+// authentications the 3DS server did not answer.
+const ThreeDSError = "error"
+
+// Registry holds every gateway collector plus the Go runtime and process
+// collectors. It is used instead of the global default registry so tests can
+// inspect it without interference from imported libraries.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PaymentsTotal counts processed payments by final status.
+	PaymentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_total",
+		Help:      "Payments processed, by payment status, currency and acquirer.",
+	}, []string{"status", "currency", "acquirer"})
+
+	// HTTPRequestDuration observes handler latency by route pattern.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP handlers, by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	// BankRequestDuration observes BankClient.ProcessPayment latency.
+	BankRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bank_request_duration_seconds",
+		Help:      "Latency of authorization calls to the acquiring bank, by acquirer and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"acquirer", "outcome"})
+
+	// BankErrorsTotal counts failed bank calls by error class.
+	BankErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bank_errors_total",
+		Help:      "Failed authorization calls to the acquiring bank, by acquirer and error class.",
+	}, []string{"acquirer", "class"})
+
+	// BankRetriesTotal counts attempts retried because the acquirer did not
+	// process them, by the error class of the failed attempt.
+	BankRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bank_retries_total",
+		Help:      "Authorization attempts retried because the acquiring bank did not process them, by acquirer and error class.",
+	}, []string{"acquirer", "class"})
+
+	// BankConcurrencyLimit reports the bulkhead limit of each acquirer.
+	BankConcurrencyLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bank_concurrency_limit",
+		Help:      "Maximum concurrent authorization calls to the acquiring bank, by acquirer.",
+	}, []string{"acquirer"})
+
+	// BankInFlight reports the calls holding a bulkhead slot.
+	BankInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bank_in_flight",
+		Help:      "Authorization calls to the acquiring bank in flight, by acquirer.",
+	}, []string{"acquirer"})
+
+	// BankRejectedTotal counts calls refused by the bulkhead.
+	BankRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bank_rejected_total",
+		Help:      "Authorization calls refused because too many were in flight to the acquiring bank, by acquirer.",
+	}, []string{"acquirer"})
+
+	// BankHedgedTotal counts hedged attempts sent because the first attempt
+	// was slow.
+	BankHedgedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bank_hedged_total",
+		Help:      "Hedged authorization attempts sent to the acquiring bank, by acquirer.",
+	}, []string{"acquirer"})
+
+	// BankHedgeWinsTotal counts which attempt answered first in hedged calls.
+	BankHedgeWinsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bank_hedge_wins_total",
+		Help:      "Hedged authorization calls by the attempt that answered first, primary or hedge, by acquirer.",
+	}, []string{"acquirer", "winner"})
+
+	// DuplicatePaymentsTotal counts payments matching an earlier identical
+	// payment, by the action taken.
+	DuplicatePaymentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicate_payments_total",
+		Help:      "Payments identical to a recent one (same card, amount and currency), by action taken.",
+	}, []string{"action"})
+
+	// DeclinesTotal counts declined payments by acquirer and normalized
+	// decline code.
+	DeclinesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "declines_total",
+		Help:      "Payments declined by the acquiring bank, by acquirer, normalized decline code and soft/hard type.",
+	}, []string{"acquirer", "code", "type"})
+
+	// RateLimitedTotal counts requests refused by the rate limiter.
+	RateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests refused with 429 by the rate limiter, by endpoint and merchant tier.",
+	}, []string{"endpoint", "tier"})
+
+	// PaymentQueueLength reports the payments waiting for asynchronous
+	// processing.
+	PaymentQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "payment_queue_length",
+		Help:      "Number of payments waiting in the asynchronous processing queue.",
+	})
+
+	// WebhookDeliveriesTotal counts payment callbacks to merchants, by
+	// outcome.
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Payment callbacks sent to merchants, by outcome (delivered, failed or dropped).",
+	}, []string{"outcome"})
+
+	// BatchItemsTotal counts processed payments of batches, by payment
+	// status.
+	BatchItemsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batch_items_total",
+		Help:      "Payments of batches processed, by payment status.",
+	}, []string{"status"})
+
+	// BatchesInProgress reports the batches being processed.
+	BatchesInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "batches_in_progress",
+		Help:      "Number of payment batches being processed.",
+	})
+
+	// SubscriptionChargesTotal counts the payments made by the subscription
+	// scheduler, by payment status.
+	SubscriptionChargesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subscription_charges_total",
+		Help:      "Subscription charges made, by payment status.",
+	}, []string{"status"})
+
+	// SubscriptionDunningTotal counts failed subscription charges by
+	// outcome: retry when another attempt is scheduled, suspended when the
+	// subscription is stopped.
+	SubscriptionDunningTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subscription_dunning_total",
+		Help:      "Failed subscription charges, by outcome (retry, suspended).",
+	}, []string{"outcome"})
+
+	// ThreeDSAuthenticationsTotal counts 3-D Secure authentications, by
+	// outcome.
+	ThreeDSAuthenticationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "three_ds_authentications_total",
+		Help:      "3-D Secure authentication results, by status (authenticated, attempted, failed, rejected, unavailable, challenge_required or error).",
+	}, []string{"status"})
+
+	// RepositoryPayments reports the number of payments held in storage.
+	RepositoryPayments = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "repository_payments",
+		Help:      "Number of payments currently held by the payments repository.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PaymentsTotal,
+		HTTPRequestDuration,
+		BankRequestDuration,
+		BankErrorsTotal,
+		BankRetriesTotal,
+		BankConcurrencyLimit,
+		BankInFlight,
+		BankRejectedTotal,
+		BankHedgedTotal,
+		BankHedgeWinsTotal,
+		DuplicatePaymentsTotal,
+		DeclinesTotal,
+		RateLimitedTotal,
+		PaymentQueueLength,
+		WebhookDeliveriesTotal,
+		BatchItemsTotal,
+		BatchesInProgress,
+		SubscriptionChargesTotal,
+		SubscriptionDunningTotal,
+		ThreeDSAuthenticationsTotal,
+		RepositoryPayments,
+	)
+}
+
+// Handler returns an http.Handler serving Registry in the Prometheus text
+// exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,159 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGateway returns the gateway handler with a single acquirer, named
+// acquirer, authorizing every payment.
+func newGateway(t *testing.T, acquirer string) http.Handler {
+	t.Helper()
+	bank := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"authorized":true,"authorization_code":"AUTH-1"}`))
+	}))
+	t.Cleanup(bank.Close)
+
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+bank:
+  acquirers:
+    - {name: `+acquirer+`, url: "`+bank.URL+`", weight: 1}
+payments:
+  allowed_currencies: [GBP]
+`), 0o600))
+	load := func() (*config.Config, error) {
+		cfg, _, err := config.Load([]string{"--config", path}, func(string) string { return "" })
+		return cfg, err
+	}
+	cfg, err := load()
+	require.NoError(t, err)
+	gateway, err := api.New(config.NewReloader(cfg, load))
+	require.NoError(t, err)
+	return gateway.Handler()
+}
+
+// scrape fetches /metrics from handler and parses the exposition.
+func scrape(t *testing.T, handler http.Handler) (string, map[string]*dto.MetricFamily) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	require.NoError(t, err)
+	return body, families
+}
+
+// find returns the metric of family with exactly labels, or nil.
+func find(family *dto.MetricFamily, labels map[string]string) *dto.Metric {
+	for _, m := range family.GetMetric() {
+		got := make(map[string]string)
+		for _, l := range m.GetLabel() {
+			got[l.GetName()] = l.GetValue()
+		}
+		if len(got) != len(labels) {
+			continue
+		}
+		match := true
+		for k, v := range labels {
+			if got[k] != v {
+				match = false
+			}
+		}
+		if match {
+			return m
+		}
+	}
+	return nil
+}
+
+// defBuckets are the upper bounds of the latency histograms, those of
+// prometheus.DefBuckets, as scraped.
+var defBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, math.Inf(1)}
+
+// bounds returns the upper bounds of the buckets of a histogram.
+func bounds(m *dto.Metric) []float64 {
+	var bounds []float64
+	for _, b := range m.GetHistogram().GetBucket() {
+		bounds = append(bounds, b.GetUpperBound())
+	}
+	return bounds
+}
+
+func TestRegistry_AfterRequest(t *testing.T) {
+	handler := newGateway(t, "metrics-scrape")
+
+	body := `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2099,"currency":"GBP","amount":100,"cvv":"123"}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/payments", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var payment struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/payments/"+payment.ID, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	raw, families := scrape(t, handler)
+
+	t.Run("HTTP latency", func(t *testing.T) {
+		family := families["payment_gateway_http_request_duration_seconds"]
+		require.NotNil(t, family)
+		assert.Equal(t, dto.MetricType_HISTOGRAM, family.GetType())
+		post := find(family, map[string]string{"method": "POST", "route": "/api/payments", "code": "200"})
+		require.NotNil(t, post)
+		assert.GreaterOrEqual(t, post.GetHistogram().GetSampleCount(), uint64(1))
+		assert.Equal(t, defBuckets, bounds(post))
+		assert.NotNil(t, find(family, map[string]string{"method": "GET", "route": "/api/payments/{id}", "code": "200"}),
+			"the route is the pattern, not the path")
+	})
+
+	t.Run("Bank latency", func(t *testing.T) {
+		family := families["payment_gateway_bank_request_duration_seconds"]
+		require.NotNil(t, family)
+		assert.Equal(t, dto.MetricType_HISTOGRAM, family.GetType())
+		m := find(family, map[string]string{"acquirer": "metrics-scrape", "outcome": "authorized"})
+		require.NotNil(t, m)
+		assert.GreaterOrEqual(t, m.GetHistogram().GetSampleCount(), uint64(1))
+		assert.Equal(t, defBuckets, bounds(m))
+	})
+
+	t.Run("Payments", func(t *testing.T) {
+		family := families["payment_gateway_payments_total"]
+		require.NotNil(t, family)
+		assert.Equal(t, dto.MetricType_COUNTER, family.GetType())
+		m := find(family, map[string]string{"status": "Authorized", "currency": "GBP", "acquirer": "metrics-scrape"})
+		require.NotNil(t, m)
+		assert.GreaterOrEqual(t, m.GetCounter().GetValue(), float64(1))
+	})
+
+	t.Run("Repository size", func(t *testing.T) {
+		family := families["payment_gateway_repository_payments"]
+		require.NotNil(t, family)
+		assert.Equal(t, dto.MetricType_GAUGE, family.GetType())
+		assert.GreaterOrEqual(t, family.GetMetric()[0].GetGauge().GetValue(), float64(1))
+	})
+
+	t.Run("No card data or payment IDs", func(t *testing.T) {
+		assert.NotContains(t, raw, "2222405343248877")
+		assert.NotContains(t, raw, payment.ID)
+	})
+}
@@ -0,0 +1,205 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PreferAsync is the Prefer header preference (RFC 7240) asking for a
+// payment to be processed asynchronously.
+const PreferAsync = "respond-async"
+
+var (
+	// ErrQueueFull is returned by JobQueue.Push when the queue is at
+	// capacity.
+	ErrQueueFull = errors.New("payment queue is full")
+	// ErrQueueClosed is returned by JobQueue.Push once the queue is closed,
+	// and by JobQueue.Pop once it is also empty.
+	ErrQueueClosed = errors.New("payment queue is closed")
+)
+
+// Job is a payment accepted for asynchronous processing. It holds the card
+// data needed to authorize it, so durable queues must encrypt it.
+type Job struct {
+	// Payment is the Pending payment, as stored when it was accepted.
+	Payment Payment `json:"payment"`
+	// Request is the validated request, with card tokens resolved.
+	Request  PostPaymentRequest `json:"request"`
+	Merchant string             `json:"merchant,omitempty"`
+	// Duplicate is the duplicate reservation made for the payment, released
+	// when it is not authorized.
+	Duplicate *DuplicateKey `json:"duplicate,omitempty"`
+}
+
+// JobQueue holds payments accepted for asynchronous processing until a
+// worker authorizes them.
+type JobQueue interface {
+	// Push adds job, or returns ErrQueueFull. A durable queue has stored
+	// the job when Push returns.
+	Push(job Job) error
+	// Pop removes the oldest job, waiting for one until ctx is done. Once
+	// the queue is closed it returns the jobs left, then ErrQueueClosed.
+	Pop(ctx context.Context) (Job, error)
+	// Ack records that the payment with paymentID was processed, so that a
+	// durable queue does not recover it.
+	Ack(paymentID string) error
+	// Recovered returns the jobs a durable queue recovered from an earlier
+	// run. They are also returned by Pop.
+	Recovered() []Job
+	// Len returns the number of jobs waiting.
+	Len() int
+	// Close makes Push refuse new jobs.
+	Close()
+}
+
+// Notifier tells merchants the outcome of their asynchronous payments.
+type Notifier interface {
+	Notify(ctx context.Context, merchant string, payment *Payment)
+}
+
+// EnableAsync lets clients ask for asynchronous processing with
+// "Prefer: respond-async". Such payments are answered with 202 and a Pending
+// payment, and queued until ProcessQueue authorizes them; notifier, when not
+// nil, then tells the merchant of the outcome. Payments recovered by the
+// queue are stored as Pending.
+func (h *PaymentsHandler) EnableAsync(queue JobQueue, notifier Notifier) {
+	h.queue = queue
+	h.notifier = notifier
+	for _, job := range queue.Recovered() {
+		h.storage.AddPayment(job.Payment)
+	}
+	metrics.PaymentQueueLength.Set(float64(queue.Len()))
+}
+
+// ProcessQueue authorizes queued payments with the given number of workers,
+// until the queue is closed and empty or ctx is done. Payments being
+// authorized when ctx is done are finished first.
+func (h *PaymentsHandler) ProcessQueue(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := h.queue.Pop(ctx)
+				if err != nil {
+					return
+				}
+				metrics.PaymentQueueLength.Set(float64(h.queue.Len()))
+				h.process(context.WithoutCancel(ctx), job)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// prefersAsync reports whether the request asks for asynchronous processing.
+func prefersAsync(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(pref, ";")
+			if strings.EqualFold(strings.TrimSpace(name), PreferAsync) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// accept stores a prepared payment as Pending, queues it and answers 202 with
+// a Location to poll.
+func (h *PaymentsHandler) accept(w http.ResponseWriter, r *http.Request, p *prepared) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	payment := p.payment
+	payment.PaymentStatus = "Pending"
+	h.store(ctx, payment)
+	err := h.queue.Push(Job{
+		Payment:   payment,
+		Request:   p.req,
+		Merchant:  p.merchant,
+		Duplicate: p.dup.key,
+	})
+	if err != nil {
+		p.dup.release()
+		code := problem.CodeInternalError
+		if errors.Is(err, ErrQueueFull) {
+			code = problem.CodeQueueFull
+		}
+		payment.PaymentStatus = "Failed"
+		payment.FailureCode = string(code)
+		h.storage.UpdatePayment(payment)
+		metrics.PaymentsTotal.WithLabelValues("Failed", payment.Currency, unknownAcquirer).Inc()
+
+		if code == problem.CodeQueueFull {
+			logger.WarnContext(ctx, "payment refused, queue is full")
+			w.Header().Set("Retry-After", "1")
+			problem.Write(w, r, problem.New(code, "Too many payments are waiting to be processed, retry shortly").
+				WithPaymentStatus("Failed"))
+			return
+		}
+		logger.ErrorContext(ctx, "failed to queue payment", "error", err)
+		problem.Write(w, r, problem.New(code, "Payment could not be queued").WithPaymentStatus("Failed"))
+		return
+	}
+	metrics.PaymentQueueLength.Set(float64(h.queue.Len()))
+	logger.InfoContext(ctx, "payment queued", "payment_id", payment.Id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", r.URL.Path+"/"+payment.Id)
+	w.Header().Set("Preference-Applied", PreferAsync)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(wire.From[Codec](ctx).EncodePayment(&payment))
+}
+
+// process authorizes a queued payment, stores the outcome, removes it from
+// the queue and notifies the merchant. Payments the issuer declines for lack
+// of authentication are notified as RequiresAction, then again once the
+// challenge ends.
+func (h *PaymentsHandler) process(ctx context.Context, job Job) {
+	ctx, span := tracing.Tracer().Start(ctx, "payments.ProcessQueued",
+		trace.WithAttributes(attribute.String("payment.id", job.Payment.Id)))
+	defer span.End()
+	logger := logging.FromContext(ctx).With("payment_id", job.Payment.Id, "merchant_id", job.Merchant)
+	ctx = logging.WithLogger(ctx, logger)
+
+	p := &prepared{
+		req:      job.Request,
+		payment:  job.Payment,
+		merchant: job.Merchant,
+		dup:      duplicateCheck{release: func() {}, key: job.Duplicate},
+		stored:   true,
+		notify:   job.Merchant != "",
+	}
+	if key := job.Duplicate; key != nil {
+		p.dup.release = func() { h.duplicates.Release(*key, job.Payment.Id) }
+	}
+
+	payment, prob := h.authorize(ctx, p)
+	if prob != nil {
+		span.SetStatus(codes.Error, string(prob.Code))
+		failed := h.fail(p, prob.Code)
+		payment = &failed
+	}
+
+	if err := h.queue.Ack(payment.Id); err != nil {
+		logger.ErrorContext(ctx, "failed to remove processed payment from the queue", "error", err)
+	}
+	if h.notifier != nil && job.Merchant != "" {
+		h.notifier.Notify(ctx, job.Merchant, payment)
+	}
+}
@@ -0,0 +1,148 @@
+package payments_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/jobqueue"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier records the payments it is asked to notify, by merchant.
+type recordingNotifier struct {
+	mu       sync.Mutex
+	payments map[string][]payments.Payment
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, merchant string, payment *payments.Payment) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.payments == nil {
+		n.payments = make(map[string][]payments.Payment)
+	}
+	n.payments[merchant] = append(n.payments[merchant], *payment)
+}
+
+func TestPostPaymentHandler_Async(t *testing.T) {
+	const body = `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2030,"currency":"USD","amount":100,"cvv":"123"}`
+
+	post := func(handler *payments.PaymentsHandler, prefer string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+		req.Header.Set(payments.MerchantIDHeader, "acme")
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		w := httptest.NewRecorder()
+		withV1(handler.PostHandler()).ServeHTTP(w, req)
+		var respBody map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &respBody))
+		return w, respBody
+	}
+
+	var (
+		mu         sync.Mutex
+		references []string
+		bankErr    error
+	)
+	bank := &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			references = append(references, req.Reference)
+			if bankErr != nil {
+				return nil, bankErr
+			}
+			return &payments.BankAuthorization{Authorized: true, AuthorizationCode: "AUTH-1"}, nil
+		},
+	}
+
+	t.Run("Queued and processed", func(t *testing.T) {
+		repo := payments.NewPaymentsRepository()
+		handler := payments.NewPaymentsHandler(repo, bank)
+		queue := jobqueue.NewMemory(10)
+		notifier := &recordingNotifier{}
+		handler.EnableAsync(queue, notifier)
+		references = nil
+
+		w, queued := post(handler, "respond-async, wait=10")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "Pending", queued["payment_status"])
+		id, _ := queued["id"].(string)
+		require.NotEmpty(t, id)
+		assert.Equal(t, "/api/payments/"+id, w.Header().Get("Location"))
+		assert.Equal(t, payments.PreferAsync, w.Header().Get("Preference-Applied"))
+		assert.Equal(t, "Pending", repo.GetPayment(id).PaymentStatus)
+		assert.Empty(t, references, "the bank is only called by the workers")
+
+		queue.Close()
+		handler.ProcessQueue(context.Background(), 2)
+
+		stored := repo.GetPayment(id)
+		require.NotNil(t, stored)
+		assert.Equal(t, "Authorized", stored.PaymentStatus)
+		assert.Equal(t, []string{id}, references, "the payment ID is the bank reference")
+		require.Len(t, notifier.payments["acme"], 1)
+		assert.Equal(t, "Authorized", notifier.payments["acme"][0].PaymentStatus)
+	})
+
+	t.Run("Bank failure", func(t *testing.T) {
+		repo := payments.NewPaymentsRepository()
+		handler := payments.NewPaymentsHandler(repo, bank)
+		queue := jobqueue.NewMemory(10)
+		handler.EnableAsync(queue, nil)
+		bankErr = errors.New("connection refused")
+		defer func() { bankErr = nil }()
+
+		w, queued := post(handler, "respond-async")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		queue.Close()
+		handler.ProcessQueue(context.Background(), 1)
+
+		stored := repo.GetPayment(queued["id"].(string))
+		require.NotNil(t, stored)
+		assert.Equal(t, "Failed", stored.PaymentStatus)
+		assert.Equal(t, "upstream_unavailable", stored.FailureCode)
+	})
+
+	t.Run("Queue full", func(t *testing.T) {
+		repo := payments.NewPaymentsRepository()
+		handler := payments.NewPaymentsHandler(repo, bank)
+		handler.EnableAsync(jobqueue.NewMemory(1), nil)
+
+		w, _ := post(handler, "respond-async")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		w, refused := post(handler, "respond-async")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "queue_full", refused["code"])
+		assert.Equal(t, "Failed", refused["payment_status"])
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Synchronous without the preference", func(t *testing.T) {
+		handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), bank)
+		handler.EnableAsync(jobqueue.NewMemory(1), nil)
+
+		w, paid := post(handler, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Authorized", paid["payment_status"])
+	})
+
+	t.Run("Preference ignored when async is disabled", func(t *testing.T) {
+		handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), bank)
+
+		w, paid := post(handler, "respond-async")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Authorized", paid["payment_status"])
+		assert.Empty(t, w.Header().Get("Preference-Applied"))
+	})
+}
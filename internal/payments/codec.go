@@ -0,0 +1,14 @@
+package payments
+
+import (
+	"io"
+)
+
+// Codec translates between the wire models of one API version and the
+// domain model, so that the handlers do not depend on any API version.
+type Codec interface {
+	// DecodePaymentRequest reads a payment request body.
+	DecodePaymentRequest(r io.Reader) (*PostPaymentRequest, error)
+	// EncodePayment returns the response model to send for p.
+	EncodePayment(p *Payment) any
+}
@@ -0,0 +1,97 @@
+package payments
+
+// Initiator says who started a payment made with a stored credential.
+type Initiator string
+
+const (
+	// InitiatorCustomer marks a customer-initiated transaction (CIT): the
+	// cardholder is present and authenticates the payment.
+	InitiatorCustomer Initiator = "customer"
+	// InitiatorMerchant marks a merchant-initiated transaction (MIT): the
+	// merchant charges a stored card under an agreement the cardholder
+	// accepted earlier, without them.
+	InitiatorMerchant Initiator = "merchant"
+)
+
+// Sequence says whether a payment stores a credential or uses a stored one.
+type Sequence string
+
+const (
+	// SequenceInitial is the customer-initiated payment that stores the
+	// credential and establishes the agreement.
+	SequenceInitial Sequence = "initial"
+	// SequenceSubsequent payments use a credential stored earlier.
+	SequenceSubsequent Sequence = "subsequent"
+)
+
+// CredentialReason is the agreement under which a credential is stored.
+type CredentialReason string
+
+const (
+	ReasonRecurring   CredentialReason = "recurring"
+	ReasonInstallment CredentialReason = "installment"
+	ReasonUnscheduled CredentialReason = "unscheduled"
+)
+
+// StoredCredential flags a payment made with, or storing, a card kept on file.
+// Acquirers and card schemes treat such payments differently; in particular
+// a merchant-initiated payment is sent without a CVV, which cannot be
+// stored.
+type StoredCredential struct {
+	Initiator Initiator        `json:"initiator"`
+	Sequence  Sequence         `json:"sequence"`
+	Reason    CredentialReason `json:"reason,omitempty"`
+	// NetworkTransactionID is the scheme's identifier of the initial
+	// payment, which links subsequent merchant-initiated payments to the
+	// agreement. It is returned with the initial payment.
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
+}
+
+// MerchantInitiated reports whether c flags a merchant-initiated payment.
+// It is false for a nil c. The handler only admits such payments for the
+// card of a verified initial payment; see
+// PaymentsHandler.checkMerchantInitiated.
+func (c *StoredCredential) MerchantInitiated() bool {
+	return c != nil && c.Initiator == InitiatorMerchant
+}
+
+func (c *StoredCredential) validate() error {
+	switch c.Initiator {
+	case InitiatorCustomer, InitiatorMerchant:
+	case "":
+		return invalid("stored_credential.initiator", "stored_credential.initiator is required")
+	default:
+		return invalid("stored_credential.initiator", "stored_credential.initiator must be customer or merchant")
+	}
+	switch c.Sequence {
+	case SequenceInitial, SequenceSubsequent:
+	case "":
+		return invalid("stored_credential.sequence", "stored_credential.sequence is required")
+	default:
+		return invalid("stored_credential.sequence", "stored_credential.sequence must be initial or subsequent")
+	}
+	switch c.Reason {
+	case "", ReasonRecurring, ReasonInstallment, ReasonUnscheduled:
+	default:
+		return invalid("stored_credential.reason", "stored_credential.reason must be recurring, installment or unscheduled")
+	}
+
+	if c.Sequence == SequenceInitial {
+		if c.Initiator != InitiatorCustomer {
+			return invalid("stored_credential.initiator", "the initial payment of a stored credential must be customer-initiated")
+		}
+		if c.NetworkTransactionID != "" {
+			return invalid("stored_credential.network_transaction_id", "the initial payment has no network_transaction_id yet")
+		}
+		return nil
+	}
+	if c.Initiator == InitiatorMerchant {
+		if c.Reason == "" {
+			return invalid("stored_credential.reason", "merchant-initiated payments require stored_credential.reason")
+		}
+		if c.NetworkTransactionID == "" {
+			return invalid("stored_credential.network_transaction_id", "merchant-initiated payments require the network_transaction_id of the initial payment")
+		}
+	}
+	return nil
+}
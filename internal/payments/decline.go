@@ -0,0 +1,87 @@
+package payments
+
+// DeclineCode is the normalized reason an acquirer declined a payment. Each
+// acquirer reports reasons in its own format; bank clients translate them
+// into these codes so merchants can handle every acquirer the same way.
+type DeclineCode string
+
+const (
+	DeclineInsufficientFunds DeclineCode = "insufficient_funds"
+	DeclineDoNotHonor        DeclineCode = "do_not_honor"
+	DeclineExpiredCard       DeclineCode = "expired_card"
+	DeclineSuspectedFraud    DeclineCode = "suspected_fraud"
+	DeclineInvalidCVV        DeclineCode = "invalid_cvv"
+	DeclineInvalidCard       DeclineCode = "invalid_card"
+	DeclineLostOrStolen      DeclineCode = "lost_or_stolen"
+	DeclineLimitExceeded     DeclineCode = "limit_exceeded"
+	DeclineNotPermitted      DeclineCode = "transaction_not_permitted"
+	DeclineIssuerUnavailable DeclineCode = "issuer_unavailable"
+	// DeclineAuthenticationRequired asks for the cardholder to be
+	// authenticated with 3-D Secure (strong customer authentication).
+	DeclineAuthenticationRequired DeclineCode = "authentication_required"
+	// DeclineAuthenticationFailed is reported by the gateway itself when the
+	// cardholder failed 3-D Secure authentication.
+	DeclineAuthenticationFailed DeclineCode = "authentication_failed"
+)
+
+// DeclineType tells a merchant whether retrying a declined payment can
+// succeed.
+type DeclineType string
+
+const (
+	// DeclineSoft declines are temporary: the same card may be approved
+	// later or with a smaller amount.
+	DeclineSoft DeclineType = "soft"
+	// DeclineHard declines are final: retrying with the same card data
+	// will be declined again.
+	DeclineHard DeclineType = "hard"
+)
+
+var declineTypes = map[DeclineCode]DeclineType{
+	DeclineInsufficientFunds: DeclineSoft,
+	DeclineDoNotHonor:        DeclineSoft,
+	DeclineLimitExceeded:     DeclineSoft,
+	DeclineIssuerUnavailable: DeclineSoft,
+	// Retrying with the cardholder authenticated may succeed.
+	DeclineAuthenticationRequired: DeclineSoft,
+	DeclineExpiredCard:            DeclineHard,
+	DeclineSuspectedFraud:         DeclineHard,
+	DeclineInvalidCVV:             DeclineHard,
+	DeclineInvalidCard:            DeclineHard,
+	DeclineLostOrStolen:           DeclineHard,
+	DeclineNotPermitted:           DeclineHard,
+	DeclineAuthenticationFailed:   DeclineHard,
+}
+
+// DeclineCodes returns every known decline code.
+func DeclineCodes() []DeclineCode {
+	return []DeclineCode{
+		DeclineInsufficientFunds,
+		DeclineDoNotHonor,
+		DeclineExpiredCard,
+		DeclineSuspectedFraud,
+		DeclineInvalidCVV,
+		DeclineInvalidCard,
+		DeclineLostOrStolen,
+		DeclineLimitExceeded,
+		DeclineNotPermitted,
+		DeclineIssuerUnavailable,
+		DeclineAuthenticationRequired,
+		DeclineAuthenticationFailed,
+	}
+}
+
+// Valid reports whether c is a known decline code.
+func (c DeclineCode) Valid() bool {
+	_, ok := declineTypes[c]
+	return ok
+}
+
+// Type returns whether c is a soft or a hard decline. Unknown codes are
+// soft, like do_not_honor, since nothing says retrying is pointless.
+func (c DeclineCode) Type() DeclineType {
+	if t, ok := declineTypes[c]; ok {
+		return t
+	}
+	return DeclineSoft
+}
@@ -0,0 +1,38 @@
+package payments_test
+
+import (
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeclineCode_Type(t *testing.T) {
+	tests := []struct {
+		code payments.DeclineCode
+		want payments.DeclineType
+	}{
+		{payments.DeclineInsufficientFunds, payments.DeclineSoft},
+		{payments.DeclineDoNotHonor, payments.DeclineSoft},
+		{payments.DeclineIssuerUnavailable, payments.DeclineSoft},
+		{payments.DeclineExpiredCard, payments.DeclineHard},
+		{payments.DeclineSuspectedFraud, payments.DeclineHard},
+		{payments.DeclineInvalidCVV, payments.DeclineHard},
+		{payments.DeclineAuthenticationRequired, payments.DeclineSoft},
+		{payments.DeclineAuthenticationFailed, payments.DeclineHard},
+		{"something_new", payments.DeclineSoft},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.code.Type())
+		})
+	}
+}
+
+func TestDeclineCodes_AreValid(t *testing.T) {
+	for _, code := range payments.DeclineCodes() {
+		assert.True(t, code.Valid(), code)
+	}
+	assert.False(t, payments.DeclineCode("something_new").Valid())
+}
@@ -0,0 +1,81 @@
+package payments
+
+import (
+	"sync"
+	"time"
+)
+
+// DuplicateAction is what happens to a payment matching an earlier one.
+type DuplicateAction string
+
+const (
+	// DuplicateWarn lets the payment through and reports the earlier
+	// payment in DuplicateOf.
+	DuplicateWarn DuplicateAction = "warn"
+	// DuplicateReject refuses the payment with 409 Conflict.
+	DuplicateReject DuplicateAction = "reject"
+)
+
+// duplicateSweepInterval is how often expired entries are dropped.
+const duplicateSweepInterval = time.Minute
+
+// DuplicateKey identifies payments considered identical: same merchant, card
+// fingerprint, amount and currency.
+type DuplicateKey struct {
+	Merchant    string
+	Fingerprint string
+	Currency    string
+	Amount      int
+}
+
+type duplicateEntry struct {
+	paymentID string
+	expires   time.Time
+}
+
+// DuplicateDetector remembers recent payments by DuplicateKey. A payment is
+// reserved before it is sent to the bank, so two identical requests racing
+// each other are detected as well.
+type DuplicateDetector struct {
+	mu        sync.Mutex
+	entries   map[DuplicateKey]duplicateEntry
+	lastSweep time.Time
+}
+
+func NewDuplicateDetector() *DuplicateDetector {
+	return &DuplicateDetector{entries: make(map[DuplicateKey]duplicateEntry)}
+}
+
+// Reserve returns the ID of a payment with the same key seen within window
+// before now, if any. Otherwise it records paymentID under key for window and
+// returns false.
+func (d *DuplicateDetector) Reserve(key DuplicateKey, paymentID string, window time.Duration, now time.Time) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastSweep) >= duplicateSweepInterval {
+		for k, e := range d.entries {
+			if !now.Before(e.expires) {
+				delete(d.entries, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if e, ok := d.entries[key]; ok && now.Before(e.expires) {
+		return e.paymentID, true
+	}
+	d.entries[key] = duplicateEntry{paymentID: paymentID, expires: now.Add(window)}
+	return "", false
+}
+
+// Release forgets the reservation of paymentID under key, for payments that
+// were not authorized and may be retried.
+func (d *DuplicateDetector) Release(key DuplicateKey, paymentID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.entries[key]; ok && e.paymentID == paymentID {
+		delete(d.entries, key)
+	}
+}
@@ -0,0 +1,60 @@
+package payments_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateDetector(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := 5 * time.Minute
+	key := payments.DuplicateKey{Merchant: "acme", Fingerprint: "fp", Currency: "USD", Amount: 100}
+
+	d := payments.NewDuplicateDetector()
+
+	match, found := d.Reserve(key, "pay-1", window, start)
+	assert.False(t, found)
+	assert.Empty(t, match)
+
+	match, found = d.Reserve(key, "pay-2", window, start.Add(time.Minute))
+	assert.True(t, found)
+	assert.Equal(t, "pay-1", match, "the earliest payment is reported")
+
+	for _, other := range []payments.DuplicateKey{
+		{Merchant: "other", Fingerprint: "fp", Currency: "USD", Amount: 100},
+		{Merchant: "acme", Fingerprint: "fp2", Currency: "USD", Amount: 100},
+		{Merchant: "acme", Fingerprint: "fp", Currency: "EUR", Amount: 100},
+		{Merchant: "acme", Fingerprint: "fp", Currency: "USD", Amount: 101},
+	} {
+		_, found := d.Reserve(other, "pay-x", window, start.Add(time.Minute))
+		assert.False(t, found, "%+v must not match", other)
+	}
+
+	// Only the payment holding the reservation can release it.
+	d.Release(key, "pay-2")
+	_, found = d.Reserve(key, "pay-3", window, start.Add(2*time.Minute))
+	assert.True(t, found)
+
+	d.Release(key, "pay-1")
+	match, found = d.Reserve(key, "pay-4", window, start.Add(2*time.Minute))
+	assert.False(t, found, "a released payment can be retried")
+	assert.Empty(t, match)
+
+	_, found = d.Reserve(key, "pay-5", window, start.Add(2*time.Minute+window))
+	assert.False(t, found, "entries expire after the window")
+}
+
+func TestCardFingerprinter(t *testing.T) {
+	f := payments.NewCardFingerprinter([]byte("key-1"))
+
+	fingerprint := f.Fingerprint("2222405343248877")
+	assert.Len(t, fingerprint, 64)
+	assert.NotContains(t, fingerprint, "8877")
+	assert.Equal(t, fingerprint, f.Fingerprint("2222 4053 4324 8877"))
+	assert.NotEqual(t, fingerprint, f.Fingerprint("2222405343248878"))
+	assert.NotEqual(t, fingerprint, payments.NewCardFingerprinter([]byte("key-2")).Fingerprint("2222405343248877"),
+		"fingerprints depend on the key")
+}
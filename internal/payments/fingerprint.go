@@ -0,0 +1,30 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// CardFingerprinter derives a stable identifier for a card number that can
+// be stored and shown to merchants. It is an HMAC-SHA256 of the PAN under a
+// secret key, so it cannot be reversed or brute-forced over the small PAN
+// space without the key.
+type CardFingerprinter struct {
+	key []byte
+}
+
+// NewCardFingerprinter returns a CardFingerprinter keyed with key. The key
+// must stay the same for fingerprints to remain comparable.
+func NewCardFingerprinter(key []byte) *CardFingerprinter {
+	return &CardFingerprinter{key: append([]byte(nil), key...)}
+}
+
+// Fingerprint returns the hex-encoded fingerprint of cardNumber. Spaces are
+// ignored, so grouped and ungrouped numbers get the same fingerprint.
+func (f *CardFingerprinter) Fingerprint(cardNumber string) string {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(strings.ReplaceAll(cardNumber, " ", "")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,11 +1,25 @@
 package payments
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync/atomic"
+	"time"
 
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // BankAuthorization define o que o Gateway espera receber do Banco.
@@ -14,23 +28,128 @@ type BankAuthorization struct {
 	Authorized        bool
 	AuthorizationCode string
 	ErrorMessage      string
+	// DeclineCode is the normalized reason for a declined payment.
+	DeclineCode DeclineCode
+	Acquirer    string
+	// NetworkTransactionID is the scheme's identifier of the payment, when
+	// the acquirer returns one.
+	NetworkTransactionID string
 }
 
 // BankGateway define o contrato que qualquer cliente bancário deve seguir.
 type BankGateway interface {
-	ProcessPayment(req *PostPaymentRequest) (*BankAuthorization, error)
+	ProcessPayment(ctx context.Context, req *PostPaymentRequest) (*BankAuthorization, error)
 }
 
+// ErrBankOverloaded is returned by a BankGateway refusing a payment because
+// too many calls to the acquirer are already in flight. The payment was not
+// sent, so it can be retried.
+var ErrBankOverloaded = errors.New("too many concurrent calls to the bank")
+
+// ErrCardTokenNotFound is returned by a CardVault for unknown tokens.
+var ErrCardTokenNotFound = errors.New("card token not found")
+
+// VaultCard is the card data a CardVault holds for a token.
+type VaultCard struct {
+	CardNumber  string
+	ExpiryMonth int
+	ExpiryYear  int
+	// Merchant is the merchant that tokenized the card.
+	Merchant string
+}
+
+// CardVault resolves card tokens issued by the token vault. It is defined
+// here so that payments does not depend on the vault package.
+type CardVault interface {
+	Detokenize(ctx context.Context, token string) (*VaultCard, error)
+}
+
+// ErrCustomerNotFound and ErrPaymentMethodNotFound are returned by a
+// CustomerDirectory for unknown customers and payment methods, and
+// ErrNoDefaultPaymentMethod for customers without a default one.
+var (
+	ErrCustomerNotFound       = errors.New("customer not found")
+	ErrPaymentMethodNotFound  = errors.New("payment method not found")
+	ErrNoDefaultPaymentMethod = errors.New("customer has no default payment method")
+)
+
+// SavedPaymentMethod is a customer's payment method as a CustomerDirectory
+// returns it.
+type SavedPaymentMethod struct {
+	ID        string
+	CardToken string
+	// Currency is the customer's currency, used when a payment names none.
+	Currency string
+}
+
+// CustomerDirectory resolves the saved payment methods of customers. It is
+// defined here so that payments does not depend on the customer package.
+type CustomerDirectory interface {
+	// PaymentMethod returns the payment method methodID of the customer,
+	// or its default one when methodID is empty. Customers of other
+	// merchants are not found.
+	PaymentMethod(ctx context.Context, merchant, customerID, methodID string) (*SavedPaymentMethod, error)
+}
+
+// MerchantIDHeader identifies the merchant on whose behalf a request is made.
+const MerchantIDHeader = "X-Merchant-Id"
+
 type PaymentsHandler struct {
-	storage    *PaymentsRepository
-	bankClient BankGateway
+	storage      *PaymentsRepository
+	bankClient   BankGateway
+	vault        CardVault
+	customers    CustomerDirectory
+	fingerprints *CardFingerprinter
+	duplicates   *DuplicateDetector
+	auditLog     *audit.Log
+	rules        atomic.Pointer[Rules]
+	// queue is nil unless EnableAsync was called.
+	queue    JobQueue
+	notifier Notifier
+	// authenticator is nil unless EnableThreeDS was called.
+	authenticator    Authenticator
+	callbackURL      func(paymentID string) string
+	challengeTimeout time.Duration
+	challenges       *challenges
 }
 
 func NewPaymentsHandler(storage *PaymentsRepository, bankClient BankGateway) *PaymentsHandler {
-	return &PaymentsHandler{
+	h := &PaymentsHandler{
 		storage:    storage,
 		bankClient: bankClient,
+		duplicates: NewDuplicateDetector(),
 	}
+	h.rules.Store(DefaultRules())
+	return h
+}
+
+// SetCardVault enables payments by card token. Without a vault, requests
+// carrying card_token are rejected.
+func (h *PaymentsHandler) SetCardVault(vault CardVault) {
+	h.vault = vault
+}
+
+// SetCustomerDirectory enables payments by customer_id. Without one,
+// requests carrying customer_id are rejected.
+func (h *PaymentsHandler) SetCustomerDirectory(customers CustomerDirectory) {
+	h.customers = customers
+}
+
+// SetCardFingerprinter enables card fingerprints and, with them, duplicate
+// detection.
+func (h *PaymentsHandler) SetCardFingerprinter(f *CardFingerprinter) {
+	h.fingerprints = f
+}
+
+// SetAuditLog records every stored payment in log.
+func (h *PaymentsHandler) SetAuditLog(log *audit.Log) {
+	h.auditLog = log
+}
+
+// SetRules replaces the validation rules. It is safe to call while the
+// handler is serving requests.
+func (h *PaymentsHandler) SetRules(rules *Rules) {
+	h.rules.Store(rules)
 }
 
 // GetHandler returns an http.HandlerFunc that handles HTTP GET requests.
@@ -41,78 +160,490 @@ func (h *PaymentsHandler) GetHandler() http.HandlerFunc {
 		id := chi.URLParam(r, "id")
 		payment := h.storage.GetPayment(id)
 
-		if payment != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			if err := json.NewEncoder(w).Encode(payment); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		} else {
-			w.WriteHeader(http.StatusNotFound)
+		if payment == nil {
+			problem.Write(w, r, problem.New(problem.CodePaymentNotFound, "No payment with ID "+id))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(wire.From[Codec](r.Context()).EncodePayment(payment)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
 		}
 	}
 }
 
+// PostHandler returns an http.HandlerFunc that validates a payment, sends it
+// to the acquirer and stores the outcome. With EnableAsync, payments asking
+// for it are queued instead; see accept. With EnableThreeDS, payments are
+// authenticated first when the rules require it; see authenticate.
 func (h *PaymentsHandler) PostHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req PostPaymentRequest
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error_message":  "Invalid request body format",
-				"payment_status": "Rejected",
-			})
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		codec := wire.From[Codec](ctx)
+
+		req, err := codec.DecodePaymentRequest(r.Body)
+		if err != nil {
+			logger.InfoContext(ctx, "rejected malformed payment request", "error", err)
+			metrics.PaymentsTotal.WithLabelValues("Rejected", h.currencyLabel(""), unknownAcquirer).Inc()
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid request body format").
+				WithPaymentStatus("Rejected"))
 			return
 		}
 
-		if err := req.Validate(); err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error_message":  err.Error(),
-				"payment_status": "Rejected",
-			})
+		p, prob := h.prepare(ctx, r.Header.Get(MerchantIDHeader), *req)
+		if prob != nil {
+			problem.Write(w, r, prob)
 			return
 		}
-		bankResponse, err := h.bankClient.ProcessPayment(&req)
-		if err != nil {
-			h.respondWithError(w, http.StatusBadGateway, "Financial institution unavailable", "Failed")
+
+		var payment *Payment
+		if h.requiresAuthentication(p) {
+			payment, prob = h.authenticate(ctx, p)
+		}
+		if payment == nil && prob == nil {
+			if h.queue != nil && prefersAsync(r) {
+				h.accept(w, r, p)
+				return
+			}
+			payment, prob = h.authorize(ctx, p)
+		}
+		if prob != nil {
+			if prob.Code == problem.CodeUpstreamOverloaded {
+				w.Header().Set("Retry-After", "1")
+			}
+			problem.Write(w, r, prob)
 			return
 		}
 
-		status := "Declined"
-		if bankResponse.Authorized {
-			status = "Authorized"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(codec.EncodePayment(payment))
+	}
+}
+
+// Submit processes req on behalf of merchant exactly as POST /payments does
+// without asynchronous processing. It returns the stored payment, or the
+// problem explaining why none was stored.
+func (h *PaymentsHandler) Submit(ctx context.Context, merchant string, req PostPaymentRequest) (*Payment, *problem.Problem) {
+	p, prob := h.prepare(ctx, merchant, req)
+	if prob != nil {
+		return nil, prob
+	}
+	if h.requiresAuthentication(p) {
+		if payment, prob := h.authenticate(ctx, p); payment != nil || prob != nil {
+			return payment, prob
 		}
+	}
+	return h.authorize(ctx, p)
+}
+
+// prepared is a payment accepted by prepare and not yet sent to the
+// acquirer.
+type prepared struct {
+	req      PostPaymentRequest
+	payment  Payment
+	merchant string
+	dup      duplicateCheck
+	// stored reports that the payment was stored before its outcome, as
+	// Pending or RequiresAction.
+	stored bool
+	// notify tells the merchant the outcome of a challenged payment that
+	// was processed asynchronously.
+	notify bool
+}
+
+// prepare resolves the customer's payment method and the card token,
+// validates req and checks it for duplicates. It returns the problem to
+// answer with when the payment is not accepted.
+func (h *PaymentsHandler) prepare(ctx context.Context, merchant string, req PostPaymentRequest) (*prepared, *problem.Problem) {
+	logger := logging.FromContext(ctx)
+
+	if err := h.resolveCustomer(ctx, merchant, &req); err != nil {
+		if errors.Is(err, errCustomerLookup) {
+			logger.ErrorContext(ctx, "customer lookup failed", "error", err)
+			metrics.PaymentsTotal.WithLabelValues("Failed", h.currencyLabel(req.Currency), unknownAcquirer).Inc()
+			return nil, problem.New(problem.CodeInternalError, "Customer could not be resolved").
+				WithPaymentStatus("Failed")
+		}
+		logger.InfoContext(ctx, "rejected payment request with invalid customer", "error", err)
+		metrics.PaymentsTotal.WithLabelValues("Rejected", h.currencyLabel(req.Currency), unknownAcquirer).Inc()
+		return nil, rejection(err)
+	}
 
-		paymentID := uuid.New().String()
-		lastFour := req.CardNumber[len(req.CardNumber)-4:]
-
-		response := PostPaymentResponse{
-			Id:                 paymentID,
-			PaymentStatus:      status,
-			CardNumberLastFour: lastFour,
-			ExpiryMonth:        req.ExpiryMonth,
-			ExpiryYear:         req.ExpiryYear,
-			Currency:           req.Currency,
-			Amount:             req.Amount,
+	if err := h.resolveCardToken(ctx, &req); err != nil {
+		if errors.Is(err, errCardTokenLookup) {
+			logger.ErrorContext(ctx, "card token lookup failed", "error", err)
+			metrics.PaymentsTotal.WithLabelValues("Failed", h.currencyLabel(req.Currency), unknownAcquirer).Inc()
+			return nil, problem.New(problem.CodeInternalError, "Card token could not be resolved").
+				WithPaymentStatus("Failed")
 		}
+		logger.InfoContext(ctx, "rejected payment request with invalid card token", "error", err)
+		metrics.PaymentsTotal.WithLabelValues("Rejected", h.currencyLabel(req.Currency), unknownAcquirer).Inc()
+		return nil, rejection(err)
+	}
 
-		h.storage.AddPayment(response)
+	if err := h.validate(ctx, &req); err != nil {
+		logger.InfoContext(ctx, "rejected invalid payment request", "error", err)
+		metrics.PaymentsTotal.WithLabelValues("Rejected", h.currencyLabel(req.Currency), unknownAcquirer).Inc()
+		return nil, rejection(err)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(response)
+	paymentID := uuid.New().String()
+	var fingerprint string
+	if h.fingerprints != nil {
+		fingerprint = h.fingerprints.Fingerprint(req.CardNumber)
+	}
+
+	if err := h.checkMerchantInitiated(merchant, &req, fingerprint); err != nil {
+		logger.InfoContext(ctx, "rejected unverified merchant-initiated payment", "error", err)
+		metrics.PaymentsTotal.WithLabelValues("Rejected", req.Currency, unknownAcquirer).Inc()
+		return nil, rejection(err)
+	}
+
+	dup := h.checkDuplicate(merchant, &req, paymentID, fingerprint)
+	if dup.found {
+		metrics.DuplicatePaymentsTotal.WithLabelValues(string(dup.action)).Inc()
+		if dup.action == DuplicateReject {
+			logger.InfoContext(ctx, "rejected duplicate payment", "duplicate_of", dup.match)
+			metrics.PaymentsTotal.WithLabelValues("Rejected", req.Currency, unknownAcquirer).Inc()
+			return nil, problem.New(problem.CodeDuplicatePayment,
+				fmt.Sprintf("duplicate of payment %s: same card, amount and currency within %s", dup.match, dup.window)).
+				WithPaymentStatus("Rejected")
+		}
+		logger.WarnContext(ctx, "possible duplicate payment", "duplicate_of", dup.match)
 	}
+
+	req.Reference = paymentID
+	// Cardholders are only authenticated by the gateway.
+	req.Authentication = nil
+	payment := newPayment(&req, paymentID, fingerprint)
+	payment.Merchant = merchant
+	if dup.found {
+		payment.DuplicateOf = dup.match
+	}
+	return &prepared{req: req, payment: payment, merchant: merchant, dup: dup}, nil
 }
 
-func (h *PaymentsHandler) respondWithError(w http.ResponseWriter, code int, msg string, status string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error_message":  msg,
-		"payment_status": status,
+// authorize sends a prepared payment to the acquirer and stores the outcome.
+// Payments the acquirer did not answer are not stored; the problem returned
+// says why. Payments the issuer declines for lack of authentication are
+// authenticated and sent again.
+//
+// The acquirer may authorize the payment once it is sent, so authorize is
+// not cancelled with ctx and stores the outcome even when the client has
+// gone. The bank client bounds the call by its timeout.
+func (h *PaymentsHandler) authorize(ctx context.Context, p *prepared) (*Payment, *problem.Problem) {
+	ctx = context.WithoutCancel(ctx)
+	logger := logging.FromContext(ctx)
+
+	bankResponse, err := h.bankClient.ProcessPayment(ctx, &p.req)
+	if errors.Is(err, ErrBankOverloaded) {
+		p.dup.release()
+		logger.WarnContext(ctx, "bank authorization refused, too many calls in flight")
+		metrics.PaymentsTotal.WithLabelValues("Failed", p.req.Currency, unknownAcquirer).Inc()
+		return nil, problem.New(problem.CodeUpstreamOverloaded, "Financial institution is at capacity, retry shortly").
+			WithPaymentStatus("Failed")
+	}
+	if err != nil {
+		p.dup.release()
+		logger.ErrorContext(ctx, "bank authorization failed", "error", err)
+		metrics.PaymentsTotal.WithLabelValues("Failed", p.req.Currency, unknownAcquirer).Inc()
+		return nil, problem.New(problem.CodeUpstreamUnavailable, "Financial institution unavailable").
+			WithPaymentStatus("Failed")
+	}
+
+	if h.stepUp(p, bankResponse) {
+		logger.InfoContext(ctx, "issuer requires 3-D Secure authentication", "payment_id", p.payment.Id)
+		if payment, prob := h.authenticate(ctx, p); payment != nil || prob != nil {
+			return payment, prob
+		}
+		return h.authorize(ctx, p)
+	}
+
+	payment := p.payment
+	settle(&payment, bankResponse)
+	if !bankResponse.Authorized {
+		p.dup.release()
+	}
+	h.save(ctx, p, payment)
+	h.recordProcessed(ctx, payment, bankResponse.Acquirer)
+	return &payment, nil
+}
+
+// save stores payment, the outcome of p, in place of the payment stored
+// before it, if any.
+func (h *PaymentsHandler) save(ctx context.Context, p *prepared, payment Payment) {
+	if p.stored {
+		h.storage.UpdatePayment(payment)
+		return
+	}
+	h.store(ctx, payment)
+	p.stored = true
+}
+
+// fail records that a payment stored before its outcome failed with code,
+// and returns it.
+func (h *PaymentsHandler) fail(p *prepared, code problem.Code) Payment {
+	p.dup.release()
+	payment := p.payment
+	payment.PaymentStatus = "Failed"
+	payment.FailureCode = string(code)
+	if payment.ThreeDS != nil && payment.ThreeDS.ChallengeURL != "" {
+		auth := *payment.ThreeDS
+		auth.ChallengeURL = ""
+		payment.ThreeDS = &auth
+	}
+	h.storage.UpdatePayment(payment)
+	return payment
+}
+
+var (
+	// errCardTokenLookup marks vault failures other than an unknown token.
+	errCardTokenLookup    = errors.New("card token lookup failed")
+	errCardTokensDisabled = errors.New("card tokens are not enabled")
+	errUnknownCardToken   = errors.New("card_token not found")
+	// errCustomerLookup marks CustomerDirectory failures other than unknown
+	// customers and payment methods.
+	errCustomerLookup    = errors.New("customer lookup failed")
+	errCustomersDisabled = errors.New("customers are not enabled")
+)
+
+// rejection returns the problem answering a request rejected with err.
+func rejection(err error) *problem.Problem {
+	var p *problem.Problem
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		p = problem.New(problem.CodeValidationFailed, invalid.Message).
+			WithErrors(problem.FieldError{Field: invalid.Field, Message: invalid.Message})
+	case errors.Is(err, errCardTokensDisabled):
+		p = problem.New(problem.CodeCardTokensDisabled, err.Error())
+	case errors.Is(err, errUnknownCardToken):
+		p = problem.New(problem.CodeCardTokenNotFound, err.Error())
+	default:
+		p = problem.New(problem.CodeValidationFailed, err.Error())
+	}
+	return p.WithPaymentStatus("Rejected")
+}
+
+// resolveCustomer sets req.CardToken to the token of the payment method of
+// the merchant's customer, and req.PaymentMethodID to the method used. The
+// customer's currency is used when req has none.
+func (h *PaymentsHandler) resolveCustomer(ctx context.Context, merchant string, req *PostPaymentRequest) error {
+	if req.CustomerID == "" {
+		if req.PaymentMethodID != "" {
+			return invalid("payment_method_id", "payment_method_id requires customer_id")
+		}
+		return nil
+	}
+	if req.CardNumber != "" || req.CardToken != "" {
+		return invalid("customer_id", "provide either customer_id or a card, not both")
+	}
+	if h.customers == nil {
+		return errCustomersDisabled
+	}
+
+	method, err := h.customers.PaymentMethod(ctx, merchant, req.CustomerID, req.PaymentMethodID)
+	switch {
+	case errors.Is(err, ErrCustomerNotFound):
+		return invalid("customer_id", "customer_id does not match any customer")
+	case errors.Is(err, ErrPaymentMethodNotFound):
+		return invalid("payment_method_id", "payment_method_id does not match any payment method of the customer")
+	case errors.Is(err, ErrNoDefaultPaymentMethod):
+		return invalid("payment_method_id", "the customer has no default payment method; send payment_method_id")
+	case err != nil:
+		return fmt.Errorf("%w: %w", errCustomerLookup, err)
+	}
+
+	req.CardToken = method.CardToken
+	req.PaymentMethodID = method.ID
+	if req.Currency == "" {
+		req.Currency = method.Currency
+	}
+	return nil
+}
+
+// resolveCardToken replaces req.CardToken with the card data held by the
+// vault. Expiry fields sent with the token take precedence over the stored
+// ones so that reissued cards keep working.
+func (h *PaymentsHandler) resolveCardToken(ctx context.Context, req *PostPaymentRequest) error {
+	if req.CardToken == "" {
+		return nil
+	}
+	if req.CardNumber != "" {
+		return invalid("card_token", "provide either card_number or card_token, not both")
+	}
+	if h.vault == nil {
+		return errCardTokensDisabled
+	}
+
+	card, err := h.vault.Detokenize(ctx, req.CardToken)
+	if errors.Is(err, ErrCardTokenNotFound) {
+		return errUnknownCardToken
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errCardTokenLookup, err)
+	}
+
+	req.CardNumber = card.CardNumber
+	if req.ExpiryMonth == 0 && req.ExpiryYear == 0 {
+		req.ExpiryMonth = card.ExpiryMonth
+		req.ExpiryYear = card.ExpiryYear
+	}
+	return nil
+}
+
+// checkMerchantInitiated admits a merchant-initiated req, which is sent
+// without a CVV and is never authenticated, only for the card, identified
+// by fingerprint, of an authorized customer-initiated initial payment the
+// merchant made under the network transaction ID req names. Card tokens are
+// held to the same rule: the vault issues them for any card number.
+func (h *PaymentsHandler) checkMerchantInitiated(merchant string, req *PostPaymentRequest, fingerprint string) error {
+	if !req.StoredCredential.MerchantInitiated() {
+		return nil
+	}
+	initial := h.storage.InitialPayment(req.StoredCredential.NetworkTransactionID)
+	if initial != nil && initial.PaymentStatus == "Authorized" && initial.Merchant == merchant &&
+		initial.StoredCredential.Initiator == InitiatorCustomer &&
+		fingerprint != "" && initial.CardFingerprint == fingerprint {
+		return nil
+	}
+	return invalid("stored_credential.network_transaction_id",
+		"network_transaction_id does not match an authorized initial payment of the card by this merchant")
+}
+
+// duplicateCheck is the outcome of checkDuplicate.
+type duplicateCheck struct {
+	// found reports an earlier identical payment, identified by match.
+	found  bool
+	match  string
+	action DuplicateAction
+	window time.Duration
+	// release drops the reservation made for this payment so that it can be
+	// retried. It does nothing when no reservation was made.
+	release func()
+	// key is the reserved key, nil when no reservation was made.
+	key *DuplicateKey
+}
+
+// checkDuplicate looks for an identical payment within the merchant's
+// duplicate window. When there is none, paymentID is reserved so that the
+// following identical payments are flagged until it is released.
+func (h *PaymentsHandler) checkDuplicate(merchant string, req *PostPaymentRequest, paymentID, fingerprint string) duplicateCheck {
+	check := duplicateCheck{release: func() {}}
+	window, action := h.rules.Load().duplicatePolicy(merchant)
+	if fingerprint == "" || window <= 0 {
+		return check
+	}
+
+	key := DuplicateKey{Merchant: merchant, Fingerprint: fingerprint, Currency: req.Currency, Amount: req.Amount}
+	check.match, check.found = h.duplicates.Reserve(key, paymentID, window, time.Now())
+	check.action = action
+	check.window = window
+	if !check.found {
+		check.release = func() { h.duplicates.Release(key, paymentID) }
+		check.key = &key
+	}
+	return check
+}
+
+// validate runs req.Validate inside its own span.
+func (h *PaymentsHandler) validate(ctx context.Context, req *PostPaymentRequest) error {
+	_, span := tracing.Tracer().Start(ctx, "payments.Validate")
+	defer span.End()
+
+	err := req.ValidateWith(h.rules.Load())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// store writes the payment to the repository inside its own span.
+func (h *PaymentsHandler) store(ctx context.Context, payment Payment) {
+	_, span := tracing.Tracer().Start(ctx, "payments.repository.AddPayment",
+		trace.WithAttributes(attribute.String("payment.id", payment.Id)))
+	defer span.End()
+
+	h.storage.AddPayment(payment)
+}
+
+// newPayment returns the payment record of req, without an outcome.
+func newPayment(req *PostPaymentRequest, id, fingerprint string) Payment {
+	return Payment{
+		Id:                 id,
+		CardNumberLastFour: req.CardNumber[len(req.CardNumber)-4:],
+		CardFingerprint:    fingerprint,
+		ExpiryMonth:        req.ExpiryMonth,
+		ExpiryYear:         req.ExpiryYear,
+		Currency:           req.Currency,
+		Amount:             req.Amount,
+		StoredCredential:   req.StoredCredential,
+		CustomerID:         req.CustomerID,
+		PaymentMethodID:    req.PaymentMethodID,
+	}
+}
+
+// settle records the acquirer's answer in payment.
+func settle(payment *Payment, auth *BankAuthorization) {
+	payment.NetworkTransactionID = auth.NetworkTransactionID
+	if auth.Authorized {
+		payment.PaymentStatus = "Authorized"
+		return
+	}
+	payment.PaymentStatus = "Declined"
+	payment.DeclineCode = auth.DeclineCode
+	if payment.DeclineCode == "" {
+		payment.DeclineCode = DeclineDoNotHonor
+	}
+	payment.DeclineType = payment.DeclineCode.Type()
+	metrics.DeclinesTotal.WithLabelValues(auth.Acquirer,
+		string(payment.DeclineCode), string(payment.DeclineType)).Inc()
+}
+
+// recordProcessed audits, counts and logs a payment the acquirer answered.
+func (h *PaymentsHandler) recordProcessed(ctx context.Context, payment Payment, acquirer string) {
+	h.auditLog.Record(ctx, audit.ActionPaymentCreated, "payment:"+payment.Id, nil, map[string]any{
+		"payment_status":        payment.PaymentStatus,
+		"amount":                payment.Amount,
+		"currency":              payment.Currency,
+		"card_number_last_four": payment.CardNumberLastFour,
+		"card_fingerprint":      payment.CardFingerprint,
+		"acquirer":              acquirer,
+		"decline_code":          payment.DeclineCode,
+		"duplicate_of":          payment.DuplicateOf,
+		"three_ds_status":       threeDSStatus(payment),
 	})
+	metrics.PaymentsTotal.WithLabelValues(payment.PaymentStatus, payment.Currency, acquirer).Inc()
+	logging.FromContext(ctx).InfoContext(ctx, "payment processed",
+		"payment_id", payment.Id,
+		"payment_status", payment.PaymentStatus,
+		"decline_code", payment.DeclineCode,
+		"card_number_last_four", payment.CardNumberLastFour,
+		"currency", payment.Currency,
+		"amount", payment.Amount,
+	)
+}
+
+// threeDSStatus returns the status of the authentication of payment, empty
+// when it was not authenticated.
+func threeDSStatus(payment Payment) AuthenticationStatus {
+	if payment.ThreeDS == nil {
+		return ""
+	}
+	return payment.ThreeDS.Status
+}
+
+// unknownAcquirer labels payments that never got an answer from an acquirer.
+const unknownAcquirer = "unknown"
+
+// currencyLabel bounds the cardinality of the currency label: anything that
+// is not an allowed currency is reported as "other".
+func (h *PaymentsHandler) currencyLabel(currency string) string {
+	if h.rules.Load().AllowsCurrency(currency) {
+		return currency
+	}
+	return "other"
 }
@@ -2,25 +2,48 @@ package payments_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+// withV1 serves next with the codec of API v1, as the router does.
+func withV1(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(wire.With[payments.Codec](r.Context(), v1.Codec{})))
+	})
+}
+
 type MockBankGateway struct{}
 
-func (m *MockBankGateway) ProcessPayment(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+func (m *MockBankGateway) ProcessPayment(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
 	return &payments.BankAuthorization{}, nil
 }
 
 func TestGetPaymentHandler(t *testing.T) {
-	payment := payments.PostPaymentResponse{
+	payment := payments.Payment{
 		Id:                 "test-id",
 		PaymentStatus:      "test-successful-status",
 		CardNumberLastFour: "1234",
@@ -35,6 +58,7 @@ func TestGetPaymentHandler(t *testing.T) {
 	handler := payments.NewPaymentsHandler(ps, &MockBankGateway{})
 
 	r := chi.NewRouter()
+	r.Use(withV1)
 	r.Get("/api/payments/{id}", handler.GetHandler())
 
 	httpServer := &http.Server{
@@ -61,6 +85,13 @@ func TestGetPaymentHandler(t *testing.T) {
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+		var body problem.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, problem.CodePaymentNotFound, body.Code)
+		assert.Equal(t, "urn:payment-gateway:problem:payment_not_found", body.Type)
+		assert.Equal(t, "/api/payments/NonExistingID", body.Instance)
 	})
 }
 
@@ -68,7 +99,7 @@ type ConfigurableBankGateway struct {
 	ProcessPaymentFunc func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error)
 }
 
-func (m *ConfigurableBankGateway) ProcessPayment(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+func (m *ConfigurableBankGateway) ProcessPayment(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
 	if m.ProcessPaymentFunc != nil {
 		return m.ProcessPaymentFunc(req)
 	}
@@ -108,6 +139,7 @@ func TestPostPaymentHandler(t *testing.T) {
 				"card_number_last_four": "3456",
 				"amount":                float64(1000),
 				"currency":              "USD",
+				"decline_code":          nil,
 			},
 		},
 		{
@@ -118,12 +150,28 @@ func TestPostPaymentHandler(t *testing.T) {
 					Authorized:        false,
 					AuthorizationCode: "",
 					ErrorMessage:      "Insufficient funds",
+					DeclineCode:       payments.DeclineInsufficientFunds,
 				}, nil
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: map[string]interface{}{
 				"payment_status":        "Declined",
 				"card_number_last_four": "3456",
+				"decline_code":          "insufficient_funds",
+				"decline_type":          "soft",
+			},
+		},
+		{
+			name:        "Success: Payment Declined Without a Reason",
+			requestBody: validReq,
+			mockBankFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+				return &payments.BankAuthorization{Authorized: false}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"payment_status": "Declined",
+				"decline_code":   "do_not_honor",
+				"decline_type":   "soft",
 			},
 		},
 		{
@@ -140,7 +188,8 @@ func TestPostPaymentHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
 				"payment_status": "Rejected",
-				"error_message":  "currency not supported",
+				"code":           "validation_failed",
+				"detail":         "currency not supported",
 			},
 		},
 		{
@@ -150,7 +199,8 @@ func TestPostPaymentHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
 				"payment_status": "Rejected",
-				"error_message":  "Invalid request body format",
+				"code":           "malformed_request",
+				"detail":         "Invalid request body format",
 			},
 		},
 		{
@@ -162,7 +212,20 @@ func TestPostPaymentHandler(t *testing.T) {
 			expectedStatus: http.StatusBadGateway,
 			expectedBody: map[string]interface{}{
 				"payment_status": "Failed",
-				"error_message":  "Financial institution unavailable",
+				"code":           "upstream_unavailable",
+				"detail":         "Financial institution unavailable",
+			},
+		},
+		{
+			name:        "Failure: Bank Overloaded",
+			requestBody: validReq,
+			mockBankFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+				return nil, payments.ErrBankOverloaded
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody: map[string]interface{}{
+				"payment_status": "Failed",
+				"code":           "upstream_overloaded",
 			},
 		},
 	}
@@ -185,7 +248,7 @@ func TestPostPaymentHandler(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 
 			w := httptest.NewRecorder()
-			handler.PostHandler().ServeHTTP(w, req)
+			withV1(handler.PostHandler()).ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
@@ -209,3 +272,593 @@ func TestPostPaymentHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestPostPaymentHandler_DoesNotLogCardData(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logging.New(&logs, slog.LevelDebug)
+
+	mockBank := &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			return nil, fmt.Errorf("bank rejected request (400): map[card_number:%s cvv:%s]", req.CardNumber, req.Cvv)
+		},
+	}
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), mockBank)
+
+	body := `{"card_number":"4111111111111111","expiry_month":12,"expiry_year":2030,"currency":"USD","amount":100,"cvv":"987"}`
+	req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+	req = req.WithContext(logging.WithLogger(req.Context(), logger))
+
+	w := httptest.NewRecorder()
+	withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, logs.String(), "bank authorization failed")
+	assert.NotContains(t, logs.String(), "4111111111111111")
+	assert.NotContains(t, logs.String(), "987")
+}
+
+// cancellingBankGateway cancels the request, as a merchant disconnecting
+// mid-call would, and authorizes the payment.
+type cancellingBankGateway struct {
+	cancel context.CancelFunc
+	err    error
+}
+
+func (m *cancellingBankGateway) ProcessPayment(ctx context.Context, req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+	m.cancel()
+	m.err = ctx.Err()
+	return &payments.BankAuthorization{Authorized: true}, nil
+}
+
+func TestPostPaymentHandler_ClientGone(t *testing.T) {
+	repo := payments.NewPaymentsRepository()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockBank := &cancellingBankGateway{cancel: cancel}
+	handler := payments.NewPaymentsHandler(repo, mockBank)
+
+	body := `{"card_number":"1234567890123456","expiry_month":12,"expiry_year":2030,"currency":"EUR","amount":100,"cvv":"123"}`
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/api/payments", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mockBank.err, "the bank call is not cancelled with the request")
+	var payment payments.Payment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &payment))
+	stored := repo.GetPayment(payment.Id)
+	require.NotNil(t, stored, "the outcome is stored")
+	assert.Equal(t, "Authorized", stored.PaymentStatus)
+}
+
+func TestPostPaymentHandler_Metrics(t *testing.T) {
+	mockBank := &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			return &payments.BankAuthorization{Authorized: true, Acquirer: "handler-metrics"}, nil
+		},
+	}
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), mockBank)
+
+	body := `{"card_number":"1234567890123456","expiry_month":12,"expiry_year":2030,"currency":"EUR","amount":100,"cvv":"123"}`
+	req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.PaymentsTotal.WithLabelValues("Authorized", "EUR", "handler-metrics")))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(metrics.RepositoryPayments), float64(1))
+}
+
+func TestPostPaymentHandler_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), &MockBankGateway{})
+
+	body := `{"card_number":"1234567890123456","expiry_month":12,"expiry_year":2030,"currency":"USD","amount":100,"cvv":"123"}`
+	req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, []string{"payments.Validate", "payments.repository.AddPayment"}, names)
+}
+
+type MockCardVault struct {
+	cards map[string]payments.VaultCard
+	err   error
+}
+
+func (m *MockCardVault) Detokenize(ctx context.Context, token string) (*payments.VaultCard, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	card, ok := m.cards[token]
+	if !ok {
+		return nil, payments.ErrCardTokenNotFound
+	}
+	return &card, nil
+}
+
+func TestPostPaymentHandler_CardToken(t *testing.T) {
+	cardVault := &MockCardVault{cards: map[string]payments.VaultCard{
+		"tok_valid": {CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030},
+	}}
+
+	tests := []struct {
+		name           string
+		vault          payments.CardVault
+		body           string
+		expectedStatus int
+		expectedBody   map[string]interface{}
+		expectedCard   *payments.PostPaymentRequest
+	}{
+		{
+			name:           "Token resolves to the stored card",
+			vault:          cardVault,
+			body:           `{"card_token":"tok_valid","currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"payment_status":        "Declined",
+				"card_number_last_four": "8877",
+				"expiry_month":          float64(4),
+				"expiry_year":           float64(2030),
+			},
+			expectedCard: &payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030},
+		},
+		{
+			name:           "Expiry sent with the token takes precedence",
+			vault:          cardVault,
+			body:           `{"card_token":"tok_valid","expiry_month":6,"expiry_year":2031,"currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusOK,
+			expectedCard:   &payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 6, ExpiryYear: 2031},
+		},
+		{
+			name:           "Token still requires a CVV",
+			vault:          cardVault,
+			body:           `{"card_token":"tok_valid","currency":"USD","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "cvv is required"},
+		},
+		{
+			name:           "Unknown token",
+			vault:          cardVault,
+			body:           `{"card_token":"tok_missing","currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "card_token_not_found", "detail": "card_token not found", "payment_status": "Rejected"},
+		},
+		{
+			name:           "Token and card number together",
+			vault:          cardVault,
+			body:           `{"card_token":"tok_valid","card_number":"2222405343248877","currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "provide either card_number or card_token, not both"},
+		},
+		{
+			name:           "Vault disabled",
+			body:           `{"card_token":"tok_valid","currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "card_tokens_disabled", "detail": "card tokens are not enabled"},
+		},
+		{
+			name:           "Vault failure",
+			vault:          &MockCardVault{err: errors.New("unwrap failed")},
+			body:           `{"card_token":"tok_valid","currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   map[string]interface{}{"code": "internal_error", "payment_status": "Failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *payments.PostPaymentRequest
+			mockBank := &ConfigurableBankGateway{
+				ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+					sent = req
+					return &payments.BankAuthorization{}, nil
+				},
+			}
+			handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), mockBank)
+			if tt.vault != nil {
+				handler.SetCardVault(tt.vault)
+			}
+
+			req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			var respBody map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &respBody))
+			for key, expectedValue := range tt.expectedBody {
+				assert.Equal(t, expectedValue, respBody[key], "Field %s mismatch", key)
+			}
+
+			if tt.expectedCard == nil {
+				assert.Nil(t, sent, "the bank must not be called")
+				return
+			}
+			assert.Equal(t, tt.expectedCard.CardNumber, sent.CardNumber)
+			assert.Equal(t, tt.expectedCard.ExpiryMonth, sent.ExpiryMonth)
+			assert.Equal(t, tt.expectedCard.ExpiryYear, sent.ExpiryYear)
+		})
+	}
+}
+
+// MockCustomerDirectory holds the customers of the merchant acme.
+type MockCustomerDirectory struct {
+	methods map[string][]payments.SavedPaymentMethod
+	err     error
+}
+
+// PaymentMethod treats the first method of a customer as its default one.
+func (m *MockCustomerDirectory) PaymentMethod(ctx context.Context, merchant, customerID, methodID string) (*payments.SavedPaymentMethod, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	methods, ok := m.methods[customerID]
+	if !ok || merchant != "acme" {
+		return nil, payments.ErrCustomerNotFound
+	}
+	if len(methods) == 0 && methodID == "" {
+		return nil, payments.ErrNoDefaultPaymentMethod
+	}
+	for _, method := range methods {
+		if methodID == "" || method.ID == methodID {
+			return &method, nil
+		}
+	}
+	return nil, payments.ErrPaymentMethodNotFound
+}
+
+func TestPostPaymentHandler_Customer(t *testing.T) {
+	cardVault := &MockCardVault{cards: map[string]payments.VaultCard{
+		"tok_default": {CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030},
+		"tok_other":   {CardNumber: "2222405343241111", ExpiryMonth: 5, ExpiryYear: 2031},
+	}}
+	directory := &MockCustomerDirectory{methods: map[string][]payments.SavedPaymentMethod{
+		"cus_1": {{ID: "pm_default", CardToken: "tok_default", Currency: "EUR"}, {ID: "pm_other", CardToken: "tok_other", Currency: "EUR"}},
+		"cus_2": {},
+	}}
+
+	tests := []struct {
+		name           string
+		directory      payments.CustomerDirectory
+		merchant       string
+		body           string
+		expectedStatus int
+		expectedBody   map[string]interface{}
+		expectedCard   string
+	}{
+		{
+			name:           "Default payment method and currency of the customer",
+			directory:      directory,
+			body:           `{"customer_id":"cus_1","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"customer_id":           "cus_1",
+				"payment_method_id":     "pm_default",
+				"card_number_last_four": "8877",
+				"currency":              "EUR",
+			},
+			expectedCard: "2222405343248877",
+		},
+		{
+			name:           "Chosen payment method and currency",
+			directory:      directory,
+			body:           `{"customer_id":"cus_1","payment_method_id":"pm_other","currency":"USD","amount":100,"cvv":"123"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]interface{}{"payment_method_id": "pm_other", "card_number_last_four": "1111", "currency": "USD"},
+			expectedCard:   "2222405343241111",
+		},
+		{
+			name:           "Saved payment method without a CVV",
+			directory:      directory,
+			body:           `{"customer_id":"cus_1","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "cvv is required", "payment_status": "Rejected"},
+		},
+		{
+			name:           "Unknown customer",
+			directory:      directory,
+			body:           `{"customer_id":"cus_missing","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "customer_id does not match any customer", "payment_status": "Rejected"},
+		},
+		{
+			name:           "Customer of another merchant",
+			directory:      directory,
+			merchant:       "hooli",
+			body:           `{"customer_id":"cus_1","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "customer_id does not match any customer", "payment_status": "Rejected"},
+		},
+		{
+			name:           "Unknown payment method",
+			directory:      directory,
+			body:           `{"customer_id":"cus_1","payment_method_id":"pm_missing","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "payment_method_id does not match any payment method of the customer"},
+		},
+		{
+			name:           "Customer without default payment method",
+			directory:      directory,
+			body:           `{"customer_id":"cus_2","currency":"USD","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "the customer has no default payment method; send payment_method_id"},
+		},
+		{
+			name:           "Customer and card together",
+			directory:      directory,
+			body:           `{"customer_id":"cus_1","card_token":"tok_other","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "provide either customer_id or a card, not both"},
+		},
+		{
+			name:           "Payment method without customer",
+			directory:      directory,
+			body:           `{"payment_method_id":"pm_default","currency":"USD","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "payment_method_id requires customer_id"},
+		},
+		{
+			name:           "Customers disabled",
+			body:           `{"customer_id":"cus_1","amount":100}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "customers are not enabled"},
+		},
+		{
+			name:           "Directory failure",
+			directory:      &MockCustomerDirectory{err: errors.New("store unavailable")},
+			body:           `{"customer_id":"cus_1","amount":100}`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   map[string]interface{}{"code": "internal_error", "payment_status": "Failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *payments.PostPaymentRequest
+			mockBank := &ConfigurableBankGateway{
+				ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+					sent = req
+					return &payments.BankAuthorization{}, nil
+				},
+			}
+			repo := payments.NewPaymentsRepository()
+			handler := payments.NewPaymentsHandler(repo, mockBank)
+			handler.SetCardVault(cardVault)
+			if tt.directory != nil {
+				handler.SetCustomerDirectory(tt.directory)
+			}
+
+			req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(tt.body))
+			merchant := "acme"
+			if tt.merchant != "" {
+				merchant = tt.merchant
+			}
+			req.Header.Set(payments.MerchantIDHeader, merchant)
+			w := httptest.NewRecorder()
+			withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			var respBody map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &respBody))
+			for key, expectedValue := range tt.expectedBody {
+				assert.Equal(t, expectedValue, respBody[key], "Field %s mismatch", key)
+			}
+
+			if tt.expectedCard == "" {
+				assert.Nil(t, sent, "the bank must not be called")
+				return
+			}
+			assert.Equal(t, tt.expectedCard, sent.CardNumber)
+			assert.Equal(t, "123", sent.Cvv, "saved payment methods are paid with a CVV")
+			assert.Len(t, repo.CustomerPayments("cus_1", 10), 1)
+		})
+	}
+}
+
+func TestPostPaymentHandler_Duplicates(t *testing.T) {
+	const body = `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2030,"currency":"USD","amount":100,"cvv":"123"}`
+
+	post := func(handler *payments.PaymentsHandler, merchant, body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+		req.Header.Set(payments.MerchantIDHeader, merchant)
+		w := httptest.NewRecorder()
+		withV1(handler.PostHandler()).ServeHTTP(w, req)
+		var respBody map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &respBody))
+		return w.Code, respBody
+	}
+
+	authorized := true
+	bank := &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			return &payments.BankAuthorization{Authorized: authorized}, nil
+		},
+	}
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), bank)
+	handler.SetCardFingerprinter(payments.NewCardFingerprinter([]byte("test-key")))
+	handler.SetRules(payments.NewRules([]string{"USD"},
+		payments.WithDuplicatePolicy(time.Minute, payments.DuplicateWarn,
+			map[string]payments.DuplicateAction{"strict": payments.DuplicateReject}),
+	))
+
+	t.Run("Fingerprint is returned", func(t *testing.T) {
+		code, first := post(handler, "relaxed", body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotEmpty(t, first["card_fingerprint"])
+		assert.Nil(t, first["duplicate_of"])
+
+		code, other := post(handler, "relaxed", strings.Replace(body, `"amount":100`, `"amount":200`, 1))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, first["card_fingerprint"], other["card_fingerprint"], "same card, same fingerprint")
+		assert.Nil(t, other["duplicate_of"], "a different amount is not a duplicate")
+	})
+
+	t.Run("Warn", func(t *testing.T) {
+		code, first := post(handler, "warn", body)
+		assert.Equal(t, http.StatusOK, code)
+
+		code, second := post(handler, "warn", body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Authorized", second["payment_status"])
+		assert.Equal(t, first["id"], second["duplicate_of"])
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		code, first := post(handler, "strict", body)
+		assert.Equal(t, http.StatusOK, code)
+
+		code, second := post(handler, "strict", body)
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, "Rejected", second["payment_status"])
+		assert.Equal(t, fmt.Sprintf("duplicate of payment %s: same card, amount and currency within 1m0s", first["id"]), second["detail"])
+		assert.Equal(t, "duplicate_payment", second["code"])
+	})
+
+	t.Run("Declined payments can be retried", func(t *testing.T) {
+		authorized = false
+		code, _ := post(handler, "declined", body)
+		assert.Equal(t, http.StatusOK, code)
+
+		authorized = true
+		code, second := post(handler, "declined", body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Nil(t, second["duplicate_of"])
+	})
+}
+
+func TestPaymentsHandler_Submit(t *testing.T) {
+	repo := payments.NewPaymentsRepository()
+	handler := payments.NewPaymentsHandler(repo, &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			return &payments.BankAuthorization{Authorized: true}, nil
+		},
+	})
+	req := payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030, Currency: "USD", Amount: 100, Cvv: "123"}
+
+	payment, p := handler.Submit(context.Background(), "acme", req)
+	require.Nil(t, p)
+	assert.Equal(t, "Authorized", payment.PaymentStatus)
+	assert.Equal(t, payment, repo.GetPayment(payment.Id), "the payment is stored")
+
+	req.Amount = -1
+	payment, p = handler.Submit(context.Background(), "acme", req)
+	assert.Nil(t, payment)
+	require.NotNil(t, p)
+	assert.Equal(t, problem.CodeValidationFailed, p.Code)
+	assert.Equal(t, "Rejected", p.PaymentStatus)
+}
+
+func TestPaymentsHandler_Submit_StoredCredential(t *testing.T) {
+	var sent *payments.PostPaymentRequest
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			sent = req
+			// Odd amounts are declined.
+			return &payments.BankAuthorization{Authorized: req.Amount%2 == 0, NetworkTransactionID: "NTI-" + req.Reference}, nil
+		},
+	})
+	handler.SetCardFingerprinter(payments.NewCardFingerprinter([]byte("key-1")))
+	handler.SetCardVault(&MockCardVault{cards: map[string]payments.VaultCard{
+		"tok_valid": {CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030},
+		"tok_other": {CardNumber: "2222405343241111", ExpiryMonth: 4, ExpiryYear: 2030},
+	}})
+
+	initial := func(merchant string, amount int) string {
+		t.Helper()
+		payment, p := handler.Submit(context.Background(), merchant, payments.PostPaymentRequest{
+			CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030, Currency: "USD", Amount: amount, Cvv: "123",
+			StoredCredential: &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceInitial},
+		})
+		require.Nil(t, p)
+		return payment.NetworkTransactionID
+	}
+	authorized := initial("acme", 100)
+	declined := initial("acme", 101)
+	otherMerchant := initial("hooli", 100)
+
+	tests := []struct {
+		name         string
+		cardNumber   string
+		cardToken    string
+		ntid         string
+		wantAccepted bool
+	}{
+		{"Card of the initial payment", "2222405343248877", "", authorized, true},
+		{"Unknown network transaction ID", "2222405343248877", "", "NTI-unknown", false},
+		{"Another card", "2222405343241111", "", authorized, false},
+		{"Declined initial payment", "2222405343248877", "", declined, false},
+		{"Initial payment of another merchant", "2222405343248877", "", otherMerchant, false},
+		{"Card token of the initial payment's card", "", "tok_valid", authorized, true},
+		{"Card token with an unknown network transaction ID", "", "tok_valid", "NTI-unknown", false},
+		{"Card token of another card", "", "tok_other", authorized, false},
+		{"Card token with the initial payment of another merchant", "", "tok_valid", otherMerchant, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			credential := &payments.StoredCredential{
+				Initiator:            payments.InitiatorMerchant,
+				Sequence:             payments.SequenceSubsequent,
+				Reason:               payments.ReasonRecurring,
+				NetworkTransactionID: tt.ntid,
+			}
+			payment, p := handler.Submit(context.Background(), "acme", payments.PostPaymentRequest{
+				CardNumber: tt.cardNumber, CardToken: tt.cardToken, ExpiryMonth: 4, ExpiryYear: 2030,
+				Currency: "USD", Amount: 200, StoredCredential: credential,
+			})
+
+			if !tt.wantAccepted {
+				require.NotNil(t, p, "a merchant-initiated payment needs the initial payment of the card")
+				assert.Equal(t, problem.CodeValidationFailed, p.Code)
+				require.Len(t, p.Errors, 1)
+				assert.Equal(t, "stored_credential.network_transaction_id", p.Errors[0].Field)
+				assert.Nil(t, sent, "the payment is not sent to the acquirer")
+				return
+			}
+			require.Nil(t, p, "merchant-initiated payments need no CVV")
+			assert.Equal(t, credential, sent.StoredCredential, "the flags are sent to the acquirer")
+			assert.Equal(t, credential, payment.StoredCredential)
+			assert.Equal(t, "NTI-"+payment.Id, payment.NetworkTransactionID)
+		})
+	}
+}
+
+func TestPostPaymentHandler_Audit(t *testing.T) {
+	auditLog, err := audit.Open("")
+	assert.NoError(t, err)
+
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), &MockBankGateway{})
+	handler.SetAuditLog(auditLog)
+
+	for _, body := range []string{
+		`{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2030,"currency":"USD","amount":100,"cvv":"123"}`,
+		`{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2030,"currency":"USD","amount":0,"cvv":"123"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+		req = req.WithContext(audit.WithRequest(req.Context(), audit.Request{Actor: "merchant:acme", RequestID: "req-1"}))
+		withV1(handler.PostHandler()).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	events := auditLog.Query(audit.Filter{})
+	if assert.Len(t, events, 1, "only stored payments are audited") {
+		e := events[0]
+		assert.Equal(t, audit.ActionPaymentCreated, e.Action)
+		assert.Equal(t, "merchant:acme", e.Actor)
+		assert.Equal(t, "req-1", e.RequestID)
+		assert.True(t, strings.HasPrefix(e.Resource, "payment:"))
+		assert.Equal(t, "Declined", e.After["payment_status"])
+		assert.Equal(t, "8877", e.After["card_number_last_four"])
+		assert.NotContains(t, fmt.Sprint(e.After), "2222405343248877")
+	}
+}
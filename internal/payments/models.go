@@ -1,30 +1,77 @@
 package payments
 
+// PostPaymentRequest is the domain payment request. Each API version decodes
+// its own request model into it through a Codec.
 type PostPaymentRequest struct {
-	CardNumber  string `json:"card_number"`
+	CardNumber string `json:"card_number"`
+	// CardToken is a token from POST /api/tokens, used instead of
+	// CardNumber.
+	CardToken   string `json:"card_token,omitempty"`
 	ExpiryMonth int    `json:"expiry_month"`
 	ExpiryYear  int    `json:"expiry_year"`
 	Currency    string `json:"currency"`
 	Amount      int    `json:"amount"`
-	Cvv         string `json:"cvv"`
+	// Cvv is required unless StoredCredential flags a merchant-initiated
+	// payment.
+	Cvv string `json:"cvv"`
+	// CustomerID pays with a saved payment method of the customer instead
+	// of a card: PaymentMethodID, or the customer's default one.
+	CustomerID      string `json:"customer_id,omitempty"`
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+	// StoredCredential flags payments made with, or storing, a card kept on
+	// file. It is nil for one-off payments.
+	StoredCredential *StoredCredential `json:"stored_credential,omitempty"`
+	// ThreeDS holds the merchant's 3-D Secure options.
+	ThreeDS *ThreeDSRequest `json:"three_ds,omitempty"`
+	// Authentication is the 3-D Secure authentication of the cardholder,
+	// obtained by the gateway and forwarded to the acquirer. Clients cannot
+	// set it.
+	Authentication *Authentication `json:"authentication,omitempty"`
+	// Reference identifies the payment to the acquirer. Acquirers with
+	// idempotent references authorize it at most once, however many times
+	// it is sent.
+	Reference string `json:"reference,omitempty"`
 }
 
-type PostPaymentResponse struct {
+// Payment is the domain record of a processed payment, as stored by the
+// repository. Each API version encodes it into its own response model
+// through a Codec.
+type Payment struct {
 	Id                 string `json:"id"`
 	PaymentStatus      string `json:"payment_status"`
 	CardNumberLastFour string `json:"card_number_last_four"`
-	ExpiryMonth        int    `json:"expiry_month"`
-	ExpiryYear         int    `json:"expiry_year"`
-	Currency           string `json:"currency"`
-	Amount             int    `json:"amount"`
-}
-
-type GetPaymentResponse struct {
-	Id                 string `json:"id"`
-	PaymentStatus      string `json:"payment_status"`
-	CardNumberLastFour int    `json:"card_number_last_four"`
-	ExpiryMonth        int    `json:"expiry_month"`
-	ExpiryYear         int    `json:"expiry_year"`
-	Currency           string `json:"currency"`
-	Amount             int    `json:"amount"`
+	// CardFingerprint is the same for every payment made with the same
+	// card, without revealing the card number.
+	CardFingerprint string `json:"card_fingerprint,omitempty"`
+	ExpiryMonth     int    `json:"expiry_month"`
+	ExpiryYear      int    `json:"expiry_year"`
+	Currency        string `json:"currency"`
+	Amount          int    `json:"amount"`
+	// DeclineCode is the normalized reason a Declined payment was declined.
+	DeclineCode DeclineCode `json:"decline_code,omitempty"`
+	// DeclineType is soft when retrying the payment later may succeed and
+	// hard when it will be declined again.
+	DeclineType DeclineType `json:"decline_type,omitempty"`
+	// DuplicateOf is the ID of an earlier payment with the same card,
+	// amount and currency, when the merchant's duplicate action is warn.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// FailureCode is the problem code of a payment that Failed while
+	// processed asynchronously.
+	FailureCode string `json:"failure_code,omitempty"`
+	// StoredCredential is the stored credential flags of the request.
+	StoredCredential *StoredCredential `json:"stored_credential,omitempty"`
+	// NetworkTransactionID is the scheme's identifier of the payment, as
+	// returned by the acquirer. Merchant-initiated payments send the one of
+	// the initial payment of their agreement.
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
+	// CustomerID and PaymentMethodID identify the saved payment method
+	// paid with.
+	CustomerID      string `json:"customer_id,omitempty"`
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+	// Merchant is the merchant the payment was made for.
+	Merchant string `json:"merchant,omitempty"`
+	// ThreeDS is the 3-D Secure authentication of the cardholder. A
+	// RequiresAction payment holds the challenge the cardholder must
+	// complete.
+	ThreeDS *Authentication `json:"three_ds,omitempty"`
 }
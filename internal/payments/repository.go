@@ -1,19 +1,45 @@
 package payments
 
+import (
+	"context"
+	"slices"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+)
+
 type getPaymentRequest struct {
 	id       string
-	respChan chan *PostPaymentResponse
+	respChan chan *Payment
+}
+
+type initialPaymentRequest struct {
+	networkTransactionID string
+	respChan             chan *Payment
+}
+
+type customerPaymentsRequest struct {
+	customerID string
+	limit      int
+	respChan   chan []Payment
 }
 
 type PaymentsRepository struct {
-	addChan chan PostPaymentResponse
-	getChan chan getPaymentRequest
+	addChan        chan Payment
+	updateChan     chan Payment
+	getChan        chan getPaymentRequest
+	byCustomerChan chan customerPaymentsRequest
+	initialChan    chan initialPaymentRequest
+	pingChan       chan chan struct{}
 }
 
 func NewPaymentsRepository() *PaymentsRepository {
 	repo := &PaymentsRepository{
-		addChan: make(chan PostPaymentResponse),
-		getChan: make(chan getPaymentRequest),
+		addChan:        make(chan Payment),
+		updateChan:     make(chan Payment),
+		getChan:        make(chan getPaymentRequest),
+		byCustomerChan: make(chan customerPaymentsRequest),
+		initialChan:    make(chan initialPaymentRequest),
+		pingChan:       make(chan chan struct{}),
 	}
 
 	go repo.monitor()
@@ -22,15 +48,25 @@ func NewPaymentsRepository() *PaymentsRepository {
 }
 
 func (ps *PaymentsRepository) monitor() {
-	var paymentsList []PostPaymentResponse
+	var paymentsList []Payment
 
 	for {
 		select {
 		case p := <-ps.addChan:
 			paymentsList = append(paymentsList, p)
+			metrics.RepositoryPayments.Set(float64(len(paymentsList)))
+
+		case p := <-ps.updateChan:
+			i := slices.IndexFunc(paymentsList, func(existing Payment) bool { return existing.Id == p.Id })
+			if i < 0 {
+				paymentsList = append(paymentsList, p)
+				metrics.RepositoryPayments.Set(float64(len(paymentsList)))
+			} else {
+				paymentsList[i] = p
+			}
 
 		case req := <-ps.getChan:
-			var found *PostPaymentResponse
+			var found *Payment
 			for i := range paymentsList {
 				if paymentsList[i].Id == req.id {
 					clone := paymentsList[i]
@@ -39,12 +75,37 @@ func (ps *PaymentsRepository) monitor() {
 				}
 			}
 			req.respChan <- found
+
+		case req := <-ps.byCustomerChan:
+			found := []Payment{}
+			for i := len(paymentsList) - 1; i >= 0 && len(found) < req.limit; i-- {
+				if paymentsList[i].CustomerID == req.customerID {
+					found = append(found, paymentsList[i])
+				}
+			}
+			req.respChan <- found
+
+		case req := <-ps.initialChan:
+			var found *Payment
+			for i := range paymentsList {
+				p := &paymentsList[i]
+				if p.NetworkTransactionID == req.networkTransactionID &&
+					p.StoredCredential != nil && p.StoredCredential.Sequence == SequenceInitial {
+					clone := *p
+					found = &clone
+					break
+				}
+			}
+			req.respChan <- found
+
+		case pong := <-ps.pingChan:
+			close(pong)
 		}
 	}
 }
 
-func (ps *PaymentsRepository) GetPayment(id string) *PostPaymentResponse {
-	respChan := make(chan *PostPaymentResponse)
+func (ps *PaymentsRepository) GetPayment(id string) *Payment {
+	respChan := make(chan *Payment)
 
 	ps.getChan <- getPaymentRequest{
 		id:       id,
@@ -54,6 +115,47 @@ func (ps *PaymentsRepository) GetPayment(id string) *PostPaymentResponse {
 	return <-respChan
 }
 
-func (ps *PaymentsRepository) AddPayment(payment PostPaymentResponse) {
+// CustomerPayments returns up to limit payments of a customer, newest
+// first.
+func (ps *PaymentsRepository) CustomerPayments(customerID string, limit int) []Payment {
+	respChan := make(chan []Payment)
+	ps.byCustomerChan <- customerPaymentsRequest{customerID: customerID, limit: limit, respChan: respChan}
+	return <-respChan
+}
+
+// InitialPayment returns the initial payment of a stored credential with
+// the network transaction ID the acquirer returned for it, or nil.
+func (ps *PaymentsRepository) InitialPayment(networkTransactionID string) *Payment {
+	respChan := make(chan *Payment)
+	ps.initialChan <- initialPaymentRequest{networkTransactionID: networkTransactionID, respChan: respChan}
+	return <-respChan
+}
+
+func (ps *PaymentsRepository) AddPayment(payment Payment) {
 	ps.addChan <- payment
 }
+
+// UpdatePayment replaces the payment with the same ID, or adds it when there
+// is none.
+func (ps *PaymentsRepository) UpdatePayment(payment Payment) {
+	ps.updateChan <- payment
+}
+
+// Ping round-trips through the monitor goroutine and returns an error if it
+// does not answer before ctx is done, which means the monitor is wedged.
+func (ps *PaymentsRepository) Ping(ctx context.Context) error {
+	pong := make(chan struct{})
+
+	select {
+	case ps.pingChan <- pong:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-pong:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,6 +1,8 @@
 package payments_test
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -13,8 +15,8 @@ import (
 func TestAddAndGetPayment(t *testing.T) {
 	repo := payments.NewPaymentsRepository()
 	id := uuid.New().String()
-	
-	inputPayment := payments.PostPaymentResponse{
+
+	inputPayment := payments.Payment{
 		Id:                 id,
 		PaymentStatus:      "Authorized",
 		CardNumberLastFour: "1234",
@@ -44,6 +46,26 @@ func TestGetPaymentNotFound(t *testing.T) {
 	assert.Nil(t, result)
 }
 
+func TestCustomerPayments(t *testing.T) {
+	repo := payments.NewPaymentsRepository()
+	for i, customerID := range []string{"cus_1", "cus_2", "cus_1", "cus_1", ""} {
+		repo.AddPayment(payments.Payment{Id: fmt.Sprintf("pay-%d", i), CustomerID: customerID})
+	}
+
+	ids := func(p []payments.Payment) []string {
+		ids := []string{}
+		for _, payment := range p {
+			ids = append(ids, payment.Id)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"pay-3", "pay-2", "pay-0"}, ids(repo.CustomerPayments("cus_1", 10)), "newest first")
+	assert.Equal(t, []string{"pay-3", "pay-2"}, ids(repo.CustomerPayments("cus_1", 2)))
+	assert.Equal(t, []string{"pay-1"}, ids(repo.CustomerPayments("cus_2", 10)))
+	assert.NotNil(t, repo.CustomerPayments("cus_missing", 10))
+	assert.Empty(t, repo.CustomerPayments("cus_missing", 10))
+}
+
 func TestConcurrencySafety(t *testing.T) {
 	repo := payments.NewPaymentsRepository()
 	concurrencyLevel := 100
@@ -53,7 +75,7 @@ func TestConcurrencySafety(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			payment := payments.PostPaymentResponse{
+			payment := payments.Payment{
 				Id:            uuid.New().String(),
 				PaymentStatus: "Authorized",
 				Amount:        50,
@@ -82,4 +104,12 @@ func TestConcurrencySafety(t *testing.T) {
 	case <-time.After(2 * time.Second):
 		assert.FailNow(t, "Timeout")
 	}
-}
\ No newline at end of file
+}
+func TestPing(t *testing.T) {
+	repo := payments.NewPaymentsRepository()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, repo.Ping(ctx))
+}
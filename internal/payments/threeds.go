@@ -0,0 +1,398 @@
+package payments
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+)
+
+// AuthenticationStatus is the outcome of a 3-D Secure authentication, the
+// transStatus of EMV 3-D Secure.
+type AuthenticationStatus string
+
+const (
+	// AuthenticationSucceeded: the cardholder was authenticated (Y).
+	AuthenticationSucceeded AuthenticationStatus = "authenticated"
+	// AuthenticationAttempted: the issuer or the card does not take part
+	// in 3-D Secure, and the attempt stands as proof (A).
+	AuthenticationAttempted AuthenticationStatus = "attempted"
+	// AuthenticationFailed: the cardholder was not authenticated (N).
+	AuthenticationFailed AuthenticationStatus = "failed"
+	// AuthenticationRejected: the issuer refused to authenticate (R).
+	AuthenticationRejected AuthenticationStatus = "rejected"
+	// AuthenticationUnavailable: authentication could not be performed,
+	// for technical reasons (U).
+	AuthenticationUnavailable AuthenticationStatus = "unavailable"
+	// AuthenticationChallengeRequired: the cardholder must complete a
+	// challenge at the ACS (C).
+	AuthenticationChallengeRequired AuthenticationStatus = "challenge_required"
+)
+
+// proceeds reports whether a payment with an authentication of status s is
+// sent to the acquirer. Unavailable authentications are sent without
+// liability shift, and the issuer decides.
+func (s AuthenticationStatus) proceeds() bool {
+	return s == AuthenticationSucceeded || s == AuthenticationAttempted || s == AuthenticationUnavailable
+}
+
+// Authentication is the outcome of the 3-D Secure authentication of a
+// payment. ECI, CAVV and DSTransactionID are forwarded to the acquirer.
+type Authentication struct {
+	// TransactionID identifies the authentication to the 3DS server.
+	TransactionID string               `json:"transaction_id"`
+	Version       string               `json:"version,omitempty"`
+	Status        AuthenticationStatus `json:"status"`
+	// ECI is the electronic commerce indicator given by the scheme.
+	ECI string `json:"eci,omitempty"`
+	// CAVV is the cryptogram proving the authentication to the issuer.
+	CAVV            string `json:"cavv,omitempty"`
+	DSTransactionID string `json:"ds_transaction_id,omitempty"`
+	// LiabilityShift reports that fraud chargebacks are borne by the
+	// issuer rather than the merchant.
+	LiabilityShift bool `json:"liability_shift"`
+	// ChallengeURL is where the cardholder completes the challenge, while
+	// Status is challenge_required.
+	ChallengeURL string `json:"challenge_url,omitempty"`
+}
+
+// AuthenticationRequest is the payment whose cardholder an Authenticator
+// authenticates.
+type AuthenticationRequest struct {
+	PaymentID   string
+	CardNumber  string
+	ExpiryMonth int
+	ExpiryYear  int
+	Currency    string
+	Amount      int
+	Merchant    string
+	// NotificationURL is where the ACS sends the cardholder's browser back
+	// once a challenge ends.
+	NotificationURL string
+}
+
+// Authenticator authenticates cardholders with 3-D Secure. It is defined
+// here so that payments does not depend on the threeds package.
+type Authenticator interface {
+	// Authenticate starts the authentication of a payment. Its outcome is
+	// final unless the cardholder must complete a challenge at
+	// ChallengeURL.
+	Authenticate(ctx context.Context, req *AuthenticationRequest) (*Authentication, error)
+	// Result returns the outcome of the authentication transactionID once
+	// its challenge ended.
+	Result(ctx context.Context, transactionID string) (*Authentication, error)
+}
+
+// ThreeDSRequest holds the merchant's 3-D Secure options of a payment.
+type ThreeDSRequest struct {
+	// ReturnURL is where the cardholder's browser is redirected once a
+	// challenge ends, with the payment_id query parameter. Without one, the
+	// completion endpoint answers with the payment.
+	ReturnURL string `json:"return_url,omitempty"`
+}
+
+func (t *ThreeDSRequest) validate() error {
+	if t.ReturnURL == "" {
+		return nil
+	}
+	if u, err := url.Parse(t.ReturnURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalid("three_ds.return_url", "three_ds.return_url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// EnableThreeDS authenticates cardholders with authenticator when the rules
+// require it, and when the issuer declines a payment for lack of
+// authentication. Payments whose cardholder must complete a challenge are
+// answered as RequiresAction and fail when the challenge is not completed
+// within challengeTimeout; callbackURL returns the URL of
+// CompleteAuthenticationHandler for a payment.
+func (h *PaymentsHandler) EnableThreeDS(authenticator Authenticator, callbackURL func(paymentID string) string, challengeTimeout time.Duration) {
+	h.authenticator = authenticator
+	h.callbackURL = callbackURL
+	h.challengeTimeout = challengeTimeout
+	h.challenges = &challenges{pending: make(map[string]*pendingChallenge)}
+}
+
+// requiresAuthentication reports whether p must be authenticated before it
+// is sent to the acquirer.
+func (h *PaymentsHandler) requiresAuthentication(p *prepared) bool {
+	return h.authenticator != nil && h.rules.Load().requiresAuthentication(&p.req)
+}
+
+// stepUp reports whether the acquirer declined p for lack of authentication
+// and the cardholder can be authenticated.
+func (h *PaymentsHandler) stepUp(p *prepared, auth *BankAuthorization) bool {
+	return h.authenticator != nil && !auth.Authorized && auth.DeclineCode == DeclineAuthenticationRequired &&
+		p.req.Authentication == nil && !p.req.StoredCredential.MerchantInitiated()
+}
+
+// authenticate authenticates the cardholder of p. It returns the payment or
+// the problem to answer with when the payment goes no further for now: it
+// awaits a challenge, failed authentication, or could not be authenticated.
+// Otherwise it returns neither, and p.req carries the authentication to send
+// to the acquirer.
+func (h *PaymentsHandler) authenticate(ctx context.Context, p *prepared) (*Payment, *problem.Problem) {
+	logger := logging.FromContext(ctx)
+
+	auth, err := h.authenticator.Authenticate(ctx, &AuthenticationRequest{
+		PaymentID:       p.payment.Id,
+		CardNumber:      p.req.CardNumber,
+		ExpiryMonth:     p.req.ExpiryMonth,
+		ExpiryYear:      p.req.ExpiryYear,
+		Currency:        p.req.Currency,
+		Amount:          p.req.Amount,
+		Merchant:        p.merchant,
+		NotificationURL: h.callbackURL(p.payment.Id),
+	})
+	if err != nil {
+		p.dup.release()
+		logger.ErrorContext(ctx, "3-D Secure authentication failed", "error", err)
+		metrics.ThreeDSAuthenticationsTotal.WithLabelValues(metrics.ThreeDSError).Inc()
+		metrics.PaymentsTotal.WithLabelValues("Failed", p.req.Currency, unknownAcquirer).Inc()
+		return nil, problem.New(problem.CodeAuthenticationUnavailable, "3-D Secure authentication unavailable").
+			WithPaymentStatus("Failed")
+	}
+	metrics.ThreeDSAuthenticationsTotal.WithLabelValues(string(auth.Status)).Inc()
+
+	if auth.Status == AuthenticationChallengeRequired {
+		p.payment.ThreeDS = auth
+		payment := p.payment
+		payment.PaymentStatus = "RequiresAction"
+		h.save(ctx, p, payment)
+		h.challenges.add(p, h.challengeTimeout, h.expire)
+		logger.InfoContext(ctx, "payment requires a 3-D Secure challenge", "payment_id", payment.Id)
+		return &payment, nil
+	}
+	return h.authenticated(ctx, p, auth)
+}
+
+// authenticated records the final outcome of the authentication of p. It
+// declines the payment when the cardholder was not authenticated.
+func (h *PaymentsHandler) authenticated(ctx context.Context, p *prepared, auth *Authentication) (*Payment, *problem.Problem) {
+	final := *auth
+	final.ChallengeURL = ""
+	p.payment.ThreeDS = &final
+	if final.Status.proceeds() {
+		p.req.Authentication = &final
+		return nil, nil
+	}
+
+	p.dup.release()
+	payment := p.payment
+	payment.PaymentStatus = "Declined"
+	payment.DeclineCode = DeclineAuthenticationFailed
+	payment.DeclineType = payment.DeclineCode.Type()
+	metrics.DeclinesTotal.WithLabelValues(unknownAcquirer,
+		string(payment.DeclineCode), string(payment.DeclineType)).Inc()
+	h.save(ctx, p, payment)
+	h.recordProcessed(ctx, payment, unknownAcquirer)
+	return &payment, nil
+}
+
+// expire fails a payment whose challenge was not completed in time.
+func (h *PaymentsHandler) expire(p *prepared) {
+	ctx := context.Background()
+	payment := h.fail(p, problem.CodeAuthenticationExpired)
+	metrics.PaymentsTotal.WithLabelValues("Failed", payment.Currency, unknownAcquirer).Inc()
+	logging.FromContext(ctx).InfoContext(ctx, "3-D Secure challenge expired", "payment_id", payment.Id)
+	if p.notify && h.notifier != nil {
+		h.notifier.Notify(ctx, p.merchant, &payment)
+	}
+}
+
+// CompleteAuthenticationHandler returns an http.HandlerFunc resuming a
+// RequiresAction payment once its cardholder completed the challenge. The
+// ACS posts the challenge response (cres) from the cardholder's browser; its
+// outcome is not trusted, and the result is fetched from the 3DS server
+// instead. The payment is then sent to the acquirer.
+func (h *PaymentsHandler) CompleteAuthenticationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		id := chi.URLParam(r, "id")
+
+		transactionID, err := challengeTransaction(r)
+		if err != nil {
+			logger.InfoContext(ctx, "rejected malformed challenge response", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid challenge response"))
+			return
+		}
+
+		p, err := h.challenges.take(id, transactionID)
+		if errors.Is(err, errOtherTransaction) {
+			problem.Write(w, r, problem.New(problem.CodeValidationFailed, err.Error()).
+				WithErrors(problem.FieldError{Field: "cres", Message: err.Error()}))
+			return
+		}
+		if err != nil {
+			payment := h.storage.GetPayment(id)
+			switch {
+			case payment == nil:
+				problem.Write(w, r, problem.New(problem.CodePaymentNotFound, "No payment with ID "+id))
+			case payment.FailureCode == string(problem.CodeAuthenticationExpired):
+				problem.Write(w, r, problem.New(problem.CodeAuthenticationExpired,
+					"The 3-D Secure challenge of payment "+id+" expired"))
+			default:
+				problem.Write(w, r, problem.New(problem.CodeConflict,
+					"Payment "+id+" is not awaiting 3-D Secure authentication"))
+			}
+			return
+		}
+
+		// The payment is no longer awaiting its cardholder, so it is completed
+		// and stored even if the cardholder's browser goes away.
+		ctx = context.WithoutCancel(ctx)
+		payment, prob := h.completeChallenge(ctx, p, transactionID)
+		if p.notify && h.notifier != nil {
+			h.notifier.Notify(ctx, p.merchant, payment)
+		}
+
+		if p.req.ThreeDS != nil && p.req.ThreeDS.ReturnURL != "" {
+			http.Redirect(w, r, withPaymentID(p.req.ThreeDS.ReturnURL, payment.Id), http.StatusSeeOther)
+			return
+		}
+		if prob != nil {
+			problem.Write(w, r, prob)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(wire.From[Codec](ctx).EncodePayment(payment))
+	}
+}
+
+// completeChallenge fetches the outcome of the challenge of p and authorizes
+// the payment. It returns the stored payment, with the problem that made it
+// fail, if any.
+func (h *PaymentsHandler) completeChallenge(ctx context.Context, p *prepared, transactionID string) (*Payment, *problem.Problem) {
+	auth, err := h.authenticator.Result(ctx, transactionID)
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "3-D Secure result lookup failed", "error", err)
+		metrics.ThreeDSAuthenticationsTotal.WithLabelValues(metrics.ThreeDSError).Inc()
+		metrics.PaymentsTotal.WithLabelValues("Failed", p.req.Currency, unknownAcquirer).Inc()
+		payment := h.fail(p, problem.CodeAuthenticationUnavailable)
+		return &payment, problem.New(problem.CodeAuthenticationUnavailable, "3-D Secure authentication unavailable").
+			WithPaymentStatus("Failed")
+	}
+	metrics.ThreeDSAuthenticationsTotal.WithLabelValues(string(auth.Status)).Inc()
+	if auth.Status == AuthenticationChallengeRequired {
+		// The ACS ended the challenge without an outcome.
+		auth.Status = AuthenticationFailed
+	}
+
+	if payment, prob := h.authenticated(ctx, p, auth); payment != nil || prob != nil {
+		return payment, prob
+	}
+	payment, prob := h.authorize(ctx, p)
+	if prob != nil {
+		failed := h.fail(p, prob.Code)
+		return &failed, prob
+	}
+	return payment, nil
+}
+
+// challengeResponse is the challenge response (CRes) of EMV 3-D Secure, as
+// posted base64url-encoded in the cres form field.
+type challengeResponse struct {
+	ThreeDSServerTransID string `json:"threeDSServerTransID"`
+}
+
+// challengeTransaction returns the 3DS server transaction ID of the
+// challenge response posted with r.
+func challengeTransaction(r *http.Request) (string, error) {
+	cres := r.PostFormValue("cres")
+	if cres == "" {
+		return "", errors.New("cres is required")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cres, "="))
+	if err != nil {
+		return "", fmt.Errorf("cres is not base64url: %w", err)
+	}
+	var cr challengeResponse
+	if err := json.Unmarshal(raw, &cr); err != nil {
+		return "", fmt.Errorf("cres is not a challenge response: %w", err)
+	}
+	if cr.ThreeDSServerTransID == "" {
+		return "", errors.New("cres has no threeDSServerTransID")
+	}
+	return cr.ThreeDSServerTransID, nil
+}
+
+// withPaymentID adds the payment_id query parameter to a return URL,
+// already checked by ThreeDSRequest.validate.
+func withPaymentID(returnURL, paymentID string) string {
+	u, _ := url.Parse(returnURL)
+	q := u.Query()
+	q.Set("payment_id", paymentID)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+var (
+	errNotAwaitingChallenge = errors.New("payment is not awaiting a challenge")
+	errOtherTransaction     = errors.New("the challenge response is for another authentication of the payment")
+)
+
+// pendingChallenge is a payment awaiting its cardholder, with the timer
+// failing it when the challenge expires.
+type pendingChallenge struct {
+	p     *prepared
+	timer *time.Timer
+}
+
+// challenges holds the payments awaiting their cardholder until the
+// challenge is completed or expires. They are kept in memory: payments
+// challenged when the gateway stops stay RequiresAction.
+type challenges struct {
+	mu      sync.Mutex
+	pending map[string]*pendingChallenge
+}
+
+// add holds p until it is taken, or until timeout, when expire is called
+// with it.
+func (c *challenges) add(p *prepared, timeout time.Duration, expire func(*prepared)) {
+	id, transactionID := p.payment.Id, p.payment.ThreeDS.TransactionID
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[id] = &pendingChallenge{
+		p: p,
+		timer: time.AfterFunc(timeout, func() {
+			if p, err := c.take(id, transactionID); err == nil {
+				expire(p)
+			}
+		}),
+	}
+}
+
+// take removes the payment paymentID awaiting the challenge transactionID.
+// No payment awaits a challenge when 3-D Secure is disabled and c is nil.
+func (c *challenges) take(paymentID, transactionID string) (*prepared, error) {
+	if c == nil {
+		return nil, errNotAwaitingChallenge
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pc, ok := c.pending[paymentID]
+	if !ok {
+		return nil, errNotAwaitingChallenge
+	}
+	if pc.p.payment.ThreeDS.TransactionID != transactionID {
+		return nil, errOtherTransaction
+	}
+	pc.timer.Stop()
+	delete(c.pending, paymentID)
+	return pc.p, nil
+}
@@ -0,0 +1,328 @@
+package payments_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAuthenticator answers authentications with Status, or with a
+// challenge whose outcome is Outcome.
+type MockAuthenticator struct {
+	mu        sync.Mutex
+	Status    payments.AuthenticationStatus
+	Outcome   payments.AuthenticationStatus
+	Err       error
+	Requests  []payments.AuthenticationRequest
+	lastTrans int
+}
+
+func (m *MockAuthenticator) Authenticate(ctx context.Context, req *payments.AuthenticationRequest) (*payments.Authentication, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Requests = append(m.Requests, *req)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.lastTrans++
+	auth := authentication(m.Status)
+	auth.TransactionID = fmt.Sprintf("trans-%d", m.lastTrans)
+	if m.Status == payments.AuthenticationChallengeRequired {
+		auth.ChallengeURL = "https://acs.test/challenge/" + auth.TransactionID
+	}
+	return auth, nil
+}
+
+func (m *MockAuthenticator) Result(ctx context.Context, transactionID string) (*payments.Authentication, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	auth := authentication(m.Outcome)
+	auth.TransactionID = transactionID
+	return auth, nil
+}
+
+func authentication(status payments.AuthenticationStatus) *payments.Authentication {
+	auth := &payments.Authentication{Version: "2.2.0", Status: status, ECI: "07"}
+	if status == payments.AuthenticationSucceeded {
+		auth.ECI, auth.CAVV, auth.LiabilityShift = "05", "AAABBBCCC", true
+	}
+	return auth
+}
+
+// cres encodes the challenge response an ACS posts for transactionID.
+func cres(transactionID string) string {
+	raw, _ := json.Marshal(map[string]string{"threeDSServerTransID": transactionID, "transStatus": "Y"})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func TestPostPaymentHandler_ThreeDS(t *testing.T) {
+	const body = `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2030,"currency":"EUR","amount":5000,"cvv":"123"}`
+
+	var (
+		mu   sync.Mutex
+		sent []payments.PostPaymentRequest
+	)
+	bank := &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, *req)
+			if req.Authentication == nil && strings.HasSuffix(req.CardNumber, "91") {
+				return &payments.BankAuthorization{DeclineCode: payments.DeclineAuthenticationRequired}, nil
+			}
+			return &payments.BankAuthorization{Authorized: true, AuthorizationCode: "AUTH-1"}, nil
+		},
+	}
+
+	setup := func(auth *MockAuthenticator, timeout time.Duration) (*payments.PaymentsRepository, http.Handler) {
+		mu.Lock()
+		sent = nil
+		mu.Unlock()
+		repo := payments.NewPaymentsRepository()
+		handler := payments.NewPaymentsHandler(repo, bank)
+		handler.SetRules(payments.NewRules([]string{"EUR", "USD"},
+			payments.WithThreeDS([]string{"EUR"}, map[string]int{"EUR": 3000})))
+		handler.EnableThreeDS(auth, func(id string) string {
+			return "https://gateway.test/api/payments/" + id + "/3ds/complete"
+		}, timeout)
+
+		r := chi.NewRouter()
+		r.Use(withV1)
+		r.Post("/api/payments", handler.PostHandler())
+		r.Post("/api/payments/{id}/3ds/complete", handler.CompleteAuthenticationHandler())
+		return repo, r
+	}
+	post := func(router http.Handler, body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var respBody map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &respBody))
+		return w.Code, respBody
+	}
+	complete := func(router http.Handler, id, cres string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/payments/"+id+"/3ds/complete",
+			strings.NewReader(url.Values{"cres": {cres}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Frictionless", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationSucceeded}
+		_, router := setup(auth, time.Minute)
+
+		code, paid := post(router, body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Authorized", paid["payment_status"])
+		threeDS, _ := paid["three_ds"].(map[string]interface{})
+		assert.Equal(t, "authenticated", threeDS["status"])
+		assert.Equal(t, "05", threeDS["eci"])
+		assert.Equal(t, true, threeDS["liability_shift"])
+
+		require.Len(t, auth.Requests, 1)
+		assert.Equal(t, "https://gateway.test/api/payments/"+paid["id"].(string)+"/3ds/complete", auth.Requests[0].NotificationURL)
+		require.Len(t, sent, 1)
+		require.NotNil(t, sent[0].Authentication, "the authentication is forwarded to the bank")
+		assert.Equal(t, "AAABBBCCC", sent[0].Authentication.CAVV)
+	})
+
+	t.Run("Not required", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationSucceeded}
+		_, router := setup(auth, time.Minute)
+
+		code, paid := post(router, strings.Replace(body, `"amount":5000`, `"amount":2999`, 1))
+		assert.Equal(t, http.StatusOK, code, "below the exemption threshold")
+		assert.Nil(t, paid["three_ds"])
+		code, paid = post(router, strings.Replace(body, `"EUR"`, `"USD"`, 1))
+		assert.Equal(t, http.StatusOK, code, "currency without 3-D Secure")
+		assert.Nil(t, paid["three_ds"])
+		assert.Empty(t, auth.Requests)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		_, router := setup(&MockAuthenticator{Status: payments.AuthenticationFailed}, time.Minute)
+
+		code, paid := post(router, body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Declined", paid["payment_status"])
+		assert.Equal(t, "authentication_failed", paid["decline_code"])
+		assert.Equal(t, "hard", paid["decline_type"])
+		assert.Empty(t, sent, "the bank is not called")
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		_, router := setup(&MockAuthenticator{Err: errors.New("connection refused")}, time.Minute)
+
+		code, failed := post(router, body)
+		assert.Equal(t, http.StatusBadGateway, code)
+		assert.Equal(t, "authentication_unavailable", failed["code"])
+		assert.Equal(t, "Failed", failed["payment_status"])
+		assert.Empty(t, sent)
+	})
+
+	t.Run("Challenge", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationChallengeRequired, Outcome: payments.AuthenticationSucceeded}
+		repo, router := setup(auth, time.Minute)
+
+		code, pending := post(router, body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "RequiresAction", pending["payment_status"])
+		threeDS, _ := pending["three_ds"].(map[string]interface{})
+		assert.Equal(t, "https://acs.test/challenge/trans-1", threeDS["challenge_url"])
+		id := pending["id"].(string)
+		assert.Equal(t, "RequiresAction", repo.GetPayment(id).PaymentStatus)
+		assert.Empty(t, sent, "the bank is called once the challenge is completed")
+
+		assert.Equal(t, http.StatusBadRequest, complete(router, id, "not-a-cres").Code)
+		w := complete(router, id, cres("trans-2"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "validation_failed")
+
+		w = complete(router, id, cres("trans-1"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var paid map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &paid))
+		assert.Equal(t, "Authorized", paid["payment_status"])
+		threeDS, _ = paid["three_ds"].(map[string]interface{})
+		assert.Equal(t, "authenticated", threeDS["status"])
+		assert.Nil(t, threeDS["challenge_url"])
+		assert.Equal(t, "Authorized", repo.GetPayment(id).PaymentStatus)
+		require.Len(t, sent, 1)
+		assert.Equal(t, id, sent[0].Reference)
+		require.NotNil(t, sent[0].Authentication)
+
+		w = complete(router, id, cres("trans-1"))
+		assert.Equal(t, http.StatusConflict, w.Code, "the challenge is completed once")
+		assert.Equal(t, http.StatusNotFound, complete(router, "unknown", cres("trans-1")).Code)
+	})
+
+	t.Run("Browser gone", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationChallengeRequired, Outcome: payments.AuthenticationSucceeded}
+		repo, router := setup(auth, time.Minute)
+
+		_, pending := post(router, body)
+		id := pending["id"].(string)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, _ := http.NewRequestWithContext(ctx, "POST", "/api/payments/"+id+"/3ds/complete",
+			strings.NewReader(url.Values{"cres": {cres("trans-1")}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, "Authorized", repo.GetPayment(id).PaymentStatus,
+			"the payment completes without the cardholder's browser")
+		assert.Len(t, sent, 1)
+	})
+
+	t.Run("Challenge failed", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationChallengeRequired, Outcome: payments.AuthenticationFailed}
+		repo, router := setup(auth, time.Minute)
+
+		_, pending := post(router, body)
+		id := pending["id"].(string)
+		w := complete(router, id, cres("trans-1"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Declined", repo.GetPayment(id).PaymentStatus)
+		assert.Equal(t, payments.DeclineAuthenticationFailed, repo.GetPayment(id).DeclineCode)
+		assert.Empty(t, sent)
+	})
+
+	t.Run("Return URL", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationChallengeRequired, Outcome: payments.AuthenticationSucceeded}
+		_, router := setup(auth, time.Minute)
+
+		_, pending := post(router, strings.Replace(body, `"cvv"`, `"three_ds":{"return_url":"https://shop.test/done?order=7"},"cvv"`, 1))
+		id := pending["id"].(string)
+		w := complete(router, id, cres("trans-1"))
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "https://shop.test/done?order=7&payment_id="+id, w.Header().Get("Location"))
+
+		code, rejected := post(router, strings.Replace(body, `"cvv"`, `"three_ds":{"return_url":"/done"},"cvv"`, 1))
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "three_ds.return_url must be an absolute http or https URL", rejected["detail"])
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationChallengeRequired, Outcome: payments.AuthenticationSucceeded}
+		repo, router := setup(auth, 10*time.Millisecond)
+
+		_, pending := post(router, body)
+		id := pending["id"].(string)
+		assert.Eventually(t, func() bool {
+			return repo.GetPayment(id).PaymentStatus == "Failed"
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "authentication_expired", repo.GetPayment(id).FailureCode)
+
+		w := complete(router, id, cres("trans-1"))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "authentication_expired")
+		assert.Empty(t, sent)
+	})
+
+	t.Run("Issuer step-up", func(t *testing.T) {
+		auth := &MockAuthenticator{Status: payments.AuthenticationSucceeded}
+		_, router := setup(auth, time.Minute)
+
+		code, paid := post(router, strings.NewReplacer(`"EUR"`, `"USD"`, "8877", "8891").Replace(body))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Authorized", paid["payment_status"])
+		require.Len(t, auth.Requests, 1, "the issuer asked for authentication")
+		require.Len(t, sent, 2, "the payment is sent again once authenticated")
+		assert.Nil(t, sent[0].Authentication)
+		assert.NotNil(t, sent[1].Authentication)
+	})
+}
+
+func TestRules_RequiresAuthentication(t *testing.T) {
+	handler := payments.NewPaymentsHandler(payments.NewPaymentsRepository(), &ConfigurableBankGateway{
+		ProcessPaymentFunc: func(req *payments.PostPaymentRequest) (*payments.BankAuthorization, error) {
+			return &payments.BankAuthorization{Authorized: true, NetworkTransactionID: "NTI-2"}, nil
+		},
+	})
+	handler.SetRules(payments.NewRules([]string{"EUR"}, payments.WithThreeDS([]string{"EUR"}, nil)))
+	auth := &MockAuthenticator{Status: payments.AuthenticationSucceeded}
+	handler.EnableThreeDS(auth, func(id string) string { return "https://gateway.test/" + id }, time.Minute)
+	handler.SetCardFingerprinter(payments.NewCardFingerprinter([]byte("key-1")))
+
+	req := payments.PostPaymentRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2030, Currency: "EUR", Amount: 1, Cvv: "123"}
+	req.StoredCredential = &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceInitial}
+	payment, p := handler.Submit(context.Background(), "acme", req)
+	require.Nil(t, p)
+	assert.Equal(t, "Authorized", payment.PaymentStatus)
+	assert.Len(t, auth.Requests, 1, "every amount is authenticated without an exemption")
+
+	req.Cvv = ""
+	req.StoredCredential = &payments.StoredCredential{
+		Initiator:            payments.InitiatorMerchant,
+		Sequence:             payments.SequenceSubsequent,
+		Reason:               payments.ReasonRecurring,
+		NetworkTransactionID: payment.NetworkTransactionID,
+	}
+	payment, p = handler.Submit(context.Background(), "acme", req)
+	require.Nil(t, p)
+	assert.Equal(t, "Authorized", payment.PaymentStatus)
+	assert.Len(t, auth.Requests, 1, "merchant-initiated payments are out of scope of SCA")
+}
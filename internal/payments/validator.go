@@ -1,97 +1,266 @@
 package payments
 
 import (
-	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
 )
 
-var allowedCurrencies = map[string]bool{
-	"USD": true,
-	"EUR": true,
-	"BRL": true,
-}
+// DefaultAllowedCurrencies are the currencies accepted when no other list is
+// configured.
+var DefaultAllowedCurrencies = []string{"USD", "EUR", "BRL"}
 
 var numericRegex = regexp.MustCompile(`^[0-9]+$`)
 
+// ValidationError reports the request field that failed validation.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
+
+// Rules holds the configurable business rules applied during validation.
+// Rules are immutable once built so they can be swapped atomically.
+type Rules struct {
+	allowedCurrencies map[string]bool
+	maxAmount         map[string]int
+	blockedBINs       []string
+
+	threeDSCurrencies  map[string]bool
+	threeDSExemptBelow map[string]int
+
+	duplicateWindow    time.Duration
+	duplicateAction    DuplicateAction
+	merchantDuplicates map[string]DuplicateAction
+}
+
+// RuleOption customises Rules built by NewRules.
+type RuleOption func(*Rules)
+
+// WithMaxAmounts caps the amount, in minor units, accepted per currency.
+func WithMaxAmounts(limits map[string]int) RuleOption {
+	return func(r *Rules) {
+		for currency, max := range limits {
+			r.maxAmount[currency] = max
+		}
+	}
+}
+
+// WithBlockedBINs rejects cards whose number starts with any of bins.
+func WithBlockedBINs(bins []string) RuleOption {
+	return func(r *Rules) {
+		r.blockedBINs = append(r.blockedBINs, bins...)
+	}
+}
+
+// WithDuplicatePolicy flags payments identical to one authorized less than
+// window ago. action applies to every merchant without an entry in
+// merchants. A zero window disables duplicate detection.
+func WithDuplicatePolicy(window time.Duration, action DuplicateAction, merchants map[string]DuplicateAction) RuleOption {
+	return func(r *Rules) {
+		r.duplicateWindow = window
+		r.duplicateAction = action
+		for merchant, a := range merchants {
+			r.merchantDuplicates[merchant] = a
+		}
+	}
+}
+
+// WithThreeDS requires 3-D Secure authentication of the customer-initiated
+// payments in currencies, except those below the amount exemptBelow gives
+// for their currency (the low-value exemption).
+func WithThreeDS(currencies []string, exemptBelow map[string]int) RuleOption {
+	return func(r *Rules) {
+		for _, c := range currencies {
+			r.threeDSCurrencies[c] = true
+		}
+		for currency, min := range exemptBelow {
+			r.threeDSExemptBelow[currency] = min
+		}
+	}
+}
+
+// NewRules builds Rules accepting the given currencies.
+func NewRules(allowedCurrencies []string, opts ...RuleOption) *Rules {
+	r := &Rules{
+		allowedCurrencies:  make(map[string]bool, len(allowedCurrencies)),
+		maxAmount:          make(map[string]int),
+		threeDSCurrencies:  make(map[string]bool),
+		threeDSExemptBelow: make(map[string]int),
+		duplicateAction:    DuplicateWarn,
+		merchantDuplicates: make(map[string]DuplicateAction),
+	}
+	for _, c := range allowedCurrencies {
+		r.allowedCurrencies[c] = true
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// DefaultRules returns Rules built from DefaultAllowedCurrencies.
+func DefaultRules() *Rules {
+	return NewRules(DefaultAllowedCurrencies)
+}
+
+// AllowsCurrency reports whether currency is accepted.
+func (r *Rules) AllowsCurrency(currency string) bool {
+	return r.allowedCurrencies[currency]
+}
+
+// blocksCard reports whether the card number matches a blocked BIN.
+func (r *Rules) blocksCard(cardNumber string) bool {
+	for _, bin := range r.blockedBINs {
+		if strings.HasPrefix(cardNumber, bin) {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicatePolicy returns the duplicate window and the action for merchant.
+func (r *Rules) duplicatePolicy(merchant string) (time.Duration, DuplicateAction) {
+	if action, ok := r.merchantDuplicates[merchant]; ok {
+		return r.duplicateWindow, action
+	}
+	return r.duplicateWindow, r.duplicateAction
+}
+
+// requiresAuthentication reports whether req must be authenticated with 3-D
+// Secure before it is sent to the acquirer. Merchant-initiated payments are
+// out of scope, as the cardholder is not there to authenticate; the handler
+// admits them only for the card of a verified initial payment.
+func (r *Rules) requiresAuthentication(req *PostPaymentRequest) bool {
+	if !r.threeDSCurrencies[req.Currency] || req.StoredCredential.MerchantInitiated() {
+		return false
+	}
+	return req.Amount >= r.threeDSExemptBelow[req.Currency]
+}
+
+// Validate checks req against DefaultRules.
 func (req *PostPaymentRequest) Validate() error {
-	if err := req.validateCurrency(); err != nil {
+	return req.ValidateWith(DefaultRules())
+}
+
+// ValidateWith checks req against rules.
+func (req *PostPaymentRequest) ValidateWith(rules *Rules) error {
+	if err := req.validateCurrency(rules); err != nil {
 		return err
 	}
-	if err := req.validateAmount(); err != nil {
+	if err := req.validateAmount(rules); err != nil {
 		return err
 	}
-	if err := req.validateCardNumber(); err != nil {
+	if err := req.validateCardNumber(rules); err != nil {
 		return err
 	}
+	if req.StoredCredential != nil {
+		if err := req.StoredCredential.validate(); err != nil {
+			return err
+		}
+	}
 	if err := req.validateCVV(); err != nil {
 		return err
 	}
+	if req.ThreeDS != nil {
+		if err := req.ThreeDS.validate(); err != nil {
+			return err
+		}
+	}
 	return req.validateExpiry()
 }
 
-func (req *PostPaymentRequest) validateCurrency() error {
+// ValidateCard checks a card number and expiry date with the same rules used
+// for payment requests, without any risk rules. The token vault uses it.
+func ValidateCard(cardNumber string, expiryMonth, expiryYear int) error {
+	req := PostPaymentRequest{CardNumber: cardNumber, ExpiryMonth: expiryMonth, ExpiryYear: expiryYear}
+	if err := req.validateCardNumber(&Rules{}); err != nil {
+		return err
+	}
+	return req.validateExpiry()
+}
+
+func (req *PostPaymentRequest) validateCurrency(rules *Rules) error {
 	if req.Currency == "" {
-		return errors.New("currency is required")
+		return invalid("currency", "currency is required")
 	}
-	if !allowedCurrencies[req.Currency] {
-		return errors.New("currency not supported")
+	if !rules.AllowsCurrency(req.Currency) {
+		return invalid("currency", "currency not supported")
 	}
 	return nil
 }
 
-func (req *PostPaymentRequest) validateAmount() error {
+func (req *PostPaymentRequest) validateAmount(rules *Rules) error {
 	if req.Amount <= 0 {
-		return errors.New("amount must be greater than 0")
+		return invalid("amount", "amount must be greater than 0")
+	}
+	if max, ok := rules.maxAmount[req.Currency]; ok && req.Amount > max {
+		return invalid("amount", fmt.Sprintf("amount exceeds the maximum allowed for %s", req.Currency))
 	}
 	return nil
 }
 
-func (req *PostPaymentRequest) validateCardNumber() error {
+func (req *PostPaymentRequest) validateCardNumber(rules *Rules) error {
 	if req.CardNumber == "" {
-		return errors.New("card_number is required")
+		return invalid("card_number", "card_number is required")
 	}
 
 	cleanCard := strings.ReplaceAll(req.CardNumber, " ", "")
 
 	if !numericRegex.MatchString(cleanCard) {
-		return errors.New("card_number must contain only numeric characters")
+		return invalid("card_number", "card_number must contain only numeric characters")
 	}
 
 	if len(cleanCard) < 14 || len(cleanCard) > 19 {
-		return errors.New("card_number must be between 14 and 19 characters")
+		return invalid("card_number", "card_number must be between 14 and 19 characters")
+	}
+	if rules.blocksCard(cleanCard) {
+		return invalid("card_number", "card is blocked by risk rules")
 	}
 	return nil
 }
 
+// validateCVV requires a CVV except on merchant-initiated payments, where
+// the cardholder is not there to give it and which the handler admits only
+// for the card of a verified initial payment.
 func (req *PostPaymentRequest) validateCVV() error {
+	if req.Cvv == "" && req.StoredCredential.MerchantInitiated() {
+		return nil
+	}
 	if req.Cvv == "" {
-		return errors.New("cvv is required")
+		return invalid("cvv", "cvv is required")
 	}
 	if !numericRegex.MatchString(req.Cvv) {
-		return errors.New("cvv must contain only numeric characters")
+		return invalid("cvv", "cvv must contain only numeric characters")
 	}
 	if len(req.Cvv) < 3 || len(req.Cvv) > 4 {
-		return errors.New("cvv must be 3 or 4 characters")
+		return invalid("cvv", "cvv must be 3 or 4 characters")
 	}
 	return nil
 }
 
 func (req *PostPaymentRequest) validateExpiry() error {
 	if req.ExpiryMonth < 1 || req.ExpiryMonth > 12 {
-		return errors.New("expiry_month must be between 1 and 12")
+		return invalid("expiry_month", "expiry_month must be between 1 and 12")
 	}
 
 	now := time.Now()
 	currentYear, currentMonth, _ := now.Date()
 
 	if req.ExpiryYear < currentYear {
-		return errors.New("expiry_year must be in the future")
+		return invalid("expiry_year", "expiry_year must be in the future")
 	}
 
 	if req.ExpiryYear == currentYear && req.ExpiryMonth < int(currentMonth) {
-		return errors.New("expiry date must be in the future")
+		return invalid("expiry_month", "expiry date must be in the future")
 	}
 
 	return nil
@@ -124,6 +124,18 @@ func TestPostPaymentRequest_Validate(t *testing.T) {
 			},
 			wantErr: "cvv is required",
 		},
+		{
+			name: "Invalid CVV (Empty, payment_method_id)",
+			req: payments.PostPaymentRequest{
+				CardNumber:      "1234567890123456",
+				ExpiryMonth:     12,
+				ExpiryYear:      futureYear,
+				Currency:        "USD",
+				Amount:          1000,
+				PaymentMethodID: "pm_1",
+			},
+			wantErr: "cvv is required",
+		},
 	}
 
 	for _, tt := range tests {
@@ -138,3 +150,109 @@ func TestPostPaymentRequest_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestPostPaymentRequest_ValidateWith(t *testing.T) {
+	req := payments.PostPaymentRequest{
+		CardNumber:  "1234567890123456",
+		ExpiryMonth: 12,
+		ExpiryYear:  time.Now().Year() + 1,
+		Currency:    "GBP",
+		Amount:      100,
+		Cvv:         "123",
+	}
+
+	assert.EqualError(t, req.Validate(), "currency not supported")
+	assert.NoError(t, req.ValidateWith(payments.NewRules([]string{"GBP"})))
+	assert.EqualError(t, req.ValidateWith(payments.NewRules([]string{"USD"})), "currency not supported")
+}
+
+func TestPostPaymentRequest_ValidateWith_LimitsAndRisk(t *testing.T) {
+	rules := payments.NewRules([]string{"GBP", "USD"},
+		payments.WithMaxAmounts(map[string]int{"GBP": 1000}),
+		payments.WithBlockedBINs([]string{"411111"}),
+	)
+
+	tests := []struct {
+		name       string
+		currency   string
+		amount     int
+		cardNumber string
+		wantErr    string
+	}{
+		{name: "Within limit", currency: "GBP", amount: 1000, cardNumber: "2222405343248877"},
+		{name: "Over limit", currency: "GBP", amount: 1001, cardNumber: "2222405343248877", wantErr: "amount exceeds the maximum allowed for GBP"},
+		{name: "Currency without limit", currency: "USD", amount: 1000000, cardNumber: "2222405343248877"},
+		{name: "Blocked BIN", currency: "USD", amount: 100, cardNumber: "4111111111111111", wantErr: "card is blocked by risk rules"},
+		{name: "Blocked BIN with spaces", currency: "USD", amount: 100, cardNumber: "4111 1111 1111 1111", wantErr: "card is blocked by risk rules"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := payments.PostPaymentRequest{
+				CardNumber:  tt.cardNumber,
+				ExpiryMonth: 12,
+				ExpiryYear:  time.Now().Year() + 1,
+				Currency:    tt.currency,
+				Amount:      tt.amount,
+				Cvv:         "123",
+			}
+			err := req.ValidateWith(rules)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostPaymentRequest_Validate_StoredCredential(t *testing.T) {
+	mit := func(reason payments.CredentialReason, nti string) *payments.StoredCredential {
+		return &payments.StoredCredential{
+			Initiator:            payments.InitiatorMerchant,
+			Sequence:             payments.SequenceSubsequent,
+			Reason:               reason,
+			NetworkTransactionID: nti,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		credential *payments.StoredCredential
+		cvv        string
+		wantErr    string
+	}{
+		{name: "Initial CIT", credential: &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceInitial, Reason: payments.ReasonRecurring}, cvv: "123"},
+		{name: "Initial CIT without CVV", credential: &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceInitial}, wantErr: "cvv is required"},
+		{name: "Initial MIT", credential: &payments.StoredCredential{Initiator: payments.InitiatorMerchant, Sequence: payments.SequenceInitial, Reason: payments.ReasonRecurring}, wantErr: "the initial payment of a stored credential must be customer-initiated"},
+		{name: "Initial with network transaction ID", credential: &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceInitial, NetworkTransactionID: "NTI-1"}, cvv: "123", wantErr: "the initial payment has no network_transaction_id yet"},
+		{name: "Subsequent MIT without CVV", credential: mit(payments.ReasonRecurring, "NTI-1")},
+		{name: "Subsequent MIT with invalid CVV", credential: mit(payments.ReasonRecurring, "NTI-1"), cvv: "12", wantErr: "cvv must be 3 or 4 characters"},
+		{name: "Subsequent MIT without network transaction ID", credential: mit(payments.ReasonRecurring, ""), wantErr: "merchant-initiated payments require the network_transaction_id of the initial payment"},
+		{name: "Subsequent MIT without reason", credential: mit("", "NTI-1"), wantErr: "merchant-initiated payments require stored_credential.reason"},
+		{name: "Subsequent CIT without CVV", credential: &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceSubsequent}, wantErr: "cvv is required"},
+		{name: "Unknown initiator", credential: &payments.StoredCredential{Initiator: "bank", Sequence: payments.SequenceInitial}, cvv: "123", wantErr: "stored_credential.initiator must be customer or merchant"},
+		{name: "Missing sequence", credential: &payments.StoredCredential{Initiator: payments.InitiatorCustomer}, cvv: "123", wantErr: "stored_credential.sequence is required"},
+		{name: "Unknown reason", credential: mit("trial", "NTI-1"), wantErr: "stored_credential.reason must be recurring, installment or unscheduled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := payments.PostPaymentRequest{
+				CardNumber:       "2222405343248877",
+				ExpiryMonth:      12,
+				ExpiryYear:       time.Now().Year() + 1,
+				Currency:         "USD",
+				Amount:           100,
+				Cvv:              tt.cvv,
+				StoredCredential: tt.credential,
+			}
+			err := req.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
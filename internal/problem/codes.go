@@ -0,0 +1,116 @@
+package problem
+
+import "net/http"
+
+// Code is a stable, machine-readable error code. Codes are part of the API
+// contract: never rename or reuse one.
+type Code string
+
+const (
+	CodeMalformedRequest          Code = "malformed_request"
+	CodeValidationFailed          Code = "validation_failed"
+	CodeCardTokenNotFound         Code = "card_token_not_found"
+	CodeCardTokensDisabled        Code = "card_tokens_disabled"
+	CodeCardDeclined              Code = "card_declined"
+	CodeDuplicatePayment          Code = "duplicate_payment"
+	CodePaymentNotFound           Code = "payment_not_found"
+	CodeBatchNotFound             Code = "batch_not_found"
+	CodeBatchTooLarge             Code = "batch_too_large"
+	CodeUnsupportedMedia          Code = "unsupported_media_type"
+	CodeSubscriptionNotFound      Code = "subscription_not_found"
+	CodeCustomerNotFound          Code = "customer_not_found"
+	CodePaymentMethodNotFound     Code = "payment_method_not_found"
+	CodeNotFound                  Code = "not_found"
+	CodeMethodNotAllowed          Code = "method_not_allowed"
+	CodeUnsupportedVersion        Code = "unsupported_version"
+	CodeVersionRetired            Code = "version_retired"
+	CodeUnauthorized              Code = "unauthorized"
+	CodeConflict                  Code = "conflict"
+	CodeRateLimited               Code = "rate_limited"
+	CodeUpstreamUnavailable       Code = "upstream_unavailable"
+	CodeUpstreamOverloaded        Code = "upstream_overloaded"
+	CodeQueueFull                 Code = "queue_full"
+	CodeAuthenticationUnavailable Code = "authentication_unavailable"
+	CodeAuthenticationExpired     Code = "authentication_expired"
+	CodeInternalError             Code = "internal_error"
+)
+
+// Entry describes a Code in the catalogue.
+type Entry struct {
+	Code   Code   `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+	// Description says when the code is used.
+	Description string `json:"description"`
+}
+
+// catalogue lists every code. Keep it in sync with the Swagger
+// documentation; TestCatalogue_IsDocumented checks that it is.
+var catalogue = []Entry{
+	{CodeMalformedRequest, http.StatusBadRequest, "Malformed request",
+		"The request body is not valid JSON or does not match the expected shape."},
+	{CodeValidationFailed, http.StatusBadRequest, "Validation failed",
+		"One or more fields are invalid; see errors for each field."},
+	{CodeCardTokenNotFound, http.StatusBadRequest, "Card token not found",
+		"card_token does not match any stored card."},
+	{CodeCardTokensDisabled, http.StatusBadRequest, "Card tokens disabled",
+		"card_token was sent but the token vault is not enabled."},
+	{CodeCardDeclined, http.StatusPaymentRequired, "Card declined",
+		"The acquirer declined the card. Reserved for decline reasons; declined payments are currently answered with 200 and payment_status Declined."},
+	{CodeDuplicatePayment, http.StatusConflict, "Duplicate payment",
+		"An identical payment (same card, amount and currency) was authorized recently and the merchant rejects duplicates."},
+	{CodePaymentNotFound, http.StatusNotFound, "Payment not found",
+		"No payment has the requested ID."},
+	{CodeBatchNotFound, http.StatusNotFound, "Batch not found",
+		"No payment batch has the requested ID."},
+	{CodeBatchTooLarge, http.StatusRequestEntityTooLarge, "Batch too large",
+		"The batch holds more payments than batches.max_items allows; split it."},
+	{CodeUnsupportedMedia, http.StatusUnsupportedMediaType, "Unsupported media type",
+		"The request body is in a format the endpoint does not accept; see the endpoint's documentation."},
+	{CodeSubscriptionNotFound, http.StatusNotFound, "Subscription not found",
+		"No subscription has the requested ID."},
+	{CodeCustomerNotFound, http.StatusNotFound, "Customer not found",
+		"No customer has the requested ID."},
+	{CodePaymentMethodNotFound, http.StatusNotFound, "Payment method not found",
+		"The customer has no payment method with the requested ID."},
+	{CodeNotFound, http.StatusNotFound, "Not found",
+		"No route matches the request path."},
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "Method not allowed",
+		"The route exists but does not support the request method."},
+	{CodeUnsupportedVersion, http.StatusNotAcceptable, "Unsupported API version",
+		"The API-Version header or Accept media type asks for a version the gateway does not serve."},
+	{CodeVersionRetired, http.StatusGone, "API version retired",
+		"The requested API version is past its sunset date; see the Link header for its successor."},
+	{CodeUnauthorized, http.StatusUnauthorized, "Unauthorized",
+		"The request lacks valid credentials, such as the admin bearer token."},
+	{CodeConflict, http.StatusConflict, "Conflict",
+		"The request conflicts with the current state, such as starting a job that is already running."},
+	{CodeRateLimited, http.StatusTooManyRequests, "Rate limited",
+		"Too many requests; retry after the time given in Retry-After."},
+	{CodeUpstreamUnavailable, http.StatusBadGateway, "Upstream unavailable",
+		"The acquiring bank could not be reached or answered with an error."},
+	{CodeUpstreamOverloaded, http.StatusServiceUnavailable, "Upstream overloaded",
+		"Too many calls to the acquiring bank are in flight, so the payment was not sent; retry after the time given in Retry-After."},
+	{CodeQueueFull, http.StatusServiceUnavailable, "Queue full",
+		"The queue of payments processed asynchronously is full, so the payment was not accepted; retry after the time given in Retry-After."},
+	{CodeAuthenticationUnavailable, http.StatusBadGateway, "Authentication unavailable",
+		"The 3DS server could not be reached or answered with an error, so the cardholder could not be authenticated with 3-D Secure."},
+	{CodeAuthenticationExpired, http.StatusConflict, "Authentication expired",
+		"The cardholder did not complete the 3-D Secure challenge within three_ds.challenge_timeout, and the payment failed."},
+	{CodeInternalError, http.StatusInternalServerError, "Internal error",
+		"The gateway failed to process the request."},
+}
+
+// Catalogue returns every code with its status, title and description.
+func Catalogue() []Entry {
+	return append([]Entry(nil), catalogue...)
+}
+
+func lookup(code Code) Entry {
+	for _, e := range catalogue {
+		if e.Code == code {
+			return e
+		}
+	}
+	return Entry{Code: code, Status: http.StatusInternalServerError, Title: "Internal error"}
+}
@@ -0,0 +1,87 @@
+// Package problem writes error responses as RFC 7807 Problem Details
+// (application/problem+json). Every problem carries a stable, machine-readable
+// Code from the catalogue in this package; clients should branch on the code,
+// never on the human-readable title or detail.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// typePrefix starts the type URI of every problem. The URN is stable and not
+// meant to be dereferenced; the catalogue is documented in Swagger and
+// DesignDecisions.md.
+const typePrefix = "urn:payment-gateway:problem:"
+
+// FieldError describes one invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed.
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// PaymentStatus is set by payment endpoints so that clients can tell a
+	// rejected payment from a failed one without parsing the code.
+	PaymentStatus string `json:"payment_status,omitempty"`
+}
+
+// New returns the problem for code with the status and title from the
+// catalogue and the given detail.
+func New(code Code, detail string) *Problem {
+	entry := lookup(code)
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  entry.Title,
+		Status: entry.Status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WithErrors adds field errors to p and returns it.
+func (p *Problem) WithErrors(errs ...FieldError) *Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+// WithPaymentStatus sets the payment_status member of p and returns it.
+func (p *Problem) WithPaymentStatus(status string) *Problem {
+	p.PaymentStatus = status
+	return p
+}
+
+// Write sends p as the response to r, filling in the request ID and path.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(CodeNotFound, "No resource at "+r.URL.Path))
+}
+
+// MethodNotAllowed answers requests whose route exists for other methods.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(CodeMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path))
+}
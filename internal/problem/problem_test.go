@@ -0,0 +1,103 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Post("/api/payments", func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.New(problem.CodeValidationFailed, "currency not supported").
+			WithErrors(problem.FieldError{Field: "currency", Message: "currency not supported"}).
+			WithPaymentStatus("Rejected"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/payments", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "urn:payment-gateway:problem:validation_failed",
+		"title": "Validation failed",
+		"status": 400,
+		"detail": "currency not supported",
+		"instance": "/api/payments",
+		"code": "validation_failed",
+		"request_id": "req-1",
+		"errors": [{"field": "currency", "message": "currency not supported"}],
+		"payment_status": "Rejected"
+	}`, w.Body.String())
+}
+
+func TestRouterHandlers(t *testing.T) {
+	router := chi.NewRouter()
+	router.NotFound(problem.NotFound)
+	router.MethodNotAllowed(problem.MethodNotAllowed)
+	router.Get("/api/payments/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantCode   problem.Code
+	}{
+		{http.MethodGet, "/api/unknown", http.StatusNotFound, problem.CodeNotFound},
+		{http.MethodDelete, "/api/payments/1", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.wantCode), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.wantCode, p.Code)
+			assert.Equal(t, tt.wantStatus, p.Status)
+			assert.Equal(t, tt.path, p.Instance)
+		})
+	}
+}
+
+func TestCatalogue_IsDocumented(t *testing.T) {
+	var spec struct {
+		Info struct {
+			Description string `json:"description"`
+		} `json:"info"`
+		Definitions map[string]struct {
+			Enum []string `json:"enum"`
+		} `json:"definitions"`
+	}
+	data, err := os.ReadFile("../../docs/swagger.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &spec))
+
+	enum := spec.Definitions["problem.Code"].Enum
+	seen := map[problem.Code]bool{}
+	for _, entry := range problem.Catalogue() {
+		assert.False(t, seen[entry.Code], "%s is listed twice", entry.Code)
+		seen[entry.Code] = true
+
+		assert.Contains(t, enum, string(entry.Code), "regenerate the Swagger docs with swag init")
+		assert.Contains(t, spec.Info.Description, "| "+string(entry.Code)+" | ", "%s is missing from the error table in main.go", entry.Code)
+		assert.NotEmpty(t, entry.Title)
+		assert.NotEmpty(t, entry.Description)
+		assert.Equal(t, entry.Status, problem.New(entry.Code, "").Status)
+	}
+	assert.Len(t, enum, len(seen), "every documented code is in the catalogue")
+}
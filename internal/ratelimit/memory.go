@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets of idle keys are dropped.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	// tokens is the number of requests left at updated.
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Memory is a Limiter keeping its buckets in memory. Buckets are not shared
+// between gateway instances, so the effective limit grows with the number
+// of instances.
+type Memory struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// MemoryOption configures a Memory limiter.
+type MemoryOption func(*Memory)
+
+// WithClock makes the limiter read the time from now instead of time.Now.
+func WithClock(now func() time.Time) MemoryOption {
+	return func(m *Memory) {
+		m.now = now
+	}
+}
+
+func NewMemory(opts ...MemoryOption) *Memory {
+	m := &Memory{now: time.Now, buckets: make(map[string]*bucket)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (Decision, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+
+	d := Decision{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((1 - b.tokens) * float64(limit.interval()))
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = time.Duration((float64(limit.Burst) - b.tokens) * float64(limit.interval()))
+	return d, nil
+}
+
+// refill adds the requests earned since the last update, up to the bucket
+// size. A bucket whose limit shrank is capped to the new size.
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(b.limit.interval())
+		b.updated = now
+	}
+	if burst := float64(b.limit.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+}
+
+// sweep drops the buckets that have refilled completely: they are
+// indistinguishable from new ones.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
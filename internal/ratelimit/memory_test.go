@@ -0,0 +1,119 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestMemory_Allow(t *testing.T) {
+	// 10 requests per second with room for bursts of 3.
+	limit := ratelimit.Limit{Requests: 10, Per: time.Second, Burst: 3}
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    ratelimit.Decision
+	}{
+		{"First request", 0, ratelimit.Decision{Allowed: true, Limit: 3, Remaining: 2, Reset: 100 * time.Millisecond}},
+		{"Second request", 0, ratelimit.Decision{Allowed: true, Limit: 3, Remaining: 1, Reset: 200 * time.Millisecond}},
+		{"Third request empties the bucket", 0, ratelimit.Decision{Allowed: true, Limit: 3, Remaining: 0, Reset: 300 * time.Millisecond}},
+		{"Fourth request is limited", 0, ratelimit.Decision{Limit: 3, Remaining: 0, Reset: 300 * time.Millisecond, RetryAfter: 100 * time.Millisecond}},
+		{"Partial refill is not enough", 40 * time.Millisecond, ratelimit.Decision{Limit: 3, Remaining: 0, Reset: 260 * time.Millisecond, RetryAfter: 60 * time.Millisecond}},
+		{"One request refilled", 60 * time.Millisecond, ratelimit.Decision{Allowed: true, Limit: 3, Remaining: 0, Reset: 300 * time.Millisecond}},
+		{"Bucket refills up to the burst", time.Hour, ratelimit.Decision{Allowed: true, Limit: 3, Remaining: 2, Reset: 100 * time.Millisecond}},
+	}
+
+	c := &clock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := ratelimit.NewMemory(ratelimit.WithClock(c.Now))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Advance(tt.advance)
+			got, err := limiter.Allow(context.Background(), "merchant:acme", limit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Allowed, got.Allowed)
+			assert.Equal(t, tt.want.Limit, got.Limit)
+			assert.Equal(t, tt.want.Remaining, got.Remaining)
+			assert.InDelta(t, tt.want.Reset, got.Reset, float64(time.Microsecond))
+			assert.InDelta(t, tt.want.RetryAfter, got.RetryAfter, float64(time.Microsecond))
+		})
+	}
+}
+
+func TestMemory_KeysAreIndependent(t *testing.T) {
+	c := &clock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := ratelimit.NewMemory(ratelimit.WithClock(c.Now))
+	limit := ratelimit.Limit{Requests: 1, Per: time.Minute, Burst: 1}
+
+	first, _ := limiter.Allow(context.Background(), "merchant:acme", limit)
+	again, _ := limiter.Allow(context.Background(), "merchant:acme", limit)
+	other, _ := limiter.Allow(context.Background(), "merchant:globex", limit)
+
+	assert.True(t, first.Allowed)
+	assert.False(t, again.Allowed)
+	assert.True(t, other.Allowed, "each key has its own bucket")
+}
+
+func TestMemory_LimitChange(t *testing.T) {
+	c := &clock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := ratelimit.NewMemory(ratelimit.WithClock(c.Now))
+
+	d, _ := limiter.Allow(context.Background(), "ip:10.0.0.1", ratelimit.Limit{Requests: 100, Per: time.Second, Burst: 100})
+	assert.Equal(t, 99, d.Remaining)
+
+	d, _ = limiter.Allow(context.Background(), "ip:10.0.0.1", ratelimit.Limit{Requests: 2, Per: time.Second, Burst: 2})
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 1, d.Remaining, "the bucket is capped to the new burst")
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	c := &clock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := ratelimit.NewMemory(ratelimit.WithClock(c.Now))
+	limit := ratelimit.Limit{Requests: 50, Per: time.Minute, Burst: 50}
+
+	const keys, perKey = 8, 200
+	var allowed [keys]atomic.Int64
+	var wg sync.WaitGroup
+	for k := 0; k < keys; k++ {
+		for i := 0; i < perKey; i++ {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				d, err := limiter.Allow(context.Background(), fmt.Sprintf("merchant:%d", k), limit)
+				if err == nil && d.Allowed {
+					allowed[k].Add(1)
+				}
+			}(k)
+		}
+	}
+	wg.Wait()
+
+	for k := range allowed {
+		assert.EqualValues(t, limit.Burst, allowed[k].Load(), "key %d", k)
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+// DefaultTier is the tier of merchants not assigned to another one, and of
+// requests without a merchant ID.
+const DefaultTier = "default"
+
+// Policy says which Limit applies to a request, by endpoint and merchant
+// tier. Endpoints without a limit are not throttled.
+type Policy struct {
+	tiers     map[string]map[string]Limit
+	merchants map[string]string
+}
+
+// PolicyOption configures a Policy.
+type PolicyOption func(*Policy)
+
+// WithTier adds a tier whose limits replace the default tier's for the
+// endpoints it lists.
+func WithTier(name string, limits map[string]Limit) PolicyOption {
+	return func(p *Policy) {
+		p.tiers[name] = withBursts(limits)
+	}
+}
+
+// WithMerchantTiers assigns merchants to tiers.
+func WithMerchantTiers(tiers map[string]string) PolicyOption {
+	return func(p *Policy) {
+		for merchant, tier := range tiers {
+			p.merchants[merchant] = tier
+		}
+	}
+}
+
+// NewPolicy returns a Policy applying limits, keyed by endpoint, to the
+// default tier. A Limit without a Burst gets a bucket of Requests.
+func NewPolicy(limits map[string]Limit, opts ...PolicyOption) *Policy {
+	p := &Policy{
+		tiers:     map[string]map[string]Limit{DefaultTier: withBursts(limits)},
+		merchants: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Tier returns the tier of merchant.
+func (p *Policy) Tier(merchant string) string {
+	if tier, ok := p.merchants[merchant]; ok {
+		return tier
+	}
+	return DefaultTier
+}
+
+// Limit returns the limit of endpoint in tier. It returns false when the
+// endpoint is not throttled.
+func (p *Policy) Limit(endpoint, tier string) (Limit, bool) {
+	if limit, ok := p.tiers[tier][endpoint]; ok {
+		return limit, true
+	}
+	limit, ok := p.tiers[DefaultTier][endpoint]
+	return limit, ok
+}
+
+func withBursts(limits map[string]Limit) map[string]Limit {
+	out := make(map[string]Limit, len(limits))
+	for endpoint, limit := range limits {
+		if limit.Burst == 0 {
+			limit.Burst = limit.Requests
+		}
+		out[endpoint] = limit
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	payments := ratelimit.Limit{Requests: 10, Per: time.Second}
+	goldPayments := ratelimit.Limit{Requests: 100, Per: time.Second, Burst: 200}
+	lookups := ratelimit.Limit{Requests: 50, Per: time.Second, Burst: 50}
+
+	policy := ratelimit.NewPolicy(
+		map[string]ratelimit.Limit{"create_payment": payments, "get_payment": lookups},
+		ratelimit.WithTier("gold", map[string]ratelimit.Limit{"create_payment": goldPayments}),
+		ratelimit.WithMerchantTiers(map[string]string{"acme": "gold"}),
+	)
+
+	assert.Equal(t, "gold", policy.Tier("acme"))
+	assert.Equal(t, ratelimit.DefaultTier, policy.Tier("globex"))
+	assert.Equal(t, ratelimit.DefaultTier, policy.Tier(""))
+
+	tests := []struct {
+		endpoint string
+		tier     string
+		want     ratelimit.Limit
+		wantOK   bool
+	}{
+		{"create_payment", ratelimit.DefaultTier, ratelimit.Limit{Requests: 10, Per: time.Second, Burst: 10}, true},
+		{"create_payment", "gold", goldPayments, true},
+		{"get_payment", "gold", lookups, true},
+		{"create_token", "gold", ratelimit.Limit{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint+"/"+tt.tier, func(t *testing.T) {
+			got, ok := policy.Limit(tt.endpoint, tt.tier)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Package ratelimit throttles requests with token buckets. A Policy decides
+// which Limit applies to a request and a Limiter keeps the buckets.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit is a token bucket holding up to Burst requests and refilled with
+// Requests every Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// interval is the time it takes to refill one request.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// Decision is the outcome of Limiter.Allow.
+type Decision struct {
+	Allowed bool
+	// Limit is the bucket size.
+	Limit int
+	// Remaining is the number of requests left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter takes one request out of the bucket identified by key.
+// Implementations must be safe for concurrent use.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
@@ -0,0 +1,68 @@
+package subscription
+
+import (
+	"io"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// Request is the domain request creating a subscription.
+type Request struct {
+	CardToken     string   `json:"card_token"`
+	Amount        int      `json:"amount"`
+	Currency      string   `json:"currency"`
+	Interval      Interval `json:"interval"`
+	IntervalCount int      `json:"interval_count,omitempty"`
+	// InitialPaymentID is the authorized customer-initiated payment that
+	// stored the card.
+	InitialPaymentID string `json:"initial_payment_id"`
+	// StartAt is the first charge. It defaults to one billing period after
+	// the subscription is created, the initial payment paying for the
+	// first.
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// validate checks the fields of req that do not depend on other resources.
+// Amount and currency are checked against the payment rules when charged.
+func (req *Request) validate(now time.Time) error {
+	if req.CardToken == "" {
+		return invalid("card_token", "card_token is required")
+	}
+	if req.Amount <= 0 {
+		return invalid("amount", "amount must be greater than 0")
+	}
+	if req.Currency == "" {
+		return invalid("currency", "currency is required")
+	}
+	switch req.Interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+	case "":
+		return invalid("interval", "interval is required")
+	default:
+		return invalid("interval", "interval must be day, week, month or year")
+	}
+	if req.IntervalCount < 0 {
+		return invalid("interval_count", "interval_count must be greater than 0")
+	}
+	if req.InitialPaymentID == "" {
+		return invalid("initial_payment_id", "initial_payment_id is required")
+	}
+	if req.StartAt != nil && req.StartAt.Before(now.Add(-time.Minute)) {
+		return invalid("start_at", "start_at must not be in the past")
+	}
+	return nil
+}
+
+func invalid(field, message string) error {
+	return &payments.ValidationError{Field: field, Message: message}
+}
+
+// Codec translates between the wire models of one API version and
+// subscriptions, so that the handler does not depend on any API version.
+type Codec interface {
+	// DecodeSubscriptionRequest reads a subscription request body.
+	DecodeSubscriptionRequest(r io.Reader) (*Request, error)
+	// EncodeSubscription returns the response model to send for s.
+	EncodeSubscription(s *Subscription) any
+}
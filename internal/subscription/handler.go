@@ -0,0 +1,320 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// Submitter processes one payment exactly as POST /payments does.
+// payments.PaymentsHandler implements it.
+type Submitter interface {
+	Submit(ctx context.Context, merchant string, req payments.PostPaymentRequest) (*payments.Payment, *problem.Problem)
+}
+
+// PaymentLookup returns stored payments, or nil for unknown IDs.
+// payments.PaymentsRepository implements it.
+type PaymentLookup interface {
+	GetPayment(id string) *payments.Payment
+}
+
+type Handler struct {
+	store        *store
+	submitter    Submitter
+	payments     PaymentLookup
+	vault        payments.CardVault
+	fingerprints *payments.CardFingerprinter
+	auditLog     *audit.Log
+	// concurrency bounds the charges made at once.
+	concurrency int
+	// retries are the delays before each retry of a failed charge. A
+	// charge failing once more is not retried.
+	retries []time.Duration
+}
+
+// NewHandler returns a Handler charging subscriptions through submitter, at
+// most concurrency at once, and retrying failed charges after each delay of
+// retries in turn. Subscriptions charge card tokens of vault, stored by
+// payments found with lookup; fingerprints matches the card of a token to
+// the card of its initial payment.
+func NewHandler(submitter Submitter, lookup PaymentLookup, vault payments.CardVault, fingerprints *payments.CardFingerprinter, concurrency int, retries []time.Duration) *Handler {
+	return &Handler{
+		store:        newStore(),
+		submitter:    submitter,
+		payments:     lookup,
+		vault:        vault,
+		fingerprints: fingerprints,
+		concurrency:  concurrency,
+		retries:      retries,
+	}
+}
+
+// SetAuditLog records every created and cancelled subscription in log.
+func (h *Handler) SetAuditLog(log *audit.Log) {
+	h.auditLog = log
+}
+
+// PostHandler returns an http.HandlerFunc that creates a subscription
+// charging the card stored by an initial customer-initiated payment.
+func (h *Handler) PostHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		codec := wire.From[Codec](ctx)
+
+		req, err := codec.DecodeSubscriptionRequest(r.Body)
+		if err != nil {
+			logger.InfoContext(ctx, "rejected malformed subscription request", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid request body format"))
+			return
+		}
+
+		now := time.Now().UTC()
+		merchant := r.Header.Get(payments.MerchantIDHeader)
+		initial, err := h.check(ctx, merchant, req, now)
+		if err != nil {
+			var invalid *payments.ValidationError
+			switch {
+			case errors.As(err, &invalid):
+				logger.InfoContext(ctx, "rejected invalid subscription request", "error", err)
+				problem.Write(w, r, problem.New(problem.CodeValidationFailed, invalid.Message).
+					WithErrors(problem.FieldError{Field: invalid.Field, Message: invalid.Message}))
+			case errors.Is(err, payments.ErrCardTokenNotFound):
+				problem.Write(w, r, problem.New(problem.CodeCardTokenNotFound, "card_token not found"))
+			default:
+				logger.ErrorContext(ctx, "card token lookup failed", "error", err)
+				problem.Write(w, r, problem.New(problem.CodeInternalError, "Card token could not be resolved"))
+			}
+			return
+		}
+
+		sub := &Subscription{
+			ID:                   uuid.New().String(),
+			Merchant:             merchant,
+			Status:               StatusActive,
+			CardToken:            req.CardToken,
+			Amount:               req.Amount,
+			Currency:             req.Currency,
+			Interval:             req.Interval,
+			IntervalCount:        max(req.IntervalCount, 1),
+			InitialPaymentID:     initial.Id,
+			NetworkTransactionID: initial.NetworkTransactionID,
+			CreatedAt:            now,
+			Charges:              []Charge{},
+		}
+		if req.StartAt != nil {
+			sub.StartAt = req.StartAt.UTC()
+		} else {
+			sub.StartAt = now
+			sub.StartAt = sub.periodStart(1)
+		}
+		next := sub.StartAt
+		sub.NextChargeAt = &next
+		h.store.add(sub)
+
+		logger.InfoContext(ctx, "subscription created", "subscription_id", sub.ID, "next_charge_at", next)
+		h.auditLog.Record(ctx, audit.ActionSubscriptionCreated, "subscription:"+sub.ID, nil, map[string]any{
+			"amount":             sub.Amount,
+			"currency":           sub.Currency,
+			"interval":           sub.Interval,
+			"interval_count":     sub.IntervalCount,
+			"initial_payment_id": sub.InitialPaymentID,
+			"start_at":           sub.StartAt,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", r.URL.Path+"/"+sub.ID)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(codec.EncodeSubscription(sub))
+	}
+}
+
+// check validates req and returns its initial payment. The card token must
+// exist and hold the card of the initial payment, compared by fingerprint,
+// which must be an authorized customer-initiated payment of merchant that
+// stored it.
+func (h *Handler) check(ctx context.Context, merchant string, req *Request, now time.Time) (*payments.Payment, error) {
+	if err := req.validate(now); err != nil {
+		return nil, err
+	}
+
+	initial := h.payments.GetPayment(req.InitialPaymentID)
+	switch {
+	case initial == nil || initial.Merchant != merchant:
+		return nil, invalid("initial_payment_id", "initial_payment_id does not match any payment")
+	case initial.StoredCredential == nil || initial.StoredCredential.Sequence != payments.SequenceInitial:
+		return nil, invalid("initial_payment_id", "the initial payment must be sent with stored_credential sequence initial")
+	case initial.PaymentStatus != "Authorized":
+		return nil, invalid("initial_payment_id", "the initial payment was not authorized")
+	case initial.NetworkTransactionID == "":
+		return nil, invalid("initial_payment_id", "the acquirer returned no network_transaction_id for the initial payment")
+	}
+
+	card, err := h.vault.Detokenize(ctx, req.CardToken)
+	if err != nil {
+		return nil, err
+	}
+	if initial.CardFingerprint == "" || h.fingerprints.Fingerprint(card.CardNumber) != initial.CardFingerprint {
+		return nil, invalid("card_token", "card_token does not hold the card of the initial payment")
+	}
+	return initial, nil
+}
+
+// GetHandler returns an http.HandlerFunc that returns a subscription of the
+// requesting merchant and its charges.
+func (h *Handler) GetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		sub := h.store.get(r.Header.Get(payments.MerchantIDHeader), id)
+		if sub == nil {
+			problem.Write(w, r, problem.New(problem.CodeSubscriptionNotFound, "No subscription with ID "+id))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(wire.From[Codec](r.Context()).EncodeSubscription(sub))
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that cancels a subscription of
+// the requesting merchant and returns it. Cancelling it again changes nothing.
+func (h *Handler) DeleteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+		merchant := r.Header.Get(payments.MerchantIDHeader)
+		before := h.store.get(merchant, id)
+		sub := h.store.cancel(merchant, id, time.Now().UTC())
+		if sub == nil {
+			problem.Write(w, r, problem.New(problem.CodeSubscriptionNotFound, "No subscription with ID "+id))
+			return
+		}
+
+		if before != nil && before.Status != StatusCancelled {
+			logging.FromContext(ctx).InfoContext(ctx, "subscription cancelled", "subscription_id", id)
+			h.auditLog.Record(ctx, audit.ActionSubscriptionCancelled, "subscription:"+id,
+				map[string]any{"status": before.Status}, map[string]any{"status": sub.Status})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(wire.From[Codec](ctx).EncodeSubscription(sub))
+	}
+}
+
+// Run charges the subscriptions due every interval until ctx is done. It
+// returns once the charges in flight are finished.
+func (h *Handler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.ChargeDue(context.WithoutCancel(ctx), time.Now().UTC())
+		}
+	}
+}
+
+// ChargeDue charges every subscription due at now and waits for the
+// outcomes.
+func (h *Handler) ChargeDue(ctx context.Context, now time.Time) {
+	var g errgroup.Group
+	g.SetLimit(h.concurrency)
+	for _, sub := range h.store.due(now) {
+		sub := sub
+		g.Go(func() error {
+			h.charge(ctx, sub, now)
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// charge makes the merchant-initiated payment due for sub and schedules the
+// next one: the next period after a success, a retry after a failure that
+// may pass later, and none otherwise.
+func (h *Handler) charge(ctx context.Context, sub *Subscription, now time.Time) {
+	ctx, span := tracing.Tracer().Start(ctx, "subscription.Charge", trace.WithAttributes(
+		attribute.String("subscription.id", sub.ID),
+		attribute.Int("subscription.period", sub.PeriodsPaid+1),
+		attribute.Int("subscription.attempt", sub.FailedAttempts+1),
+	))
+	defer span.End()
+	logger := logging.FromContext(ctx).With("subscription_id", sub.ID, "merchant_id", sub.Merchant)
+	ctx = logging.WithLogger(ctx, logger)
+
+	payment, prob := h.submitter.Submit(ctx, sub.Merchant, payments.PostPaymentRequest{
+		CardToken: sub.CardToken,
+		Currency:  sub.Currency,
+		Amount:    sub.Amount,
+		StoredCredential: &payments.StoredCredential{
+			Initiator:            payments.InitiatorMerchant,
+			Sequence:             payments.SequenceSubsequent,
+			Reason:               payments.ReasonRecurring,
+			NetworkTransactionID: sub.NetworkTransactionID,
+		},
+	})
+
+	c := Charge{Period: sub.PeriodsPaid + 1, Attempt: sub.FailedAttempts + 1, At: now}
+	retry := false
+	if payment != nil {
+		c.PaymentID = payment.Id
+		c.PaymentStatus = payment.PaymentStatus
+		c.DeclineCode = payment.DeclineCode
+		retry = payment.DeclineType == payments.DeclineSoft
+	} else {
+		c.PaymentStatus = prob.PaymentStatus
+		c.Problem = prob
+		// Failed payments never reached a decision by the acquirer;
+		// Rejected ones will be rejected again.
+		retry = prob.PaymentStatus == "Failed"
+	}
+	sub.Charges = append(sub.Charges, c)
+	metrics.SubscriptionChargesTotal.WithLabelValues(c.PaymentStatus).Inc()
+
+	switch {
+	case c.PaymentStatus == "Authorized":
+		sub.Status = StatusActive
+		sub.PeriodsPaid++
+		sub.FailedAttempts = 0
+		next := sub.periodStart(sub.PeriodsPaid)
+		sub.NextChargeAt = &next
+		logger.InfoContext(ctx, "subscription charged", "payment_id", c.PaymentID, "next_charge_at", next)
+
+	case retry && sub.FailedAttempts < len(h.retries):
+		next := now.Add(h.retries[sub.FailedAttempts])
+		sub.Status = StatusPastDue
+		sub.FailedAttempts++
+		sub.NextChargeAt = &next
+		metrics.SubscriptionDunningTotal.WithLabelValues("retry").Inc()
+		logger.WarnContext(ctx, "subscription charge failed, retrying",
+			"payment_status", c.PaymentStatus, "decline_code", c.DeclineCode, "next_charge_at", next)
+
+	default:
+		sub.Status = StatusSuspended
+		sub.FailedAttempts++
+		sub.NextChargeAt = nil
+		metrics.SubscriptionDunningTotal.WithLabelValues("suspended").Inc()
+		logger.WarnContext(ctx, "subscription suspended",
+			"payment_status", c.PaymentStatus, "decline_code", c.DeclineCode, "attempts", sub.FailedAttempts)
+	}
+	h.store.update(sub)
+}
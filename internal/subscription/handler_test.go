@@ -0,0 +1,340 @@
+package subscription_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/subscription"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withV1 serves next with the codec of API v1, as the router does.
+func withV1(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(wire.With[subscription.Codec](r.Context(), v1.Codec{})))
+	})
+}
+
+// fakeSubmitter answers each payment with the next of its statuses, and
+// authorizes payments once they run out. Rejected and Failed statuses are
+// answered with a problem, like payments.PaymentsHandler does.
+type fakeSubmitter struct {
+	mu        sync.Mutex
+	statuses  []string
+	requests  []payments.PostPaymentRequest
+	merchants []string
+}
+
+func (f *fakeSubmitter) Submit(ctx context.Context, merchant string, req payments.PostPaymentRequest) (*payments.Payment, *problem.Problem) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	f.merchants = append(f.merchants, merchant)
+	status := "Authorized"
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+
+	switch status {
+	case "Rejected":
+		return nil, problem.New(problem.CodeValidationFailed, "card expired").WithPaymentStatus(status)
+	case "Failed":
+		return nil, problem.New(problem.CodeUpstreamUnavailable, "bank unavailable").WithPaymentStatus(status)
+	case "SoftDeclined":
+		return &payments.Payment{Id: "pay-soft", PaymentStatus: "Declined", DeclineCode: payments.DeclineInsufficientFunds, DeclineType: payments.DeclineSoft}, nil
+	case "HardDeclined":
+		return &payments.Payment{Id: "pay-hard", PaymentStatus: "Declined", DeclineCode: payments.DeclineLostOrStolen, DeclineType: payments.DeclineHard}, nil
+	}
+	return &payments.Payment{Id: "pay-ok", PaymentStatus: status}, nil
+}
+
+type fakePayments map[string]*payments.Payment
+
+func (f fakePayments) GetPayment(id string) *payments.Payment { return f[id] }
+
+type fakeVault map[string]string
+
+func (f fakeVault) Detokenize(ctx context.Context, token string) (*payments.VaultCard, error) {
+	number, ok := f[token]
+	if !ok {
+		return nil, payments.ErrCardTokenNotFound
+	}
+	return &payments.VaultCard{CardNumber: number, ExpiryMonth: 4, ExpiryYear: 2099}, nil
+}
+
+var fingerprints = payments.NewCardFingerprinter([]byte("test-key"))
+
+var initialPayment = &payments.Payment{
+	Id:                   "pay-initial",
+	Merchant:             "acme",
+	PaymentStatus:        "Authorized",
+	CardNumberLastFour:   "8877",
+	CardFingerprint:      fingerprints.Fingerprint("2222405343248877"),
+	StoredCredential:     &payments.StoredCredential{Initiator: payments.InitiatorCustomer, Sequence: payments.SequenceInitial},
+	NetworkTransactionID: "NTI-1",
+}
+
+var retries = []time.Duration{24 * time.Hour, 72 * time.Hour}
+
+func newHandler(submitter *fakeSubmitter) (*subscription.Handler, *chi.Mux) {
+	h := subscription.NewHandler(submitter,
+		fakePayments{
+			initialPayment.Id: initialPayment,
+			"pay-single":      {Id: "pay-single", PaymentStatus: "Authorized", CardNumberLastFour: "8877"},
+			"pay-declined": {Id: "pay-declined", Merchant: "acme", PaymentStatus: "Declined", CardNumberLastFour: "8877",
+				CardFingerprint: initialPayment.CardFingerprint, StoredCredential: initialPayment.StoredCredential},
+			"pay-other-merchant": {Id: "pay-other-merchant", Merchant: "globex", PaymentStatus: "Authorized", CardNumberLastFour: "8877",
+				CardFingerprint: initialPayment.CardFingerprint, StoredCredential: initialPayment.StoredCredential,
+				NetworkTransactionID: "NTI-2"},
+		},
+		fakeVault{"tok_1": "2222405343248877", "tok_2": "2222405343241111", "tok_3": "4111111111118877"},
+		fingerprints, 2, retries)
+	r := chi.NewRouter()
+	r.Use(withV1)
+	r.Post("/api/subscriptions", h.PostHandler())
+	r.Get("/api/subscriptions/{id}", h.GetHandler())
+	r.Delete("/api/subscriptions/{id}", h.DeleteHandler())
+	return h, r
+}
+
+func send(t *testing.T, r http.Handler, method, path, body string) (*httptest.ResponseRecorder, v1.Subscription) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(payments.MerchantIDHeader, "acme")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var s v1.Subscription
+	if w.Code < 300 {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+	}
+	return w, s
+}
+
+func create(t *testing.T, r http.Handler, startAt time.Time) v1.Subscription {
+	t.Helper()
+	w, s := send(t, r, http.MethodPost, "/api/subscriptions", `{"card_token":"tok_1","amount":1000,"currency":"USD",
+		"interval":"month","initial_payment_id":"pay-initial","start_at":"`+startAt.Format(time.RFC3339)+`"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	return s
+}
+
+func TestHandler_Post(t *testing.T) {
+	_, r := newHandler(&fakeSubmitter{})
+
+	w, s := send(t, r, http.MethodPost, "/api/subscriptions", `{"card_token":"tok_1","amount":1000,"currency":"USD",
+		"interval":"week","interval_count":2,"initial_payment_id":"pay-initial"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "/api/subscriptions/"+s.Id, w.Header().Get("Location"))
+	assert.Equal(t, string(subscription.StatusActive), s.Status)
+	assert.Equal(t, "NTI-1", s.NetworkTransactionID)
+	assert.Equal(t, s.CreatedAt.AddDate(0, 0, 14), s.StartAt, "the initial payment pays for the first period")
+	require.NotNil(t, s.NextChargeAt)
+	assert.Equal(t, s.StartAt, *s.NextChargeAt)
+	assert.Empty(t, s.Charges)
+
+	w, got := send(t, r, http.MethodGet, "/api/subscriptions/"+s.Id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, s.Id, got.Id)
+}
+
+func TestHandler_Post_Invalid(t *testing.T) {
+	_, r := newHandler(&fakeSubmitter{})
+	body := func(token, initial, interval string) string {
+		return `{"card_token":"` + token + `","amount":1000,"currency":"USD","interval":"` + interval + `","initial_payment_id":"` + initial + `"}`
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  problem.Code
+		wantField string
+	}{
+		{"Malformed", "{", problem.CodeMalformedRequest, ""},
+		{"Unknown interval", body("tok_1", "pay-initial", "fortnight"), problem.CodeValidationFailed, "interval"},
+		{"Unknown initial payment", body("tok_1", "pay-missing", "month"), problem.CodeValidationFailed, "initial_payment_id"},
+		{"Initial payment without stored credential", body("tok_1", "pay-single", "month"), problem.CodeValidationFailed, "initial_payment_id"},
+		{"Initial payment declined", body("tok_1", "pay-declined", "month"), problem.CodeValidationFailed, "initial_payment_id"},
+		{"Unknown token", body("tok_missing", "pay-initial", "month"), problem.CodeCardTokenNotFound, ""},
+		{"Initial payment of another merchant", body("tok_1", "pay-other-merchant", "month"), problem.CodeValidationFailed, "initial_payment_id"},
+		{"Token of another card", body("tok_2", "pay-initial", "month"), problem.CodeValidationFailed, "card_token"},
+		{"Token of another card with the same last four", body("tok_3", "pay-initial", "month"), problem.CodeValidationFailed, "card_token"},
+		{"Start in the past", `{"card_token":"tok_1","amount":1000,"currency":"USD","interval":"month","initial_payment_id":"pay-initial","start_at":"2020-01-01T00:00:00Z"}`,
+			problem.CodeValidationFailed, "start_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := send(t, r, http.MethodPost, "/api/subscriptions", tt.body)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.wantCode, p.Code)
+			if tt.wantField != "" {
+				require.Len(t, p.Errors, 1)
+				assert.Equal(t, tt.wantField, p.Errors[0].Field)
+			}
+		})
+	}
+}
+
+func TestHandler_ChargeDue(t *testing.T) {
+	submitter := &fakeSubmitter{}
+	h, r := newHandler(submitter)
+	start := time.Date(2099, 1, 31, 9, 0, 0, 0, time.UTC)
+	s := create(t, r, start)
+
+	h.ChargeDue(context.Background(), start.Add(-time.Second))
+	assert.Empty(t, submitter.requests, "nothing is due before the start")
+
+	h.ChargeDue(context.Background(), start)
+	require.Len(t, submitter.requests, 1)
+	assert.Equal(t, payments.PostPaymentRequest{
+		CardToken: "tok_1",
+		Currency:  "USD",
+		Amount:    1000,
+		StoredCredential: &payments.StoredCredential{
+			Initiator:            payments.InitiatorMerchant,
+			Sequence:             payments.SequenceSubsequent,
+			Reason:               payments.ReasonRecurring,
+			NetworkTransactionID: "NTI-1",
+		},
+	}, submitter.requests[0])
+	assert.Equal(t, []string{"acme"}, submitter.merchants, "charged on behalf of the subscription's merchant")
+
+	_, got := send(t, r, http.MethodGet, "/api/subscriptions/"+s.Id, "")
+	assert.Equal(t, string(subscription.StatusActive), got.Status)
+	assert.Equal(t, 1, got.PeriodsPaid)
+	require.NotNil(t, got.NextChargeAt)
+	assert.Equal(t, time.Date(2099, 2, 28, 9, 0, 0, 0, time.UTC), *got.NextChargeAt, "monthly charges keep to the last day of shorter months")
+	assert.Equal(t, []v1.SubscriptionCharge{{Period: 1, Attempt: 1, At: start, PaymentId: "pay-ok", PaymentStatus: "Authorized"}}, got.Charges)
+
+	h.ChargeDue(context.Background(), *got.NextChargeAt)
+	_, got = send(t, r, http.MethodGet, "/api/subscriptions/"+s.Id, "")
+	assert.Equal(t, 2, got.PeriodsPaid)
+	assert.Equal(t, time.Date(2099, 3, 31, 9, 0, 0, 0, time.UTC), *got.NextChargeAt)
+}
+
+func TestHandler_ChargeDue_Dunning(t *testing.T) {
+	start := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		statuses   []string
+		wantStatus subscription.Status
+		wantPaid   int
+		wantNext   *time.Time
+	}{
+		{
+			name:       "Soft decline recovered",
+			statuses:   []string{"SoftDeclined", "Failed", "Authorized"},
+			wantStatus: subscription.StatusActive,
+			wantPaid:   1,
+			wantNext:   ptr(time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:       "Retries exhausted",
+			statuses:   []string{"SoftDeclined", "SoftDeclined", "SoftDeclined"},
+			wantStatus: subscription.StatusSuspended,
+		},
+		{
+			name:       "Hard decline",
+			statuses:   []string{"HardDeclined"},
+			wantStatus: subscription.StatusSuspended,
+		},
+		{
+			name:       "Rejected",
+			statuses:   []string{"Rejected"},
+			wantStatus: subscription.StatusSuspended,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submitter := &fakeSubmitter{statuses: tt.statuses}
+			h, r := newHandler(submitter)
+			s := create(t, r, start)
+
+			now := start
+			for range tt.statuses {
+				h.ChargeDue(context.Background(), now)
+				_, s = send(t, r, http.MethodGet, "/api/subscriptions/"+s.Id, "")
+				if s.Status == string(subscription.StatusPastDue) {
+					assert.Equal(t, now.Add(retries[s.Charges[len(s.Charges)-1].Attempt-1]), *s.NextChargeAt, "retried on the dunning schedule")
+				}
+				if s.NextChargeAt == nil {
+					break
+				}
+				now = *s.NextChargeAt
+			}
+
+			assert.Equal(t, string(tt.wantStatus), s.Status)
+			assert.Equal(t, tt.wantPaid, s.PeriodsPaid)
+			assert.Equal(t, tt.wantNext, s.NextChargeAt)
+			assert.Len(t, s.Charges, len(tt.statuses))
+			for i, c := range s.Charges {
+				assert.Equal(t, 1, c.Period)
+				assert.Equal(t, i+1, c.Attempt)
+			}
+		})
+	}
+}
+
+func TestHandler_Delete(t *testing.T) {
+	submitter := &fakeSubmitter{}
+	h, r := newHandler(submitter)
+	start := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := create(t, r, start)
+
+	w, got := send(t, r, http.MethodDelete, "/api/subscriptions/"+s.Id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(subscription.StatusCancelled), got.Status)
+	assert.Nil(t, got.NextChargeAt)
+	require.NotNil(t, got.CancelledAt)
+
+	w, again := send(t, r, http.MethodDelete, "/api/subscriptions/"+s.Id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, got.CancelledAt, again.CancelledAt, "cancelling again changes nothing")
+
+	h.ChargeDue(context.Background(), start)
+	assert.Empty(t, submitter.requests, "cancelled subscriptions are not charged")
+
+	w, _ = send(t, r, http.MethodDelete, "/api/subscriptions/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w, _ = send(t, r, http.MethodGet, "/api/subscriptions/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_OtherMerchant(t *testing.T) {
+	submitter := &fakeSubmitter{}
+	h, r := newHandler(submitter)
+	start := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := create(t, r, start)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			req := httptest.NewRequest(method, "/api/subscriptions/"+s.Id, nil)
+			req.Header.Set(payments.MerchantIDHeader, "globex")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, problem.CodeSubscriptionNotFound, p.Code)
+		})
+	}
+
+	h.ChargeDue(context.Background(), start)
+	assert.Len(t, submitter.requests, 1, "another merchant cannot cancel the subscription")
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,125 @@
+// Package subscription charges stored cards on a schedule. Each charge is a
+// merchant-initiated payment, linked to the customer-initiated payment that
+// stored the card by its network transaction ID, and made through the same
+// path as single payments. Charges declined for a reason that may pass later
+// are retried on a dunning schedule.
+package subscription
+
+import (
+	"slices"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+)
+
+// Status is the state of a subscription.
+type Status string
+
+const (
+	// StatusActive subscriptions are charged at the start of each period.
+	StatusActive Status = "Active"
+	// StatusPastDue subscriptions failed their last charge, which is
+	// retried on the dunning schedule.
+	StatusPastDue Status = "PastDue"
+	// StatusSuspended subscriptions are no longer charged: a charge was
+	// declined for good, rejected, or failed every retry.
+	StatusSuspended Status = "Suspended"
+	// StatusCancelled subscriptions were cancelled by the merchant.
+	StatusCancelled Status = "Cancelled"
+)
+
+// Interval is the unit of a billing period.
+type Interval string
+
+const (
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+	IntervalYear  Interval = "year"
+)
+
+// Subscription charges a stored card the same amount every billing period.
+type Subscription struct {
+	ID        string `json:"id"`
+	Merchant  string `json:"merchant,omitempty"`
+	Status    Status `json:"status"`
+	CardToken string `json:"card_token"`
+	Amount    int    `json:"amount"`
+	Currency  string `json:"currency"`
+	// Interval and IntervalCount give the length of a billing period, such
+	// as 3 months.
+	Interval      Interval `json:"interval"`
+	IntervalCount int      `json:"interval_count"`
+	// InitialPaymentID is the customer-initiated payment that stored the
+	// card, whose NetworkTransactionID every charge sends.
+	InitialPaymentID     string    `json:"initial_payment_id"`
+	NetworkTransactionID string    `json:"network_transaction_id"`
+	CreatedAt            time.Time `json:"created_at"`
+	// StartAt is the start of the first billing period; later periods
+	// start one period apart from it.
+	StartAt time.Time `json:"start_at"`
+	// PeriodsPaid counts the billing periods charged successfully.
+	PeriodsPaid int `json:"periods_paid"`
+	// FailedAttempts counts the failed charges of the current period.
+	FailedAttempts int `json:"failed_attempts"`
+	// NextChargeAt is when the next charge is due. It is nil once the
+	// subscription is Suspended or Cancelled.
+	NextChargeAt *time.Time `json:"next_charge_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	// Charges lists every charge made, oldest first.
+	Charges []Charge `json:"charges"`
+}
+
+// Charge is one payment attempted for a subscription.
+type Charge struct {
+	// Period is the billing period charged, from 1.
+	Period int `json:"period"`
+	// Attempt counts the charges of the period, from 1.
+	Attempt int       `json:"attempt"`
+	At      time.Time `json:"at"`
+	// PaymentID identifies the stored payment. It is empty when no payment
+	// was stored.
+	PaymentID     string               `json:"payment_id,omitempty"`
+	PaymentStatus string               `json:"payment_status"`
+	DeclineCode   payments.DeclineCode `json:"decline_code,omitempty"`
+	// Problem says why no payment was stored: the charge was Rejected or
+	// Failed.
+	Problem *problem.Problem `json:"problem,omitempty"`
+}
+
+// periodStart returns the start of billing period n, counted from 0. Monthly
+// and yearly periods keep the day of StartAt, or the last day of shorter
+// months.
+func (s *Subscription) periodStart(n int) time.Time {
+	count := n * s.IntervalCount
+	switch s.Interval {
+	case IntervalDay:
+		return s.StartAt.AddDate(0, 0, count)
+	case IntervalWeek:
+		return s.StartAt.AddDate(0, 0, 7*count)
+	case IntervalYear:
+		return addMonths(s.StartAt, 12*count)
+	default:
+		return addMonths(s.StartAt, count)
+	}
+}
+
+// addMonths adds months to t without overflowing into the following month
+// when its day does not exist there: January 31 plus one month is the last
+// day of February.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	first := time.Date(year, month+time.Month(months), 1, hour, min, sec, t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func (s *Subscription) clone() *Subscription {
+	c := *s
+	c.Charges = slices.Clone(s.Charges)
+	return &c
+}
@@ -0,0 +1,138 @@
+package subscription
+
+import "time"
+
+type getRequest struct {
+	merchant, id string
+	respChan     chan *Subscription
+}
+
+type dueRequest struct {
+	now      time.Time
+	respChan chan []*Subscription
+}
+
+type cancelRequest struct {
+	merchant, id string
+	at           time.Time
+	respChan     chan *Subscription
+}
+
+// store keeps subscriptions in memory. Like the payments repository it owns
+// its state in a single monitor goroutine, and hands out copies.
+// Subscriptions are only found by the merchant that created them; to any
+// other, they do not exist.
+type store struct {
+	addChan    chan *Subscription
+	updateChan chan *Subscription
+	getChan    chan getRequest
+	dueChan    chan dueRequest
+	cancelChan chan cancelRequest
+}
+
+func newStore() *store {
+	s := &store{
+		addChan:    make(chan *Subscription),
+		updateChan: make(chan *Subscription),
+		getChan:    make(chan getRequest),
+		dueChan:    make(chan dueRequest),
+		cancelChan: make(chan cancelRequest),
+	}
+
+	go s.monitor()
+
+	return s
+}
+
+func (s *store) monitor() {
+	subscriptions := make(map[string]*Subscription)
+	find := func(merchant, id string) (*Subscription, bool) {
+		sub, ok := subscriptions[id]
+		if !ok || sub.Merchant != merchant {
+			return nil, false
+		}
+		return sub, true
+	}
+
+	for {
+		select {
+		case sub := <-s.addChan:
+			subscriptions[sub.ID] = sub
+
+		case sub := <-s.updateChan:
+			stored, ok := subscriptions[sub.ID]
+			switch {
+			case !ok:
+			case stored.Status == StatusCancelled:
+				// Cancelled while being charged: keep the charge, not
+				// the schedule.
+				stored.Charges = sub.Charges
+			default:
+				subscriptions[sub.ID] = sub
+			}
+
+		case req := <-s.getChan:
+			var found *Subscription
+			if sub, ok := find(req.merchant, req.id); ok {
+				found = sub.clone()
+			}
+			req.respChan <- found
+
+		case req := <-s.dueChan:
+			var due []*Subscription
+			for _, sub := range subscriptions {
+				if sub.NextChargeAt != nil && !sub.NextChargeAt.After(req.now) {
+					due = append(due, sub.clone())
+				}
+			}
+			req.respChan <- due
+
+		case req := <-s.cancelChan:
+			var found *Subscription
+			if sub, ok := find(req.merchant, req.id); ok {
+				if sub.Status != StatusCancelled {
+					at := req.at
+					sub.Status = StatusCancelled
+					sub.CancelledAt = &at
+					sub.NextChargeAt = nil
+				}
+				found = sub.clone()
+			}
+			req.respChan <- found
+		}
+	}
+}
+
+// add stores a copy of sub.
+func (s *store) add(sub *Subscription) {
+	s.addChan <- sub.clone()
+}
+
+// update replaces a stored subscription with a copy of sub, unless it was
+// cancelled in the meantime; only its charges are updated then.
+func (s *store) update(sub *Subscription) {
+	s.updateChan <- sub.clone()
+}
+
+// get returns the subscription of merchant with id, or nil when there is
+// none.
+func (s *store) get(merchant, id string) *Subscription {
+	respChan := make(chan *Subscription)
+	s.getChan <- getRequest{merchant: merchant, id: id, respChan: respChan}
+	return <-respChan
+}
+
+// due returns the subscriptions with a charge due at now.
+func (s *store) due(now time.Time) []*Subscription {
+	respChan := make(chan []*Subscription)
+	s.dueChan <- dueRequest{now: now, respChan: respChan}
+	return <-respChan
+}
+
+// cancel cancels a subscription and returns it, or nil when merchant has
+// none with id. Cancelling a cancelled subscription changes nothing.
+func (s *store) cancel(merchant, id string, at time.Time) *Subscription {
+	respChan := make(chan *Subscription)
+	s.cancelChan <- cancelRequest{merchant: merchant, id: id, at: at, respChan: respChan}
+	return <-respChan
+}
@@ -0,0 +1,152 @@
+// Package threeds authenticates cardholders with 3-D Secure through a 3DS
+// server, and simulates a 3DS server with its ACS so that the whole flow can
+// be exercised offline.
+//
+// The 3DS server API is a JSON rendering of the EMV 3-D Secure messages:
+// POST /authentications starts an authentication (AReq/ARes) and GET
+// /authentications/{id} returns its outcome once a challenge ended (RReq).
+package threeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// DefaultTimeout bounds calls to the 3DS server.
+const DefaultTimeout = 5 * time.Second
+
+// authenticationRequest is the body of POST /authentications.
+type authenticationRequest struct {
+	PaymentID       string `json:"payment_id"`
+	CardNumber      string `json:"card_number"`
+	ExpiryDate      string `json:"expiry_date"` // Format: "MM/YYYY"
+	Currency        string `json:"currency"`
+	Amount          int    `json:"amount"`
+	MerchantID      string `json:"merchant_id,omitempty"`
+	NotificationURL string `json:"notification_url"`
+}
+
+// authenticationResponse is the outcome of an authentication.
+type authenticationResponse struct {
+	ThreeDSServerTransID string `json:"three_ds_server_trans_id"`
+	DSTransID            string `json:"ds_trans_id,omitempty"`
+	MessageVersion       string `json:"message_version"`
+	// TransStatus is Y, A, N, R, U or C.
+	TransStatus         string `json:"trans_status"`
+	ECI                 string `json:"eci,omitempty"`
+	AuthenticationValue string `json:"authentication_value,omitempty"`
+	ChallengeURL        string `json:"challenge_url,omitempty"`
+}
+
+// transStatuses maps EMV transStatus values to authentication statuses.
+var transStatuses = map[string]payments.AuthenticationStatus{
+	"Y": payments.AuthenticationSucceeded,
+	"A": payments.AuthenticationAttempted,
+	"N": payments.AuthenticationFailed,
+	"R": payments.AuthenticationRejected,
+	"U": payments.AuthenticationUnavailable,
+	"C": payments.AuthenticationChallengeRequired,
+}
+
+// Client calls a 3DS server. It implements payments.Authenticator.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Option customises a Client built by NewClient.
+type Option func(*Client)
+
+// WithTimeout bounds each call to the 3DS server.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.client.Timeout = timeout
+	}
+}
+
+// NewClient returns a Client of the 3DS server at baseURL.
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Authenticate starts the authentication of a payment.
+func (c *Client) Authenticate(ctx context.Context, req *payments.AuthenticationRequest) (*payments.Authentication, error) {
+	body, err := json.Marshal(authenticationRequest{
+		PaymentID:       req.PaymentID,
+		CardNumber:      req.CardNumber,
+		ExpiryDate:      fmt.Sprintf("%02d/%d", req.ExpiryMonth, req.ExpiryYear),
+		Currency:        req.Currency,
+		Amount:          req.Amount,
+		MerchantID:      req.Merchant,
+		NotificationURL: req.NotificationURL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal authentication request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/authentications", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return c.do(httpReq)
+}
+
+// Result returns the outcome of the authentication transactionID.
+func (c *Client) Result(ctx context.Context, transactionID string) (*payments.Authentication, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.baseURL+"/authentications/"+url.PathEscape(transactionID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(httpReq)
+}
+
+func (c *Client) do(req *http.Request) (*payments.Authentication, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("3DS server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("3DS server answered %d", resp.StatusCode)
+	}
+
+	var ares authenticationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ares); err != nil {
+		return nil, fmt.Errorf("failed to decode 3DS server response: %w", err)
+	}
+	status, ok := transStatuses[ares.TransStatus]
+	if !ok {
+		return nil, fmt.Errorf("3DS server returned unknown trans_status %q", ares.TransStatus)
+	}
+	if status == payments.AuthenticationChallengeRequired && ares.ChallengeURL == "" {
+		return nil, fmt.Errorf("3DS server asked for a challenge without challenge_url")
+	}
+	return &payments.Authentication{
+		TransactionID:   ares.ThreeDSServerTransID,
+		Version:         ares.MessageVersion,
+		Status:          status,
+		ECI:             ares.ECI,
+		CAVV:            ares.AuthenticationValue,
+		DSTransactionID: ares.DSTransID,
+		// Fraud liability shifts to the issuer for authenticated payments
+		// and for attempts.
+		LiabilityShift: status == payments.AuthenticationSucceeded || status == payments.AuthenticationAttempted,
+		ChallengeURL:   ares.ChallengeURL,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package threeds_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/threeds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func authenticationRequest(card string) *payments.AuthenticationRequest {
+	return &payments.AuthenticationRequest{
+		PaymentID:       "pay-1",
+		CardNumber:      card,
+		ExpiryMonth:     4,
+		ExpiryYear:      2099,
+		Currency:        "EUR",
+		Amount:          5000,
+		Merchant:        "acme",
+		NotificationURL: "https://gateway.test/api/payments/pay-1/3ds/complete",
+	}
+}
+
+func TestClient_Authenticate(t *testing.T) {
+	srv := httptest.NewServer(threeds.NewSimulator())
+	defer srv.Close()
+	client := threeds.NewClient(srv.URL)
+
+	tests := []struct {
+		name           string
+		card           string
+		wantStatus     payments.AuthenticationStatus
+		wantECI        string
+		liabilityShift bool
+	}{
+		{name: "Frictionless", card: "2222405343248877", wantStatus: payments.AuthenticationSucceeded, wantECI: "05", liabilityShift: true},
+		{name: "Attempted", card: "2222405343248857", wantStatus: payments.AuthenticationAttempted, wantECI: "06", liabilityShift: true},
+		{name: "Failed", card: "2222405343248887", wantStatus: payments.AuthenticationFailed, wantECI: "07"},
+		{name: "Unavailable", card: "2222405343248867", wantStatus: payments.AuthenticationUnavailable, wantECI: "07"},
+		{name: "Challenge", card: "2222405343248891", wantStatus: payments.AuthenticationChallengeRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := client.Authenticate(context.Background(), authenticationRequest(tt.card))
+			require.NoError(t, err)
+			assert.NotEmpty(t, auth.TransactionID)
+			assert.NotEmpty(t, auth.DSTransactionID)
+			assert.Equal(t, "2.2.0", auth.Version)
+			assert.Equal(t, tt.wantStatus, auth.Status)
+			assert.Equal(t, tt.wantECI, auth.ECI)
+			assert.Equal(t, tt.liabilityShift, auth.LiabilityShift)
+			assert.Equal(t, tt.liabilityShift, auth.CAVV != "", "only authenticated payments have a CAVV")
+			if tt.wantStatus == payments.AuthenticationChallengeRequired {
+				assert.Equal(t, srv.URL+"/challenge/"+auth.TransactionID, auth.ChallengeURL)
+			} else {
+				assert.Empty(t, auth.ChallengeURL)
+			}
+		})
+	}
+}
+
+func TestClient_Result(t *testing.T) {
+	srv := httptest.NewServer(threeds.NewSimulator())
+	defer srv.Close()
+	client := threeds.NewClient(srv.URL)
+
+	auth, err := client.Authenticate(context.Background(), authenticationRequest("2222405343248891"))
+	require.NoError(t, err)
+
+	pending, err := client.Result(context.Background(), auth.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, payments.AuthenticationChallengeRequired, pending.Status)
+
+	completeChallenge(t, auth.ChallengeURL, threeds.SimulatorOTP)
+	result, err := client.Result(context.Background(), auth.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, payments.AuthenticationSucceeded, result.Status)
+	assert.Equal(t, "05", result.ECI)
+	assert.NotEmpty(t, result.CAVV)
+	assert.True(t, result.LiabilityShift)
+
+	_, err = client.Result(context.Background(), "unknown")
+	assert.EqualError(t, err, "3DS server answered 404")
+}
+
+func TestClient_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "Unknown status", body: `{"three_ds_server_trans_id":"t-1","trans_status":"X"}`, wantErr: `3DS server returned unknown trans_status "X"`},
+		{name: "Challenge without URL", body: `{"three_ds_server_trans_id":"t-1","trans_status":"C"}`, wantErr: "3DS server asked for a challenge without challenge_url"},
+		{name: "Malformed", body: `{`, wantErr: "failed to decode 3DS server response: unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := threeds.NewClient(srv.URL).Authenticate(context.Background(), authenticationRequest("2222405343248877"))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client := threeds.NewClient(srv.URL, threeds.WithTimeout(10*time.Millisecond))
+	_, err := client.Authenticate(context.Background(), authenticationRequest("2222405343248877"))
+	assert.ErrorContains(t, err, "3DS server request failed")
+}
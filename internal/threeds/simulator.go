@@ -0,0 +1,271 @@
+package threeds
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// SimulatorOTP is the one-time password that passes the challenges of the
+// Simulator. Any other fails them.
+const SimulatorOTP = "123456"
+
+// simulatorVersion is the EMV 3-D Secure version the Simulator answers with.
+const simulatorVersion = "2.2.0"
+
+// Simulator is a 3DS server and ACS for tests and local development. The
+// outcome depends on the second-to-last digit of the card number:
+//
+//	9	the cardholder is challenged; SimulatorOTP passes the challenge
+//	8	authentication fails without a challenge (N)
+//	6	authentication is unavailable (U)
+//	5	authentication is attempted (A)
+//
+// Every other card is authenticated without a challenge (Y).
+type Simulator struct {
+	publicURL string
+	router    chi.Router
+
+	mu           sync.Mutex
+	transactions map[string]*transaction
+}
+
+// transaction is an authentication held by the Simulator.
+type transaction struct {
+	response        authenticationResponse
+	notificationURL string
+	cardNumber      string
+	amount          int
+	currency        string
+}
+
+// SimulatorOption customises a Simulator built by NewSimulator.
+type SimulatorOption func(*Simulator)
+
+// WithPublicURL sets the base URL of the challenge pages as reached by
+// cardholders' browsers. By default it is the URL the authentication request
+// was sent to.
+func WithPublicURL(publicURL string) SimulatorOption {
+	return func(s *Simulator) {
+		s.publicURL = strings.TrimSuffix(publicURL, "/")
+	}
+}
+
+// NewSimulator returns a Simulator holding no authentications.
+func NewSimulator(opts ...SimulatorOption) *Simulator {
+	s := &Simulator{transactions: make(map[string]*transaction)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	r := chi.NewRouter()
+	r.Post("/authentications", s.authenticate)
+	r.Get("/authentications/{id}", s.result)
+	r.Get("/challenge/{id}", s.challengePage)
+	r.Post("/challenge/{id}", s.completeChallenge)
+	s.router = r
+	return s
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// authenticate answers an authentication request (AReq) without a
+// challenge, or asks for one.
+func (s *Simulator) authenticate(w http.ResponseWriter, r *http.Request) {
+	var req authenticationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.CardNumber) < 2 || req.NotificationURL == "" {
+		http.Error(w, "card_number and notification_url are required", http.StatusBadRequest)
+		return
+	}
+
+	id := uuid.New().String()
+	t := &transaction{
+		response: authenticationResponse{
+			ThreeDSServerTransID: id,
+			DSTransID:            uuid.New().String(),
+			MessageVersion:       simulatorVersion,
+		},
+		notificationURL: req.NotificationURL,
+		cardNumber:      req.CardNumber,
+		amount:          req.Amount,
+		currency:        req.Currency,
+	}
+	switch req.CardNumber[len(req.CardNumber)-2] {
+	case '9':
+		t.response.TransStatus = "C"
+		t.response.ChallengeURL = s.baseURL(r) + "/challenge/" + id
+	case '8':
+		t.conclude("N")
+	case '6':
+		t.conclude("U")
+	case '5':
+		t.conclude("A")
+	default:
+		t.conclude("Y")
+	}
+
+	s.mu.Lock()
+	s.transactions[id] = t
+	response := t.response
+	s.mu.Unlock()
+	writeJSON(w, response)
+}
+
+// result returns the outcome of an authentication (RReq).
+func (s *Simulator) result(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	t, ok := s.transactions[chi.URLParam(r, "id")]
+	var response authenticationResponse
+	if ok {
+		response = t.response
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, response)
+}
+
+// challengePage serves the form asking the cardholder for the one-time
+// password.
+func (s *Simulator) challengePage(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.pendingChallenge(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "no challenge is pending for this authentication", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	challengeTemplate.Execute(w, map[string]any{
+		"Amount":   t.amount,
+		"Currency": t.currency,
+		"LastFour": t.cardNumber[len(t.cardNumber)-4:],
+		"OTP":      SimulatorOTP,
+	})
+}
+
+// completeChallenge checks the one-time password and sends the
+// cardholder's browser to the notification URL with the challenge response
+// (CRes).
+func (s *Simulator) completeChallenge(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.mu.Lock()
+	t, ok := s.transactions[id]
+	if ok && t.response.TransStatus == "C" {
+		if r.PostFormValue("otp") == SimulatorOTP {
+			t.conclude("Y")
+		} else {
+			t.conclude("N")
+		}
+	} else {
+		ok = false
+	}
+	var status, notificationURL string
+	if ok {
+		status, notificationURL = t.response.TransStatus, t.notificationURL
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "no challenge is pending for this authentication", http.StatusNotFound)
+		return
+	}
+
+	cres, _ := json.Marshal(map[string]string{
+		"threeDSServerTransID": id,
+		"messageType":          "CRes",
+		"messageVersion":       simulatorVersion,
+		"transStatus":          status,
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	notifyTemplate.Execute(w, map[string]any{
+		"NotificationURL": notificationURL,
+		"CRes":            base64.RawURLEncoding.EncodeToString(cres),
+	})
+}
+
+// pendingChallenge returns a copy of the authentication id when it awaits a
+// challenge.
+func (s *Simulator) pendingChallenge(id string) (transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transactions[id]
+	if !ok || t.response.TransStatus != "C" {
+		return transaction{}, false
+	}
+	return *t, true
+}
+
+// baseURL returns the base URL of the challenge pages.
+func (s *Simulator) baseURL(r *http.Request) string {
+	if s.publicURL != "" {
+		return s.publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// conclude sets the final outcome of t, with the ECI and CAVV a scheme
+// would give it.
+func (t *transaction) conclude(status string) {
+	t.response.TransStatus = status
+	t.response.ChallengeURL = ""
+	switch status {
+	case "Y":
+		t.response.ECI = "05"
+		t.response.AuthenticationValue = newCAVV()
+	case "A":
+		t.response.ECI = "06"
+		t.response.AuthenticationValue = newCAVV()
+	default:
+		t.response.ECI = "07"
+	}
+}
+
+// newCAVV returns a random 20-byte cryptogram, the size of a real one.
+func newCAVV() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+var challengeTemplate = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+<head><title>3-D Secure simulator</title></head>
+<body>
+<h1>Confirm your payment</h1>
+<p>Payment of {{.Amount}} (minor units) {{.Currency}} with the card ending {{.LastFour}}.</p>
+<form method="post">
+<label>One-time password (use {{.OTP}}): <input name="otp" autocomplete="one-time-code" autofocus></label>
+<button type="submit">Confirm</button>
+</form>
+</body>
+</html>
+`))
+
+var notifyTemplate = template.Must(template.New("notify").Parse(`<!DOCTYPE html>
+<html>
+<head><title>3-D Secure simulator</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.NotificationURL}}">
+<input type="hidden" name="cres" value="{{.CRes}}">
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
@@ -0,0 +1,108 @@
+package threeds_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/threeds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cresField = regexp.MustCompile(`name="cres" value="([^"]+)"`)
+
+// completeChallenge submits otp to the challenge page, as the cardholder
+// would, and returns the decoded challenge response posted back to the
+// notification URL.
+func completeChallenge(t *testing.T, challengeURL, otp string) map[string]string {
+	t.Helper()
+	resp, err := http.PostForm(challengeURL, url.Values{"otp": {otp}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	page, _ := io.ReadAll(resp.Body)
+
+	m := cresField.FindSubmatch(page)
+	require.NotNil(t, m, "the page posts the challenge response")
+	raw, err := base64.RawURLEncoding.DecodeString(string(m[1]))
+	require.NoError(t, err)
+	var cres map[string]string
+	require.NoError(t, json.Unmarshal(raw, &cres))
+	return cres
+}
+
+func startChallenge(t *testing.T, srv *httptest.Server) map[string]string {
+	t.Helper()
+	body := `{"payment_id":"pay-1","card_number":"2222405343248891","expiry_date":"04/2099","currency":"EUR","amount":5000,"notification_url":"https://gateway.test/complete"}`
+	resp, err := http.Post(srv.URL+"/authentications", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var ares map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&ares))
+	require.Equal(t, "C", ares["trans_status"])
+	return ares
+}
+
+func TestSimulator_Challenge(t *testing.T) {
+	srv := httptest.NewServer(threeds.NewSimulator())
+	defer srv.Close()
+	ares := startChallenge(t, srv)
+
+	resp, err := http.Get(ares["challenge_url"])
+	require.NoError(t, err)
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(page), "card ending 8891")
+	assert.Contains(t, string(page), threeds.SimulatorOTP)
+
+	resp, err = http.PostForm(ares["challenge_url"], url.Values{"otp": {threeds.SimulatorOTP}})
+	require.NoError(t, err)
+	page, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(page), `action="https://gateway.test/complete"`)
+	m := cresField.FindSubmatch(page)
+	require.NotNil(t, m)
+	raw, err := base64.RawURLEncoding.DecodeString(string(m[1]))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"threeDSServerTransID":"`+ares["three_ds_server_trans_id"]+`","messageType":"CRes","messageVersion":"2.2.0","transStatus":"Y"}`, string(raw))
+
+	resp, err = http.Get(ares["challenge_url"])
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "the challenge can be completed once")
+}
+
+func TestSimulator_ChallengeWithWrongOTP(t *testing.T) {
+	srv := httptest.NewServer(threeds.NewSimulator())
+	defer srv.Close()
+	ares := startChallenge(t, srv)
+
+	cres := completeChallenge(t, ares["challenge_url"], "000000")
+	assert.Equal(t, "N", cres["transStatus"])
+}
+
+func TestSimulator_PublicURL(t *testing.T) {
+	srv := httptest.NewServer(threeds.NewSimulator(threeds.WithPublicURL("http://localhost:8100/")))
+	defer srv.Close()
+	ares := startChallenge(t, srv)
+
+	assert.Equal(t, "http://localhost:8100/challenge/"+ares["three_ds_server_trans_id"], ares["challenge_url"])
+}
+
+func TestSimulator_InvalidRequest(t *testing.T) {
+	srv := httptest.NewServer(threeds.NewSimulator())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/authentications", "application/json", strings.NewReader(`{"card_number":"2222405343248877"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
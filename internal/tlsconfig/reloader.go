@@ -0,0 +1,96 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertReloader serves a certificate loaded from a certificate and a key
+// file, and loads them again when they change, so that renewed
+// certificates are picked up without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+
+	mu    sync.Mutex
+	state [2]fileState
+}
+
+// NewCertReloader loads the PEM certificate chain in certFile and its key
+// in keyFile.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate. It has the signature of
+// tls.Config.GetCertificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// Reload loads the files again. The current certificate is kept when they
+// cannot be loaded, for example while they are being rewritten.
+func (c *CertReloader) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := [2]fileState{stat(c.certFile), stat(c.keyFile)}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	c.cert.Store(&cert)
+	c.state = state
+	return nil
+}
+
+// Watch reloads the certificate whenever either file changes, checking
+// every interval, until ctx is done.
+func (c *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			changed := c.state != [2]fileState{stat(c.certFile), stat(c.keyFile)}
+			c.mu.Unlock()
+			if !changed {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				slog.Error("failed to reload TLS certificate, keeping the current one", "file", c.certFile, "error", err)
+				continue
+			}
+			slog.Info("TLS certificate reloaded", "file", c.certFile)
+		}
+	}
+}
+
+// fileState is what Watch compares to detect a changed file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}
+}
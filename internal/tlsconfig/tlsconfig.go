@@ -0,0 +1,136 @@
+// Package tlsconfig builds the TLS settings of the gateway from files and
+// names found in the configuration, and keeps served certificates up to date
+// when their files change.
+package tlsconfig
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion returns the TLS version named "1.2" or "1.3". Older versions
+// are not accepted.
+func ParseVersion(name string) (uint16, error) {
+	if v, ok := versions[name]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q (supported: 1.2, 1.3)", name)
+}
+
+// ParseCipherSuites returns the IDs of the named cipher suites, using the
+// names of crypto/tls (e.g. "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256").
+// Suites that crypto/tls considers insecure are rejected.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unsupported cipher suite %q", name))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, errors.Join(errs...)
+}
+
+// CipherSuiteNames lists the names accepted by ParseCipherSuites.
+func CipherSuiteNames() []string {
+	var names []string
+	for _, suite := range tls.CipherSuites() {
+		names = append(names, suite.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadCertPool reads the PEM certificates in file.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", file)
+	}
+	return pool, nil
+}
+
+// Identity returns the identity of a peer certificate: its subject common
+// name, or its first DNS name when the common name is empty.
+func Identity(cert *x509.Certificate) string {
+	if name := strings.TrimSpace(cert.Subject.CommonName); name != "" {
+		return name
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+// ParseFingerprint decodes a SHA-256 certificate fingerprint written in hex,
+// optionally with colons between bytes as printed by openssl.
+func ParseFingerprint(s string) ([]byte, error) {
+	sum, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("%q is not a hex SHA-256 fingerprint", s)
+	}
+	return sum, nil
+}
+
+// Fingerprint returns the SHA-256 of the DER encoding of cert.
+func Fingerprint(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.Raw)
+	return sum[:]
+}
+
+// LoadPrivateKey reads a PEM private key (PKCS #8, PKCS #1 or SEC 1) usable
+// for signatures: an ECDSA, RSA or Ed25519 key.
+func LoadPrivateKey(file string) (crypto.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key in %s", file)
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key in %s: %w", file, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T in %s", key, file)
+	}
+	return signer, nil
+}
@@ -0,0 +1,162 @@
+package tlsconfig_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tlsconfig/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    uint16
+		wantErr bool
+	}{
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.1", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tlsconfig.ParseVersion(tt.name)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := tlsconfig.ParseCipherSuites([]string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, ids)
+
+	_, err = tlsconfig.ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA", "TLS_NOPE"})
+	assert.ErrorContains(t, err, `unsupported cipher suite "TLS_RSA_WITH_RC4_128_SHA"`, "insecure suites are rejected")
+	assert.ErrorContains(t, err, `unsupported cipher suite "TLS_NOPE"`)
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, "test-ca")
+
+	pool, err := tlsconfig.LoadCertPool(tlstest.WriteFile(t, dir, "ca.pem", ca.PEM))
+	require.NoError(t, err)
+	assert.True(t, pool.Equal(ca.Pool()))
+
+	_, err = tlsconfig.LoadCertPool(tlstest.WriteFile(t, dir, "empty.pem", []byte("not pem")))
+	assert.ErrorContains(t, err, "no PEM certificates")
+
+	_, err = tlsconfig.LoadCertPool(filepath.Join(dir, "missing.pem"))
+	assert.ErrorContains(t, err, "failed to read CA file")
+}
+
+func TestIdentity(t *testing.T) {
+	assert.Equal(t, "acme", tlsconfig.Identity(&x509.Certificate{DNSNames: []string{"ignored"}, Subject: pkix.Name{CommonName: "acme"}}))
+	assert.Equal(t, "acme.example", tlsconfig.Identity(&x509.Certificate{DNSNames: []string{"acme.example"}}))
+	assert.Empty(t, tlsconfig.Identity(&x509.Certificate{}))
+}
+
+func TestParseFingerprint(t *testing.T) {
+	ca := tlstest.NewCA(t, "test-ca")
+	want := tlsconfig.Fingerprint(ca.Cert)
+	hexSum := hex.EncodeToString(want)
+
+	for _, s := range []string{hexSum, strings.ToUpper(hexSum), colons(hexSum)} {
+		got, err := tlsconfig.ParseFingerprint(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got)
+	}
+	for _, s := range []string{"", "zz", hexSum[:40]} {
+		_, err := tlsconfig.ParseFingerprint(s)
+		assert.ErrorContains(t, err, "is not a hex SHA-256 fingerprint", s)
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	_, keyPEM := tlstest.NewCA(t, "test-ca").Issue(t, "acquirer")
+
+	key, err := tlsconfig.LoadPrivateKey(tlstest.WriteFile(t, dir, "ec.pem", keyPEM))
+	require.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, key)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	key, err = tlsconfig.LoadPrivateKey(tlstest.WriteFile(t, dir, "rsa.pem",
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	require.NoError(t, err)
+	assert.IsType(t, &rsa.PrivateKey{}, key)
+
+	_, err = tlsconfig.LoadPrivateKey(tlstest.WriteFile(t, dir, "garbage.pem", []byte("not pem")))
+	assert.ErrorContains(t, err, "no PEM private key")
+	_, err = tlsconfig.LoadPrivateKey(filepath.Join(dir, "missing.pem"))
+	assert.ErrorContains(t, err, "failed to read private key")
+}
+
+func colons(hexSum string) string {
+	var pairs []string
+	for i := 0; i < len(hexSum); i += 2 {
+		pairs = append(pairs, hexSum[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, "test-ca")
+	certPEM, keyPEM := ca.Issue(t, "gateway-1")
+	certFile := tlstest.WriteFile(t, dir, "cert.pem", certPEM)
+	keyFile := tlstest.WriteFile(t, dir, "key.pem", keyPEM)
+
+	reloader, err := tlsconfig.NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "gateway-1", commonName(t, reloader))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	// A half-written renewal keeps the current certificate.
+	certPEM, keyPEM = ca.Issue(t, "gateway-2")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, "gateway-1", commonName(t, reloader))
+
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	assert.Eventually(t, func() bool { return commonName(t, reloader) == "gateway-2" }, 2*time.Second, 10*time.Millisecond,
+		"the renewed certificate is picked up by Watch")
+
+	_, err = tlsconfig.NewCertReloader(certFile, filepath.Join(dir, "missing.pem"))
+	assert.ErrorContains(t, err, "failed to load certificate")
+}
+
+func commonName(t *testing.T, r *tlsconfig.CertReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
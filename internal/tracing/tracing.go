@@ -0,0 +1,103 @@
+// Package tracing wires OpenTelemetry tracing for the gateway: provider and
+// exporter setup, the tracer used by every package, and W3C trace context
+// propagation.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/LuizZucchi/payment-gateway-challenge-go"
+	serviceName         = "payment-gateway"
+)
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Response headers carrying the trace and span IDs of a request, so merchants
+// can quote them to support.
+const (
+	TraceIDHeader = "X-Trace-Id"
+	SpanIDHeader  = "X-Span-Id"
+)
+
+// Propagator extracts and injects W3C traceparent/tracestate and baggage.
+// It is used directly rather than through the otel global so propagation does
+// not depend on Setup having been called.
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracer returns the tracer used for all gateway spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the global tracer provider. exporter is one of ExporterNone
+// (or empty), ExporterStdout ("console" is accepted as in the OpenTelemetry
+// OTEL_TRACES_EXPORTER convention) or ExporterOTLP; the OTLP exporter is
+// configured through the standard OTEL_EXPORTER_OTLP_* environment variables.
+// The returned function flushes and stops the provider.
+func Setup(ctx context.Context, exporter, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(Propagator)
+
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout, "console":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Inject writes the trace context of ctx into h.
+func Inject(ctx context.Context, h http.Header) {
+	Propagator.Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// Extract returns ctx enriched with the trace context found in h.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return Propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
@@ -0,0 +1,57 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	header := http.Header{}
+	tracing.Inject(ctx, header)
+
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", header.Get("traceparent"))
+
+	extracted := trace.SpanContextFromContext(tracing.Extract(context.Background(), header))
+	assert.Equal(t, traceID, extracted.TraceID())
+	assert.Equal(t, spanID, extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{name: "Disabled (empty)", exporter: ""},
+		{name: "Disabled (none)", exporter: tracing.ExporterNone},
+		{name: "Stdout", exporter: tracing.ExporterStdout},
+		{name: "Unknown", exporter: "zipkin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := tracing.Setup(context.Background(), tt.exporter, "test")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package vault
+
+import (
+	"io"
+)
+
+// Codec translates between the wire models of one API version and the
+// vault's Card and Token, so that the handler does not depend on any API
+// version.
+type Codec interface {
+	// DecodeTokenRequest reads a tokenization request body.
+	DecodeTokenRequest(r io.Reader) (*Card, error)
+	// EncodeToken returns the response model to send for t.
+	EncodeToken(t *Token) any
+}
@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+)
+
+// envelope is a PAN encrypted under a fresh data key, with the data key
+// itself encrypted (wrapped) under a master key from the key ring. Rotating
+// the master key only rewraps the data key; the PAN ciphertext is untouched.
+type envelope struct {
+	keyID      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+// sealEnvelope encrypts plaintext under a new data key wrapped by the
+// ring's active key. aad is authenticated but not encrypted; it binds the
+// envelope to its token so that records cannot be swapped.
+func sealEnvelope(ring *keyring.Ring, plaintext, aad []byte) (envelope, error) {
+	dataKey := make([]byte, keyring.KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return envelope{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	dek, err := keyring.NewAEAD(dataKey)
+	if err != nil {
+		return envelope{}, err
+	}
+	ciphertext, err := keyring.Seal(dek, plaintext, aad)
+	if err != nil {
+		return envelope{}, err
+	}
+	keyID, wrappedKey, err := ring.Seal(dataKey, aad)
+	if err != nil {
+		return envelope{}, err
+	}
+	return envelope{keyID: keyID, wrappedKey: wrappedKey, ciphertext: ciphertext}, nil
+}
+
+// openEnvelope reverses sealEnvelope with whichever ring key wrapped env.
+func openEnvelope(ring *keyring.Ring, env envelope, aad []byte) ([]byte, error) {
+	dataKey, err := ring.Open(env.keyID, env.wrappedKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	dek, err := keyring.NewAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := keyring.Open(dek, env.ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt card data: %w", err)
+	}
+	return plaintext, nil
+}
+
+// rewrapEnvelope re-encrypts the data key of env under the ring's active key.
+func rewrapEnvelope(ring *keyring.Ring, env envelope, aad []byte) (envelope, error) {
+	dataKey, err := ring.Open(env.keyID, env.wrappedKey, aad)
+	if err != nil {
+		return envelope{}, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	keyID, wrappedKey, err := ring.Seal(dataKey, aad)
+	if err != nil {
+		return envelope{}, err
+	}
+	return envelope{keyID: keyID, wrappedKey: wrappedKey, ciphertext: env.ciphertext}, nil
+}
@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+)
+
+// The envelope format is internal, so these tests live in package vault.
+
+func testRing(t *testing.T, active string, ids ...string) *keyring.Ring {
+	t.Helper()
+	keys := make([]keyring.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = keyring.Key{ID: id, Material: bytes.Repeat([]byte(id[len(id)-1:]), keyring.KeySize)}
+	}
+	ring, err := keyring.New(active, keys...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return ring
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	ring := testRing(t, "v1", "v1")
+	pan := []byte("4111111111111111")
+
+	env, err := sealEnvelope(ring, pan, []byte("tok_a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if env.keyID != "v1" {
+		t.Errorf("Expected key v1, got %q", env.keyID)
+	}
+	if bytes.Contains(env.ciphertext, pan) || bytes.Contains(env.wrappedKey, pan) {
+		t.Error("Envelope contains the PAN in clear text")
+	}
+
+	got, err := openEnvelope(ring, env, []byte("tok_a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, pan) {
+		t.Errorf("Expected %s, got %s", pan, got)
+	}
+}
+
+func TestEnvelope_Tampering(t *testing.T) {
+	ring := testRing(t, "v1", "v1")
+	otherRing := testRing(t, "v2", "v2")
+
+	env, err := sealEnvelope(ring, []byte("4111111111111111"), []byte("tok_a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := openEnvelope(otherRing, env, []byte("tok_a")); err == nil {
+		t.Error("Expected error with the wrong master key, got nil")
+	}
+	if _, err := openEnvelope(ring, env, []byte("tok_b")); err == nil {
+		t.Error("Expected error when the envelope is moved to another token, got nil")
+	}
+
+	env.ciphertext[len(env.ciphertext)-1] ^= 0xff
+	if _, err := openEnvelope(ring, env, []byte("tok_a")); err == nil {
+		t.Error("Expected error for modified ciphertext, got nil")
+	}
+}
+
+func TestEnvelope_Rewrap(t *testing.T) {
+	old := testRing(t, "v1", "v1")
+	rotated := testRing(t, "v2", "v1", "v2")
+	pan := []byte("4111111111111111")
+
+	env, err := sealEnvelope(old, pan, []byte("tok_a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rewrapped, err := rewrapEnvelope(rotated, env, []byte("tok_a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rewrapped.keyID != "v2" {
+		t.Errorf("Expected key v2, got %q", rewrapped.keyID)
+	}
+	if !bytes.Equal(rewrapped.ciphertext, env.ciphertext) {
+		t.Error("Expected the card ciphertext to be left untouched")
+	}
+
+	onlyNew := testRing(t, "v2", "v2")
+	got, err := openEnvelope(onlyNew, rewrapped, []byte("tok_a"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, pan) {
+		t.Errorf("Expected %s, got %s", pan, got)
+	}
+}
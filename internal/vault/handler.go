@@ -0,0 +1,74 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/audit"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/problem"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+)
+
+type TokensHandler struct {
+	vault    *Vault
+	auditLog *audit.Log
+}
+
+func NewTokensHandler(vault *Vault) *TokensHandler {
+	return &TokensHandler{vault: vault}
+}
+
+// SetAuditLog records every issued token in log.
+func (h *TokensHandler) SetAuditLog(log *audit.Log) {
+	h.auditLog = log
+}
+
+// PostHandler returns an http.HandlerFunc that exchanges card data for a
+// token.
+func (h *TokensHandler) PostHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		codec := wire.From[Codec](ctx)
+
+		card, err := codec.DecodeTokenRequest(r.Body)
+		if err != nil {
+			logger.InfoContext(ctx, "rejected malformed token request", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeMalformedRequest, "Invalid request body format"))
+			return
+		}
+
+		if err := payments.ValidateCard(card.Number, card.ExpiryMonth, card.ExpiryYear); err != nil {
+			logger.InfoContext(ctx, "rejected invalid token request", "error", err)
+			p := problem.New(problem.CodeValidationFailed, err.Error())
+			var invalid *payments.ValidationError
+			if errors.As(err, &invalid) {
+				p.WithErrors(problem.FieldError{Field: invalid.Field, Message: invalid.Message})
+			}
+			problem.Write(w, r, p)
+			return
+		}
+
+		card.Merchant = r.Header.Get(payments.MerchantIDHeader)
+		token, err := h.vault.Tokenize(ctx, *card)
+		if err != nil {
+			logger.ErrorContext(ctx, "tokenization failed", "error", err)
+			problem.Write(w, r, problem.New(problem.CodeInternalError, "Card could not be tokenized"))
+			return
+		}
+
+		logger.InfoContext(ctx, "card tokenized", "card_number_last_four", token.LastFour)
+		h.auditLog.Record(ctx, audit.ActionTokenCreated, "token:"+token.Token, nil, map[string]any{
+			"card_number_last_four": token.LastFour,
+			"expiry_month":          token.ExpiryMonth,
+			"expiry_year":           token.ExpiryYear,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(codec.EncodeToken(token))
+	}
+}
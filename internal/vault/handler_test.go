@@ -0,0 +1,114 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/LuizZucchi/payment-gateway-challenge-go/internal/api/v1"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/vault"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withV1 serves next with the codec of API v1, as the router does.
+func withV1(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(wire.With[vault.Codec](r.Context(), v1.Codec{})))
+	})
+}
+
+func TestPostTokenHandler(t *testing.T) {
+	nextYear := time.Now().Year() + 1
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:           "Success",
+			body:           fmt.Sprintf(`{"card_number":"2222405343248877","expiry_month":4,"expiry_year":%d}`, nextYear),
+			expectedStatus: http.StatusCreated,
+			expectedBody: map[string]interface{}{
+				"card_number_last_four": "8877",
+				"expiry_month":          float64(4),
+				"expiry_year":           float64(nextYear),
+			},
+		},
+		{
+			name:           "Invalid card number",
+			body:           fmt.Sprintf(`{"card_number":"2222-4053","expiry_month":4,"expiry_year":%d}`, nextYear),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"code":   "validation_failed",
+				"detail": "card_number must contain only numeric characters",
+				"errors": []interface{}{map[string]interface{}{
+					"field":   "card_number",
+					"message": "card_number must contain only numeric characters",
+				}},
+			},
+		},
+		{
+			name:           "Expired card",
+			body:           `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2001}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "validation_failed", "detail": "expiry_year must be in the future"},
+		},
+		{
+			name:           "Malformed body",
+			body:           `{"card_number":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]interface{}{"code": "malformed_request", "detail": "Invalid request body format"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newVault(t)
+			handler := vault.NewTokensHandler(v)
+
+			req, _ := http.NewRequest("POST", "/api/tokens", bytes.NewBufferString(tt.body))
+			req.Header.Set(payments.MerchantIDHeader, "acme")
+			w := httptest.NewRecorder()
+			withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			var respBody map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &respBody))
+			for key, expectedValue := range tt.expectedBody {
+				assert.Equal(t, expectedValue, respBody[key], "Field %s mismatch", key)
+			}
+
+			if w.Code == http.StatusCreated {
+				token, ok := respBody["token"].(string)
+				require.True(t, ok, "Response should contain a token")
+				card, err := v.Detokenize(context.Background(), token)
+				require.NoError(t, err)
+				assert.Equal(t, "2222405343248877", card.CardNumber)
+				assert.Equal(t, "acme", card.Merchant, "tokens record the merchant that created them")
+			}
+		})
+	}
+}
+
+func TestPostTokenHandler_IgnoresCVV(t *testing.T) {
+	handler := vault.NewTokensHandler(newVault(t))
+
+	body := fmt.Sprintf(`{"card_number":"2222405343248877","expiry_month":4,"expiry_year":%d,"cvv":"987"}`, time.Now().Year()+1)
+	req, _ := http.NewRequest("POST", "/api/tokens", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	withV1(handler.PostHandler()).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "987")
+	assert.NotContains(t, w.Body.String(), "cvv")
+}
@@ -0,0 +1,16 @@
+package vault
+
+// PostTokenRequest is the card data exchanged for a token. There is
+// deliberately no CVV field: it must not be stored, so it is not accepted.
+type PostTokenRequest struct {
+	CardNumber  string `json:"card_number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+type PostTokenResponse struct {
+	Token              string `json:"token"`
+	CardNumberLastFour string `json:"card_number_last_four"`
+	ExpiryMonth        int    `json:"expiry_month"`
+	ExpiryYear         int    `json:"expiry_year"`
+}
@@ -0,0 +1,152 @@
+package vault
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// record is what the vault keeps per token. It never contains the CVV or the
+// PAN in clear text.
+type record struct {
+	token       string
+	card        envelope
+	lastFour    string
+	expiryMonth int
+	expiryYear  int
+	merchant    string
+	createdAt   time.Time
+}
+
+type getRecordRequest struct {
+	token    string
+	respChan chan *record
+}
+
+type staleRecordsRequest struct {
+	activeKeyID string
+	after       string
+	limit       int
+	respChan    chan []string
+}
+
+// store keeps vault records in memory. Like the payments repository it owns
+// its state in a single monitor goroutine, but it is a separate instance so
+// card data never shares storage with payment records.
+type store struct {
+	putChan   chan record
+	getChan   chan getRecordRequest
+	staleChan chan staleRecordsRequest
+	usageChan chan chan map[string]int
+	pingChan  chan chan struct{}
+}
+
+func newStore() *store {
+	s := &store{
+		putChan:   make(chan record),
+		getChan:   make(chan getRecordRequest),
+		staleChan: make(chan staleRecordsRequest),
+		usageChan: make(chan chan map[string]int),
+		pingChan:  make(chan chan struct{}),
+	}
+
+	go s.monitor()
+
+	return s
+}
+
+func (s *store) monitor() {
+	records := make(map[string]record)
+
+	for {
+		select {
+		case r := <-s.putChan:
+			records[r.token] = r
+
+		case req := <-s.getChan:
+			var found *record
+			if r, ok := records[req.token]; ok {
+				found = &r
+			}
+			req.respChan <- found
+
+		case req := <-s.staleChan:
+			var tokens []string
+			for token, r := range records {
+				if token > req.after && r.card.keyID != req.activeKeyID {
+					tokens = append(tokens, token)
+				}
+			}
+			sort.Strings(tokens)
+			if len(tokens) > req.limit {
+				tokens = tokens[:req.limit]
+			}
+			req.respChan <- tokens
+
+		case respChan := <-s.usageChan:
+			usage := make(map[string]int)
+			for _, r := range records {
+				usage[r.card.keyID]++
+			}
+			respChan <- usage
+
+		case pong := <-s.pingChan:
+			close(pong)
+		}
+	}
+}
+
+func (s *store) put(r record) {
+	s.putChan <- r
+}
+
+func (s *store) get(token string) *record {
+	respChan := make(chan *record)
+
+	s.getChan <- getRecordRequest{
+		token:    token,
+		respChan: respChan,
+	}
+
+	return <-respChan
+}
+
+// stale returns, in ascending order, up to limit tokens greater than after
+// whose data key is not wrapped by activeKeyID.
+func (s *store) stale(activeKeyID, after string, limit int) []string {
+	respChan := make(chan []string)
+
+	s.staleChan <- staleRecordsRequest{
+		activeKeyID: activeKeyID,
+		after:       after,
+		limit:       limit,
+		respChan:    respChan,
+	}
+
+	return <-respChan
+}
+
+// keyUsage returns the number of records per wrapping key ID.
+func (s *store) keyUsage() map[string]int {
+	respChan := make(chan map[string]int)
+	s.usageChan <- respChan
+	return <-respChan
+}
+
+// ping round-trips through the monitor goroutine.
+func (s *store) ping(ctx context.Context) error {
+	pong := make(chan struct{})
+
+	select {
+	case s.pingChan <- pong:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-pong:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
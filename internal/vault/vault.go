@@ -0,0 +1,224 @@
+// Package vault exchanges card data for opaque tokens. PANs are kept with
+// envelope encryption: each card is encrypted under its own data key, which
+// is in turn encrypted under a master key from a keyring.Ring. The CVV is
+// never stored.
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// TokenPrefix starts every token so they are easy to tell apart from PANs.
+const TokenPrefix = "tok_"
+
+// tokenBytes is the amount of randomness in a token.
+const tokenBytes = 24
+
+// Card is the card data accepted by Tokenize.
+type Card struct {
+	Number      string
+	ExpiryMonth int
+	ExpiryYear  int
+	// Merchant is the merchant tokenizing the card.
+	Merchant string
+}
+
+// Token describes a stored card without revealing it.
+type Token struct {
+	Token       string
+	LastFour    string
+	ExpiryMonth int
+	ExpiryYear  int
+	CreatedAt   time.Time
+}
+
+type Vault struct {
+	store *store
+
+	// mu guards ring. Writers to the store hold it for reading so that
+	// SetKeyRing can check which keys are in use without racing them.
+	mu   sync.RWMutex
+	ring *keyring.Ring
+}
+
+// New returns a Vault whose data keys are wrapped by the keys in ring.
+func New(ring *keyring.Ring) *Vault {
+	return &Vault{ring: ring, store: newStore()}
+}
+
+// SetKeyRing replaces the master keys. It fails, keeping the current ring,
+// if a key that still wraps stored records would be dropped; re-encrypt
+// those records with a keyring.Job first.
+func (v *Vault) SetKeyRing(ring *keyring.Ring) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.CheckKeyRing(ring); err != nil {
+		return err
+	}
+	v.ring = ring
+	return nil
+}
+
+// CheckKeyRing reports whether ring holds every key that wraps a stored
+// record.
+func (v *Vault) CheckKeyRing(ring *keyring.Ring) error {
+	usage := v.store.keyUsage()
+	for _, id := range sortedKeys(usage) {
+		if !ring.Has(id) {
+			return fmt.Errorf("vault key %q still wraps %d records; re-encrypt them before removing it", id, usage[id])
+		}
+	}
+	return nil
+}
+
+// ActiveKeyID returns the ID of the master key wrapping new data keys.
+func (v *Vault) ActiveKeyID() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.ring.ActiveID()
+}
+
+// KeyUsage returns the number of stored records per master key ID.
+func (v *Vault) KeyUsage() map[string]int {
+	return v.store.keyUsage()
+}
+
+// Tokenize encrypts and stores card and returns a new token for it.
+func (v *Vault) Tokenize(ctx context.Context, card Card) (*Token, error) {
+	_, span := tracing.Tracer().Start(ctx, "vault.Tokenize")
+	defer span.End()
+
+	token, err := newToken()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	number := strings.ReplaceAll(card.Number, " ", "")
+	env, err := sealEnvelope(v.ring, []byte(number), []byte(token))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	r := record{
+		token:       token,
+		card:        env,
+		lastFour:    number[len(number)-4:],
+		expiryMonth: card.ExpiryMonth,
+		expiryYear:  card.ExpiryYear,
+		merchant:    card.Merchant,
+		createdAt:   time.Now().UTC(),
+	}
+	v.store.put(r)
+
+	return &Token{
+		Token:       r.token,
+		LastFour:    r.lastFour,
+		ExpiryMonth: r.expiryMonth,
+		ExpiryYear:  r.expiryYear,
+		CreatedAt:   r.createdAt,
+	}, nil
+}
+
+// Detokenize returns the card stored under token. It implements
+// payments.CardVault.
+func (v *Vault) Detokenize(ctx context.Context, token string) (*payments.VaultCard, error) {
+	_, span := tracing.Tracer().Start(ctx, "vault.Detokenize")
+	defer span.End()
+
+	r := v.store.get(token)
+	if r == nil {
+		span.SetStatus(codes.Error, payments.ErrCardTokenNotFound.Error())
+		return nil, payments.ErrCardTokenNotFound
+	}
+
+	v.mu.RLock()
+	ring := v.ring
+	v.mu.RUnlock()
+
+	number, err := openEnvelope(ring, r.card, []byte(r.token))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return &payments.VaultCard{
+		CardNumber:  string(number),
+		ExpiryMonth: r.expiryMonth,
+		ExpiryYear:  r.expiryYear,
+		Merchant:    r.merchant,
+	}, nil
+}
+
+// Stale returns, in ascending order, up to limit tokens greater than after
+// whose data key is not wrapped by the active master key. Together with
+// Rotate it implements keyring.Rotatable.
+func (v *Vault) Stale(ctx context.Context, after string, limit int) ([]string, error) {
+	v.mu.RLock()
+	active := v.ring.ActiveID()
+	v.mu.RUnlock()
+
+	return v.store.stale(active, after, limit), nil
+}
+
+// Rotate rewraps the data key of the record stored under token with the
+// active master key. The card ciphertext itself is not touched.
+func (v *Vault) Rotate(ctx context.Context, token string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	r := v.store.get(token)
+	if r == nil {
+		return payments.ErrCardTokenNotFound
+	}
+	if r.card.keyID == v.ring.ActiveID() {
+		return nil
+	}
+
+	env, err := rewrapEnvelope(v.ring, r.card, []byte(r.token))
+	if err != nil {
+		return err
+	}
+	r.card = env
+	v.store.put(*r)
+	return nil
+}
+
+// Ping round-trips through the vault store.
+func (v *Vault) Ping(ctx context.Context) error {
+	return v.store.ping(ctx)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,116 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRing(t *testing.T, active string, ids ...string) *keyring.Ring {
+	t.Helper()
+	keys := make([]keyring.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = keyring.Key{ID: id, Material: bytes.Repeat([]byte(id), keyring.KeySize/len(id))}
+	}
+	ring, err := keyring.New(active, keys...)
+	require.NoError(t, err)
+	return ring
+}
+
+func newVault(t *testing.T) *vault.Vault {
+	t.Helper()
+	return vault.New(newRing(t, "v1", "v1"))
+}
+
+func TestVault_TokenizeDetokenize(t *testing.T) {
+	v := newVault(t)
+	ctx := context.Background()
+
+	token, err := v.Tokenize(ctx, vault.Card{Number: "4111 1111 1111 1111", ExpiryMonth: 4, ExpiryYear: 2030, Merchant: "acme"})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(token.Token, vault.TokenPrefix))
+	assert.NotContains(t, token.Token, "4111")
+	assert.Equal(t, "1111", token.LastFour)
+	assert.Equal(t, 4, token.ExpiryMonth)
+	assert.Equal(t, 2030, token.ExpiryYear)
+
+	card, err := v.Detokenize(ctx, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, &payments.VaultCard{CardNumber: "4111111111111111", ExpiryMonth: 4, ExpiryYear: 2030, Merchant: "acme"}, card)
+}
+
+func TestVault_TokensAreUnique(t *testing.T) {
+	v := newVault(t)
+	card := vault.Card{Number: "4111111111111111", ExpiryMonth: 4, ExpiryYear: 2030}
+
+	first, err := v.Tokenize(context.Background(), card)
+	require.NoError(t, err)
+	second, err := v.Tokenize(context.Background(), card)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.Token, second.Token)
+}
+
+func TestVault_Detokenize_UnknownToken(t *testing.T) {
+	v := newVault(t)
+
+	_, err := v.Detokenize(context.Background(), "tok_unknown")
+	assert.ErrorIs(t, err, payments.ErrCardTokenNotFound)
+}
+
+func TestVault_IsolatedInstances(t *testing.T) {
+	a, b := newVault(t), newVault(t)
+
+	token, err := a.Tokenize(context.Background(), vault.Card{Number: "4111111111111111", ExpiryMonth: 4, ExpiryYear: 2030})
+	require.NoError(t, err)
+
+	_, err = b.Detokenize(context.Background(), token.Token)
+	assert.ErrorIs(t, err, payments.ErrCardTokenNotFound)
+}
+
+func TestVault_Ping(t *testing.T) {
+	assert.NoError(t, newVault(t).Ping(context.Background()))
+}
+
+func TestVault_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	v := newVault(t)
+
+	var tokens []string
+	for i := 0; i < 5; i++ {
+		token, err := v.Tokenize(ctx, vault.Card{Number: "4111111111111111", ExpiryMonth: 4, ExpiryYear: 2030})
+		require.NoError(t, err)
+		tokens = append(tokens, token.Token)
+	}
+	assert.Equal(t, map[string]int{"v1": 5}, v.KeyUsage())
+
+	// Retiring a key that still wraps records is refused.
+	assert.ErrorContains(t, v.SetKeyRing(newRing(t, "v2", "v2")), `vault key "v1" still wraps 5 records`)
+
+	require.NoError(t, v.SetKeyRing(newRing(t, "v2", "v1", "v2")))
+	assert.Equal(t, "v2", v.ActiveKeyID())
+
+	fresh, err := v.Tokenize(ctx, vault.Card{Number: "5555555555554444", ExpiryMonth: 4, ExpiryYear: 2030})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"v1": 5, "v2": 1}, v.KeyUsage())
+
+	progress, err := keyring.NewJob("vault", v, 2).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, keyring.JobCompleted, progress.State)
+	assert.Equal(t, 5, progress.Rotated)
+	assert.Equal(t, map[string]int{"v2": 6}, v.KeyUsage())
+
+	require.NoError(t, v.SetKeyRing(newRing(t, "v2", "v2")))
+	for _, token := range append(tokens, fresh.Token) {
+		_, err := v.Detokenize(ctx, token)
+		assert.NoError(t, err, "token %s must still resolve after rotation", token)
+	}
+}
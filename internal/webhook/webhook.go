@@ -0,0 +1,258 @@
+// Package webhook tells merchants the outcome of their asynchronous payments
+// by POSTing the payment to a callback URL configured for each merchant.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+)
+
+// Callbacks are signed like the requests the gateway sends to acquirers:
+// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256, under the
+// merchant's secret, of "<timestamp>.<body>", and SignatureTimestampHeader
+// holds the Unix timestamp so that receivers can refuse replays.
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// Defaults of the delivery options.
+const (
+	DefaultTimeout   = 5 * time.Second
+	DefaultAttempts  = 3
+	DefaultBackoff   = time.Second
+	DefaultWorkers   = 4
+	DefaultQueueSize = 1000
+)
+
+// Endpoint is where a merchant receives callbacks.
+type Endpoint struct {
+	URL string
+	// Secret signs callbacks; they are sent unsigned when it is empty.
+	Secret []byte
+}
+
+// Notifier delivers payment callbacks. It implements payments.Notifier.
+// Callbacks are queued and delivered, with their retries, by a pool of
+// workers, so that a slow or failing endpoint does not hold up the caller.
+type Notifier struct {
+	endpoints map[string]Endpoint
+	encode    func(*payments.Payment) any
+	client    *http.Client
+	attempts  int
+	backoff   time.Duration
+	workers   int
+	queueSize int
+	now       func() time.Time
+
+	// mu guards closed, so that Notify does not send on a closed queue.
+	mu         sync.RWMutex
+	closed     bool
+	deliveries chan delivery
+	running    sync.WaitGroup
+	// stopping is done once Close gives up waiting; deliveries in flight
+	// are then abandoned.
+	stopping context.Context
+	stop     context.CancelFunc
+}
+
+// delivery is a callback waiting for a worker.
+type delivery struct {
+	ctx      context.Context
+	merchant string
+	endpoint Endpoint
+	body     []byte
+}
+
+// Option customises a Notifier built by New.
+type Option func(*Notifier)
+
+// WithTimeout bounds each delivery attempt.
+func WithTimeout(timeout time.Duration) Option {
+	return func(n *Notifier) {
+		n.client.Timeout = timeout
+	}
+}
+
+// WithRetries makes up to attempts delivery attempts, waiting backoff before
+// the second and doubling it for each further one.
+func WithRetries(attempts int, backoff time.Duration) Option {
+	return func(n *Notifier) {
+		n.attempts = attempts
+		n.backoff = backoff
+	}
+}
+
+// WithWorkers delivers up to workers callbacks at once, and queues up to
+// queueSize more; callbacks beyond that are dropped.
+func WithWorkers(workers, queueSize int) Option {
+	return func(n *Notifier) {
+		n.workers = workers
+		n.queueSize = queueSize
+	}
+}
+
+// New returns a Notifier posting to the endpoints of each merchant the
+// payment model returned by encode, and starts its workers. Close stops
+// them.
+func New(endpoints map[string]Endpoint, encode func(*payments.Payment) any, opts ...Option) *Notifier {
+	n := &Notifier{
+		endpoints: endpoints,
+		encode:    encode,
+		client:    &http.Client{Timeout: DefaultTimeout},
+		attempts:  DefaultAttempts,
+		backoff:   DefaultBackoff,
+		workers:   DefaultWorkers,
+		queueSize: DefaultQueueSize,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.deliveries = make(chan delivery, n.queueSize)
+	n.stopping, n.stop = context.WithCancel(context.Background())
+	n.running.Add(n.workers)
+	for i := 0; i < n.workers; i++ {
+		go n.work()
+	}
+	return n
+}
+
+// Notify queues payment for delivery to the merchant's endpoint, if any,
+// and returns without waiting for it. Callbacks are dropped, and counted,
+// when the queue is full or the Notifier closed, since the merchant can
+// still poll the payment.
+func (n *Notifier) Notify(ctx context.Context, merchant string, payment *payments.Payment) {
+	endpoint, ok := n.endpoints[merchant]
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	body, err := json.Marshal(n.encode(payment))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encode payment callback", "error", err)
+		return
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	d := delivery{ctx: context.WithoutCancel(ctx), merchant: merchant, endpoint: endpoint, body: body}
+	if !n.closed {
+		select {
+		case n.deliveries <- d:
+			return
+		default:
+		}
+	}
+	metrics.WebhookDeliveriesTotal.WithLabelValues("dropped").Inc()
+	logger.WarnContext(ctx, "payment callback dropped, delivery queue full or closed", "merchant_id", merchant)
+}
+
+// Close stops taking callbacks and waits, until ctx is done, for those
+// queued to be delivered. Deliveries still running then are abandoned.
+func (n *Notifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.deliveries)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		n.stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work delivers queued callbacks until the queue is closed and empty.
+func (n *Notifier) work() {
+	defer n.running.Done()
+	for d := range n.deliveries {
+		ctx, cancel := context.WithCancel(d.ctx)
+		stop := context.AfterFunc(n.stopping, cancel)
+		n.send(ctx, d)
+		stop()
+		cancel()
+	}
+}
+
+// send delivers d. Attempts answered with anything but a 2xx status are
+// retried; failures are logged and counted, since the merchant can still
+// poll the payment.
+func (n *Notifier) send(ctx context.Context, d delivery) {
+	logger := logging.FromContext(ctx)
+	merchant, endpoint, body := d.merchant, d.endpoint, d.body
+
+	var err error
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.deliver(ctx, endpoint, body)
+		if err == nil {
+			metrics.WebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+			logger.DebugContext(ctx, "payment callback delivered", "merchant_id", merchant, "attempt", attempt)
+			return
+		}
+		if attempt >= n.attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+	metrics.WebhookDeliveriesTotal.WithLabelValues("failed").Inc()
+	logger.WarnContext(ctx, "payment callback failed", "merchant_id", merchant, "error", err)
+}
+
+// deliver makes one delivery attempt.
+func (n *Notifier) deliver(ctx context.Context, endpoint Endpoint, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(endpoint.Secret) > 0 {
+		ts := strconv.FormatInt(n.now().Unix(), 10)
+		mac := hmac.New(sha256.New, endpoint.Secret)
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		req.Header.Set(SignatureTimestampHeader, ts)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback answered %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/metrics"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/payments"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(p *payments.Payment) any {
+	return map[string]string{"id": p.Id, "status": p.PaymentStatus}
+}
+
+func TestNotifier_SignsCallbacks(t *testing.T) {
+	secret := []byte("callback-secret")
+	received := make(chan *http.Request, 1)
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer srv.Close()
+
+	n := webhook.New(map[string]webhook.Endpoint{"acme": {URL: srv.URL, Secret: secret}}, encode)
+	delivered := testutil.ToFloat64(metrics.WebhookDeliveriesTotal.WithLabelValues("delivered"))
+	n.Notify(context.Background(), "acme", &payments.Payment{Id: "pay-1", PaymentStatus: "Authorized"})
+
+	r := <-received
+	require.NoError(t, n.Close(context.Background()))
+	assert.JSONEq(t, `{"id":"pay-1","status":"Authorized"}`, string(body))
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+	ts := r.Header.Get(webhook.SignatureTimestampHeader)
+	require.NotEmpty(t, ts)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get(webhook.SignatureHeader))
+	assert.Equal(t, delivered+1, testutil.ToFloat64(metrics.WebhookDeliveriesTotal.WithLabelValues("delivered")))
+}
+
+func TestNotifier_Retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	endpoints := map[string]webhook.Endpoint{"acme": {URL: srv.URL}}
+	payment := &payments.Payment{Id: "pay-1", PaymentStatus: "Declined"}
+
+	t.Run("Delivered on the last attempt", func(t *testing.T) {
+		n := webhook.New(endpoints, encode, webhook.WithRetries(3, time.Millisecond))
+		n.Notify(context.Background(), "acme", payment)
+		require.NoError(t, n.Close(context.Background()))
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Gives up after the attempts", func(t *testing.T) {
+		calls.Store(-10)
+		failed := testutil.ToFloat64(metrics.WebhookDeliveriesTotal.WithLabelValues("failed"))
+		n := webhook.New(endpoints, encode, webhook.WithRetries(2, time.Millisecond))
+		n.Notify(context.Background(), "acme", payment)
+		require.NoError(t, n.Close(context.Background()))
+		assert.Equal(t, int32(-8), calls.Load())
+		assert.Equal(t, failed+1, testutil.ToFloat64(metrics.WebhookDeliveriesTotal.WithLabelValues("failed")))
+	})
+
+	t.Run("Merchants without an endpoint are skipped", func(t *testing.T) {
+		calls.Store(0)
+		n := webhook.New(endpoints, encode)
+		n.Notify(context.Background(), "other", payment)
+		require.NoError(t, n.Close(context.Background()))
+		assert.Zero(t, calls.Load())
+	})
+}
+
+func TestNotifier_DeliversInTheBackground(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+	payment := &payments.Payment{Id: "pay-1", PaymentStatus: "Authorized"}
+
+	t.Run("Notify does not wait for the endpoint", func(t *testing.T) {
+		n := webhook.New(map[string]webhook.Endpoint{"acme": {URL: srv.URL}}, encode)
+		returned := make(chan struct{})
+		go func() {
+			n.Notify(context.Background(), "acme", payment)
+			close(returned)
+		}()
+		select {
+		case <-returned:
+		case <-time.After(time.Second):
+			t.Fatal("Notify waited for the delivery")
+		}
+
+		close(release)
+		require.NoError(t, n.Close(context.Background()))
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Close abandons deliveries once its context is done", func(t *testing.T) {
+		var started atomic.Bool
+		unblock := make(chan struct{})
+		stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started.Store(true)
+			<-unblock
+		}))
+		defer stuck.Close()
+		defer close(unblock)
+		n := webhook.New(map[string]webhook.Endpoint{"acme": {URL: stuck.URL}}, encode,
+			webhook.WithTimeout(time.Minute), webhook.WithRetries(1, 0))
+		n.Notify(context.Background(), "acme", payment)
+		require.Eventually(t, started.Load, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, n.Close(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("Callbacks after Close are dropped", func(t *testing.T) {
+		n := webhook.New(map[string]webhook.Endpoint{"acme": {URL: srv.URL}}, encode)
+		require.NoError(t, n.Close(context.Background()))
+		dropped := testutil.ToFloat64(metrics.WebhookDeliveriesTotal.WithLabelValues("dropped"))
+		n.Notify(context.Background(), "acme", payment)
+		assert.Equal(t, dropped+1, testutil.ToFloat64(metrics.WebhookDeliveriesTotal.WithLabelValues("dropped")))
+	})
+}
@@ -0,0 +1,31 @@
+// Package wire carries the codec of the API version serving a request to the
+// handlers, which translate their requests and responses through it so that
+// they do not depend on any API version.
+package wire
+
+import (
+	"context"
+	"fmt"
+)
+
+// key is the context key of the codec of type C, so that every handler
+// package gets its own.
+type key[C any] struct{}
+
+// With returns a copy of ctx that makes the handlers use c as their codec of
+// type C for the request.
+func With[C any](ctx context.Context, c C) context.Context {
+	return context.WithValue(ctx, key[C]{}, c)
+}
+
+// From returns the codec of type C stored in ctx by With. Every route is
+// served through an API version, which stores its codecs, so a request
+// without one is a routing bug and From panics.
+func From[C any](ctx context.Context) C {
+	c, ok := ctx.Value(key[C]{}).(C)
+	if !ok {
+		var zero C
+		panic(fmt.Sprintf("wire: no %T codec for the request; serve the route through an API version", &zero))
+	}
+	return c
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/keyring"
+)
+
+const keysUsage = `usage: payment-gateway keys <command> [flags]
+
+commands:
+  generate   print a new base64-encoded 32-byte key
+  status     show which keys protect vault records and the rotation progress
+  rotate     start or resume re-encrypting vault records under the active key
+  stop       stop the re-encryption job; the next rotate resumes it
+
+status, rotate and stop call the admin API of a running gateway and read the
+bearer token from ADMIN_TOKEN.
+`
+
+// keysCommand implements the "keys" subcommand and returns the exit code.
+func keysCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, keysUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", defaultAdminAddr, "base URL of the gateway")
+	wait := fs.Bool("wait", false, "with rotate, wait for the job to finish and print its progress")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	client := newAdminClient(*addr)
+
+	var err error
+	switch args[0] {
+	case "generate":
+		err = generateKey(stdout)
+	case "status":
+		err = printKeysStatus(stdout, client, http.MethodGet)
+	case "rotate":
+		err = printKeysStatus(stdout, client, http.MethodPost)
+		if err == nil && *wait {
+			err = waitForRotation(stdout, client)
+		}
+	case "stop":
+		err = printKeysStatus(stdout, client, http.MethodDelete)
+	default:
+		fmt.Fprint(stderr, keysUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func generateKey(w io.Writer) error {
+	key := make([]byte, keyring.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, base64.StdEncoding.EncodeToString(key))
+	return err
+}
+
+// keysStatus calls the keys admin endpoint matching method.
+func keysStatus(c *adminClient, method string) (*api.KeysStatus, error) {
+	path := "/admin/keys"
+	if method != http.MethodGet {
+		path = "/admin/keys/rotation"
+	}
+	var status api.KeysStatus
+	if err := c.do(method, path, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func printKeysStatus(w io.Writer, c *adminClient, method string) error {
+	status, err := keysStatus(c, method)
+	if err != nil {
+		return err
+	}
+	return printJSON(w, status)
+}
+
+func waitForRotation(w io.Writer, c *adminClient) error {
+	for {
+		time.Sleep(time.Second)
+		status, err := keysStatus(c, http.MethodGet)
+		if err != nil {
+			return err
+		}
+		p := status.Rotation
+		fmt.Fprintf(w, "%s: rotated=%d failed=%d cursor=%s\n", p.State, p.Rotated, p.Failed, p.Cursor)
+		switch p.State {
+		case keyring.JobRunning:
+			continue
+		case keyring.JobCompleted:
+			return nil
+		default:
+			return errors.New("re-encryption did not complete: " + p.LastError)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/LuizZucchi/payment-gateway-challenge-go/docs"
 	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/api"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/config"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/logging"
+	"github.com/LuizZucchi/payment-gateway-challenge-go/internal/tracing"
 )
 
 var (
@@ -19,22 +25,104 @@ var (
 
 //	@title			Payment Gateway Challenge Go
 //	@description	Interview challenge for building a Payment Gateway - Go version
+//	@description
+//	@description	Payment and token operations are versioned under `/v1`. The `/api` paths are an
+//	@description	alias answering in the version named by the `API-Version` header or an
+//	@description	`application/vnd.payment-gateway.v1+json` Accept type, `v1` by default.
+//	@description	Deprecated versions carry `Deprecation` and `Sunset` headers.
+//	@description
+//	@description	Errors are RFC 7807 problem details (application/problem+json). Branch on
+//	@description	the stable `code` member, never on `title` or `detail`:
+//	@description
+//	@description	| Code | Status | Meaning |
+//	@description	|------|--------|---------|
+//	@description	| malformed_request | 400 | The body is not valid JSON or has the wrong shape. |
+//	@description	| validation_failed | 400 | One or more fields are invalid; see `errors`. |
+//	@description	| card_token_not_found | 400 | `card_token` does not match a stored card. |
+//	@description	| card_tokens_disabled | 400 | `card_token` was sent but the vault is not enabled. |
+//	@description	| card_declined | 402 | Reserved for decline reasons. |
+//	@description	| duplicate_payment | 409 | An identical payment was made recently and the merchant rejects duplicates. |
+//	@description	| payment_not_found | 404 | No payment has the requested ID. |
+//	@description	| batch_not_found | 404 | No payment batch has the requested ID. |
+//	@description	| batch_too_large | 413 | The batch holds more payments than `batches.max_items` allows; split it. |
+//	@description	| subscription_not_found | 404 | No subscription has the requested ID. |
+//	@description	| customer_not_found | 404 | No customer has the requested ID. |
+//	@description	| payment_method_not_found | 404 | The customer has no payment method with the requested ID. |
+//	@description	| unsupported_media_type | 415 | The request body is in a format the endpoint does not accept. |
+//	@description	| not_found | 404 | No route matches the path. |
+//	@description	| method_not_allowed | 405 | The route does not support the method. |
+//	@description	| unsupported_version | 406 | The requested API version is not served. |
+//	@description	| version_retired | 410 | The requested API version is past its sunset date. |
+//	@description	| unauthorized | 401 | Missing or invalid admin bearer token. |
+//	@description	| conflict | 409 | The request conflicts with the current state. |
+//	@description	| rate_limited | 429 | Too many requests; see Retry-After. |
+//	@description	| upstream_unavailable | 502 | The acquiring bank could not be reached. |
+//	@description	| upstream_overloaded | 503 | Too many calls to the acquiring bank are in flight; see Retry-After. |
+//	@description	| queue_full | 503 | The asynchronous payment queue is full; see Retry-After. |
+//	@description	| authentication_unavailable | 502 | The 3DS server could not be reached; the cardholder could not be authenticated. |
+//	@description	| authentication_expired | 409 | The 3-D Secure challenge was not completed in time and the payment failed. |
+//	@description	| internal_error | 500 | The gateway failed to process the request. |
 
 //	@host		localhost:8090
 //	@BasePath	/
 
-// @securityDefinitions.basic	BasicAuth
+// @securityDefinitions.apikey	AdminToken
+// @in							header
+// @name						Authorization
+// @description				Admin API token as "Bearer <admin.token>".
 func main() {
-	fmt.Printf("version %s, commit %s, built at %s\n", version, commit, date)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "keys":
+			os.Exit(keysCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "audit":
+			os.Exit(auditCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "acs-simulator":
+			os.Exit(acsSimulatorCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The level lives in a LevelVar so that config reloads can change it.
+	level := new(slog.LevelVar)
+	setLogLevel(level, cfg)
+	slog.SetDefault(logging.New(os.Stdout, level))
+	slog.Info("starting payment gateway", "version", version, "commit", commit, "built_at", date, "config_file", opts.ConfigFile)
 	docs.SwaggerInfo.Version = version
 
-	err := run()
+	reloader := config.NewReloader(cfg, func() (*config.Config, error) {
+		cfg, _, err := config.Load(os.Args[1:], os.Getenv)
+		return cfg, err
+	})
+	reloader.Subscribe(func(cfg *config.Config) { setLogLevel(level, cfg) })
+
+	err = run(reloader, opts.ConfigFile)
 	if err != nil {
-		fmt.Printf("fatal API error: %v\n", err)
+		slog.Error("fatal API error", "error", err)
 	}
 }
 
-func run() error {
+func setLogLevel(level *slog.LevelVar, cfg *config.Config) {
+	_ = level.UnmarshalText([]byte(cfg.Log.Level)) // checked by config.Validate
+}
+
+func run(reloader *config.Reloader, configFile string) error {
+	cfg := reloader.Current()
 	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
@@ -42,20 +130,39 @@ func run() error {
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		<-c
-		fmt.Printf("sigterm/interrupt signal\n")
+		slog.Info("sigterm/interrupt signal")
 		cancel()
 	}()
 
 	defer func() {
 		// recover after panic
 		if x := recover(); x != nil {
-			fmt.Printf("run time panic:\n%v\n", x)
+			slog.Error("run time panic", "panic", x)
 			panic(x)
 		}
 	}()
 
-	api := api.New()
-	if err := api.Run(ctx, ":8090"); err != nil {
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, version)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+
+	// SIGHUP and changes to the config file reload runtime settings.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go reloader.Watch(ctx, configFile, cfg.Reload.WatchInterval.Std(), hup)
+
+	api, err := api.New(reloader)
+	if err != nil {
+		return err
+	}
+	if err := api.Run(ctx, cfg.Server.Addr); err != nil {
 		return err
 	}
 
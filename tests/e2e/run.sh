@@ -114,13 +114,13 @@ echo -e "\n[3/5] Running Scenarios..."
 
 # Scenario 1: Success (Card ending in odd number)
 PAYMENT_ID=$(run_test "Authorized Payment" \
-    "curl -s -X POST $API_URL/api/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123451\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"USD\", \"amount\": 1000, \"cvv\": \"123\"}'" \
+    "curl -s -X POST $API_URL/v1/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123451\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"USD\", \"amount\": 1000, \"cvv\": \"123\"}'" \
     200 ".payment_status" "Authorized")
 
 # Scenario 2: GET
 if [ ! -z "$PAYMENT_ID" ] && [ "$PAYMENT_ID" != "null" ]; then
     run_test "Retrieve Payment (GET)" \
-        "curl -s -X GET $API_URL/api/payments/$PAYMENT_ID" \
+        "curl -s -X GET $API_URL/v1/payments/$PAYMENT_ID" \
         200 ".payment_status" "Authorized" > /dev/null
 else
     echo -e "${RED}Skipping GET test (No ID captured)${NC}" >&2
@@ -128,18 +128,23 @@ fi
 
 # Scenario 3: Decline (Card ending in even number)
 run_test "Declined Payment" \
-    "curl -s -X POST $API_URL/api/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123452\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"EUR\", \"amount\": 500, \"cvv\": \"123\"}'" \
+    "curl -s -X POST $API_URL/v1/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123452\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"EUR\", \"amount\": 500, \"cvv\": \"123\"}'" \
     200 ".payment_status" "Declined" > /dev/null
 
+# Scenario 3b: Hard decline (Card ending in 8)
+run_test "Hard Decline Reason" \
+    "curl -s -X POST $API_URL/v1/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123458\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"USD\", \"amount\": 500, \"cvv\": \"123\"}'" \
+    200 ".decline_code" "suspected_fraud" > /dev/null
+
 # Scenario 4: Validation (Invalid Currency)
 # NOTE: Changed from BRL to JPY
 run_test "Validation Error" \
-    "curl -s -X POST $API_URL/api/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123451\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"JPY\", \"amount\": 1000, \"cvv\": \"123\"}'" \
+    "curl -s -X POST $API_URL/v1/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123451\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"JPY\", \"amount\": 1000, \"cvv\": \"123\"}'" \
     400 ".payment_status" "Rejected" > /dev/null
 
 # Scenario 5: Bank Error (Card ending in 0)
 run_test "Bank Unavailable" \
-    "curl -s -X POST $API_URL/api/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123450\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"USD\", \"amount\": 1000, \"cvv\": \"123\"}'" \
+    "curl -s -X POST $API_URL/v1/payments -H 'Content-Type: application/json' -d '{\"card_number\": \"1234567890123450\", \"expiry_month\": 12, \"expiry_year\": 2030, \"currency\": \"USD\", \"amount\": 1000, \"cvv\": \"123\"}'" \
     502 ".payment_status" "Failed" > /dev/null
 
 # ==============================================================================
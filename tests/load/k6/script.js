@@ -14,7 +14,7 @@ export const options = {
 };
 
 export default function () {
-  const url = 'http://localhost:8090/api/payments';
+  const url = 'http://localhost:8090/v1/payments';
   
   const payload = JSON.stringify({
     card_number: "1234567890123451",